@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+	"github.com/hwipl/smc-go/pkg/smcr"
+)
+
+const (
+	// timeFormat is the format of timestamps in the output
+	timeFormat = "15:04:05.000000"
+
+	// minimum RoCEv1 and RoCEv2 packet lengths: headers and icrc
+	roceV1MinLen = roce.GRHLen + roce.BTHLen + 4
+	roceV2MinLen = roce.BTHLen + 4
+)
+
+// dumper decodes packets and writes the SMC messages in them to out
+type dumper struct {
+	out       io.Writer
+	reserved  bool
+	hex       bool
+	grh       bool
+	bth       bool
+	data      bool
+	assembler *clc.StreamAssembler
+	sends     *roce.SendAssembler
+	streams   *smcr.Reconstructor
+	index     *smcr.Index
+	cursors   *smcr.CursorTracker
+	failovers *smcr.FailoverAnalyzer
+	events    bool
+	audit     bool
+	rtt       bool
+	cdc       bool
+	failover  bool
+	lgrs      map[*smcr.LinkGroup]*linkGroup
+
+	// timestamp of the current packet
+	ts time.Time
+}
+
+// newDumper returns a new dumper that writes to out
+func newDumper(out io.Writer) *dumper {
+	d := &dumper{out: out}
+	d.assembler = clc.NewStreamAssembler(d.handleCLC)
+	d.sends = roce.NewSendAssembler()
+	d.index = smcr.NewIndex()
+	d.streams = smcr.NewReconstructor(d.index, d.handleData)
+	d.cursors = smcr.NewCursorTracker(d.index, d.handleCursorEvent)
+	d.failovers = smcr.NewFailoverAnalyzer(d.index, d.handleIncident)
+	d.lgrs = make(map[*smcr.LinkGroup]*linkGroup)
+	return d
+}
+
+// formatTime converts the timestamp ts to a string in local time
+func formatTime(ts time.Time) string {
+	return ts.Local().Format(timeFormat)
+}
+
+// printf writes the formatted string to the output and makes sure it ends
+// with a newline
+func (d *dumper) printf(format string, a ...interface{}) {
+	s := fmt.Sprintf(format, a...)
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	io.WriteString(d.out, s)
+}
+
+// handleCLC prints the CLC message m extracted from a TCP stream
+func (d *dumper) handleCLC(m *clc.StreamMessage) {
+	src, dst := m.Net.Endpoints()
+	sport, dport := m.Transport.Endpoints()
+	prefix := fmt.Sprintf("%s %s:%s -> %s:%s: ",
+		formatTime(m.Timestamp), src, sport, dst, dport)
+	if m.Err != nil {
+		d.printf("%sError parsing CLC message: %v", prefix, m.Err)
+		return
+	}
+	d.index.AddCLC(m.Net, m.Transport, m.Message)
+	d.streams.AddCLC(m.Net, m.Transport, m.Message)
+	d.cursors.AddCLC(m.Net, m.Transport, m.Message)
+	if d.reserved {
+		d.printf("%s%s", prefix, m.Message.Reserved())
+	} else {
+		d.printf("%s%s", prefix, m.Message.String())
+	}
+	if d.hex {
+		d.printf("%s", m.Message.Dump())
+	}
+}
+
+// handleData prints the application data of a SMC-R connection
+// reconstructed from RDMA writes and CDC messages
+func (d *dumper) handleData(s *smcr.StreamData) {
+	if !d.data {
+		return
+	}
+	src, dst := s.Net.Endpoints()
+	sport, dport := s.Transport.Endpoints()
+	d.printf("%s %s:%s -> %s:%s: SMC-R Data: %d bytes, Skipped: %d, "+
+		"Missing: %d", formatTime(s.Timestamp), src, sport, dst, dport,
+		len(s.Data), s.Skipped, s.Missing)
+	d.printf("%s", hex.Dump(s.Data))
+}
+
+// handleCursorEvent prints the flow-control anomalies and connection events
+// found in CDC messages
+func (d *dumper) handleCursorEvent(e *smcr.CursorEvent) {
+	if !d.cdc {
+		return
+	}
+	f := e.Flow
+	src, dst := f.Net.Endpoints()
+	sport, dport := f.Transport.Endpoints()
+	d.printf("%s %s:%s -> %s:%s: CDC %s", formatTime(e.Timestamp), src,
+		sport, dst, dport, e)
+}
+
+// handleIncident prints the report of a link failover incident
+func (d *dumper) handleIncident(i *smcr.Incident) {
+	if !d.failover {
+		return
+	}
+	d.printf("%s", i)
+}
+
+// linkGroup stores the LLC state of a link group
+type linkGroup struct {
+	tracker    *llc.LinkGroup
+	auditor    *llc.RKeyAuditor
+	correlator *llc.Correlator
+}
+
+// trackLinkGroup adds the LLC message in the RoCE packet r to the link group
+// of its destination QP and prints the link group events
+func (d *dumper) trackLinkGroup(ts time.Time, flow gopacket.Flow,
+	r *roce.RoCE) {
+	if r.LLC == nil || r.LLC.GetType() == llc.TypeCDC {
+		return
+	}
+	qp := smcr.NewQP(net.IP(flow.Dst().Raw()), r.BTH.DestQP)
+	lgr := d.index.LinkGroup(qp)
+	if lgr == nil {
+		return
+	}
+	l := d.lgrs[lgr]
+	if l == nil {
+		l = &linkGroup{
+			tracker: llc.NewLinkGroup(func(event *llc.Event) {
+				if d.events {
+					d.printf("%s Link Group %d: %s",
+						formatTime(d.ts), lgr.ID, event)
+				}
+			}),
+			auditor: llc.NewRKeyAuditor(),
+			correlator: llc.NewCorrelator(func(e *llc.Exchange) {
+				if d.rtt {
+					d.printf("%s Link Group %d: LLC %s",
+						formatTime(d.ts), lgr.ID, e)
+				}
+			}),
+		}
+		d.lgrs[lgr] = l
+	}
+	var link uint8
+	if l := lgr.Link(qp); l != nil {
+		link = l.ID
+	}
+	d.ts = ts
+	side := lgr.Sender(qp)
+	l.tracker.Add(link, side, r.LLC)
+	l.auditor.Add(side, r.LLC)
+	l.correlator.Add(ts, link, side, r.LLC)
+}
+
+// printAudit prints the findings of the RKey audits of all link groups
+func (d *dumper) printAudit() {
+	for _, lgr := range d.index.LinkGroups() {
+		l := d.lgrs[lgr]
+		if l == nil {
+			continue
+		}
+		for _, f := range l.auditor.Finish() {
+			d.printf("Link Group %d: RKey Audit: %s", lgr.ID, f)
+		}
+	}
+}
+
+// handleRoCE checks the icrc of the RoCE packet r and prints it
+func (d *dumper) handleRoCE(ts time.Time, flow gopacket.Flow, r *roce.RoCE) {
+	src, dst := flow.Endpoints()
+	if err := r.Verify(); err != nil {
+		// do not print messages in corrupt or truncated frames
+		d.printf("%s %s -> %s: %s: Bad frame: %v", formatTime(ts), src,
+			dst, r.Type, err)
+		if d.hex {
+			d.printHex(r)
+		}
+		return
+	}
+	d.sends.Add(r)
+	d.index.AddRoCE(flow, r)
+	d.streams.AddRoCE(ts, flow, r)
+	d.cursors.AddRoCE(ts, flow, r)
+	d.failovers.AddRoCE(ts, flow, r)
+	d.printf("%s %s -> %s: %s", formatTime(ts), src, dst, r.Type)
+	d.trackLinkGroup(ts, flow, r)
+	if c := d.index.ConnByCDC(flow, r); c != nil {
+		// show TCP connection of CDC message
+		src, dst := c.Net.Endpoints()
+		sport, dport := c.Transport.Endpoints()
+		d.printf("Connection: %s:%s -> %s:%s, Link Group: %d", src,
+			sport, dst, dport, c.LinkGroup.ID)
+	}
+	if d.grh && r.GRH != nil {
+		if d.reserved {
+			d.printf("%s", r.GRH.Reserved())
+		} else {
+			d.printf("%s", r.GRH.String())
+		}
+	}
+	if d.bth {
+		if d.reserved {
+			d.printf("%s", r.BTH.Reserved())
+		} else {
+			d.printf("%s", r.BTH.String())
+		}
+	}
+	if d.bth && r.ETH != nil {
+		if d.reserved {
+			d.printf("%s", r.ETH.Reserved())
+		} else {
+			d.printf("%s", r.ETH.String())
+		}
+	}
+
+	// RDMA WRITE and other operations without LLC message
+	if r.LLC == nil {
+		d.printf("Data: %d bytes", len(r.Data))
+	} else if d.reserved {
+		d.printf("%s", r.LLC.Reserved())
+	} else {
+		d.printf("%s", r.LLC.String())
+	}
+	if d.hex {
+		d.printHex(r)
+	}
+}
+
+// printHex prints the LLC message or the data in the RoCE packet r as hex dump
+func (d *dumper) printHex(r *roce.RoCE) {
+	if r.LLC != nil {
+		d.printf("%s", r.LLC.Hex())
+		return
+	}
+	if len(r.Data) > 0 {
+		d.printf("%s", hex.Dump(r.Data))
+	}
+}
+
+// handlePacket decodes the packet and prints the SMC messages in it. TCP
+// packets are passed to the CLC stream assembler, RoCEv1 and RoCEv2 packets
+// are decoded directly
+func (d *dumper) handlePacket(packet gopacket.Packet) {
+	ts := packet.Metadata().Timestamp
+
+	// RoCEv1
+	if eth, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); ok &&
+		eth.EthernetType == roce.RoCEv1EtherType {
+		if len(eth.Payload) < roceV1MinLen {
+			return
+		}
+		// the index needs the GIDs in the grh, not the mac addresses
+		r := roce.ParseRoCEv1(eth.Payload)
+		d.handleRoCE(ts, r.GRH.NetworkFlow(), r)
+		return
+	}
+
+	// RoCEv2
+	if udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		if udp.DstPort != roce.RoCEv2UDPPort ||
+			len(udp.Payload) < roceV2MinLen {
+			return
+		}
+		network := packet.NetworkLayer()
+		data := append(append([]byte{}, network.LayerContents()...),
+			network.LayerPayload()...)
+		r := roce.ParseRoCEv2IP(data)
+		if r == nil {
+			// not supported, e.g., ipv6 extension headers
+			return
+		}
+		d.handleRoCE(ts, network.NetworkFlow(), r)
+		return
+	}
+
+	// CLC in TCP
+	d.assembler.Assemble(packet)
+}
+
+// flush flushes all TCP streams, e.g., at the end of a capture
+func (d *dumper) flush() {
+	d.assembler.FlushAll()
+	d.failovers.FlushAll()
+	if d.audit {
+		d.printAudit()
+	}
+	if d.rtt {
+		d.printStats()
+	}
+}
+
+// flushOlderThan flushes TCP streams and LLC requests older than t, e.g.,
+// periodically in a live capture
+func (d *dumper) flushOlderThan(t time.Time) {
+	d.assembler.FlushOlderThan(t)
+	for _, l := range d.lgrs {
+		l.correlator.FlushOlderThan(t)
+	}
+}
+
+// printStats prints the LLC exchange statistics of all link groups
+func (d *dumper) printStats() {
+	for _, lgr := range d.index.LinkGroups() {
+		l := d.lgrs[lgr]
+		if l == nil {
+			continue
+		}
+		l.correlator.FlushAll()
+		for _, s := range l.correlator.Stats() {
+			d.printf("Link Group %d: LLC %s", lgr.ID, s)
+		}
+	}
+}
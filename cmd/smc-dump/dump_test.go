@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+// testPacket serializes the layers to packet data
+func testPacket(l ...gopacket.SerializableLayer) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	if err := gopacket.SerializeLayers(buf, opts, l...); err != nil {
+		log.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testPackets returns the packets of a TCP connection with a CLC decline
+// message and two RoCEv2 packets with a LLC test link message, the second
+// one with an invalid icrc
+func testPackets() [][]byte {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := func(proto layers.IPProtocol, client bool) *layers.IPv4 {
+		ip := &layers.IPv4{
+			Version:  4,
+			TTL:      64,
+			Protocol: proto,
+			SrcIP:    net.IPv4(10, 0, 0, 1),
+			DstIP:    net.IPv4(10, 0, 0, 2),
+		}
+		if !client {
+			ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+		}
+		return ip
+	}
+	tcp := func(t *layers.TCP, client bool, payload []byte) []byte {
+		t.SrcPort, t.DstPort = 40000, 12345
+		if !client {
+			t.SrcPort, t.DstPort = t.DstPort, t.SrcPort
+		}
+		t.Window = 65535
+		i := ip(layers.IPProtocolTCP, client)
+		t.SetNetworkLayerForChecksum(i)
+		return testPacket(eth, i, t, gopacket.Payload(payload))
+	}
+
+	decline, err := hex.DecodeString("e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9")
+	if err != nil {
+		log.Fatal(err)
+	}
+	option := []layers.TCPOption{{
+		OptionType:   254,
+		OptionLength: 6,
+		OptionData:   clc.SMCOption,
+	}}
+
+	// rc send only bth, llc test link, icrc
+	testLink, err := hex.DecodeString("072c0080" + strings.Repeat("00", 40))
+	if err != nil {
+		log.Fatal(err)
+	}
+	udp := &layers.UDP{SrcPort: 50000, DstPort: 4791}
+	udpIP := ip(layers.IPProtocolUDP, true)
+	udp.SetNetworkLayerForChecksum(udpIP)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	err = roce.SerializeLayers(buf, opts, eth, udpIP, udp,
+		&roce.BTH{Opcode: 0b100}, &llc.LLC{Message: llc.ParseLLC(testLink)},
+		&roce.ICRC{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	roceData := buf.Bytes()
+
+	// same packet with invalid icrc
+	badData := append([]byte{}, roceData...)
+	badData[len(badData)-1]++
+
+	return [][]byte{
+		tcp(&layers.TCP{Seq: 100, SYN: true, Options: option}, true,
+			nil),
+		tcp(&layers.TCP{Seq: 500, Ack: 101, SYN: true, ACK: true,
+			Options: option}, false, nil),
+		tcp(&layers.TCP{Seq: 101, Ack: 501, ACK: true}, true, nil),
+		tcp(&layers.TCP{Seq: 101, Ack: 501, ACK: true, PSH: true}, true,
+			decline),
+		roceData,
+		badData,
+	}
+}
+
+// testReadPcap writes the test packets with write to a file and checks the
+// output of readPcap
+func testReadPcap(t *testing.T, write func(f *os.File, packets [][]byte)) {
+	file := filepath.Join(t.TempDir(), "test.pcap")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(f, testPackets())
+	f.Close()
+
+	var out bytes.Buffer
+	d := newDumper(&out)
+	if err := readPcap(d, file); err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(0, 0).Format(timeFormat)
+	want := ts + " 10.0.0.1:40000 -> 10.0.0.2:12345: " +
+		"Decline: Eyecatcher: SMC-R, Type: 4 (Decline), Length: 28, " +
+		"Version: 1, Out of Sync: 0, Path: SMC-R, " +
+		"Peer ID: 9509@25:25:25:25:25:00, " +
+		"Peer Diagnosis: 0x3030000 (no SMC device found (R or D)), " +
+		"Trailer: SMC-R\n" +
+		ts + " 10.0.0.1 -> 10.0.0.2: RoCEv2\n" +
+		"LLC Test Link: Type 7, Length: 44, Reply: true, " +
+		"User Data: 0x00000000000000000000000000000000\n" +
+		ts + " 10.0.0.1 -> 10.0.0.2: RoCEv2: Bad frame: invalid icrc\n"
+	if got := out.String(); got != want {
+		t.Errorf("out = %s; want %s", got, want)
+	}
+}
+
+func TestReadPcap(t *testing.T) {
+	testReadPcap(t, func(f *os.File, packets [][]byte) {
+		w := pcapgo.NewWriter(f)
+		if err := w.WriteFileHeader(65535,
+			layers.LinkTypeEthernet); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range packets {
+			ci := gopacket.CaptureInfo{
+				Timestamp:     time.Unix(0, 0),
+				CaptureLength: len(p),
+				Length:        len(p),
+			}
+			if err := w.WritePacket(ci, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestReadPcapng(t *testing.T) {
+	testReadPcap(t, func(f *os.File, packets [][]byte) {
+		w, err := pcapgo.NewNgWriter(f, layers.LinkTypeEthernet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range packets {
+			ci := gopacket.CaptureInfo{
+				Timestamp:     time.Unix(0, 0),
+				CaptureLength: len(p),
+				Length:        len(p),
+			}
+			if err := w.WritePacket(ci, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestDumpRoCEv1(t *testing.T) {
+	var out bytes.Buffer
+	d := newDumper(&out)
+
+	// clc accept and confirm with the GIDs of the RoCEv1 packets
+	netFlow := gopacket.NewFlow(layers.EndpointIPv4,
+		net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4())
+	transport := gopacket.NewFlow(layers.EndpointTCPPort,
+		[]byte{0x9c, 0x40}, []byte{0x30, 0x39})
+	accept := &clc.AcceptSMCR{
+		Header:         clc.Header{Type: clc.TypeAccept, Flag: 1},
+		IBGID:          net.ParseIP("fe80::2"),
+		QPN:            100,
+		RMBEAlertToken: 1,
+	}
+	confirm := &clc.ConfirmSMCR{AcceptSMCR: clc.AcceptSMCR{
+		Header:         clc.Header{Type: clc.TypeConfirm},
+		IBGID:          net.ParseIP("fe80::1"),
+		QPN:            200,
+		RMBEAlertToken: 2,
+	}}
+	d.handleCLC(&clc.StreamMessage{Net: netFlow.Reverse(),
+		Transport: transport.Reverse(), Message: accept})
+	d.handleCLC(&clc.StreamMessage{Net: netFlow, Transport: transport,
+		Message: confirm})
+	out.Reset()
+
+	// RoCEv1 cdc message from server to client
+	cdc, err := hex.DecodeString("fe2c000100000002" +
+		strings.Repeat("00", 36))
+	if err != nil {
+		log.Fatal(err)
+	}
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		EthernetType: roce.RoCEv1EtherType,
+	}
+	grh := &roce.GRH{
+		Version:    6,
+		NextHeader: roce.BTHNextHeader,
+		HopLimit:   1,
+		SrcIP:      net.ParseIP("fe80::2"),
+		DstIP:      net.ParseIP("fe80::1"),
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	err = roce.SerializeLayers(buf, opts, eth, grh,
+		&roce.BTH{Opcode: 0b100, DestQP: 200},
+		&llc.LLC{Message: llc.ParseLLC(cdc)}, &roce.ICRC{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LinkTypeEthernet,
+		gopacket.Default)
+	packet.Metadata().Timestamp = time.Unix(0, 0)
+	d.handlePacket(packet)
+
+	// cdc message is matched to the tcp connection via the GIDs
+	ts := time.Unix(0, 0).Format(timeFormat)
+	want := ts + " fe80::2 -> fe80::1: RoCEv1\n" +
+		"Connection: 10.0.0.1:40000 -> 10.0.0.2:12345, Link Group: 1\n"
+	if got := out.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("out = %s; want prefix %s", got, want)
+	}
+}
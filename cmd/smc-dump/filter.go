@@ -0,0 +1,132 @@
+package main
+
+import (
+	"github.com/hwipl/smc-go/pkg/roce"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// filterSnapLen is the number of bytes accepted by the filter
+	filterSnapLen = 0x40000
+)
+
+// filterInsn is a classic BPF instruction with labels as jump targets
+type filterInsn struct {
+	label string
+	code  uint16
+	jt    string
+	jf    string
+	k     uint32
+}
+
+// assembleFilter converts insns to a classic BPF program. Empty jump
+// targets continue with the next instruction. Unconditional jumps use jt as
+// jump target
+func assembleFilter(insns []filterInsn) []unix.SockFilter {
+	labels := make(map[string]int)
+	for i, insn := range insns {
+		if insn.label != "" {
+			labels[insn.label] = i
+		}
+	}
+	jump := func(i int, label string) uint8 {
+		if label == "" {
+			return 0
+		}
+		return uint8(labels[label] - i - 1)
+	}
+
+	prog := make([]unix.SockFilter, len(insns))
+	for i, insn := range insns {
+		if insn.code == unix.BPF_JMP|unix.BPF_JA {
+			prog[i] = unix.SockFilter{
+				Code: insn.code,
+				K:    uint32(jump(i, insn.jt)),
+			}
+			continue
+		}
+		prog[i] = unix.SockFilter{
+			Code: insn.code,
+			Jt:   jump(i, insn.jt),
+			Jf:   jump(i, insn.jf),
+			K:    insn.k,
+		}
+	}
+	return prog
+}
+
+// smcFilter returns a classic BPF program for Ethernet frames that accepts
+// RoCEv1 frames, RoCEv2 packets and TCP packets with payload or the SYN, FIN
+// or RST flag. A stateless filter cannot check if the SYN packets of a
+// connection carried the SMC option and CLC messages may be split across
+// multiple segments without eyecatcher, so all TCP segments except pure ACKs
+// are accepted. The stream assembler ignores the connections without SMC
+// option
+func smcFilter() []unix.SockFilter {
+	const (
+		ldh  = unix.BPF_LD | unix.BPF_H | unix.BPF_ABS
+		ldb  = unix.BPF_LD | unix.BPF_B | unix.BPF_ABS
+		ldhx = unix.BPF_LD | unix.BPF_H | unix.BPF_IND
+		ldbx = unix.BPF_LD | unix.BPF_B | unix.BPF_IND
+		ldm  = unix.BPF_LD | unix.BPF_MEM
+		ldxm = unix.BPF_LDX | unix.BPF_B | unix.BPF_MSH
+		st   = unix.BPF_ST
+		ja   = unix.BPF_JMP | unix.BPF_JA
+		jeq  = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jset = unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K
+		jgtx = unix.BPF_JMP | unix.BPF_JGT | unix.BPF_X
+		rsh  = unix.BPF_ALU | unix.BPF_RSH | unix.BPF_K
+		and  = unix.BPF_ALU | unix.BPF_AND | unix.BPF_K
+		add  = unix.BPF_ALU | unix.BPF_ADD | unix.BPF_K
+		addx = unix.BPF_ALU | unix.BPF_ADD | unix.BPF_X
+		tax  = unix.BPF_MISC | unix.BPF_TAX
+		ret  = unix.BPF_RET | unix.BPF_K
+	)
+	return assembleFilter([]filterInsn{
+		// ethernet type
+		{code: ldh, k: 12},
+		{code: jeq, k: roce.RoCEv1EtherType, jt: "accept"},
+		{code: jeq, k: unix.ETH_P_IP, jt: "ipv4"},
+		{code: jeq, k: unix.ETH_P_IPV6, jt: "ipv6", jf: "drop"},
+
+		// ipv4: ignore fragments, X = ip header length
+		{label: "ipv4", code: ldh, k: 20},
+		{code: jset, k: 0x1fff, jt: "drop"},
+		{code: ldxm, k: 14},
+		{code: ldb, k: 23},
+		{code: jeq, k: unix.IPPROTO_TCP, jt: "tcp4"},
+		{code: jeq, k: unix.IPPROTO_UDP, jf: "drop"},
+		{code: ldhx, k: 14 + 2},
+		{code: jeq, k: roce.RoCEv2UDPPort, jt: "accept", jf: "drop"},
+
+		// ipv6 without extension headers: X = ip header length
+		{label: "ipv6", code: unix.BPF_LDX | unix.BPF_IMM, k: 40},
+		{code: ldb, k: 20},
+		{code: jeq, k: unix.IPPROTO_TCP, jt: "tcp6"},
+		{code: jeq, k: unix.IPPROTO_UDP, jf: "drop"},
+		{code: ldhx, k: 14 + 2},
+		{code: jeq, k: roce.RoCEv2UDPPort, jt: "accept", jf: "drop"},
+
+		// M[0] = ip packet length
+		{label: "tcp4", code: ldh, k: 14 + 2},
+		{code: st, k: 0},
+		{code: ja, jt: "tcp"},
+		{label: "tcp6", code: ldh, k: 14 + 4},
+		{code: add, k: 40},
+		{code: st, k: 0},
+
+		// tcp: accept syn, fin and rst, check payload length
+		{label: "tcp", code: ldbx, k: 14 + 13},
+		{code: jset, k: 0x07, jt: "accept"},
+		{code: ldbx, k: 14 + 12},
+		{code: rsh, k: 2},
+		{code: and, k: 0x3c},
+		{code: addx},
+		{code: tax},
+		{code: ldm, k: 0},
+		{code: jgtx, jt: "accept", jf: "drop"},
+
+		{label: "accept", code: ret, k: filterSnapLen},
+		{label: "drop", code: ret, k: 0},
+	})
+}
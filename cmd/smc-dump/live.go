@@ -0,0 +1,192 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// liveSnapLen is the maximum number of bytes captured per packet
+	liveSnapLen = 65536
+
+	// liveFlushInterval is the interval for flushing TCP streams without
+	// new packets during live capture
+	liveFlushInterval = time.Minute
+)
+
+// htons converts the 16 bit value i to network byte order
+func htons(i uint16) uint16 {
+	return i<<8 | i>>8
+}
+
+// liveSource is a live packet source that captures packets on a network
+// interface with an AF_PACKET socket
+type liveSource struct {
+	fd       int
+	ifindex  int
+	loopback bool
+	buf      []byte
+}
+
+// attachFilter attaches the BPF program prog to the socket fd
+func attachFilter(fd int, prog []unix.SockFilter) error {
+	fprog := &unix.SockFprog{
+		Len:    uint16(len(prog)),
+		Filter: &prog[0],
+	}
+	return unix.SetsockoptSockFprog(fd, unix.SOL_SOCKET,
+		unix.SO_ATTACH_FILTER, fprog)
+}
+
+// drain discards all packets queued on the socket fd
+func drain(fd int) error {
+	buf := make([]byte, 1)
+	for {
+		_, _, err := unix.Recvfrom(fd, buf,
+			unix.MSG_DONTWAIT|unix.MSG_TRUNC)
+		switch err {
+		case nil, unix.EINTR:
+			continue
+		case unix.EAGAIN:
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// newLiveSource opens a live packet source on the network interface with
+// name. If filter is true, a BPF filter is attached to the socket that only
+// accepts SMC traffic
+func newLiveSource(name string, filter bool) (*liveSource, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// create socket
+	proto := htons(unix.ETH_P_ALL)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(proto))
+	if err != nil {
+		return nil, err
+	}
+
+	// drop all packets until the socket is bound to the interface
+	drop := []unix.SockFilter{{Code: unix.BPF_RET | unix.BPF_K, K: 0}}
+	if err := attachFilter(fd, drop); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// bind socket to interface
+	sockaddr := &unix.SockaddrLinklayer{
+		Protocol: proto,
+		Ifindex:  ifi.Index,
+	}
+	if err := unix.Bind(fd, sockaddr); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// drain packets of all interfaces received before the drop filter
+	// was attached and the socket was bound
+	if err := drain(fd); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// replace drop filter
+	if filter {
+		err = attachFilter(fd, smcFilter())
+	} else {
+		err = unix.SetsockoptInt(fd, unix.SOL_SOCKET,
+			unix.SO_DETACH_FILTER, 0)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	return &liveSource{
+		fd:       fd,
+		ifindex:  ifi.Index,
+		loopback: ifi.Flags&net.FlagLoopback != 0,
+		buf:      make([]byte, liveSnapLen),
+	}, nil
+}
+
+// ReadPacketData reads the next packet from the socket
+func (l *liveSource) ReadPacketData() ([]byte, gopacket.CaptureInfo,
+	error) {
+	for {
+		n, from, err := unix.Recvfrom(l.fd, l.buf, unix.MSG_TRUNC)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, gopacket.CaptureInfo{}, err
+		}
+
+		// packets on loopback devices are seen twice, ignore the
+		// outgoing ones
+		if sll, ok := from.(*unix.SockaddrLinklayer); ok &&
+			l.loopback && sll.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+
+		length := n
+		if n > len(l.buf) {
+			n = len(l.buf)
+		}
+		data := make([]byte, n)
+		copy(data, l.buf[:n])
+		ci := gopacket.CaptureInfo{
+			Timestamp:      time.Now(),
+			CaptureLength:  n,
+			Length:         length,
+			InterfaceIndex: l.ifindex,
+		}
+		return data, ci, nil
+	}
+}
+
+// LinkType returns the link type of the captured packets
+func (l *liveSource) LinkType() layers.LinkType {
+	return layers.LinkTypeEthernet
+}
+
+// Close closes the socket
+func (l *liveSource) Close() error {
+	return unix.Close(l.fd)
+}
+
+// readLive captures packets on the network interface with name and passes
+// them to the dumper until an error occurs
+func readLive(d *dumper, name string, filter bool) error {
+	l, err := newLiveSource(name, filter)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	lastFlush := time.Now()
+	for {
+		data, ci, err := l.ReadPacketData()
+		if err != nil {
+			return err
+		}
+		packet := gopacket.NewPacket(data, l.LinkType(), gopacket.Default)
+		packet.Metadata().CaptureInfo = ci
+		d.handlePacket(packet)
+
+		// flush old tcp streams and llc requests
+		if ci.Timestamp.Sub(lastFlush) > liveFlushInterval {
+			d.flushOlderThan(lastFlush)
+			lastFlush = ci.Timestamp
+		}
+	}
+}
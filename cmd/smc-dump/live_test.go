@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"golang.org/x/sys/unix"
+)
+
+func TestLiveSourceFilter(t *testing.T) {
+	// open live source on loopback device, requires privileges
+	l, err := newLiveSource("lo", true)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	tv := unix.Timeval{Sec: 1}
+	err = unix.SetsockoptTimeval(l.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO,
+		&tv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// send udp packets to other port and to roce port
+	for _, port := range []string{"4792", "4791"} {
+		conn, err := net.Dial("udp4", "127.0.0.1:"+port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte("test")); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}
+
+	// only the packet to the roce port should pass the filter
+	for {
+		data, _, err := l.ReadPacketData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := gopacket.NewPacket(data, l.LinkType(),
+			gopacket.Default)
+		udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+		if !ok {
+			continue
+		}
+		if udp.DstPort != 4791 {
+			t.Fatalf("udp.DstPort = %d; want %d", udp.DstPort, 4791)
+		}
+		break
+	}
+}
+
+func TestLiveSourceFilterTCP(t *testing.T) {
+	// open live source on loopback device, requires privileges
+	l, err := newLiveSource("lo", true)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	tv := unix.Timeval{Sec: 1}
+	err = unix.SetsockoptTimeval(l.fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO,
+		&tv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// send non-clc data and a clc message split across two segments over
+	// a tcp connection
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	conn, err := net.Dial("tcp4", lis.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	server, err := lis.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	port := layers.TCPPort(lis.Addr().(*net.TCPAddr).Port)
+	segments := [][]byte{
+		[]byte("hello world"),
+		{0xe2, 0xd4, 0xc3, 0xd9, 0x04, 0x00, 0x1c, 0x10},
+		{0x25, 0x25, 0x25, 0x25, 0x25, 0x25, 0x25, 0x00},
+	}
+	for _, data := range segments {
+		if _, err := conn.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, len(data))
+		if _, err := io.ReadFull(server, buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// syn packets and all data segments should pass, pure acks should
+	// not pass
+	syns := 0
+	for i := 0; i < len(segments); {
+		data, _, err := l.ReadPacketData()
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := gopacket.NewPacket(data, l.LinkType(),
+			gopacket.Default)
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok || (tcp.SrcPort != port && tcp.DstPort != port) {
+			continue
+		}
+		if tcp.SYN {
+			syns++
+			continue
+		}
+		if len(tcp.Payload) == 0 {
+			t.Fatal("packet without payload passed the filter")
+		}
+		if !bytes.Equal(tcp.Payload, segments[i]) {
+			t.Fatalf("payload = %x; want %x", tcp.Payload,
+				segments[i])
+		}
+		i++
+	}
+	if syns != 2 {
+		t.Errorf("syns = %d; want %d", syns, 2)
+	}
+}
@@ -0,0 +1,69 @@
+// smc-dump decodes SMC traffic in pcap and pcapng files or captured live on a
+// network interface: CLC messages of TCP connections with the SMC
+// experimental option, LLC messages in RoCEv1 and RoCEv2 packets and the
+// application data of SMC-R connections
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+var (
+	pcapFile   = flag.String("f", "", "read packets from pcap or pcapng file")
+	pcapDevice = flag.String("i", "", "capture packets on network interface")
+	pcapFilter = flag.Bool("filter", false,
+		"only capture SMC traffic on network interface")
+	showReserved = flag.Bool("show-reserved", false,
+		"show reserved message fields")
+	showHex = flag.Bool("show-hex", false, "show hex dumps of messages")
+	showGRH = flag.Bool("show-grh", false,
+		"show RoCEv1 global routing headers")
+	showBTH = flag.Bool("show-bth", false,
+		"show RoCE base transport headers")
+	showData = flag.Bool("show-data", false,
+		"show SMC-R application data reconstructed from RDMA writes")
+	showEvents = flag.Bool("show-events", false,
+		"show SMC-R link group events from LLC messages")
+	auditRKeys = flag.Bool("audit-rkeys", false,
+		"show RKey life cycle problems at the end of the capture")
+	showRTT = flag.Bool("show-rtt", false,
+		"show LLC request/reply round-trip times and statistics")
+	showCDC = flag.Bool("show-cdc", false,
+		"show SMC-R flow-control anomalies and connection events from "+
+			"CDC messages")
+	showFailover = flag.Bool("show-failover", false,
+		"show SMC-R link failover incident reports")
+)
+
+func main() {
+	flag.Parse()
+	if (*pcapFile == "") == (*pcapDevice == "") {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	d := newDumper(os.Stdout)
+	d.reserved = *showReserved
+	d.hex = *showHex
+	d.grh = *showGRH
+	d.bth = *showBTH
+	d.data = *showData
+	d.events = *showEvents
+	d.audit = *auditRKeys
+	d.rtt = *showRTT
+	d.cdc = *showCDC
+	d.failover = *showFailover
+	if *pcapDevice != "" {
+		if err := readLive(d, *pcapDevice, *pcapFilter); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := readPcap(d, *pcapFile); err != nil {
+		log.Fatal(err)
+	}
+}
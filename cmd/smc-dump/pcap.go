@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+)
+
+// pcapngMagic is the block type of the section header block at the start of
+// pcapng files
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// packetReader is a pcap or pcapng file reader
+type packetReader interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// newPacketReader returns a pcap or pcapng reader for file depending on the
+// magic number at the start of the file
+func newPacketReader(file *os.File) (packetReader, error) {
+	r := bufio.NewReader(file)
+	magic, err := r.Peek(len(pcapngMagic))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(magic, pcapngMagic) {
+		return pcapgo.NewNgReader(r, pcapgo.DefaultNgReaderOptions)
+	}
+	return pcapgo.NewReader(r)
+}
+
+// readPcap reads all packets from the pcap or pcapng file and passes them to
+// the dumper
+func readPcap(d *dumper, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := newPacketReader(f)
+	if err != nil {
+		return err
+	}
+	source := gopacket.NewPacketSource(r, r.LinkType())
+	for packet := range source.Packets() {
+		d.handlePacket(packet)
+	}
+	d.flush()
+	return nil
+}
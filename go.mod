@@ -6,3 +6,5 @@ require (
 	github.com/gopacket/gopacket v1.3.0
 	golang.org/x/sys v0.25.0
 )
+
+require golang.org/x/net v0.28.0 // indirect
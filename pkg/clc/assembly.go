@@ -0,0 +1,240 @@
+package clc
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/reassembly"
+)
+
+// StreamMessage is a CLC message extracted from a reassembled TCP stream
+type StreamMessage struct {
+	// Net and Transport are the network and transport flows of the
+	// message, i.e., the IP addresses and TCP ports of the sender (source)
+	// and the receiver (destination)
+	Net       gopacket.Flow
+	Transport gopacket.Flow
+
+	// Direction is the direction of the message within the TCP
+	// connection, the client is the sender of the SYN packet
+	Direction reassembly.TCPFlowDirection
+
+	// Timestamp is the capture timestamp of the packet that contains
+	// the last byte of the message
+	Timestamp time.Time
+
+	// Message is the parsed CLC message. If parsing the message failed,
+	// Err contains the parse error and Message is nil or only partially
+	// parsed
+	Message Message
+	Err     error
+}
+
+// StreamHandler is called for every CLC message extracted from a TCP stream
+type StreamHandler func(msg *StreamMessage)
+
+// assemblerContext is the capture info of a packet passed to the assembler
+type assemblerContext gopacket.CaptureInfo
+
+// GetCaptureInfo returns the capture info of the packet
+func (a *assemblerContext) GetCaptureInfo() gopacket.CaptureInfo {
+	return gopacket.CaptureInfo(*a)
+}
+
+// halfStream is one direction of a TCP stream that carries CLC messages
+type halfStream struct {
+	buf  []byte
+	done bool
+}
+
+// stream is a TCP stream that is checked for CLC messages
+type stream struct {
+	net       gopacket.Flow
+	transport gopacket.Flow
+	handler   StreamHandler
+
+	// SMC option seen in SYN and SYN-ACK
+	syn    bool
+	synAck bool
+
+	// client to server and server to client directions
+	halves [2]halfStream
+}
+
+// Accept checks the SMC option in SYN and SYN-ACK packets and accepts all
+// packets of the stream
+func (s *stream) Accept(tcp *layers.TCP, ci gopacket.CaptureInfo,
+	dir reassembly.TCPFlowDirection, nextSeq reassembly.Sequence,
+	start *bool, ac reassembly.AssemblerContext) bool {
+	if tcp.SYN && CheckSMCOption(tcp) {
+		if tcp.ACK {
+			s.synAck = true
+		} else {
+			s.syn = true
+		}
+	}
+	return true
+}
+
+// half returns the half stream of direction dir
+func (s *stream) half(dir reassembly.TCPFlowDirection) *halfStream {
+	if dir == reassembly.TCPDirClientToServer {
+		return &s.halves[0]
+	}
+	return &s.halves[1]
+}
+
+// ReassembledSG appends the reassembled data to the half stream and
+// extracts all complete CLC messages from it. Data is only handled if both
+// SYN and SYN-ACK carried the SMC option. A half stream is not checked any
+// further after data that is not a CLC message, e.g., after a fallback to
+// TCP
+func (s *stream) ReassembledSG(sg reassembly.ScatterGather,
+	ac reassembly.AssemblerContext) {
+	if !s.syn || !s.synAck {
+		return
+	}
+	dir, _, _, skip := sg.Info()
+	h := s.half(dir)
+	if h.done {
+		return
+	}
+	if skip > 0 {
+		// missing data in the stream, framing is lost
+		h.done = true
+		h.buf = nil
+		return
+	}
+
+	length, _ := sg.Lengths()
+	if length == 0 {
+		return
+	}
+	start := len(h.buf)
+	h.buf = append(h.buf, sg.Fetch(length)...)
+
+	net, transport := s.net, s.transport
+	if dir == reassembly.TCPDirServerToClient {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+
+	for {
+		if len(h.buf) < HeaderLen {
+			if len(h.buf) >= EyecatcherLen && !HasEyecatcher(h.buf) {
+				h.done = true
+			}
+			break
+		}
+		m := &StreamMessage{
+			Net:       net,
+			Transport: transport,
+			Direction: dir,
+		}
+
+		// check eyecatcher and length like Reader
+		if !HasEyecatcher(h.buf) {
+			h.done = true
+			break
+		}
+		typ := MsgType(h.buf[4])
+		msgLen := int(binary.BigEndian.Uint16(h.buf[5:7]))
+		if msgLen > MaxMessageSize {
+			m.Err = &ParseError{Type: typ, Offset: 5, Err: ErrTooBig}
+		}
+		if msgLen < HeaderLen+TrailerLen {
+			m.Err = &ParseError{Type: typ, Offset: 5, Err: ErrTooShort}
+		}
+		if m.Err != nil {
+			m.Timestamp = sg.CaptureInfo(HeaderLen - 1 - start).Timestamp
+			s.handler(m)
+			h.done = true
+			break
+		}
+		if len(h.buf) < msgLen {
+			break
+		}
+
+		// parse message; the timestamp is the one of the packet
+		// that contains the last byte of the message
+		buf := make([]byte, msgLen)
+		copy(buf, h.buf[:msgLen])
+		m.Timestamp = sg.CaptureInfo(msgLen - 1 - start).Timestamp
+		m.Message, _, m.Err = NewMessage(buf)
+		if m.Err == nil {
+			m.Err = m.Message.Parse(buf)
+		}
+		s.handler(m)
+
+		h.buf = h.buf[msgLen:]
+		start -= msgLen
+	}
+	if h.done {
+		h.buf = nil
+	}
+}
+
+// ReassemblyComplete removes the stream when it is complete
+func (s *stream) ReassemblyComplete(ac reassembly.AssemblerContext) bool {
+	return true
+}
+
+// streamFactory creates new streams for the assembler
+type streamFactory struct {
+	handler StreamHandler
+}
+
+// New creates a new stream for the flows net and transport
+func (f *streamFactory) New(net, transport gopacket.Flow, tcp *layers.TCP,
+	ac reassembly.AssemblerContext) reassembly.Stream {
+	return &stream{
+		net:       net,
+		transport: transport,
+		handler:   f.handler,
+	}
+}
+
+// StreamAssembler reassembles TCP streams from packets and extracts the CLC
+// messages of TCP connections that negotiated SMC with the SMC option in the
+// SYN and SYN-ACK packets. Messages that are split across multiple TCP
+// segments, multiple messages in a single segment, out of order segments and
+// retransmissions are handled by the underlying gopacket reassembly.Assembler
+type StreamAssembler struct {
+	assembler *reassembly.Assembler
+}
+
+// NewStreamAssembler returns a new StreamAssembler that calls handler for
+// every extracted CLC message
+func NewStreamAssembler(handler StreamHandler) *StreamAssembler {
+	factory := &streamFactory{handler: handler}
+	pool := reassembly.NewStreamPool(factory)
+	return &StreamAssembler{
+		assembler: reassembly.NewAssembler(pool),
+	}
+}
+
+// Assemble adds the TCP segment in packet to the assembler. Packets without
+// a network or TCP layer are ignored
+func (s *StreamAssembler) Assemble(packet gopacket.Packet) {
+	netLayer := packet.NetworkLayer()
+	if netLayer == nil {
+		return
+	}
+	tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	if !ok {
+		return
+	}
+	ac := assemblerContext(packet.Metadata().CaptureInfo)
+	s.assembler.AssembleWithContext(netLayer.NetworkFlow(), tcp, &ac)
+}
+
+// FlushOlderThan flushes and closes all streams without new packets since t
+func (s *StreamAssembler) FlushOlderThan(t time.Time) {
+	s.assembler.FlushCloseOlderThan(t)
+}
+
+// FlushAll flushes and closes all streams, e.g., at the end of a capture
+func (s *StreamAssembler) FlushAll() {
+	s.assembler.FlushAll()
+}
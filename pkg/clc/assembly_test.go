@@ -0,0 +1,198 @@
+package clc
+
+import (
+	"encoding/hex"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/reassembly"
+)
+
+// testAssemblyPacket creates a TCP packet for the assembly tests
+func testAssemblyPacket(client bool, tcp *layers.TCP, payload []byte,
+	ts time.Time) gopacket.Packet {
+	clientIP := net.IPv4(10, 0, 0, 1)
+	serverIP := net.IPv4(10, 0, 0, 2)
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolTCP,
+		SrcIP:    clientIP,
+		DstIP:    serverIP,
+	}
+	tcp.SrcPort, tcp.DstPort = 40000, 12345
+	if !client {
+		ip.SrcIP, ip.DstIP = serverIP, clientIP
+		tcp.SrcPort, tcp.DstPort = tcp.DstPort, tcp.SrcPort
+	}
+	tcp.Window = 65535
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	err := gopacket.SerializeLayers(buf, opts, ip, tcp,
+		gopacket.Payload(payload))
+	if err != nil {
+		log.Fatal(err)
+	}
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4,
+		gopacket.Default)
+	packet.Metadata().Timestamp = ts
+	packet.Metadata().CaptureLength = len(buf.Bytes())
+	packet.Metadata().Length = len(buf.Bytes())
+	return packet
+}
+
+// testAssemblyHandshake returns the packets of a TCP handshake for the
+// assembly tests. If smc is true, SYN and SYN-ACK contain the SMC option
+func testAssemblyHandshake(smc bool, ts time.Time) []gopacket.Packet {
+	var options []layers.TCPOption
+	if smc {
+		options = []layers.TCPOption{{
+			OptionType:   254,
+			OptionLength: 6,
+			OptionData:   SMCOption,
+		}}
+	}
+	syn := &layers.TCP{Seq: 100, SYN: true, Options: options}
+	synAck := &layers.TCP{Seq: 500, Ack: 101, SYN: true, ACK: true,
+		Options: options}
+	ack := &layers.TCP{Seq: 101, Ack: 501, ACK: true}
+	return []gopacket.Packet{
+		testAssemblyPacket(true, syn, nil, ts),
+		testAssemblyPacket(false, synAck, nil, ts),
+		testAssemblyPacket(true, ack, nil, ts),
+	}
+}
+
+func TestStreamAssembler(t *testing.T) {
+	declineMsg := "e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9"
+	acceptMsg := "e2d4c3c4020030110123456789abcdef" +
+		"0123456789abcdefff100000ffffffff" +
+		"000000000000000000000000e2d4c3c4"
+	decline, err := hex.DecodeString(declineMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	accept, err := hex.DecodeString(acceptMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	both := append(append([]byte{}, accept...), decline...)
+
+	// client sends decline split across two segments, the second one
+	// arrives before the first one; server sends accept and decline in
+	// one segment that is retransmitted and followed by non-clc data
+	ts := time.Unix(1700000000, 0)
+	packets := testAssemblyHandshake(true, ts)
+	packets = append(packets,
+		testAssemblyPacket(true, &layers.TCP{Seq: 111, Ack: 501,
+			ACK: true, PSH: true}, decline[10:], ts.Add(2*time.Second)),
+		testAssemblyPacket(true, &layers.TCP{Seq: 101, Ack: 501,
+			ACK: true}, decline[:10], ts.Add(3*time.Second)),
+		testAssemblyPacket(false, &layers.TCP{Seq: 501, Ack: 129,
+			ACK: true, PSH: true}, both, ts.Add(4*time.Second)),
+		testAssemblyPacket(false, &layers.TCP{Seq: 501, Ack: 129,
+			ACK: true, PSH: true}, both, ts.Add(5*time.Second)),
+		testAssemblyPacket(false, &layers.TCP{Seq: 577, Ack: 129,
+			ACK: true, PSH: true}, []byte("hello world\n"),
+			ts.Add(6*time.Second)),
+		testAssemblyPacket(false, &layers.TCP{Seq: 589, Ack: 129,
+			ACK: true, PSH: true}, decline, ts.Add(7*time.Second)),
+	)
+
+	var msgs []*StreamMessage
+	a := NewStreamAssembler(func(m *StreamMessage) {
+		msgs = append(msgs, m)
+	})
+	for _, p := range packets {
+		a.Assemble(p)
+	}
+	a.FlushAll()
+
+	if len(msgs) != 3 {
+		t.Fatalf("len(msgs) = %d; want %d", len(msgs), 3)
+	}
+	for i, m := range msgs {
+		if m.Err != nil {
+			t.Errorf("msgs[%d].Err = %v; want nil", i, m.Err)
+		}
+	}
+
+	// check client message
+	if _, ok := msgs[0].Message.(*Decline); !ok {
+		t.Errorf("msgs[0].Message = %T; want *Decline", msgs[0].Message)
+	}
+	if msgs[0].Direction != reassembly.TCPDirClientToServer {
+		t.Errorf("msgs[0].Direction = %s; want %s", msgs[0].Direction,
+			reassembly.TCPDirClientToServer)
+	}
+	if got, want := msgs[0].Net.String(), "10.0.0.1->10.0.0.2"; got != want {
+		t.Errorf("msgs[0].Net = %s; want %s", got, want)
+	}
+	if got, want := msgs[0].Transport.String(), "40000->12345"; got != want {
+		t.Errorf("msgs[0].Transport = %s; want %s", got, want)
+	}
+	if want := ts.Add(2 * time.Second); !msgs[0].Timestamp.Equal(want) {
+		t.Errorf("msgs[0].Timestamp = %s; want %s", msgs[0].Timestamp,
+			want)
+	}
+
+	// check server messages
+	if _, ok := msgs[1].Message.(*AcceptSMCD); !ok {
+		t.Errorf("msgs[1].Message = %T; want *AcceptSMCD",
+			msgs[1].Message)
+	}
+	if _, ok := msgs[2].Message.(*Decline); !ok {
+		t.Errorf("msgs[2].Message = %T; want *Decline", msgs[2].Message)
+	}
+	for _, m := range msgs[1:] {
+		if m.Direction != reassembly.TCPDirServerToClient {
+			t.Errorf("Direction = %s; want %s", m.Direction,
+				reassembly.TCPDirServerToClient)
+		}
+		if got, want := m.Net.String(), "10.0.0.2->10.0.0.1"; got != want {
+			t.Errorf("Net = %s; want %s", got, want)
+		}
+		if want := ts.Add(4 * time.Second); !m.Timestamp.Equal(want) {
+			t.Errorf("Timestamp = %s; want %s", m.Timestamp, want)
+		}
+	}
+}
+
+func TestStreamAssemblerNoSMC(t *testing.T) {
+	declineMsg := "e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9"
+	decline, err := hex.DecodeString(declineMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// tcp connection without smc option
+	ts := time.Unix(1700000000, 0)
+	packets := testAssemblyHandshake(false, ts)
+	packets = append(packets, testAssemblyPacket(true,
+		&layers.TCP{Seq: 101, Ack: 501, ACK: true, PSH: true}, decline,
+		ts))
+
+	var msgs []*StreamMessage
+	a := NewStreamAssembler(func(m *StreamMessage) {
+		msgs = append(msgs, m)
+	})
+	for _, p := range packets {
+		a.Assemble(p)
+	}
+	a.FlushAll()
+
+	if len(msgs) != 0 {
+		t.Errorf("len(msgs) = %d; want %d", len(msgs), 0)
+	}
+}
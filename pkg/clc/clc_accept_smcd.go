@@ -2,8 +2,8 @@ package clc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 )
 
 const (
@@ -54,24 +54,21 @@ func (ac *AcceptSMCD) Reserved() string {
 }
 
 // Parse parses the SMC-D Accept message in buf
-func (ac *AcceptSMCD) Parse(buf []byte) {
-	// save raw message bytes
-	ac.Raw.Parse(buf)
-
-	// parse clc header
-	ac.Header.Parse(buf)
+func (ac *AcceptSMCD) Parse(buf []byte) error {
+	// parse CLC header
+	if err := ac.Header.Parse(buf); err != nil {
+		return err
+	}
 
 	// check if message is long enough
-	if ac.Length < AcceptSMCDLen {
-		err := "Error parsing CLC Accept: message too short"
-		if ac.Type == TypeConfirm {
-			err = "Error parsing CLC Confirm: message too short"
-		}
-		log.Println(err)
-		errDump(buf[:ac.Length])
-		return
+	buf, err := ac.checkLength(buf, AcceptSMCDLen)
+	if err != nil {
+		return err
 	}
 
+	// save raw message bytes
+	ac.Raw.Parse(buf)
+
 	// skip clc header
 	buf = buf[HeaderLen:]
 
@@ -105,5 +102,105 @@ func (ac *AcceptSMCD) Parse(buf []byte) {
 	buf = buf[12:]
 
 	// save trailer
-	ac.Trailer.Parse(buf)
+	if err := ac.Trailer.Parse(ac.Raw); err != nil {
+		return ac.parseError(len(ac.Raw)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the SMC-D Accept message to bytes
+func (ac *AcceptSMCD) Marshal() ([]byte, error) {
+	// write CLC header and trailer
+	buf := make([]byte, AcceptSMCDLen)
+	if err := ac.Header.marshal(buf, AcceptSMCDLen); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	b := buf[HeaderLen:]
+
+	// smcd GID
+	binary.BigEndian.PutUint64(b[:8], ac.GID)
+	b = b[8:]
+
+	// smcd Token
+	binary.BigEndian.PutUint64(b[:8], ac.Token)
+	b = b[8:]
+
+	// dmbe index
+	b[0] = ac.DMBEIdx
+	b = b[1:]
+
+	// 1 byte bitfield: dmbe size (4 bits), reserved (4 bits)
+	b[0] = (uint8(ac.DMBESize) & 0b1111) << 4
+	b[0] |= ac.reserved & 0b1111
+	b = b[1:]
+
+	// reserved
+	copy(b[:2], ac.reserved2[:])
+	b = b[2:]
+
+	// link id
+	binary.BigEndian.PutUint32(b[:4], ac.LinkID)
+	b = b[4:]
+
+	// reserved
+	copy(b[:12], ac.reserved3[:])
+
+	return buf, nil
+}
+
+// jsonAcceptSMCD stores the CLC SMC-D Accept message in JSON
+type jsonAcceptSMCD struct {
+	jsonMessage
+	GID      uint64   `json:"gid"`
+	Token    uint64   `json:"token"`
+	DMBEIdx  uint8    `json:"dmbe_idx"`
+	DMBESize RMBESize `json:"dmbe_size"`
+	LinkID   uint32   `json:"link_id"`
+}
+
+// marshalJSON converts the CLC SMC-D Accept message to JSON
+func (ac *AcceptSMCD) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonAcceptSMCD{
+		jsonMessage: newJSONMessage(&ac.Header, ac.Trailer, reserved),
+		GID:         ac.GID,
+		Token:       ac.Token,
+		DMBEIdx:     ac.DMBEIdx,
+		DMBESize:    ac.DMBESize,
+		LinkID:      ac.LinkID,
+	}
+	j.Reserved.add("reserved", ac.reserved)
+	j.Reserved.add("reserved2", ac.reserved2)
+	j.Reserved.add("reserved3", ac.reserved3)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the CLC SMC-D Accept message to JSON
+func (ac *AcceptSMCD) MarshalJSON() ([]byte, error) {
+	return ac.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the CLC SMC-D Accept message
+func (ac *AcceptSMCD) UnmarshalJSON(data []byte) error {
+	var j jsonAcceptSMCD
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&ac.Header, &ac.Trailer); err != nil {
+		return err
+	}
+	ac.GID = j.GID
+	ac.Token = j.Token
+	ac.DMBEIdx = j.DMBEIdx
+	ac.DMBESize = j.DMBESize
+	ac.LinkID = j.LinkID
+	if err := j.Reserved.get("reserved", &ac.reserved); err != nil {
+		return err
+	}
+	if err := j.Reserved.get("reserved2", ac.reserved2[:]); err != nil {
+		return err
+	}
+	return j.Reserved.get("reserved3", ac.reserved3[:])
 }
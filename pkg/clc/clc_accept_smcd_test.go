@@ -1,6 +1,7 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 	"testing"
@@ -17,8 +18,13 @@ func TestParseSMCDAccept(t *testing.T) {
 	}
 
 	// parse message
-	clc, clcLen := NewMessage(msg)
-	clc.Parse(msg)
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if clcLen != 48 {
@@ -53,4 +59,13 @@ func TestParseSMCDAccept(t *testing.T) {
 	if got != want {
 		t.Errorf("clc.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
 }
@@ -2,8 +2,8 @@ package clc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 )
 
 const (
@@ -26,7 +26,7 @@ type AcceptSMCDv2 struct {
 	LinkID     uint32 // Link identifier
 	ISMv2VCHID uint16 // ISMv2 VCHID
 	EID        EID    // EID
-	reserved3  [8]byte
+	GIDExt     uint64 // GID extension, only used by virtual ISM devices
 
 	// First Contact Extension; only present if first contact flag set
 	reserved4 byte
@@ -35,16 +35,62 @@ type AcceptSMCDv2 struct {
 	reserved5 [2]byte
 	Hostname  EID // hostname has same format as EID
 
+	// SMCv2.1 First Contact Extension fields; only present if Release >= 1
+	MaxConns    uint8       // maximum number of connections per lgr
+	MaxLinks    uint8       // maximum number of links per lgr
+	FeatureMask FeatureMask // negotiated SMCv2.1 features
+	reserved6   [4]byte     // vendor specific options
+	reserved7   [8]byte
+
 	Trailer
 }
 
+// hasFCE checks if the message contains a first contact extension
+func (ac *AcceptSMCDv2) hasFCE() bool {
+	return ac.Length >= AcceptSMCDv2FCELen
+}
+
+// hasFCEv2x checks if the message contains the SMCv2.1 fields in the first
+// contact extension
+func (ac *AcceptSMCDv2) hasFCEv2x() bool {
+	return ac.hasFCE() && ac.Release >= SMCRelease1 &&
+		ac.Length >= AcceptSMCDv2FCELen+FCEv2xLen
+}
+
+// gidExtString converts the GID extension in the SMCv2 CLC SMC-D Accept
+// message to a string
+func (ac *AcceptSMCDv2) gidExtString() string {
+	if !isVirtualCHID(ac.ISMv2VCHID) {
+		return ""
+	}
+	return fmt.Sprintf(", GID Extension: %d", ac.GIDExt)
+}
+
+// gidExtReserved converts the GID extension in the SMCv2 CLC SMC-D Accept
+// message to a string including reserved message fields. The GID extension is
+// reserved if the ISM device is not a virtual ISM device
+func (ac *AcceptSMCDv2) gidExtReserved() string {
+	if !isVirtualCHID(ac.ISMv2VCHID) {
+		reserved := make([]byte, 8)
+		binary.BigEndian.PutUint64(reserved, ac.GIDExt)
+		return fmt.Sprintf(", Reserved: %#x", reserved)
+	}
+	return ac.gidExtString()
+}
+
 // fceString converts the FCE in the SMCv2 CLC SMC-D Accept message to a string
 func (ac *AcceptSMCDv2) fceString() string {
-	if ac.Length < AcceptSMCDv2FCELen {
+	if !ac.hasFCE() {
 		return ""
 	}
 	fceFmt := ", OS Type: %s, Release: %d, Hostname: %s"
-	return fmt.Sprintf(fceFmt, ac.OSType, ac.Release, &ac.Hostname)
+	fce := fmt.Sprintf(fceFmt, ac.OSType, ac.Release, &ac.Hostname)
+	if !ac.hasFCEv2x() {
+		return fce
+	}
+	v2xFmt := ", Max Connections: %d, Max Links: %d, Feature Mask: %s"
+	return fce + fmt.Sprintf(v2xFmt, ac.MaxConns, ac.MaxLinks,
+		ac.FeatureMask)
 }
 
 // String converts the SMCv2 CLC SMC-D Accept message to a string
@@ -54,23 +100,30 @@ func (ac *AcceptSMCDv2) String() string {
 	}
 
 	acFmt := "%s, SMC-D GID: %d, SMC-D Token: %d, DMBE Index: %d, " +
-		"DMBE Size: %s, Link ID: %d, ISMv2 VCHID: %d, EID: %s%s, " +
+		"DMBE Size: %s, Link ID: %d, ISMv2 VCHID: %d, EID: %s%s%s, " +
 		"Trailer: %s"
 	return fmt.Sprintf(acFmt, ac.Header.String(), ac.GID, ac.Token,
 		ac.DMBEIdx, ac.DMBESize, ac.LinkID, ac.ISMv2VCHID, &ac.EID,
-		ac.fceString(), ac.Trailer)
+		ac.gidExtString(), ac.fceString(), ac.Trailer)
 }
 
 // fceReserved converts the FCE in the SMCv2 CLC SMC-D Accept messate to a
 // string including reserved message fields
 func (ac *AcceptSMCDv2) fceReserved() string {
-	if ac.Length < AcceptSMCDv2FCELen {
+	if !ac.hasFCE() {
 		return ""
 	}
 	fceFmt := ", Reserved: %#x, OS Type: %s, Release: %d, " +
 		"Reserved: %#x, Hostname: %s"
-	return fmt.Sprintf(fceFmt, ac.reserved4, ac.OSType, ac.Release,
+	fce := fmt.Sprintf(fceFmt, ac.reserved4, ac.OSType, ac.Release,
 		ac.reserved5, &ac.Hostname)
+	if !ac.hasFCEv2x() {
+		return fce
+	}
+	v2xFmt := ", Max Connections: %d, Max Links: %d, Feature Mask: %s, " +
+		"Reserved: %#x, Reserved: %#x"
+	return fce + fmt.Sprintf(v2xFmt, ac.MaxConns, ac.MaxLinks,
+		ac.FeatureMask, ac.reserved6, ac.reserved7)
 }
 
 // Reserved converts the SMCv2 CLC SMC-D Accept message to a string including
@@ -82,34 +135,30 @@ func (ac *AcceptSMCDv2) Reserved() string {
 
 	acFmt := "%s, SMC-D GID: %d, SMC-D Token: %d, DMBE Index: %d, " +
 		"DMBE Size: %s, Reserved: %#x, Reserved: %#x, " +
-		"Link ID: %d, ISMv2 VCHID: %d, EID: %s, Reserved: %#x%s, " +
+		"Link ID: %d, ISMv2 VCHID: %d, EID: %s%s%s, " +
 		"Trailer: %s"
 	return fmt.Sprintf(acFmt, ac.Header.Reserved(), ac.GID,
 		ac.Token, ac.DMBEIdx, ac.DMBESize, ac.reserved,
 		ac.reserved2, ac.LinkID, ac.ISMv2VCHID, &ac.EID,
-		ac.reserved3, ac.fceReserved(), ac.Trailer)
+		ac.gidExtReserved(), ac.fceReserved(), ac.Trailer)
 }
 
 // Parse parses the SMCv2 CLC SMC-D Accept message in buf
-func (ac *AcceptSMCDv2) Parse(buf []byte) {
-	// save raw message bytes
-	ac.Raw.Parse(buf)
-
-	// parse clc header
-	ac.Header.Parse(buf)
+func (ac *AcceptSMCDv2) Parse(buf []byte) error {
+	// parse CLC header
+	if err := ac.Header.Parse(buf); err != nil {
+		return err
+	}
 
 	// check if message is long enough
-	if ac.Length < AcceptSMCDv2Len || len(buf) < AcceptSMCDv2Len {
-		err := "Error parsing SMC-Dv2 CLC Accept: message too short"
-		if ac.Type == TypeConfirm {
-			err = "Error parsing SMC-Dv2 CLC Confirm: " +
-				"message too short"
-		}
-		log.Println(err)
-		errDump(buf[:ac.Length])
-		return
+	buf, err := ac.checkLength(buf, AcceptSMCDv2Len)
+	if err != nil {
+		return err
 	}
 
+	// save raw message bytes
+	ac.Raw.Parse(buf)
+
 	// skip clc header
 	buf = buf[HeaderLen:]
 
@@ -146,12 +195,12 @@ func (ac *AcceptSMCDv2) Parse(buf []byte) {
 	copy(ac.EID[:], buf[:EIDLen])
 	buf = buf[EIDLen:]
 
-	// reserved
-	copy(ac.reserved3[:], buf[:8])
+	// GID extension (reserved if not a virtual ISM device)
+	ac.GIDExt = binary.BigEndian.Uint64(buf[:8])
 	buf = buf[8:]
 
 	// parse First Contact Extension (FCE) if present
-	if ac.Length == AcceptSMCDv2FCELen {
+	if ac.hasFCE() {
 		// reserved
 		ac.reserved4 = buf[0]
 		buf = buf[1:]
@@ -171,6 +220,246 @@ func (ac *AcceptSMCDv2) Parse(buf []byte) {
 		buf = buf[EIDLen:]
 	}
 
+	// parse SMCv2.1 fields in FCE if present
+	if ac.hasFCEv2x() {
+		// max connections
+		ac.MaxConns = buf[0]
+		buf = buf[1:]
+
+		// max links
+		ac.MaxLinks = buf[0]
+		buf = buf[1:]
+
+		// feature mask
+		ac.FeatureMask = FeatureMask(binary.BigEndian.Uint16(buf[:2]))
+		buf = buf[2:]
+
+		// vendor specific options
+		copy(ac.reserved6[:], buf[:4])
+		buf = buf[4:]
+
+		// reserved
+		copy(ac.reserved7[:], buf[:8])
+		buf = buf[8:]
+	}
+
 	// save trailer
-	ac.Trailer.Parse(buf)
+	if err := ac.Trailer.Parse(ac.Raw); err != nil {
+		return ac.parseError(len(ac.Raw)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the SMCv2 SMC-D Accept message to bytes. The First Contact
+// Extension is only added if the first contact flag is set, its SMCv2.1 fields
+// only if Release >= 1
+func (ac *AcceptSMCDv2) Marshal() ([]byte, error) {
+	// calculate message length
+	length := AcceptSMCDv2Len
+	if ac.Flag == 1 {
+		length = AcceptSMCDv2FCELen
+		if ac.Release >= SMCRelease1 {
+			length += FCEv2xLen
+		}
+	}
+
+	// write CLC header and trailer
+	buf := make([]byte, length)
+	if err := ac.Header.marshal(buf, length); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	b := buf[HeaderLen:]
+
+	// smcd GID
+	binary.BigEndian.PutUint64(b[:8], ac.GID)
+	b = b[8:]
+
+	// smcd Token
+	binary.BigEndian.PutUint64(b[:8], ac.Token)
+	b = b[8:]
+
+	// dmbe index
+	b[0] = ac.DMBEIdx
+	b = b[1:]
+
+	// 1 byte bitfield: dmbe size (4 bits), reserved (4 bits)
+	b[0] = (uint8(ac.DMBESize) & 0b1111) << 4
+	b[0] |= ac.reserved & 0b1111
+	b = b[1:]
+
+	// reserved
+	copy(b[:2], ac.reserved2[:])
+	b = b[2:]
+
+	// link id
+	binary.BigEndian.PutUint32(b[:4], ac.LinkID)
+	b = b[4:]
+
+	// ISMv2 VCHID
+	binary.BigEndian.PutUint16(b[:2], ac.ISMv2VCHID)
+	b = b[2:]
+
+	// EID
+	copy(b[:EIDLen], ac.EID[:])
+	b = b[EIDLen:]
+
+	// GID extension
+	binary.BigEndian.PutUint64(b[:8], ac.GIDExt)
+	b = b[8:]
+
+	// First Contact Extension (FCE)
+	if length >= AcceptSMCDv2FCELen {
+		// reserved
+		b[0] = ac.reserved4
+		b = b[1:]
+
+		// OS type (4 bits), Release (4 bits)
+		b[0] = (uint8(ac.OSType) & 0b1111) << 4
+		b[0] |= ac.Release & 0b1111
+		b = b[1:]
+
+		// reserved
+		copy(b[:2], ac.reserved5[:])
+		b = b[2:]
+
+		// hostname
+		copy(b[:EIDLen], ac.Hostname[:])
+		b = b[EIDLen:]
+	}
+
+	// SMCv2.1 fields in FCE
+	if length >= AcceptSMCDv2FCELen+FCEv2xLen {
+		// max connections
+		b[0] = ac.MaxConns
+		b = b[1:]
+
+		// max links
+		b[0] = ac.MaxLinks
+		b = b[1:]
+
+		// feature mask
+		binary.BigEndian.PutUint16(b[:2], uint16(ac.FeatureMask))
+		b = b[2:]
+
+		// vendor specific options
+		copy(b[:4], ac.reserved6[:])
+		b = b[4:]
+
+		// reserved
+		copy(b[:8], ac.reserved7[:])
+	}
+
+	return buf, nil
+}
+
+// jsonAcceptSMCDv2 stores the SMCv2 CLC SMC-D Accept message in JSON
+type jsonAcceptSMCDv2 struct {
+	jsonMessage
+	GID        uint64   `json:"gid"`
+	Token      uint64   `json:"token"`
+	DMBEIdx    uint8    `json:"dmbe_idx"`
+	DMBESize   RMBESize `json:"dmbe_size"`
+	LinkID     uint32   `json:"link_id"`
+	ISMv2VCHID uint16   `json:"ismv2_vchid"`
+	EID        EID      `json:"eid"`
+	GIDExt     *uint64  `json:"gid_ext,omitempty"`
+	FCE        *jsonFCE `json:"fce,omitempty"`
+}
+
+// marshalJSON converts the SMCv2 CLC SMC-D Accept message to JSON
+func (ac *AcceptSMCDv2) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonAcceptSMCDv2{
+		jsonMessage: newJSONMessage(&ac.Header, ac.Trailer, reserved),
+		GID:         ac.GID,
+		Token:       ac.Token,
+		DMBEIdx:     ac.DMBEIdx,
+		DMBESize:    ac.DMBESize,
+		LinkID:      ac.LinkID,
+		ISMv2VCHID:  ac.ISMv2VCHID,
+		EID:         ac.EID,
+	}
+	j.Reserved.add("reserved", ac.reserved)
+	j.Reserved.add("reserved2", ac.reserved2)
+
+	// gid extension, reserved if the ism device is not virtual
+	if isVirtualCHID(ac.ISMv2VCHID) {
+		j.GIDExt = &ac.GIDExt
+	} else {
+		j.Reserved.add("reserved3", ac.GIDExt)
+	}
+
+	// first contact extension
+	if ac.hasFCE() {
+		j.FCE = newJSONFCE(ac.OSType, ac.Release, ac.Hostname,
+			ac.hasFCEv2x(), ac.MaxConns, ac.MaxLinks,
+			ac.FeatureMask)
+		j.Reserved.add("reserved4", ac.reserved4)
+		j.Reserved.add("reserved5", ac.reserved5)
+	}
+	if ac.hasFCEv2x() {
+		j.Reserved.add("reserved6", ac.reserved6)
+		j.Reserved.add("reserved7", ac.reserved7)
+	}
+
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the SMCv2 CLC SMC-D Accept message to JSON
+func (ac *AcceptSMCDv2) MarshalJSON() ([]byte, error) {
+	return ac.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the SMCv2 CLC SMC-D Accept message
+func (ac *AcceptSMCDv2) UnmarshalJSON(data []byte) error {
+	var j jsonAcceptSMCDv2
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&ac.Header, &ac.Trailer); err != nil {
+		return err
+	}
+	ac.GID = j.GID
+	ac.Token = j.Token
+	ac.DMBEIdx = j.DMBEIdx
+	ac.DMBESize = j.DMBESize
+	ac.LinkID = j.LinkID
+	ac.ISMv2VCHID = j.ISMv2VCHID
+	ac.EID = j.EID
+
+	// gid extension, reserved if the ism device is not virtual
+	if j.GIDExt != nil {
+		ac.GIDExt = *j.GIDExt
+	}
+	if err := j.Reserved.getUint64("reserved3", &ac.GIDExt); err != nil {
+		return err
+	}
+
+	// first contact extension
+	if j.FCE != nil {
+		err := j.FCE.toFCE(&ac.OSType, &ac.Release, &ac.Hostname,
+			&ac.MaxConns, &ac.MaxLinks, &ac.FeatureMask)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, r := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"reserved", &ac.reserved},
+		{"reserved2", ac.reserved2[:]},
+		{"reserved4", &ac.reserved4},
+		{"reserved5", ac.reserved5[:]},
+		{"reserved6", ac.reserved6[:]},
+		{"reserved7", ac.reserved7[:]},
+	} {
+		if err := j.Reserved.get(r.name, r.value); err != nil {
+			return err
+		}
+	}
+	return nil
 }
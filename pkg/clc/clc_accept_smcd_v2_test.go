@@ -1,6 +1,7 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 	"testing"
@@ -21,8 +22,13 @@ func TestParseSMCDv2Accept(t *testing.T) {
 	}
 
 	// parse message
-	clc, clcLen := NewMessage(msg)
-	clc.Parse(msg)
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if clcLen != AcceptSMCDv2Len {
@@ -55,6 +61,15 @@ func TestParseSMCDv2Accept(t *testing.T) {
 	if got != want {
 		t.Errorf("clc.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
 }
 
 func TestParseSMCDv2FCEAccept(t *testing.T) {
@@ -76,8 +91,13 @@ func TestParseSMCDv2FCEAccept(t *testing.T) {
 	}
 
 	// parse message
-	clc, clcLen := NewMessage(msg)
-	clc.Parse(msg)
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if clcLen != AcceptSMCDv2FCELen {
@@ -113,4 +133,93 @@ func TestParseSMCDv2FCEAccept(t *testing.T) {
 	if got != want {
 		t.Errorf("clc.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
+}
+
+func TestParseSMCDv21FCEAccept(t *testing.T) {
+	// prepare message with virtual ISM device and SMCv2.1 FCE
+	msgBytes := "e2d4c3c4" + "02" + "0082" + "2" + "9" + "0123456789abcdef" +
+		"0123456789abcdef" + "ff" + "1" + "0" + "0000" + "ffffffff" +
+		"ffff" +
+		"546869734973534d4376324549443031" +
+		"00000000000000000000000000000000" +
+		"0000000000000002" +
+		// fce
+		"00" + "2" + "1" + "0000" +
+		"546869734973486f73746e616d653031" +
+		"00000000000000000000000000000000" +
+		// fce v2.1
+		"00" + "00" + "0001" + "00000000" + "0000000000000000" +
+		"e2d4c3c4"
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check message length
+	if clcLen != AcceptSMCDv2FCELen+FCEv2xLen {
+		t.Errorf("clcLen = %d; want %d", clcLen,
+			AcceptSMCDv2FCELen+FCEv2xLen)
+	}
+
+	// check output message without reserved fields
+	want := "Accept: Eyecatcher: SMC-D, Type: 2 (Accept), Length: 130, " +
+		"Version: 2, First Contact: 1, Path: SMC-D, " +
+		"SMC-D GID: 81985529216486895, " +
+		"SMC-D Token: 81985529216486895, DMBE Index: 255, " +
+		"DMBE Size: 1 (32768), Link ID: 4294967295, " +
+		"ISMv2 VCHID: 65535, EID: ThisIsSMCv2EID01, " +
+		"GID Extension: 2, OS Type: 2 (Linux), Release: 1, " +
+		"Hostname: ThisIsHostname01, Max Connections: 0, " +
+		"Max Links: 0, Feature Mask: 0x1 (Emulated ISM Device), " +
+		"Trailer: SMC-D"
+	got := clc.String()
+	if got != want {
+		t.Errorf("clc.String() = %s; want %s", got, want)
+	}
+
+	// check output message with reserved fields
+	want = "Accept: Eyecatcher: SMC-D, Type: 2 (Accept), " +
+		"Length: 130, Version: 2, First Contact: 1, Reserved: 0x0, " +
+		"Path: SMC-D, SMC-D GID: 81985529216486895, " +
+		"SMC-D Token: 81985529216486895, " +
+		"DMBE Index: 255, DMBE Size: 1 (32768), Reserved: 0x0, " +
+		"Reserved: 0x0000, Link ID: 4294967295, " +
+		"ISMv2 VCHID: 65535, EID: ThisIsSMCv2EID01, " +
+		"GID Extension: 2, Reserved: 0x0, " +
+		"OS Type: 2 (Linux), Release: 1, Reserved: 0x0000, " +
+		"Hostname: ThisIsHostname01, Max Connections: 0, " +
+		"Max Links: 0, Feature Mask: 0x1 (Emulated ISM Device), " +
+		"Reserved: 0x00000000, Reserved: 0x0000000000000000, " +
+		"Trailer: SMC-D"
+	got = clc.Reserved()
+	if got != want {
+		t.Errorf("clc.Reserved() = %s; want %s", got, want)
+	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
 }
@@ -2,8 +2,8 @@ package clc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 	"net"
 )
 
@@ -15,30 +15,30 @@ const (
 // QPMTU stores a SMC QP MTU
 type QPMTU uint8
 
-// String converts qpMTU to a string
-func (m QPMTU) String() string {
-	var mtu string
-
+// mtu returns the uncompressed MTU or 0 if the MTU is reserved
+func (m QPMTU) mtu() int {
 	switch m {
 	case 1:
-		mtu = "256"
-
+		return 256
 	case 2:
-		mtu = "512"
-
+		return 512
 	case 3:
-		mtu = "1024"
-
+		return 1024
 	case 4:
-		mtu = "2048"
-
+		return 2048
 	case 5:
-		mtu = "4096"
+		return 4096
 	default:
-		mtu = "reserved"
+		return 0
 	}
+}
 
-	return fmt.Sprintf("%d (%s)", m, mtu)
+// String converts qpMTU to a string
+func (m QPMTU) String() string {
+	if m.mtu() == 0 {
+		return fmt.Sprintf("%d (reserved)", m)
+	}
+	return fmt.Sprintf("%d (%d)", m, m.mtu())
 }
 
 // AcceptSMCR stores a CLC SMC-R Accept message
@@ -97,24 +97,21 @@ func (ac *AcceptSMCR) Reserved() string {
 }
 
 // Parse parses the SMC-R Accept message in buf
-func (ac *AcceptSMCR) Parse(buf []byte) {
-	// save raw message bytes
-	ac.Raw.Parse(buf)
-
+func (ac *AcceptSMCR) Parse(buf []byte) error {
 	// parse CLC header
-	ac.Header.Parse(buf)
+	if err := ac.Header.Parse(buf); err != nil {
+		return err
+	}
 
 	// check if message is long enough
-	if ac.Length < AcceptSMCRLen {
-		err := "Error parsing CLC Accept: message too short"
-		if ac.Type == TypeConfirm {
-			err = "Error parsing CLC Confirm: message too short"
-		}
-		log.Println(err)
-		errDump(buf[:ac.Length])
-		return
+	buf, err := ac.checkLength(buf, AcceptSMCRLen)
+	if err != nil {
+		return err
 	}
 
+	// save raw message bytes
+	ac.Raw.Parse(buf)
+
 	// skip clc header
 	buf = buf[HeaderLen:]
 
@@ -174,5 +171,151 @@ func (ac *AcceptSMCR) Parse(buf []byte) {
 	buf = buf[3:]
 
 	// save trailer
-	ac.Trailer.Parse(buf)
+	if err := ac.Trailer.Parse(ac.Raw); err != nil {
+		return ac.parseError(len(ac.Raw)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the SMC-R Accept message to bytes
+func (ac *AcceptSMCR) Marshal() ([]byte, error) {
+	// write CLC header and trailer
+	buf := make([]byte, AcceptSMCRLen)
+	if err := ac.Header.marshal(buf, AcceptSMCRLen); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	b := buf[HeaderLen:]
+
+	// sender peer ID
+	copy(b[:PeerIDLen], ac.SenderPeerID[:])
+	b = b[PeerIDLen:]
+
+	// ib GID is an IPv6 Address
+	copy(b[:net.IPv6len], ac.IBGID.To16())
+	b = b[net.IPv6len:]
+
+	// ib MAC is a 6 byte MAC address
+	copy(b[:6], ac.IBMAC)
+	b = b[6:]
+
+	// QP number is 3 bytes
+	b[0] = byte(ac.QPN >> 16)
+	b[1] = byte(ac.QPN >> 8)
+	b[2] = byte(ac.QPN)
+	b = b[3:]
+
+	// rmb Rkey
+	binary.BigEndian.PutUint32(b[:4], ac.RMBRKey)
+	b = b[4:]
+
+	// rmbe Idx
+	b[0] = ac.RMBEIdx
+	b = b[1:]
+
+	// rmbe alert token
+	binary.BigEndian.PutUint32(b[:4], ac.RMBEAlertToken)
+	b = b[4:]
+
+	// 1 byte bitfield: rmbe size (4 bits) and qp mtu (4 bits)
+	b[0] = (uint8(ac.RMBESize) & 0b1111) << 4
+	b[0] |= uint8(ac.QPMTU) & 0b1111
+	b = b[1:]
+
+	// reserved
+	b[0] = ac.reserved
+	b = b[1:]
+
+	// rmb DMA addr
+	binary.BigEndian.PutUint64(b[:8], ac.RMBDMAAddr)
+	b = b[8:]
+
+	// reserved
+	b[0] = ac.reserved2
+	b = b[1:]
+
+	// Packet Sequence Number is 3 bytes
+	b[0] = byte(ac.PSN >> 16)
+	b[1] = byte(ac.PSN >> 8)
+	b[2] = byte(ac.PSN)
+
+	return buf, nil
+}
+
+// jsonAcceptSMCR stores the CLC SMC-R Accept message in JSON
+type jsonAcceptSMCR struct {
+	jsonMessage
+	SenderPeerID   PeerID   `json:"sender_peer_id"`
+	IBGID          string   `json:"ib_gid"`
+	IBMAC          string   `json:"ib_mac"`
+	QPN            int      `json:"qp_number"`
+	RMBRKey        uint32   `json:"rmb_rkey"`
+	RMBEIdx        uint8    `json:"rmbe_idx"`
+	RMBEAlertToken uint32   `json:"rmbe_alert_token"`
+	RMBESize       RMBESize `json:"rmbe_size"`
+	QPMTU          QPMTU    `json:"qp_mtu"`
+	RMBDMAAddr     uint64   `json:"rmb_dma_addr"`
+	PSN            int      `json:"psn"`
+}
+
+// marshalJSON converts the CLC SMC-R Accept message to JSON
+func (ac *AcceptSMCR) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonAcceptSMCR{
+		jsonMessage:    newJSONMessage(&ac.Header, ac.Trailer, reserved),
+		SenderPeerID:   ac.SenderPeerID,
+		IBGID:          jsonIP(ac.IBGID),
+		IBMAC:          ac.IBMAC.String(),
+		QPN:            ac.QPN,
+		RMBRKey:        ac.RMBRKey,
+		RMBEIdx:        ac.RMBEIdx,
+		RMBEAlertToken: ac.RMBEAlertToken,
+		RMBESize:       ac.RMBESize,
+		QPMTU:          ac.QPMTU,
+		RMBDMAAddr:     ac.RMBDMAAddr,
+		PSN:            ac.PSN,
+	}
+	j.Reserved.add("reserved", ac.reserved)
+	j.Reserved.add("reserved2", ac.reserved2)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the CLC SMC-R Accept message to JSON
+func (ac *AcceptSMCR) MarshalJSON() ([]byte, error) {
+	return ac.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the CLC SMC-R Accept message
+func (ac *AcceptSMCR) UnmarshalJSON(data []byte) error {
+	var j jsonAcceptSMCR
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&ac.Header, &ac.Trailer); err != nil {
+		return err
+	}
+	ibGID, err := parseJSONIP(j.IBGID)
+	if err != nil {
+		return err
+	}
+	ibMAC, err := parseJSONMAC(j.IBMAC)
+	if err != nil {
+		return err
+	}
+	ac.SenderPeerID = j.SenderPeerID
+	ac.IBGID = ibGID
+	ac.IBMAC = ibMAC
+	ac.QPN = j.QPN
+	ac.RMBRKey = j.RMBRKey
+	ac.RMBEIdx = j.RMBEIdx
+	ac.RMBEAlertToken = j.RMBEAlertToken
+	ac.RMBESize = j.RMBESize
+	ac.QPMTU = j.QPMTU
+	ac.RMBDMAAddr = j.RMBDMAAddr
+	ac.PSN = j.PSN
+	if err := j.Reserved.get("reserved", &ac.reserved); err != nil {
+		return err
+	}
+	return j.Reserved.get("reserved2", &ac.reserved2)
 }
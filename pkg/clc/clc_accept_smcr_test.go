@@ -1,6 +1,7 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 	"testing"
@@ -19,8 +20,13 @@ func TestParseSMCRAccept(t *testing.T) {
 	}
 
 	// parse message
-	clc, clcLen := NewMessage(msg)
-	clc.Parse(msg)
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if clcLen != 68 {
@@ -61,4 +67,13 @@ func TestParseSMCRAccept(t *testing.T) {
 	if got != want {
 		t.Errorf("clc.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
 }
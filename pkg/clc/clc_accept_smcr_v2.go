@@ -0,0 +1,655 @@
+package clc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+const (
+	// AcceptSMCRv2Len is the minimum length of a SMCv2 SMC-R Accept msg
+	AcceptSMCRv2Len = 108
+	// AcceptSMCRv2FCELen is the length of a SMCv2 SMC-R Accept with FCE
+	AcceptSMCRv2FCELen = 144
+	// GIDListExtLen is the minimum length of the RoCEv2 GID list
+	// extension
+	GIDListExtLen = 4
+)
+
+// AcceptSMCRv2 stores a SMCv2 CLC SMC-R Accept message
+type AcceptSMCRv2 struct {
+	Raw
+	Header
+	SenderPeerID   PeerID           // unique system id
+	IBGID          net.IP           // gid of ib_device port
+	IBMAC          net.HardwareAddr // mac of ib_device port
+	QPN            int              // QP number
+	RMBRKey        uint32           // RMB rkey
+	RMBEIdx        uint8            // Index of RMBE in RMB
+	RMBEAlertToken uint32           // unique connection id
+	RMBESize       RMBESize         // 4 bits buf size (compressed)
+	QPMTU          QPMTU            // 4 bits QP mtu
+	reserved       byte
+	RMBDMAAddr     uint64 // RMB virtual address
+	reserved2      byte
+	PSN            int // packet sequence number
+	EID            EID // EID
+	reserved3      [8]byte
+
+	// First Contact Extension; only present if first contact flag set
+	reserved4 byte
+	OSType    OSType // 4 bits
+	Release   uint8  // 4 bits
+	reserved5 [2]byte
+	Hostname  EID // hostname has same format as EID
+
+	// SMCv2.1 First Contact Extension fields; only present if Release >= 1
+	MaxConns    uint8       // maximum number of connections per lgr
+	MaxLinks    uint8       // maximum number of links per lgr
+	FeatureMask FeatureMask // negotiated SMCv2.1 features
+	reserved6   [4]byte     // vendor specific options
+	reserved7   [8]byte
+
+	// RoCEv2 GID List Extension; only present in confirm messages with
+	// first contact extension
+	GIDCnt    uint8 // number of GIDs in GID list
+	reserved8 [3]byte
+	GIDList   []net.IP // RoCEv2 GIDs
+
+	Trailer
+}
+
+// hasFCE checks if the message contains a first contact extension
+func (ac *AcceptSMCRv2) hasFCE() bool {
+	return ac.Length >= AcceptSMCRv2FCELen
+}
+
+// hasFCEv2x checks if the message contains the SMCv2.1 fields in the first
+// contact extension
+func (ac *AcceptSMCRv2) hasFCEv2x() bool {
+	return ac.hasFCE() && ac.Release >= SMCRelease1 &&
+		ac.Length >= AcceptSMCRv2FCELen+FCEv2xLen
+}
+
+// hasGIDList checks if the message contains a RoCEv2 GID list extension. It
+// follows the SMCv2.1 fields of the FCE if Release >= 1
+func (ac *AcceptSMCRv2) hasGIDList() bool {
+	fceEnd := AcceptSMCRv2FCELen
+	if ac.Release >= SMCRelease1 {
+		fceEnd += FCEv2xLen
+	}
+	return ac.hasFCE() && int(ac.Length) >= fceEnd+GIDListExtLen
+}
+
+// fceString converts the FCE in the SMCv2 CLC SMC-R Accept message to a string
+func (ac *AcceptSMCRv2) fceString() string {
+	if !ac.hasFCE() {
+		return ""
+	}
+	fceFmt := ", OS Type: %s, Release: %d, Hostname: %s"
+	fce := fmt.Sprintf(fceFmt, ac.OSType, ac.Release, &ac.Hostname)
+	if !ac.hasFCEv2x() {
+		return fce
+	}
+	v2xFmt := ", Max Connections: %d, Max Links: %d, Feature Mask: %s"
+	return fce + fmt.Sprintf(v2xFmt, ac.MaxConns, ac.MaxLinks,
+		ac.FeatureMask)
+}
+
+// gidListString converts the RoCEv2 GID list in the SMCv2 CLC SMC-R Accept
+// message to a string
+func (ac *AcceptSMCRv2) gidListString() string {
+	gids := ""
+	for i, gid := range ac.GIDList {
+		if i > 0 {
+			gids += ", "
+		}
+		gids += fmt.Sprintf("GID %d: %s", i, gid)
+	}
+	return gids
+}
+
+// gidListExtString converts the RoCEv2 GID list extension in the SMCv2 CLC
+// SMC-R Accept message to a string
+func (ac *AcceptSMCRv2) gidListExtString() string {
+	if !ac.hasGIDList() {
+		return ""
+	}
+	extFmt := ", GID Count: %d, GID List: [%s]"
+	return fmt.Sprintf(extFmt, ac.GIDCnt, ac.gidListString())
+}
+
+// String converts the SMCv2 CLC SMC-R Accept message to a string
+func (ac *AcceptSMCRv2) String() string {
+	if ac == nil {
+		return "n/a"
+	}
+
+	acFmt := "%s, Peer ID: %s, SMC-R GID: %s, RoCE MAC: %s, " +
+		"QP Number: %d, RMB RKey: %d, RMBE Index: %d, " +
+		"RMBE Alert Token: %d, RMBE Size: %s, QP MTU: %s, " +
+		"RMB Virtual Address: %#x, Packet Sequence Number: %d, " +
+		"EID: %s%s%s, Trailer: %s"
+	return fmt.Sprintf(acFmt, ac.Header.String(), ac.SenderPeerID,
+		ac.IBGID, ac.IBMAC, ac.QPN, ac.RMBRKey, ac.RMBEIdx,
+		ac.RMBEAlertToken, ac.RMBESize, ac.QPMTU, ac.RMBDMAAddr,
+		ac.PSN, &ac.EID, ac.fceString(), ac.gidListExtString(),
+		ac.Trailer)
+}
+
+// fceReserved converts the FCE in the SMCv2 CLC SMC-R Accept message to a
+// string including reserved message fields
+func (ac *AcceptSMCRv2) fceReserved() string {
+	if !ac.hasFCE() {
+		return ""
+	}
+	fceFmt := ", Reserved: %#x, OS Type: %s, Release: %d, " +
+		"Reserved: %#x, Hostname: %s"
+	fce := fmt.Sprintf(fceFmt, ac.reserved4, ac.OSType, ac.Release,
+		ac.reserved5, &ac.Hostname)
+	if !ac.hasFCEv2x() {
+		return fce
+	}
+	v2xFmt := ", Max Connections: %d, Max Links: %d, Feature Mask: %s, " +
+		"Reserved: %#x, Reserved: %#x"
+	return fce + fmt.Sprintf(v2xFmt, ac.MaxConns, ac.MaxLinks,
+		ac.FeatureMask, ac.reserved6, ac.reserved7)
+}
+
+// gidListExtReserved converts the RoCEv2 GID list extension in the SMCv2 CLC
+// SMC-R Accept message to a string including reserved message fields
+func (ac *AcceptSMCRv2) gidListExtReserved() string {
+	if !ac.hasGIDList() {
+		return ""
+	}
+	extFmt := ", GID Count: %d, Reserved: %#x, GID List: [%s]"
+	return fmt.Sprintf(extFmt, ac.GIDCnt, ac.reserved8,
+		ac.gidListString())
+}
+
+// Reserved converts the SMCv2 CLC SMC-R Accept message to a string including
+// reserved message fields
+func (ac *AcceptSMCRv2) Reserved() string {
+	if ac == nil {
+		return "n/a"
+	}
+
+	acFmt := "%s, Peer ID: %s, SMC-R GID: %s, RoCE MAC: %s, " +
+		"QP Number: %d, RMB RKey: %d, RMBE Index: %d, " +
+		"RMBE Alert Token: %d, RMBE Size: %s, QP MTU: %s, " +
+		"Reserved: %#x, RMB Virtual Address: %#x, " +
+		"Reserved: %#x, Packet Sequence Number: %d, EID: %s, " +
+		"Reserved: %#x%s%s, Trailer: %s"
+	return fmt.Sprintf(acFmt, ac.Header.Reserved(), ac.SenderPeerID,
+		ac.IBGID, ac.IBMAC, ac.QPN, ac.RMBRKey, ac.RMBEIdx,
+		ac.RMBEAlertToken, ac.RMBESize, ac.QPMTU, ac.reserved,
+		ac.RMBDMAAddr, ac.reserved2, ac.PSN, &ac.EID, ac.reserved3,
+		ac.fceReserved(), ac.gidListExtReserved(), ac.Trailer)
+}
+
+// Parse parses the SMCv2 CLC SMC-R Accept message in buf
+func (ac *AcceptSMCRv2) Parse(buf []byte) error {
+	// parse CLC header
+	if err := ac.Header.Parse(buf); err != nil {
+		return err
+	}
+
+	// check if message is long enough
+	buf, err := ac.checkLength(buf, AcceptSMCRv2Len)
+	if err != nil {
+		return err
+	}
+
+	// save raw message bytes
+	ac.Raw.Parse(buf)
+
+	// skip clc header
+	buf = buf[HeaderLen:]
+
+	// sender peer ID
+	copy(ac.SenderPeerID[:], buf[:PeerIDLen])
+	buf = buf[PeerIDLen:]
+
+	// ib GID is an IPv6 Address
+	ac.IBGID = make(net.IP, net.IPv6len)
+	copy(ac.IBGID[:], buf[:net.IPv6len])
+	buf = buf[net.IPv6len:]
+
+	// ib MAC is a 6 byte MAC address
+	ac.IBMAC = make(net.HardwareAddr, 6)
+	copy(ac.IBMAC[:], buf[:6])
+	buf = buf[6:]
+
+	// QP number is 3 bytes
+	ac.QPN = int(buf[0]) << 16
+	ac.QPN |= int(buf[1]) << 8
+	ac.QPN |= int(buf[2])
+	buf = buf[3:]
+
+	// rmb Rkey
+	ac.RMBRKey = binary.BigEndian.Uint32(buf[:4])
+	buf = buf[4:]
+
+	// rmbe Idx
+	ac.RMBEIdx = uint8(buf[0])
+	buf = buf[1:]
+
+	// rmbe alert token
+	ac.RMBEAlertToken = binary.BigEndian.Uint32(buf[:4])
+	buf = buf[4:]
+
+	// 1 byte bitfield: rmbe size (4 bits) and qp mtu (4 bits)
+	ac.RMBESize = RMBESize((uint8(buf[0]) & 0b11110000) >> 4)
+	ac.QPMTU = QPMTU(uint8(buf[0]) & 0b00001111)
+	buf = buf[1:]
+
+	// reserved
+	ac.reserved = buf[0]
+	buf = buf[1:]
+
+	// rmb DMA addr
+	ac.RMBDMAAddr = binary.BigEndian.Uint64(buf[:8])
+	buf = buf[8:]
+
+	// reserved
+	ac.reserved2 = buf[0]
+	buf = buf[1:]
+
+	// Packet Sequence Number is 3 bytes
+	ac.PSN = int(buf[0]) << 16
+	ac.PSN |= int(buf[1]) << 8
+	ac.PSN |= int(buf[2])
+	buf = buf[3:]
+
+	// EID
+	copy(ac.EID[:], buf[:EIDLen])
+	buf = buf[EIDLen:]
+
+	// reserved
+	copy(ac.reserved3[:], buf[:8])
+	buf = buf[8:]
+
+	// parse First Contact Extension (FCE) if present
+	if ac.hasFCE() {
+		// reserved
+		ac.reserved4 = buf[0]
+		buf = buf[1:]
+
+		// OS type (4 bits)
+		ac.OSType = OSType(buf[0] >> 4)
+		// Release (4 bits)
+		ac.Release = buf[0] & 0b00001111
+		buf = buf[1:]
+
+		// reserved
+		copy(ac.reserved5[:], buf[:2])
+		buf = buf[2:]
+
+		// hostname
+		copy(ac.Hostname[:], buf[:EIDLen])
+		buf = buf[EIDLen:]
+	}
+
+	// messages with Release >= 1 and data after the FCE must contain the
+	// SMCv2.1 fields before the RoCEv2 GID List Extension
+	if ac.hasFCE() && ac.Release >= SMCRelease1 && !ac.hasFCEv2x() &&
+		ac.Length >= AcceptSMCRv2FCELen+GIDListExtLen {
+		return ac.parseError(len(ac.Raw)-len(buf), ErrTooShort)
+	}
+
+	// parse SMCv2.1 fields in FCE if present
+	if ac.hasFCEv2x() {
+		// max connections
+		ac.MaxConns = buf[0]
+		buf = buf[1:]
+
+		// max links
+		ac.MaxLinks = buf[0]
+		buf = buf[1:]
+
+		// feature mask
+		ac.FeatureMask = FeatureMask(binary.BigEndian.Uint16(buf[:2]))
+		buf = buf[2:]
+
+		// vendor specific options
+		copy(ac.reserved6[:], buf[:4])
+		buf = buf[4:]
+
+		// reserved
+		copy(ac.reserved7[:], buf[:8])
+		buf = buf[8:]
+	}
+
+	// parse RoCEv2 GID List Extension if present
+	if ac.hasGIDList() {
+		// GID count
+		ac.GIDCnt = buf[0]
+		buf = buf[1:]
+
+		// reserved
+		copy(ac.reserved8[:], buf[:3])
+		buf = buf[3:]
+
+		// GIDs are IPv6 addresses
+		for i := uint8(0); i < ac.GIDCnt; i++ {
+			// make sure we are still inside the clc message
+			if len(buf) < net.IPv6len+TrailerLen {
+				offset := len(ac.Raw) - len(buf)
+				return ac.parseError(offset, ErrCountTooBig)
+			}
+
+			gid := make(net.IP, net.IPv6len)
+			copy(gid[:], buf[:net.IPv6len])
+			ac.GIDList = append(ac.GIDList, gid)
+			buf = buf[net.IPv6len:]
+		}
+	}
+
+	// save trailer
+	if err := ac.Trailer.Parse(ac.Raw); err != nil {
+		return ac.parseError(len(ac.Raw)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the SMCv2 SMC-R Accept message to bytes. The First Contact
+// Extension is only added if the first contact flag is set, its SMCv2.1 fields
+// only if Release >= 1. The RoCEv2 GID List Extension is only added to confirm
+// messages with first contact flag
+func (ac *AcceptSMCRv2) Marshal() ([]byte, error) {
+	// calculate message length
+	length := AcceptSMCRv2Len
+	fceEnd := AcceptSMCRv2FCELen
+	if ac.Flag == 1 {
+		if ac.Release >= SMCRelease1 {
+			fceEnd += FCEv2xLen
+		}
+		length = fceEnd
+		if ac.Type == TypeConfirm {
+			length += GIDListExtLen
+			length += len(ac.GIDList) * net.IPv6len
+		}
+	}
+
+	// write CLC header and trailer
+	buf := make([]byte, length)
+	if err := ac.Header.marshal(buf, length); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	b := buf[HeaderLen:]
+
+	// sender peer ID
+	copy(b[:PeerIDLen], ac.SenderPeerID[:])
+	b = b[PeerIDLen:]
+
+	// ib GID is an IPv6 Address
+	copy(b[:net.IPv6len], ac.IBGID.To16())
+	b = b[net.IPv6len:]
+
+	// ib MAC is a 6 byte MAC address
+	copy(b[:6], ac.IBMAC)
+	b = b[6:]
+
+	// QP number is 3 bytes
+	b[0] = byte(ac.QPN >> 16)
+	b[1] = byte(ac.QPN >> 8)
+	b[2] = byte(ac.QPN)
+	b = b[3:]
+
+	// rmb Rkey
+	binary.BigEndian.PutUint32(b[:4], ac.RMBRKey)
+	b = b[4:]
+
+	// rmbe Idx
+	b[0] = ac.RMBEIdx
+	b = b[1:]
+
+	// rmbe alert token
+	binary.BigEndian.PutUint32(b[:4], ac.RMBEAlertToken)
+	b = b[4:]
+
+	// 1 byte bitfield: rmbe size (4 bits) and qp mtu (4 bits)
+	b[0] = (uint8(ac.RMBESize) & 0b1111) << 4
+	b[0] |= uint8(ac.QPMTU) & 0b1111
+	b = b[1:]
+
+	// reserved
+	b[0] = ac.reserved
+	b = b[1:]
+
+	// rmb DMA addr
+	binary.BigEndian.PutUint64(b[:8], ac.RMBDMAAddr)
+	b = b[8:]
+
+	// reserved
+	b[0] = ac.reserved2
+	b = b[1:]
+
+	// Packet Sequence Number is 3 bytes
+	b[0] = byte(ac.PSN >> 16)
+	b[1] = byte(ac.PSN >> 8)
+	b[2] = byte(ac.PSN)
+	b = b[3:]
+
+	// EID
+	copy(b[:EIDLen], ac.EID[:])
+	b = b[EIDLen:]
+
+	// reserved
+	copy(b[:8], ac.reserved3[:])
+	b = b[8:]
+
+	// First Contact Extension (FCE)
+	if length >= AcceptSMCRv2FCELen {
+		// reserved
+		b[0] = ac.reserved4
+		b = b[1:]
+
+		// OS type (4 bits), Release (4 bits)
+		b[0] = (uint8(ac.OSType) & 0b1111) << 4
+		b[0] |= ac.Release & 0b1111
+		b = b[1:]
+
+		// reserved
+		copy(b[:2], ac.reserved5[:])
+		b = b[2:]
+
+		// hostname
+		copy(b[:EIDLen], ac.Hostname[:])
+		b = b[EIDLen:]
+	}
+
+	// SMCv2.1 fields in FCE
+	if fceEnd > AcceptSMCRv2FCELen {
+		// max connections
+		b[0] = ac.MaxConns
+		b = b[1:]
+
+		// max links
+		b[0] = ac.MaxLinks
+		b = b[1:]
+
+		// feature mask
+		binary.BigEndian.PutUint16(b[:2], uint16(ac.FeatureMask))
+		b = b[2:]
+
+		// vendor specific options
+		copy(b[:4], ac.reserved6[:])
+		b = b[4:]
+
+		// reserved
+		copy(b[:8], ac.reserved7[:])
+		b = b[8:]
+	}
+
+	// RoCEv2 GID List Extension
+	if length >= fceEnd+GIDListExtLen {
+		// GID count
+		b[0] = uint8(len(ac.GIDList))
+		b = b[1:]
+
+		// reserved
+		copy(b[:3], ac.reserved8[:])
+		b = b[3:]
+
+		// GIDs
+		for _, gid := range ac.GIDList {
+			copy(b[:net.IPv6len], gid.To16())
+			b = b[net.IPv6len:]
+		}
+	}
+
+	return buf, nil
+}
+
+// jsonGIDList stores the RoCEv2 GID list extension in JSON
+type jsonGIDList struct {
+	GIDCnt  uint8    `json:"gid_cnt"`
+	GIDList []string `json:"gid_list"`
+}
+
+// jsonAcceptSMCRv2 stores the SMCv2 CLC SMC-R Accept message in JSON
+type jsonAcceptSMCRv2 struct {
+	jsonMessage
+	SenderPeerID   PeerID       `json:"sender_peer_id"`
+	IBGID          string       `json:"ib_gid"`
+	IBMAC          string       `json:"ib_mac"`
+	QPN            int          `json:"qp_number"`
+	RMBRKey        uint32       `json:"rmb_rkey"`
+	RMBEIdx        uint8        `json:"rmbe_idx"`
+	RMBEAlertToken uint32       `json:"rmbe_alert_token"`
+	RMBESize       RMBESize     `json:"rmbe_size"`
+	QPMTU          QPMTU        `json:"qp_mtu"`
+	RMBDMAAddr     uint64       `json:"rmb_dma_addr"`
+	PSN            int          `json:"psn"`
+	EID            EID          `json:"eid"`
+	FCE            *jsonFCE     `json:"fce,omitempty"`
+	GIDList        *jsonGIDList `json:"gid_list_ext,omitempty"`
+}
+
+// marshalJSON converts the SMCv2 CLC SMC-R Accept message to JSON
+func (ac *AcceptSMCRv2) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonAcceptSMCRv2{
+		jsonMessage:    newJSONMessage(&ac.Header, ac.Trailer, reserved),
+		SenderPeerID:   ac.SenderPeerID,
+		IBGID:          jsonIP(ac.IBGID),
+		IBMAC:          ac.IBMAC.String(),
+		QPN:            ac.QPN,
+		RMBRKey:        ac.RMBRKey,
+		RMBEIdx:        ac.RMBEIdx,
+		RMBEAlertToken: ac.RMBEAlertToken,
+		RMBESize:       ac.RMBESize,
+		QPMTU:          ac.QPMTU,
+		RMBDMAAddr:     ac.RMBDMAAddr,
+		PSN:            ac.PSN,
+		EID:            ac.EID,
+	}
+	j.Reserved.add("reserved", ac.reserved)
+	j.Reserved.add("reserved2", ac.reserved2)
+	j.Reserved.add("reserved3", ac.reserved3)
+
+	// first contact extension
+	if ac.hasFCE() {
+		j.FCE = newJSONFCE(ac.OSType, ac.Release, ac.Hostname,
+			ac.hasFCEv2x(), ac.MaxConns, ac.MaxLinks,
+			ac.FeatureMask)
+		j.Reserved.add("reserved4", ac.reserved4)
+		j.Reserved.add("reserved5", ac.reserved5)
+	}
+	if ac.hasFCEv2x() {
+		j.Reserved.add("reserved6", ac.reserved6)
+		j.Reserved.add("reserved7", ac.reserved7)
+	}
+
+	// gid list extension
+	if ac.hasGIDList() {
+		j.GIDList = &jsonGIDList{GIDCnt: ac.GIDCnt, GIDList: []string{}}
+		for _, gid := range ac.GIDList {
+			j.GIDList.GIDList = append(j.GIDList.GIDList, gid.String())
+		}
+		j.Reserved.add("reserved8", ac.reserved8)
+	}
+
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the SMCv2 CLC SMC-R Accept message to JSON
+func (ac *AcceptSMCRv2) MarshalJSON() ([]byte, error) {
+	return ac.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the SMCv2 CLC SMC-R Accept message
+func (ac *AcceptSMCRv2) UnmarshalJSON(data []byte) error {
+	var j jsonAcceptSMCRv2
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&ac.Header, &ac.Trailer); err != nil {
+		return err
+	}
+	ibGID, err := parseJSONIP(j.IBGID)
+	if err != nil {
+		return err
+	}
+	ibMAC, err := parseJSONMAC(j.IBMAC)
+	if err != nil {
+		return err
+	}
+	ac.SenderPeerID = j.SenderPeerID
+	ac.IBGID = ibGID
+	ac.IBMAC = ibMAC
+	ac.QPN = j.QPN
+	ac.RMBRKey = j.RMBRKey
+	ac.RMBEIdx = j.RMBEIdx
+	ac.RMBEAlertToken = j.RMBEAlertToken
+	ac.RMBESize = j.RMBESize
+	ac.QPMTU = j.QPMTU
+	ac.RMBDMAAddr = j.RMBDMAAddr
+	ac.PSN = j.PSN
+	ac.EID = j.EID
+
+	// first contact extension
+	if j.FCE != nil {
+		err := j.FCE.toFCE(&ac.OSType, &ac.Release, &ac.Hostname,
+			&ac.MaxConns, &ac.MaxLinks, &ac.FeatureMask)
+		if err != nil {
+			return err
+		}
+	}
+
+	// gid list extension
+	if j.GIDList != nil {
+		ac.GIDCnt = j.GIDList.GIDCnt
+		ac.GIDList = nil
+		for _, s := range j.GIDList.GIDList {
+			gid, err := parseJSONIP(s)
+			if err != nil {
+				return err
+			}
+			ac.GIDList = append(ac.GIDList, gid)
+		}
+	}
+
+	for _, r := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"reserved", &ac.reserved},
+		{"reserved2", &ac.reserved2},
+		{"reserved3", ac.reserved3[:]},
+		{"reserved4", &ac.reserved4},
+		{"reserved5", ac.reserved5[:]},
+		{"reserved6", ac.reserved6[:]},
+		{"reserved7", ac.reserved7[:]},
+		{"reserved8", ac.reserved8[:]},
+	} {
+		if err := j.Reserved.get(r.name, r.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package clc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"testing"
+)
+
+func TestParseSMCRv2Accept(t *testing.T) {
+	// prepare message
+	msgBytes := "e2d4c3d9" + "02" + "006c" + "2" + "0" + "b1a098039babcdef" +
+		"fe800000000000009a039bfffeabcdef" +
+		"98039babcdef" + "0000e4" + "0000157d" + "01" + "00000005" +
+		"2" + "3" + "00" + "00000000f0a60000" + "00" + "72f5fe" +
+		"546869734973534d4376324549443031" +
+		"00000000000000000000000000000000" +
+		"0000000000000000" +
+		"e2d4c3d9"
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check message length
+	if clcLen != AcceptSMCRv2Len {
+		t.Errorf("clcLen = %d; want %d", clcLen, AcceptSMCRv2Len)
+	}
+
+	// check output message without reserved fields
+	want := "Accept: Eyecatcher: SMC-R, Type: 2 (Accept), " +
+		"Length: 108, Version: 2, First Contact: 0, Path: SMC-R, " +
+		"Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 228, " +
+		"RMB RKey: 5501, RMBE Index: 1, RMBE Alert Token: 5, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), " +
+		"RMB Virtual Address: 0xf0a60000, " +
+		"Packet Sequence Number: 7534078, EID: ThisIsSMCv2EID01, " +
+		"Trailer: SMC-R"
+	got := clc.String()
+	if got != want {
+		t.Errorf("clc.String() = %s; want %s", got, want)
+	}
+
+	// check output message with reserved fields
+	want = "Accept: Eyecatcher: SMC-R, Type: 2 (Accept), " +
+		"Length: 108, Version: 2, First Contact: 0, Reserved: 0x0, " +
+		"Path: SMC-R, Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 228, " +
+		"RMB RKey: 5501, RMBE Index: 1, RMBE Alert Token: 5, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), Reserved: 0x0, " +
+		"RMB Virtual Address: 0xf0a60000, Reserved: 0x0, " +
+		"Packet Sequence Number: 7534078, EID: ThisIsSMCv2EID01, " +
+		"Reserved: 0x0000000000000000, Trailer: SMC-R"
+	got = clc.Reserved()
+	if got != want {
+		t.Errorf("clc.Reserved() = %s; want %s", got, want)
+	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
+}
+
+func TestParseSMCRv2FCEAccept(t *testing.T) {
+	// prepare message
+	msgBytes := "e2d4c3d9" + "02" + "0090" + "2" + "8" + "b1a098039babcdef" +
+		"fe800000000000009a039bfffeabcdef" +
+		"98039babcdef" + "0000e4" + "0000157d" + "01" + "00000005" +
+		"2" + "3" + "00" + "00000000f0a60000" + "00" + "72f5fe" +
+		"546869734973534d4376324549443031" +
+		"00000000000000000000000000000000" +
+		"0000000000000000" +
+		// fce
+		"00" + "2" + "0" + "0000" +
+		"546869734973486f73746e616d653031" +
+		"00000000000000000000000000000000" +
+		"e2d4c3d9"
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check message length
+	if clcLen != AcceptSMCRv2FCELen {
+		t.Errorf("clcLen = %d; want %d", clcLen, AcceptSMCRv2FCELen)
+	}
+
+	// check output message without reserved fields
+	want := "Accept: Eyecatcher: SMC-R, Type: 2 (Accept), " +
+		"Length: 144, Version: 2, First Contact: 1, Path: SMC-R, " +
+		"Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 228, " +
+		"RMB RKey: 5501, RMBE Index: 1, RMBE Alert Token: 5, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), " +
+		"RMB Virtual Address: 0xf0a60000, " +
+		"Packet Sequence Number: 7534078, EID: ThisIsSMCv2EID01, " +
+		"OS Type: 2 (Linux), Release: 0, " +
+		"Hostname: ThisIsHostname01, Trailer: SMC-R"
+	got := clc.String()
+	if got != want {
+		t.Errorf("clc.String() = %s; want %s", got, want)
+	}
+
+	// check output message with reserved fields
+	want = "Accept: Eyecatcher: SMC-R, Type: 2 (Accept), " +
+		"Length: 144, Version: 2, First Contact: 1, Reserved: 0x0, " +
+		"Path: SMC-R, Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 228, " +
+		"RMB RKey: 5501, RMBE Index: 1, RMBE Alert Token: 5, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), Reserved: 0x0, " +
+		"RMB Virtual Address: 0xf0a60000, Reserved: 0x0, " +
+		"Packet Sequence Number: 7534078, EID: ThisIsSMCv2EID01, " +
+		"Reserved: 0x0000000000000000, Reserved: 0x0, " +
+		"OS Type: 2 (Linux), Release: 0, Reserved: 0x0000, " +
+		"Hostname: ThisIsHostname01, Trailer: SMC-R"
+	got = clc.Reserved()
+	if got != want {
+		t.Errorf("clc.Reserved() = %s; want %s", got, want)
+	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
+}
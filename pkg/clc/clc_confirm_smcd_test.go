@@ -1,6 +1,7 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 	"testing"
@@ -17,8 +18,13 @@ func TestParseSMCDConfirm(t *testing.T) {
 	}
 
 	// parse message
-	ac, acLen := NewMessage(msg)
-	ac.Parse(msg)
+	ac, acLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if acLen != 48 {
@@ -53,4 +59,13 @@ func TestParseSMCDConfirm(t *testing.T) {
 	if got != want {
 		t.Errorf("ac.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := ac.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("ac.Marshal() = %x; want %x", b, msg)
+	}
 }
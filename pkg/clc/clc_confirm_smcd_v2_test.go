@@ -1,6 +1,7 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 	"testing"
@@ -21,8 +22,13 @@ func TestParseSMCDv2Confirm(t *testing.T) {
 	}
 
 	// parse message
-	clc, clcLen := NewMessage(msg)
-	clc.Parse(msg)
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if clcLen != AcceptSMCDv2Len {
@@ -55,6 +61,15 @@ func TestParseSMCDv2Confirm(t *testing.T) {
 	if got != want {
 		t.Errorf("clc.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
 }
 
 func TestParseSMCDv2FCEConfirm(t *testing.T) {
@@ -76,8 +91,13 @@ func TestParseSMCDv2FCEConfirm(t *testing.T) {
 	}
 
 	// parse message
-	clc, clcLen := NewMessage(msg)
-	clc.Parse(msg)
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if clcLen != AcceptSMCDv2FCELen {
@@ -113,4 +133,13 @@ func TestParseSMCDv2FCEConfirm(t *testing.T) {
 	if got != want {
 		t.Errorf("clc.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
 }
@@ -1,6 +1,7 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 	"testing"
@@ -19,8 +20,13 @@ func TestParseSMCRConfirm(t *testing.T) {
 	}
 
 	// parse message
-	ac, acLen := NewMessage(msg)
-	ac.Parse(msg)
+	ac, acLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if acLen != 68 {
@@ -61,4 +67,13 @@ func TestParseSMCRConfirm(t *testing.T) {
 	if got != want {
 		t.Errorf("ac.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := ac.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("ac.Marshal() = %x; want %x", b, msg)
+	}
 }
@@ -0,0 +1,7 @@
+package clc
+
+// ConfirmSMCRv2 stores a SMCv2 CLC SMC-R Confirm message
+type ConfirmSMCRv2 struct {
+	// accept and confirm message have the same message fields
+	AcceptSMCRv2
+}
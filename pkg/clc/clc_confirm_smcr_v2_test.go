@@ -0,0 +1,226 @@
+package clc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"testing"
+)
+
+func TestParseSMCRv2Confirm(t *testing.T) {
+	// prepare message
+	msgBytes := "e2d4c3d9" + "03" + "006c" + "2" + "0" + "b1a098039babcdef" +
+		"fe800000000000009a039bfffeabcdef" +
+		"98039babcdef" + "0000e5" + "0000187f" + "01" + "00000006" +
+		"2" + "3" + "00" + "00000000f0a40000" + "00" + "0d89a4" +
+		"546869734973534d4376324549443031" +
+		"00000000000000000000000000000000" +
+		"0000000000000000" +
+		"e2d4c3d9"
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check message length
+	if clcLen != AcceptSMCRv2Len {
+		t.Errorf("clcLen = %d; want %d", clcLen, AcceptSMCRv2Len)
+	}
+
+	// check output message without reserved fields
+	want := "Confirm: Eyecatcher: SMC-R, Type: 3 (Confirm), " +
+		"Length: 108, Version: 2, First Contact: 0, Path: SMC-R, " +
+		"Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 229, " +
+		"RMB RKey: 6271, RMBE Index: 1, RMBE Alert Token: 6, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), " +
+		"RMB Virtual Address: 0xf0a40000, " +
+		"Packet Sequence Number: 887204, EID: ThisIsSMCv2EID01, " +
+		"Trailer: SMC-R"
+	got := clc.String()
+	if got != want {
+		t.Errorf("clc.String() = %s; want %s", got, want)
+	}
+
+	// check output message with reserved fields
+	want = "Confirm: Eyecatcher: SMC-R, Type: 3 (Confirm), " +
+		"Length: 108, Version: 2, First Contact: 0, Reserved: 0x0, " +
+		"Path: SMC-R, Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 229, " +
+		"RMB RKey: 6271, RMBE Index: 1, RMBE Alert Token: 6, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), Reserved: 0x0, " +
+		"RMB Virtual Address: 0xf0a40000, Reserved: 0x0, " +
+		"Packet Sequence Number: 887204, EID: ThisIsSMCv2EID01, " +
+		"Reserved: 0x0000000000000000, Trailer: SMC-R"
+	got = clc.Reserved()
+	if got != want {
+		t.Errorf("clc.Reserved() = %s; want %s", got, want)
+	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
+}
+
+func TestParseSMCRv2FCEConfirm(t *testing.T) {
+	// prepare message
+	msgBytes := "e2d4c3d9" + "03" + "00b4" + "2" + "8" + "b1a098039babcdef" +
+		"fe800000000000009a039bfffeabcdef" +
+		"98039babcdef" + "0000e5" + "0000187f" + "01" + "00000006" +
+		"2" + "3" + "00" + "00000000f0a40000" + "00" + "0d89a4" +
+		"546869734973534d4376324549443031" +
+		"00000000000000000000000000000000" +
+		"0000000000000000" +
+		// fce
+		"00" + "2" + "0" + "0000" +
+		"546869734973486f73746e616d653031" +
+		"00000000000000000000000000000000" +
+		// gid list
+		"02" + "000000" +
+		"fd000000000000000000000000000001" +
+		"fd000000000000000000000000000002" +
+		"e2d4c3d9"
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	clc, clcLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check message length
+	if clcLen != 180 {
+		t.Errorf("clcLen = %d; want %d", clcLen, 180)
+	}
+
+	// check output message without reserved fields
+	want := "Confirm: Eyecatcher: SMC-R, Type: 3 (Confirm), " +
+		"Length: 180, Version: 2, First Contact: 1, Path: SMC-R, " +
+		"Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 229, " +
+		"RMB RKey: 6271, RMBE Index: 1, RMBE Alert Token: 6, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), " +
+		"RMB Virtual Address: 0xf0a40000, " +
+		"Packet Sequence Number: 887204, EID: ThisIsSMCv2EID01, " +
+		"OS Type: 2 (Linux), Release: 0, " +
+		"Hostname: ThisIsHostname01, GID Count: 2, " +
+		"GID List: [GID 0: fd00::1, GID 1: fd00::2], Trailer: SMC-R"
+	got := clc.String()
+	if got != want {
+		t.Errorf("clc.String() = %s; want %s", got, want)
+	}
+
+	// check output message with reserved fields
+	want = "Confirm: Eyecatcher: SMC-R, Type: 3 (Confirm), " +
+		"Length: 180, Version: 2, First Contact: 1, Reserved: 0x0, " +
+		"Path: SMC-R, Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 229, " +
+		"RMB RKey: 6271, RMBE Index: 1, RMBE Alert Token: 6, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), Reserved: 0x0, " +
+		"RMB Virtual Address: 0xf0a40000, Reserved: 0x0, " +
+		"Packet Sequence Number: 887204, EID: ThisIsSMCv2EID01, " +
+		"Reserved: 0x0000000000000000, Reserved: 0x0, " +
+		"OS Type: 2 (Linux), Release: 0, Reserved: 0x0000, " +
+		"Hostname: ThisIsHostname01, GID Count: 2, " +
+		"Reserved: 0x000000, " +
+		"GID List: [GID 0: fd00::1, GID 1: fd00::2], Trailer: SMC-R"
+	got = clc.Reserved()
+	if got != want {
+		t.Errorf("clc.Reserved() = %s; want %s", got, want)
+	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
+}
+
+func TestParseSMCRv21FCEConfirm(t *testing.T) {
+	// prepare message with SMCv2.1 FCE
+	msgBytes := "e2d4c3d9" + "03" + "00b4" + "2" + "8" + "b1a098039babcdef" +
+		"fe800000000000009a039bfffeabcdef" +
+		"98039babcdef" + "0000e5" + "0000187f" + "01" + "00000006" +
+		"2" + "3" + "00" + "00000000f0a40000" + "00" + "0d89a4" +
+		"546869734973534d4376324549443031" +
+		"00000000000000000000000000000000" +
+		"0000000000000000" +
+		// fce
+		"00" + "2" + "1" + "0000" +
+		"546869734973486f73746e616d653031" +
+		"00000000000000000000000000000000" +
+		// fce v2.1
+		"ff" + "02" + "0000" + "00000000" + "0000000000000000" +
+		// gid list
+		"01" + "000000" +
+		"fd000000000000000000000000000001" +
+		"e2d4c3d9"
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	clc, _, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clc.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check output message without reserved fields
+	want := "Confirm: Eyecatcher: SMC-R, Type: 3 (Confirm), " +
+		"Length: 180, Version: 2, First Contact: 1, Path: SMC-R, " +
+		"Peer ID: 45472@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, QP Number: 229, " +
+		"RMB RKey: 6271, RMBE Index: 1, RMBE Alert Token: 6, " +
+		"RMBE Size: 2 (65536), QP MTU: 3 (1024), " +
+		"RMB Virtual Address: 0xf0a40000, " +
+		"Packet Sequence Number: 887204, EID: ThisIsSMCv2EID01, " +
+		"OS Type: 2 (Linux), Release: 1, " +
+		"Hostname: ThisIsHostname01, Max Connections: 255, " +
+		"Max Links: 2, Feature Mask: 0x0 (none), GID Count: 1, " +
+		"GID List: [GID 0: fd00::1], Trailer: SMC-R"
+	got := clc.String()
+	if got != want {
+		t.Errorf("clc.String() = %s; want %s", got, want)
+	}
+
+	// check marshaled message
+	b, err := clc.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("clc.Marshal() = %x; want %x", b, msg)
+	}
+}
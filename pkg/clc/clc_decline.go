@@ -2,8 +2,8 @@ package clc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 )
 
 const (
@@ -27,6 +27,7 @@ const (
 	DeclineNoV2DExt   = 0x03030005 // peer sent no clc SMC-Dv2 ext.
 	DeclineNoSEID     = 0x03030006 // peer sent no SEID
 	DeclineNoSMCD2Dev = 0x03030007 // no SMC-Dv2 device found
+	DeclineNoUEID     = 0x03030008 // peer sent no UEID
 	DeclineModeUnsupp = 0x03040000 // smc modes do not match (R or D)
 	DeclineRMBEEyeC   = 0x03050000 // peer has eyecatcher in RMBE
 	DeclineOptUnsupp  = 0x03060000 // fastopen sockopt not supported
@@ -48,9 +49,8 @@ const (
 // PeerDiagnosis stores the decline diagnosis code in a decline message
 type PeerDiagnosis uint32
 
-// String converts the peerDiagnosis to a string
-func (p PeerDiagnosis) String() string {
-	// parse peer diagnosis code
+// description returns the description of the peer diagnosis code
+func (p PeerDiagnosis) description() string {
 	var diag string
 	switch p {
 	case DeclineMem:
@@ -81,6 +81,8 @@ func (p PeerDiagnosis) String() string {
 		diag = "peer sent no SEID"
 	case DeclineNoSMCD2Dev:
 		diag = "no SMC-Dv2 device found"
+	case DeclineNoUEID:
+		diag = "peer sent no UEID"
 	case DeclineModeUnsupp:
 		diag = "smc modes do not match (R or D)"
 	case DeclineRMBEEyeC:
@@ -116,7 +118,12 @@ func (p PeerDiagnosis) String() string {
 	default:
 		diag = "Unknown"
 	}
-	return fmt.Sprintf("%#x (%s)", uint32(p), diag)
+	return diag
+}
+
+// String converts the peerDiagnosis to a string
+func (p PeerDiagnosis) String() string {
+	return fmt.Sprintf("%#x (%s)", uint32(p), p.description())
 }
 
 // Decline stores a CLC Decline message
@@ -155,20 +162,21 @@ func (d *Decline) Reserved() string {
 }
 
 // Parse parses the CLC Decline in buf
-func (d *Decline) Parse(buf []byte) {
-	// save raw message bytes
-	d.Raw.Parse(buf)
-
+func (d *Decline) Parse(buf []byte) error {
 	// parse CLC header
-	d.Header.Parse(buf)
+	if err := d.Header.Parse(buf); err != nil {
+		return err
+	}
 
 	// check if message is long enough
-	if d.Length < DeclineLen {
-		log.Println("Error parsing CLC Decline: message too short")
-		errDump(buf[:d.Length])
-		return
+	buf, err := d.checkLength(buf, DeclineLen)
+	if err != nil {
+		return err
 	}
 
+	// save raw message bytes
+	d.Raw.Parse(buf)
+
 	// skip clc header
 	buf = buf[HeaderLen:]
 
@@ -185,5 +193,71 @@ func (d *Decline) Parse(buf []byte) {
 	buf = buf[4:]
 
 	// save trailer
-	d.Trailer.Parse(buf)
+	if err := d.Trailer.Parse(d.Raw); err != nil {
+		return d.parseError(len(d.Raw)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the CLC Decline message to bytes
+func (d *Decline) Marshal() ([]byte, error) {
+	// write CLC header and trailer
+	buf := make([]byte, DeclineLen)
+	if err := d.Header.marshal(buf, DeclineLen); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	b := buf[HeaderLen:]
+
+	// sender peer ID
+	copy(b[:PeerIDLen], d.SenderPeerID[:])
+	b = b[PeerIDLen:]
+
+	// peer diagnosis
+	binary.BigEndian.PutUint32(b[:4], uint32(d.PeerDiagnosis))
+	b = b[4:]
+
+	// reserved
+	copy(b[:4], d.reserved[:])
+
+	return buf, nil
+}
+
+// jsonDecline stores the CLC Decline message in JSON
+type jsonDecline struct {
+	jsonMessage
+	SenderPeerID  PeerID        `json:"sender_peer_id"`
+	PeerDiagnosis PeerDiagnosis `json:"peer_diagnosis"`
+}
+
+// marshalJSON converts the CLC Decline message to JSON
+func (d *Decline) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonDecline{
+		jsonMessage:   newJSONMessage(&d.Header, d.Trailer, reserved),
+		SenderPeerID:  d.SenderPeerID,
+		PeerDiagnosis: d.PeerDiagnosis,
+	}
+	j.Reserved.add("reserved", d.reserved)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the CLC Decline message to JSON
+func (d *Decline) MarshalJSON() ([]byte, error) {
+	return d.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the CLC Decline message
+func (d *Decline) UnmarshalJSON(data []byte) error {
+	var j jsonDecline
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&d.Header, &d.Trailer); err != nil {
+		return err
+	}
+	d.SenderPeerID = j.SenderPeerID
+	d.PeerDiagnosis = j.PeerDiagnosis
+	return j.Reserved.get("reserved", d.reserved[:])
 }
@@ -1,6 +1,7 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
 	"testing"
@@ -16,8 +17,13 @@ func TestParseCLCDecline(t *testing.T) {
 	}
 
 	// parse message
-	decline, declineLen := NewMessage(msg)
-	decline.Parse(msg)
+	decline, declineLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decline.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if declineLen != 28 {
@@ -48,4 +54,13 @@ func TestParseCLCDecline(t *testing.T) {
 	if got != want {
 		t.Errorf("decline.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := decline.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("decline.Marshal() = %x; want %x", b, msg)
+	}
 }
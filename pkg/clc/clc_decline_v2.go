@@ -2,8 +2,8 @@ package clc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 )
 
 // clc operating system types
@@ -70,20 +70,21 @@ func (d *DeclineV2) Reserved() string {
 }
 
 // Parse parses the SMCv2 CLC Decline message in buf
-func (d *DeclineV2) Parse(buf []byte) {
-	// save raw message bytes
-	d.Raw.Parse(buf)
-
+func (d *DeclineV2) Parse(buf []byte) error {
 	// parse CLC header
-	d.Header.Parse(buf)
+	if err := d.Header.Parse(buf); err != nil {
+		return err
+	}
 
 	// check if message is long enough
-	if d.Length < DeclineLen {
-		log.Println("Error parsing CLC Decline: message too short")
-		errDump(buf[:d.Length])
-		return
+	buf, err := d.checkLength(buf, DeclineLen)
+	if err != nil {
+		return err
 	}
 
+	// save raw message bytes
+	d.Raw.Parse(buf)
+
 	// skip clc header
 	buf = buf[HeaderLen:]
 
@@ -104,5 +105,79 @@ func (d *DeclineV2) Parse(buf []byte) {
 	buf = buf[4:]
 
 	// save trailer
-	d.Trailer.Parse(buf)
+	if err := d.Trailer.Parse(d.Raw); err != nil {
+		return d.parseError(len(d.Raw)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the SMCv2 CLC Decline message to bytes
+func (d *DeclineV2) Marshal() ([]byte, error) {
+	// write CLC header and trailer
+	buf := make([]byte, DeclineLen)
+	if err := d.Header.marshal(buf, DeclineLen); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	b := buf[HeaderLen:]
+
+	// sender peer ID
+	copy(b[:PeerIDLen], d.SenderPeerID[:])
+	b = b[PeerIDLen:]
+
+	// peer diagnosis
+	binary.BigEndian.PutUint32(b[:4], uint32(d.PeerDiagnosis))
+	b = b[4:]
+
+	// reserved, os type (4 highest bits of first byte of reserved)
+	copy(b[:4], d.reserved[:])
+	b[0] |= (uint8(d.OSType) & 0b1111) << 4
+
+	return buf, nil
+}
+
+// jsonDeclineV2 stores the SMCv2 CLC Decline message in JSON
+type jsonDeclineV2 struct {
+	jsonMessage
+	SenderPeerID  PeerID        `json:"sender_peer_id"`
+	PeerDiagnosis PeerDiagnosis `json:"peer_diagnosis"`
+	OSType        string        `json:"os_type"`
+}
+
+// marshalJSON converts the SMCv2 CLC Decline message to JSON
+func (d *DeclineV2) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonDeclineV2{
+		jsonMessage:   newJSONMessage(&d.Header, d.Trailer, reserved),
+		SenderPeerID:  d.SenderPeerID,
+		PeerDiagnosis: d.PeerDiagnosis,
+		OSType:        d.OSType.String(),
+	}
+	j.Reserved.add("reserved", d.reserved)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the SMCv2 CLC Decline message to JSON
+func (d *DeclineV2) MarshalJSON() ([]byte, error) {
+	return d.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the SMCv2 CLC Decline message
+func (d *DeclineV2) UnmarshalJSON(data []byte) error {
+	var j jsonDeclineV2
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&d.Header, &d.Trailer); err != nil {
+		return err
+	}
+	osType, err := parseJSONOSType(j.OSType)
+	if err != nil {
+		return err
+	}
+	d.SenderPeerID = j.SenderPeerID
+	d.PeerDiagnosis = j.PeerDiagnosis
+	d.OSType = osType
+	return j.Reserved.get("reserved", d.reserved[:])
 }
@@ -2,8 +2,8 @@ package clc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 	"net"
 )
 
@@ -16,13 +16,19 @@ const (
 
 // IPv6Prefix stores a SMC IPv6 Prefix
 type IPv6Prefix struct {
-	prefix    net.IP
-	prefixLen uint8
+	Prefix    net.IP
+	PrefixLen uint8
 }
 
 // String converts ipv6Prefix to a string
 func (p IPv6Prefix) String() string {
-	return fmt.Sprintf("%s/%d", p.prefix, p.prefixLen)
+	return fmt.Sprintf("%s/%d", p.Prefix, p.PrefixLen)
+}
+
+// marshal writes the ipv6 prefix to buf
+func (p IPv6Prefix) marshal(buf []byte) {
+	copy(buf[:net.IPv6len], p.Prefix.To16())
+	buf[net.IPv6len] = p.PrefixLen
 }
 
 // Proposal stores a CLC Proposal message
@@ -103,20 +109,21 @@ func (p *Proposal) Reserved() string {
 }
 
 // Parse parses the CLC Proposal message in buf
-func (p *Proposal) Parse(buf []byte) {
-	// save raw message bytes
-	p.Raw.Parse(buf)
-
+func (p *Proposal) Parse(buf []byte) error {
 	// parse CLC header
-	p.Header.Parse(buf)
+	if err := p.Header.Parse(buf); err != nil {
+		return err
+	}
 
 	// check if message is long enough
-	if p.Length < ProposalLen {
-		log.Println("Error parsing CLC Proposal: message too short")
-		errDump(buf[:p.Length])
-		return
+	buf, err := p.checkLength(buf, ProposalLen)
+	if err != nil {
+		return err
 	}
 
+	// save raw message bytes
+	p.Raw.Parse(buf)
+
 	// skip clc header
 	skip := HeaderLen
 
@@ -153,10 +160,7 @@ func (p *Proposal) Parse(buf []byte) {
 
 	// make sure we do not read outside the message
 	if int(p.Length)-skip < net.IPv4len+1+2+1+TrailerLen {
-		log.Println("Error parsing CLC Proposal: " +
-			"IP Area Offset too big")
-		errDump(buf[:p.Length])
-		return
+		return p.parseError(skip, ErrOffsetOutOfRange)
 	}
 
 	// IP/prefix is an IPv4 address
@@ -182,26 +186,185 @@ func (p *Proposal) Parse(buf []byte) {
 
 		// make sure we are still inside the clc message
 		if int(p.Length)-skip < IPv6PrefixLen+TrailerLen {
-			log.Println("Error parsing CLC Proposal: " +
-				"IPv6 prefix count too big")
-			errDump(buf[:p.Length])
-			break
+			return p.parseError(skip, ErrCountTooBig)
 		}
 		// create new ipv6 prefix entry
 		ip6prefix := IPv6Prefix{}
 
 		// parse prefix and fill prefix entry
-		ip6prefix.prefix = make(net.IP, net.IPv6len)
-		copy(ip6prefix.prefix[:], buf[skip:skip+net.IPv6len])
+		ip6prefix.Prefix = make(net.IP, net.IPv6len)
+		copy(ip6prefix.Prefix[:], buf[skip:skip+net.IPv6len])
 		skip += net.IPv6len
 
 		// parse prefix length and fill prefix entry
-		ip6prefix.prefixLen = uint8(buf[skip])
+		ip6prefix.PrefixLen = uint8(buf[skip])
 
 		// add to ipv6 prefixes
 		p.IPv6Prefixes = append(p.IPv6Prefixes, ip6prefix)
 	}
 
 	// save trailer
-	p.Trailer.Parse(buf)
+	if err := p.Trailer.Parse(buf); err != nil {
+		return p.parseError(len(buf)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the CLC Proposal message to bytes. The IP area offset is
+// computed from the path like the length
+func (p *Proposal) Marshal() ([]byte, error) {
+	// the optional SMC-D info is present if SMC-D is proposed, the ip
+	// area offset is its length
+	ipAreaOffset := uint16(0)
+	if indicated(p.Path, SMCTypeD) {
+		ipAreaOffset = SMCDIPAreaOffset
+	}
+
+	// calculate message length
+	length := HeaderLen + PeerIDLen + net.IPv6len + 6 + 2
+	length += int(ipAreaOffset)
+	length += net.IPv4len + 1 + 2 + 1
+	length += len(p.IPv6Prefixes) * IPv6PrefixLen
+	length += TrailerLen
+
+	// write CLC header and trailer
+	buf := make([]byte, length)
+	if err := p.Header.marshal(buf, length); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	skip := HeaderLen
+
+	// sender peer ID
+	copy(buf[skip:skip+PeerIDLen], p.SenderPeerID[:])
+	skip += PeerIDLen
+
+	// ib GID is an IPv6 address
+	copy(buf[skip:skip+net.IPv6len], p.IBGID.To16())
+	skip += net.IPv6len
+
+	// ib MAC is a 6 byte MAC address
+	copy(buf[skip:skip+6], p.IBMAC)
+	skip += 6
+
+	// offset to ip area
+	binary.BigEndian.PutUint16(buf[skip:skip+2], ipAreaOffset)
+	skip += 2
+
+	// Optional SMC-D info
+	if ipAreaOffset == SMCDIPAreaOffset {
+		// smcd GID
+		binary.BigEndian.PutUint64(buf[skip:skip+8], p.SMCDGID)
+		skip += 8
+
+		// reserved
+		copy(buf[skip:skip+32], p.reserved[:])
+		skip += 32
+	}
+
+	// IP/prefix is an IPv4 address
+	copy(buf[skip:skip+net.IPv4len], p.Prefix.To4())
+	skip += net.IPv4len
+
+	// prefix length
+	buf[skip] = p.PrefixLen
+	skip++
+
+	// reserved
+	copy(buf[skip:skip+2], p.reserved2[:])
+	skip += 2
+
+	// ipv6 prefix count
+	buf[skip] = uint8(len(p.IPv6Prefixes))
+	skip++
+
+	// ipv6 prefixes
+	for _, prefix := range p.IPv6Prefixes {
+		prefix.marshal(buf[skip:])
+		skip += IPv6PrefixLen
+	}
+
+	return buf, nil
+}
+
+// jsonProposal stores the CLC Proposal message in JSON
+type jsonProposal struct {
+	jsonMessage
+	SenderPeerID PeerID     `json:"sender_peer_id"`
+	IBGID        string     `json:"ib_gid"`
+	IBMAC        string     `json:"ib_mac"`
+	IPAreaOffset uint16     `json:"ip_area_offset"`
+	SMCDGID      *uint64    `json:"smcd_gid,omitempty"`
+	IPInfo       jsonIPInfo `json:"ip_info"`
+}
+
+// marshalJSON converts the CLC Proposal message to JSON
+func (p *Proposal) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonProposal{
+		jsonMessage:  newJSONMessage(&p.Header, p.Trailer, reserved),
+		SenderPeerID: p.SenderPeerID,
+		IBGID:        jsonIP(p.IBGID),
+		IBMAC:        p.IBMAC.String(),
+		IPAreaOffset: p.IPAreaOffset,
+		IPInfo: jsonIPInfo{
+			Prefix:          jsonIP(p.Prefix),
+			PrefixLen:       p.PrefixLen,
+			IPv6PrefixesCnt: p.IPv6PrefixesCnt,
+			IPv6Prefixes:    append([]IPv6Prefix{}, p.IPv6Prefixes...),
+		},
+	}
+
+	// optional smc-d info
+	if p.IPAreaOffset == SMCDIPAreaOffset {
+		j.SMCDGID = &p.SMCDGID
+		j.Reserved.add("reserved", p.reserved)
+	}
+	j.Reserved.add("reserved2", p.reserved2)
+
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the CLC Proposal message to JSON
+func (p *Proposal) MarshalJSON() ([]byte, error) {
+	return p.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the CLC Proposal message
+func (p *Proposal) UnmarshalJSON(data []byte) error {
+	var j jsonProposal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&p.Header, &p.Trailer); err != nil {
+		return err
+	}
+	ibGID, err := parseJSONIP(j.IBGID)
+	if err != nil {
+		return err
+	}
+	ibMAC, err := parseJSONMAC(j.IBMAC)
+	if err != nil {
+		return err
+	}
+	prefix, err := parseJSONIP(j.IPInfo.Prefix)
+	if err != nil {
+		return err
+	}
+	p.SenderPeerID = j.SenderPeerID
+	p.IBGID = ibGID
+	p.IBMAC = ibMAC
+	p.IPAreaOffset = j.IPAreaOffset
+	if j.SMCDGID != nil {
+		p.SMCDGID = *j.SMCDGID
+	}
+	p.Prefix = prefix.To4()
+	p.PrefixLen = j.IPInfo.PrefixLen
+	p.IPv6PrefixesCnt = j.IPInfo.IPv6PrefixesCnt
+	p.IPv6Prefixes = j.IPInfo.IPv6Prefixes
+	if err := j.Reserved.get("reserved", p.reserved[:]); err != nil {
+		return err
+	}
+	return j.Reserved.get("reserved2", p.reserved2[:])
 }
@@ -1,8 +1,10 @@
 package clc
 
 import (
+	"bytes"
 	"encoding/hex"
 	"log"
+	"net"
 	"testing"
 )
 
@@ -18,8 +20,13 @@ func TestParseCLCProposalSMCRIPv4(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 52 {
@@ -55,6 +62,15 @@ func TestParseCLCProposalSMCRIPv4(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, msg)
+	}
 }
 
 func TestParseCLCProposalSMCDIPv4(t *testing.T) {
@@ -71,8 +87,13 @@ func TestParseCLCProposalSMCDIPv4(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 92 {
@@ -109,6 +130,15 @@ func TestParseCLCProposalSMCDIPv4(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, msg)
+	}
 }
 
 func TestParseCLCProposalSMCBIPv4(t *testing.T) {
@@ -125,8 +155,13 @@ func TestParseCLCProposalSMCBIPv4(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 92 {
@@ -165,6 +200,15 @@ func TestParseCLCProposalSMCBIPv4(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, msg)
+	}
 }
 
 func TestParseCLCProposalSMCRIPv6(t *testing.T) {
@@ -180,8 +224,13 @@ func TestParseCLCProposalSMCRIPv6(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 69 {
@@ -218,6 +267,15 @@ func TestParseCLCProposalSMCRIPv6(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, msg)
+	}
 }
 
 func TestParseCLCProposalSMCBIPv6(t *testing.T) {
@@ -235,8 +293,13 @@ func TestParseCLCProposalSMCBIPv6(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 109 {
@@ -275,4 +338,46 @@ func TestParseCLCProposalSMCBIPv6(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, msg)
+	}
+}
+
+func TestMarshalProposalOffset(t *testing.T) {
+	// marshal computes the ip area offset from the path
+	for _, test := range []struct {
+		path Path
+		want uint16
+	}{
+		{SMCTypeR, 0},
+		{SMCTypeD, SMCDIPAreaOffset},
+		{SMCTypeB, SMCDIPAreaOffset},
+	} {
+		p := &Proposal{
+			Header:       Header{Type: TypeProposal, Version: SMCv1},
+			IPAreaOffset: 1,
+			Prefix:       net.IPv4(127, 0, 0, 0),
+			PrefixLen:    8,
+		}
+		p.Path = test.path
+		copy(p.Eyecatcher[:], SMCREyecatcher)
+		b, err := p.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Proposal
+		if err := got.Parse(b); err != nil {
+			t.Fatal(err)
+		}
+		if got.IPAreaOffset != test.want {
+			t.Errorf("path %s: IPAreaOffset = %d; want %d",
+				test.path, got.IPAreaOffset, test.want)
+		}
+	}
 }
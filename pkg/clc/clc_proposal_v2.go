@@ -3,8 +3,8 @@ package clc
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
-	"log"
 	"net"
 )
 
@@ -43,6 +43,19 @@ type GIDEntry struct {
 	VCHID uint16
 }
 
+// ISMGID stores the GID of an ISM device including the GID extension of
+// virtual ISM devices (SMCv2.1)
+type ISMGID struct {
+	GID    uint64
+	GIDExt uint64 // only set for virtual ISM devices
+	VCHID  uint16
+}
+
+// IsVirtual checks if the GID belongs to a virtual ISM device
+func (g ISMGID) IsVirtual() bool {
+	return isVirtualCHID(g.VCHID)
+}
+
 // ProposalV2 stores a SMCv2 CLC Proposal message
 type ProposalV2 struct {
 	Raw
@@ -76,12 +89,19 @@ type ProposalV2 struct {
 	SEIDInd   uint8 // SEID indicator (1 bit): 0 not present, 1 present
 	reserved5 [2]byte
 	SMCDv2Off uint16 // SMC-Dv2 Extension Offset (if present)
-	reserved6 [32]byte
-	EIDArea   [8]EID // stores 0-8 EIDs, see EIDNumber
+	reserved6 [16]byte
+
+	// SMCv2.1 fields in Proposal v2 Extension, only valid if Release >= 1
+	MaxConns    uint8       // maximum number of connections per lgr
+	MaxLinks    uint8       // maximum number of links per lgr
+	FeatureMask FeatureMask // supported SMCv2.1 features
+	reserved7   [12]byte
+
+	EIDArea [8]EID // stores 0-8 EIDs, see EIDNumber
 
 	// Optional SMC-Dv2 Extension
 	SEID      EID
-	reserved7 [16]byte
+	reserved8 [16]byte
 	GIDArea   [8]GIDEntry // stores 0-8 GIDs/VCHIDs, see GIDNumber
 
 	Trailer
@@ -136,21 +156,59 @@ func (p *ProposalV2) propV2ExtString() string {
 
 	extFmt := "EID Number: %d, GID Number: %d, Release: %d, " +
 		"SEID Indicator: %d, SMC-Dv2 Extension Offset: %d, " +
-		"EID Area: [%s]"
+		"%sEID Area: [%s]"
 	return fmt.Sprintf(extFmt, p.EIDNumber, p.GIDNumber, p.Release,
-		p.SEIDInd, p.SMCDv2Off, eidArea)
+		p.SEIDInd, p.SMCDv2Off, p.propV2ExtV21String(), eidArea)
+}
+
+// propV2ExtV21String converts the SMCv2.1 fields of the Proposal v2 Extension
+// to a string
+func (p *ProposalV2) propV2ExtV21String() string {
+	if p.Release < SMCRelease1 {
+		return ""
+	}
+
+	v21Fmt := "Max Connections: %d, Max Links: %d, Feature Mask: %s, "
+	return fmt.Sprintf(v21Fmt, p.MaxConns, p.MaxLinks, p.FeatureMask)
+}
+
+// ISMGIDs returns the ISM GIDs in the SMC-D v2 Extension GID Area. With
+// SMCv2.1, a virtual ISM device uses two consecutive GID entries: the GID and
+// the GID extension with the same VCHID
+func (p *ProposalV2) ISMGIDs() []ISMGID {
+	var gids []ISMGID
+	num := int(p.GIDNumber)
+	if num > len(p.GIDArea) {
+		num = len(p.GIDArea)
+	}
+	for i := 0; i < num; i++ {
+		gid := ISMGID{
+			GID:   p.GIDArea[i].GID,
+			VCHID: p.GIDArea[i].VCHID,
+		}
+		if p.Release >= SMCRelease1 && gid.IsVirtual() && i+1 < num {
+			// next entry contains GID extension
+			i++
+			gid.GIDExt = p.GIDArea[i].GID
+		}
+		gids = append(gids, gid)
+	}
+	return gids
 }
 
 // smcdV2ExtString converts the SMC-D v2 Extension GID Area to a string
 func (p *ProposalV2) smcdV2ExtGIDString() string {
 	gidArea := ""
-	for i, gid := range p.GIDArea {
-		if i >= int(p.GIDNumber) {
-			break
-		}
+	for i, gid := range p.ISMGIDs() {
 		if i > 0 {
 			gidArea += ", "
 		}
+		if gid.IsVirtual() && p.Release >= SMCRelease1 {
+			gidArea += fmt.Sprintf("GID %d: %d, GID Extension %d: "+
+				"%d, VCHID %d: %d", i, gid.GID, i, gid.GIDExt,
+				i, gid.VCHID)
+			continue
+		}
 		gidArea += fmt.Sprintf("GID %d: %d, VCHID %d: %d", i, gid.GID,
 			i, gid.VCHID)
 	}
@@ -225,11 +283,29 @@ func (p *ProposalV2) propV2ExtReserved() string {
 
 	extFmt := "EID Number: %d, GID Number: %d, Reserved: %#x, " +
 		"Release: %d, Reserved: %#x, SEID Indicator: %d, " +
-		"Reserved: %#x, SMC-Dv2 Extension Offset: %d, Reserved: %#x, " +
+		"Reserved: %#x, SMC-Dv2 Extension Offset: %d, %s" +
 		"EID Area: [%s]"
 	return fmt.Sprintf(extFmt, p.EIDNumber, p.GIDNumber, p.reserved3,
 		p.Release, p.reserved4, p.SEIDInd, p.reserved5, p.SMCDv2Off,
-		p.reserved6, eidArea)
+		p.propV2ExtV21Reserved(), eidArea)
+}
+
+// propV2ExtV21Reserved converts the SMCv2.1 fields of the Proposal v2
+// Extension to a string including reserved message fields. Before SMCv2.1,
+// all these fields are reserved
+func (p *ProposalV2) propV2ExtV21Reserved() string {
+	if p.Release < SMCRelease1 {
+		reserved := append([]byte{}, p.reserved6[:]...)
+		reserved = append(reserved, p.MaxConns, p.MaxLinks,
+			byte(p.FeatureMask>>8), byte(p.FeatureMask))
+		reserved = append(reserved, p.reserved7[:]...)
+		return fmt.Sprintf("Reserved: %#x, ", reserved)
+	}
+
+	v21Fmt := "Reserved: %#x, Max Connections: %d, Max Links: %d, " +
+		"Feature Mask: %s, Reserved: %#x, "
+	return fmt.Sprintf(v21Fmt, p.reserved6, p.MaxConns, p.MaxLinks,
+		p.FeatureMask, p.reserved7)
 }
 
 // smcdV2ExtReserved converts the SMC-D v2 Extension to a string including
@@ -243,7 +319,7 @@ func (p *ProposalV2) smcdV2ExtReserved() string {
 	gidArea := p.smcdV2ExtGIDString()
 
 	extFmt := "SEID: %s, Reserved: %#x, GID Area: [%s]"
-	return fmt.Sprintf(extFmt, &p.SEID, p.reserved7, gidArea)
+	return fmt.Sprintf(extFmt, &p.SEID, p.reserved8, gidArea)
 }
 
 // Reserved converts the CLC Proposal message to a string including reserved
@@ -280,20 +356,21 @@ func (p *ProposalV2) Reserved() string {
 }
 
 // Parse parses the SMCv2 CLC Proposal message in buf
-func (p *ProposalV2) Parse(buf []byte) {
-	// save raw message bytes
-	p.Raw.Parse(buf)
-
+func (p *ProposalV2) Parse(buf []byte) error {
 	// parse CLC header
-	p.Header.Parse(buf)
+	if err := p.Header.Parse(buf); err != nil {
+		return err
+	}
 
 	// check if message is long enough
-	if p.Length < ProposalV2Len {
-		log.Println("Error parsing CLC Proposal v2: message too short")
-		errDump(buf[:p.Length])
-		return
+	buf, err := p.checkLength(buf, ProposalV2Len)
+	if err != nil {
+		return err
 	}
 
+	// save raw message bytes
+	p.Raw.Parse(buf)
+
 	// skip clc header
 	skip := HeaderLen
 
@@ -335,10 +412,7 @@ func (p *ProposalV2) Parse(buf []byte) {
 	if p.Path != SMCTypeN {
 		// make sure we do not read outside the message
 		if int(p.Length)-skip < net.IPv4len+1+2+1+TrailerLen {
-			log.Println("Error parsing CLC Proposal v2: " +
-				"IP Area Offset too big")
-			errDump(buf[:p.Length])
-			return
+			return p.parseError(skip, ErrOffsetOutOfRange)
 		}
 
 		// IP/prefix is an IPv4 address
@@ -362,21 +436,18 @@ func (p *ProposalV2) Parse(buf []byte) {
 		for i := uint8(0); i < p.IPv6PrefixesCnt; i++ {
 			// make sure we are still inside the clc message
 			if int(p.Length)-skip < IPv6PrefixLen+TrailerLen {
-				log.Println("Error parsing CLC Proposal v2: " +
-					"IPv6 prefix count too big")
-				errDump(buf[:p.Length])
-				break
+				return p.parseError(skip, ErrCountTooBig)
 			}
 			// create new ipv6 prefix entry
 			ip6prefix := IPv6Prefix{}
 
 			// parse prefix and fill prefix entry
-			ip6prefix.prefix = make(net.IP, net.IPv6len)
-			copy(ip6prefix.prefix[:], buf[skip:skip+net.IPv6len])
+			ip6prefix.Prefix = make(net.IP, net.IPv6len)
+			copy(ip6prefix.Prefix[:], buf[skip:skip+net.IPv6len])
 			skip += net.IPv6len
 
 			// parse prefix length and fill prefix entry
-			ip6prefix.prefixLen = uint8(buf[skip])
+			ip6prefix.PrefixLen = uint8(buf[skip])
 			skip++
 
 			// add to ipv6 prefixes
@@ -388,10 +459,7 @@ func (p *ProposalV2) Parse(buf []byte) {
 	if p.Pathv2 != SMCTypeN {
 		// make sure we do not read outside the message
 		if int(p.Length)-skip < ProposalV2ExtLen+TrailerLen {
-			log.Println("Error parsing CLC Proposal v2: " +
-				"Not enough space for Proposal v2 Extension")
-			errDump(buf[:p.Length])
-			return
+			return p.parseError(skip, ErrOffsetOutOfRange)
 		}
 
 		// number of EIDs in EID Area
@@ -423,17 +491,34 @@ func (p *ProposalV2) Parse(buf []byte) {
 		skip += 2
 
 		// reserved
-		copy(p.reserved6[:], buf[skip:skip+32])
-		skip += 32
+		copy(p.reserved6[:], buf[skip:skip+16])
+		skip += 16
 
-		// parse EIDs in EID Area
+		// max connections (SMCv2.1)
+		p.MaxConns = buf[skip]
+		skip++
+
+		// max links (SMCv2.1)
+		p.MaxLinks = buf[skip]
+		skip++
+
+		// feature mask (SMCv2.1)
+		p.FeatureMask = FeatureMask(binary.BigEndian.Uint16(
+			buf[skip : skip+2]))
+		skip += 2
+
+		// reserved
+		copy(p.reserved7[:], buf[skip:skip+12])
+		skip += 12
+
+		// parse EIDs in EID Area, make sure they fit into it
+		if int(p.EIDNumber) > len(p.EIDArea) {
+			return p.parseError(skip, ErrCountTooBig)
+		}
 		for i := uint8(0); i < p.EIDNumber; i++ {
 			// make sure we are still inside the clc message
 			if int(p.Length)-skip < EIDLen+TrailerLen {
-				log.Println("Error parsing CLC Proposal v2: " +
-					"EID number too big")
-				errDump(buf[:p.Length])
-				break
+				return p.parseError(skip, ErrCountTooBig)
 			}
 
 			// parse EID
@@ -446,10 +531,7 @@ func (p *ProposalV2) Parse(buf []byte) {
 	if p.Pathv2 == SMCTypeD || p.Pathv2 == SMCTypeB {
 		// make sure we do not read outside the message
 		if int(p.Length)-skip < SMCDv2ExtLen+TrailerLen {
-			log.Println("Error parsing CLC Proposal v2: " +
-				"Not enough space for SMC-D v2 Extension")
-			errDump(buf[:p.Length])
-			return
+			return p.parseError(skip, ErrOffsetOutOfRange)
 		}
 
 		// SEID
@@ -457,17 +539,17 @@ func (p *ProposalV2) Parse(buf []byte) {
 		skip += 32
 
 		// reserved
-		copy(p.reserved7[:], buf[skip:skip+16])
+		copy(p.reserved8[:], buf[skip:skip+16])
 		skip += 16
 
-		// parse GIDs in GID Area
+		// parse GIDs in GID Area, make sure they fit into it
+		if int(p.GIDNumber) > len(p.GIDArea) {
+			return p.parseError(skip, ErrCountTooBig)
+		}
 		for i := uint8(0); i < p.GIDNumber; i++ {
 			// make sure we are still inside the clc message
 			if int(p.Length)-skip < 8+2+TrailerLen {
-				log.Println("Error parsing CLC Proposal v2: " +
-					"GID number too big")
-				errDump(buf[:p.Length])
-				break
+				return p.parseError(skip, ErrCountTooBig)
 			}
 
 			// parse GID
@@ -483,5 +565,425 @@ func (p *ProposalV2) Parse(buf []byte) {
 	}
 
 	// save trailer
-	p.Trailer.Parse(buf)
+	if err := p.Trailer.Parse(buf); err != nil {
+		return p.parseError(len(buf)-TrailerLen, err)
+	}
+
+	return nil
+}
+
+// Marshal converts the SMCv2 CLC Proposal message to bytes. The IP area,
+// SMCv2 extension and SMC-Dv2 extension offsets are computed from the paths
+// and the EID number like the length
+func (p *ProposalV2) Marshal() ([]byte, error) {
+	// make sure EID and GID numbers fit into the EID and GID areas
+	if int(p.EIDNumber) > len(p.EIDArea) {
+		return nil, fmt.Errorf("Error marshaling CLC Proposal v2: "+
+			"EID number: %w", ErrCountTooBig)
+	}
+	if int(p.GIDNumber) > len(p.GIDArea) {
+		return nil, fmt.Errorf("Error marshaling CLC Proposal v2: "+
+			"GID number: %w", ErrCountTooBig)
+	}
+
+	// calculate message length and offsets. The SMC-D info is always
+	// present, the offsets are relative to the end of their fields
+	length := ProposalV2Len - TrailerLen
+	ipAreaOffset := uint16(SMCDIPAreaOffset)
+	ipAreaLen := 0
+	if p.Path != SMCTypeN {
+		ipAreaLen = net.IPv4len + 1 + 2 + 1 +
+			len(p.IPv6Prefixes)*IPv6PrefixLen
+		length += ipAreaLen
+	}
+	smcv2Offset, smcdv2Off := uint16(0), uint16(0)
+	if p.Pathv2 != SMCTypeN {
+		smcv2Offset = uint16(28 + ipAreaLen)
+		length += ProposalV2ExtLen + int(p.EIDNumber)*EIDLen
+	}
+	if indicated(p.Pathv2, SMCTypeD) {
+		smcdv2Off = uint16(ProposalV2ExtLen - 8 +
+			int(p.EIDNumber)*EIDLen)
+		length += SMCDv2ExtLen + int(p.GIDNumber)*(8+2)
+	}
+	length += TrailerLen
+
+	// write CLC header and trailer
+	buf := make([]byte, length)
+	if err := p.Header.marshal(buf, length); err != nil {
+		return nil, err
+	}
+
+	// skip clc header
+	skip := HeaderLen
+
+	// sender peer ID
+	copy(buf[skip:skip+PeerIDLen], p.SenderPeerID[:])
+	skip += PeerIDLen
+
+	// ib GID is an IPv6 address
+	copy(buf[skip:skip+net.IPv6len], p.IBGID.To16())
+	skip += net.IPv6len
+
+	// ib MAC is a 6 byte MAC address
+	copy(buf[skip:skip+6], p.IBMAC)
+	skip += 6
+
+	// offset to ip area
+	binary.BigEndian.PutUint16(buf[skip:skip+2], ipAreaOffset)
+	skip += 2
+
+	// smcd GID
+	binary.BigEndian.PutUint64(buf[skip:skip+8], p.SMCDGID)
+	skip += 8
+
+	// ism v2 vchid
+	binary.BigEndian.PutUint16(buf[skip:skip+2], p.ISMv2VCHID)
+	skip += 2
+
+	// smc v2 extension offset
+	binary.BigEndian.PutUint16(buf[skip:skip+2], smcv2Offset)
+	skip += 2
+
+	// reserved
+	copy(buf[skip:skip+28], p.reserved[:])
+	skip += 28
+
+	// optional ip/prefix info
+	if p.Path != SMCTypeN {
+		// IP/prefix is an IPv4 address
+		copy(buf[skip:skip+net.IPv4len], p.Prefix.To4())
+		skip += net.IPv4len
+
+		// prefix length
+		buf[skip] = p.PrefixLen
+		skip++
+
+		// reserved
+		copy(buf[skip:skip+2], p.reserved2[:])
+		skip += 2
+
+		// ipv6 prefix count
+		buf[skip] = uint8(len(p.IPv6Prefixes))
+		skip++
+
+		// ipv6 prefixes
+		for _, prefix := range p.IPv6Prefixes {
+			prefix.marshal(buf[skip:])
+			skip += IPv6PrefixLen
+		}
+	}
+
+	// proposal message v2 extension
+	if p.Pathv2 != SMCTypeN {
+		// number of EIDs in EID Area
+		buf[skip] = p.EIDNumber
+		skip++
+
+		// number of GIDs in ISMv2 GID Array Area
+		buf[skip] = p.GIDNumber
+		skip++
+
+		// reserved
+		buf[skip] = p.reserved3
+		skip++
+
+		// Release number (4 bits), reserved (3 bits), SEID indicator
+		// (1 bit)
+		buf[skip] = (p.Release & 0b1111) << 4
+		buf[skip] |= (p.reserved4 & 0b111) << 1
+		buf[skip] |= p.SEIDInd & 0b1
+		skip++
+
+		// reserved
+		copy(buf[skip:skip+2], p.reserved5[:])
+		skip += 2
+
+		// smcd v2 extension offset
+		binary.BigEndian.PutUint16(buf[skip:skip+2], smcdv2Off)
+		skip += 2
+
+		// reserved
+		copy(buf[skip:skip+16], p.reserved6[:])
+		skip += 16
+
+		// max connections (SMCv2.1)
+		buf[skip] = p.MaxConns
+		skip++
+
+		// max links (SMCv2.1)
+		buf[skip] = p.MaxLinks
+		skip++
+
+		// feature mask (SMCv2.1)
+		binary.BigEndian.PutUint16(buf[skip:skip+2],
+			uint16(p.FeatureMask))
+		skip += 2
+
+		// reserved
+		copy(buf[skip:skip+12], p.reserved7[:])
+		skip += 12
+
+		// EIDs in EID Area
+		for i := uint8(0); i < p.EIDNumber; i++ {
+			copy(buf[skip:skip+EIDLen], p.EIDArea[i][:])
+			skip += EIDLen
+		}
+	}
+
+	// optional smcd v2 extension
+	if p.Pathv2 == SMCTypeD || p.Pathv2 == SMCTypeB {
+		// SEID
+		copy(buf[skip:skip+32], p.SEID[:])
+		skip += 32
+
+		// reserved
+		copy(buf[skip:skip+16], p.reserved8[:])
+		skip += 16
+
+		// GIDs in GID Area
+		for i := uint8(0); i < p.GIDNumber; i++ {
+			// GID
+			binary.BigEndian.PutUint64(buf[skip:skip+8],
+				p.GIDArea[i].GID)
+			skip += 8
+
+			// VCHID
+			binary.BigEndian.PutUint16(buf[skip:skip+2],
+				p.GIDArea[i].VCHID)
+			skip += 2
+		}
+	}
+
+	return buf, nil
+}
+
+// jsonProposalV2Ext stores the CLC Proposal Message V2 Extension in JSON
+type jsonProposalV2Ext struct {
+	EIDNumber uint8  `json:"eid_number"`
+	GIDNumber uint8  `json:"gid_number"`
+	Release   uint8  `json:"release"`
+	SEIDInd   uint8  `json:"seid_indicator"`
+	SMCDv2Off uint16 `json:"smcdv2_offset"`
+
+	// SMCv2.1 fields, only present if Release >= 1
+	MaxConns    *uint8  `json:"max_conns,omitempty"`
+	MaxLinks    *uint8  `json:"max_links,omitempty"`
+	FeatureMask *uint16 `json:"feature_mask,omitempty"`
+
+	EIDArea []EID `json:"eid_area"`
+}
+
+// jsonISMGID stores an ISM GID of the SMC-Dv2 Extension GID Area in JSON
+type jsonISMGID struct {
+	GID    uint64  `json:"gid"`
+	GIDExt *uint64 `json:"gid_ext,omitempty"`
+	VCHID  uint16  `json:"vchid"`
+}
+
+// jsonSMCDv2Ext stores the SMC-Dv2 Extension in JSON
+type jsonSMCDv2Ext struct {
+	SEID    EID          `json:"seid"`
+	GIDArea []jsonISMGID `json:"gid_area"`
+}
+
+// jsonProposalV2 stores the SMCv2 CLC Proposal message in JSON
+type jsonProposalV2 struct {
+	jsonMessage
+	SenderPeerID PeerID             `json:"sender_peer_id"`
+	IBGID        string             `json:"ib_gid"`
+	IBMAC        string             `json:"ib_mac"`
+	IPAreaOffset uint16             `json:"ip_area_offset"`
+	SMCDGID      uint64             `json:"smcd_gid"`
+	ISMv2VCHID   uint16             `json:"ismv2_vchid"`
+	SMCv2Offset  uint16             `json:"smcv2_offset"`
+	IPInfo       *jsonIPInfo        `json:"ip_info,omitempty"`
+	V2Ext        *jsonProposalV2Ext `json:"v2_ext,omitempty"`
+	SMCDv2Ext    *jsonSMCDv2Ext     `json:"smcdv2_ext,omitempty"`
+}
+
+// marshalJSON converts the SMCv2 CLC Proposal message to JSON
+func (p *ProposalV2) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonProposalV2{
+		jsonMessage:  newJSONMessage(&p.Header, p.Trailer, reserved),
+		SenderPeerID: p.SenderPeerID,
+		IBGID:        jsonIP(p.IBGID),
+		IBMAC:        p.IBMAC.String(),
+		IPAreaOffset: p.IPAreaOffset,
+		SMCDGID:      p.SMCDGID,
+		ISMv2VCHID:   p.ISMv2VCHID,
+		SMCv2Offset:  p.SMCv2Offset,
+	}
+	j.Reserved.add("reserved", p.reserved)
+
+	// optional ip/prefix info
+	if p.Path != SMCTypeN {
+		j.IPInfo = &jsonIPInfo{
+			Prefix:          jsonIP(p.Prefix),
+			PrefixLen:       p.PrefixLen,
+			IPv6PrefixesCnt: p.IPv6PrefixesCnt,
+			IPv6Prefixes:    append([]IPv6Prefix{}, p.IPv6Prefixes...),
+		}
+		j.Reserved.add("reserved2", p.reserved2)
+	}
+
+	// clc proposal message v2 extension
+	if p.Pathv2 != SMCTypeN {
+		num := int(p.EIDNumber)
+		if num > len(p.EIDArea) {
+			num = len(p.EIDArea)
+		}
+		j.V2Ext = &jsonProposalV2Ext{
+			EIDNumber: p.EIDNumber,
+			GIDNumber: p.GIDNumber,
+			Release:   p.Release,
+			SEIDInd:   p.SEIDInd,
+			SMCDv2Off: p.SMCDv2Off,
+			EIDArea:   append([]EID{}, p.EIDArea[:num]...),
+		}
+		if p.Release >= SMCRelease1 {
+			mask := uint16(p.FeatureMask)
+			j.V2Ext.MaxConns = &p.MaxConns
+			j.V2Ext.MaxLinks = &p.MaxLinks
+			j.V2Ext.FeatureMask = &mask
+		}
+		j.Reserved.add("reserved3", p.reserved3)
+		j.Reserved.add("reserved4", p.reserved4)
+		j.Reserved.add("reserved5", p.reserved5)
+		j.Reserved.add("reserved6", p.reserved6)
+		j.Reserved.add("reserved7", p.reserved7)
+	}
+
+	// smc-d v2 extension
+	if p.Pathv2 == SMCTypeD || p.Pathv2 == SMCTypeB {
+		j.SMCDv2Ext = &jsonSMCDv2Ext{
+			SEID:    p.SEID,
+			GIDArea: []jsonISMGID{},
+		}
+		for _, gid := range p.ISMGIDs() {
+			g := jsonISMGID{GID: gid.GID, VCHID: gid.VCHID}
+			if gid.IsVirtual() && p.Release >= SMCRelease1 {
+				g.GIDExt = &gid.GIDExt
+			}
+			j.SMCDv2Ext.GIDArea = append(j.SMCDv2Ext.GIDArea, g)
+		}
+		j.Reserved.add("reserved8", p.reserved8)
+	}
+
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the SMCv2 CLC Proposal message to JSON
+func (p *ProposalV2) MarshalJSON() ([]byte, error) {
+	return p.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the SMCv2 CLC Proposal message
+func (p *ProposalV2) UnmarshalJSON(data []byte) error {
+	var j jsonProposalV2
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := j.toMessage(&p.Header, &p.Trailer); err != nil {
+		return err
+	}
+	ibGID, err := parseJSONIP(j.IBGID)
+	if err != nil {
+		return err
+	}
+	ibMAC, err := parseJSONMAC(j.IBMAC)
+	if err != nil {
+		return err
+	}
+	p.SenderPeerID = j.SenderPeerID
+	p.IBGID = ibGID
+	p.IBMAC = ibMAC
+	p.IPAreaOffset = j.IPAreaOffset
+	p.SMCDGID = j.SMCDGID
+	p.ISMv2VCHID = j.ISMv2VCHID
+	p.SMCv2Offset = j.SMCv2Offset
+	if err := j.Reserved.get("reserved", p.reserved[:]); err != nil {
+		return err
+	}
+
+	// optional ip/prefix info
+	if j.IPInfo != nil {
+		prefix, err := parseJSONIP(j.IPInfo.Prefix)
+		if err != nil {
+			return err
+		}
+		p.Prefix = prefix.To4()
+		p.PrefixLen = j.IPInfo.PrefixLen
+		p.IPv6PrefixesCnt = j.IPInfo.IPv6PrefixesCnt
+		p.IPv6Prefixes = j.IPInfo.IPv6Prefixes
+		err = j.Reserved.get("reserved2", p.reserved2[:])
+		if err != nil {
+			return err
+		}
+	}
+
+	// clc proposal message v2 extension
+	if ext := j.V2Ext; ext != nil {
+		if len(ext.EIDArea) > len(p.EIDArea) {
+			return fmt.Errorf("Error unmarshaling CLC %s: %w",
+				p.Type, ErrCountTooBig)
+		}
+		p.EIDNumber = ext.EIDNumber
+		p.GIDNumber = ext.GIDNumber
+		p.Release = ext.Release
+		p.SEIDInd = ext.SEIDInd
+		p.SMCDv2Off = ext.SMCDv2Off
+		if ext.MaxConns != nil {
+			p.MaxConns = *ext.MaxConns
+		}
+		if ext.MaxLinks != nil {
+			p.MaxLinks = *ext.MaxLinks
+		}
+		if ext.FeatureMask != nil {
+			p.FeatureMask = FeatureMask(*ext.FeatureMask)
+		}
+		copy(p.EIDArea[:], ext.EIDArea)
+		for _, r := range []struct {
+			name  string
+			value interface{}
+		}{
+			{"reserved3", &p.reserved3},
+			{"reserved4", &p.reserved4},
+			{"reserved5", p.reserved5[:]},
+			{"reserved6", p.reserved6[:]},
+			{"reserved7", p.reserved7[:]},
+		} {
+			if err := j.Reserved.get(r.name, r.value); err != nil {
+				return err
+			}
+		}
+	}
+
+	// smc-d v2 extension, virtual ISM devices use a second GID area
+	// entry for the GID extension
+	if ext := j.SMCDv2Ext; ext != nil {
+		p.SEID = ext.SEID
+		i := 0
+		for _, gid := range ext.GIDArea {
+			n := 1
+			if gid.GIDExt != nil {
+				n = 2
+			}
+			if i+n > len(p.GIDArea) {
+				return fmt.Errorf("Error unmarshaling CLC "+
+					"%s: %w", p.Type, ErrCountTooBig)
+			}
+			p.GIDArea[i] = GIDEntry{GID: gid.GID, VCHID: gid.VCHID}
+			if gid.GIDExt != nil {
+				p.GIDArea[i+1] = GIDEntry{GID: *gid.GIDExt,
+					VCHID: gid.VCHID}
+			}
+			i += n
+		}
+		err := j.Reserved.get("reserved8", p.reserved8[:])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,8 +1,11 @@
 package clc
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"log"
+	"net"
 	"testing"
 )
 
@@ -53,6 +56,17 @@ func TestEIDString(t *testing.T) {
 	}
 }
 
+// testProposalV2Offset returns a copy of the SMCv2 Proposal message msg with
+// the SMCv2 Extension Offset set to offset, i.e., the offset that Marshal
+// computes from the message layout
+func testProposalV2Offset(msg []byte, offset uint16) []byte {
+	// the offset follows the header, the peer ID, the SMC-R GID and MAC,
+	// the IP area offset, the SMC-D GID and the ISMv2 VCHID
+	b := append([]byte{}, msg...)
+	binary.BigEndian.PutUint16(b[HeaderLen+8+16+6+2+8+2:], offset)
+	return b
+}
+
 // TestParseCLCProposalV2SMCB tests parsing of a SMCv2 Proposal message without
 // prefix information, with SMCv2 Extension and with SMC-Dv2 Extension
 func TestParseCLCProposalV2SMCB(t *testing.T) {
@@ -95,8 +109,13 @@ func TestParseCLCProposalV2SMCB(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 214 {
@@ -145,6 +164,17 @@ func TestParseCLCProposalV2SMCB(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message, marshal computes the SMCv2 Extension
+	// Offset from the message layout
+	wantMsg := testProposalV2Offset(msg, 28)
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, wantMsg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, wantMsg)
+	}
 }
 
 // TestParseCLCProposalV2SMCBIPv4 tests parsing of a SMCv2 Proposal message
@@ -192,8 +222,13 @@ func TestParseCLCProposalV2SMCBIPv4(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 222 {
@@ -244,6 +279,17 @@ func TestParseCLCProposalV2SMCBIPv4(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message, marshal computes the SMCv2 Extension
+	// Offset from the message layout
+	wantMsg := testProposalV2Offset(msg, 36)
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, wantMsg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, wantMsg)
+	}
 }
 
 // TestParseCLCProposalV2SMCBIPv6 tests parsing of an SMCv2 Proposal message
@@ -292,8 +338,13 @@ func TestParseCLCProposalV2SMCBIPv6(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 239 {
@@ -344,6 +395,17 @@ func TestParseCLCProposalV2SMCBIPv6(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message, marshal computes the SMCv2 Extension
+	// Offset from the message layout
+	wantMsg := testProposalV2Offset(msg, 53)
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, wantMsg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, wantMsg)
+	}
 }
 
 // TestParseCLCProposalV2SMCBv1IPv4 tests parsing of a SMCv2 Proposal message
@@ -372,8 +434,13 @@ func TestParseCLCProposalV2SMCBv1IPv4(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 92 {
@@ -409,6 +476,15 @@ func TestParseCLCProposalV2SMCBv1IPv4(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, msg)
+	}
 }
 
 // TestParseCLCProposalV2SMCBv1IPv6 tests parsing of a SMCv2 Proposal message
@@ -438,8 +514,13 @@ func TestParseCLCProposalV2SMCBv1IPv6(t *testing.T) {
 	}
 
 	// parse message
-	proposal, proposalLen := NewMessage(msg)
-	proposal.Parse(msg)
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
 
 	// check message length
 	if proposalLen != 109 {
@@ -475,4 +556,172 @@ func TestParseCLCProposalV2SMCBv1IPv6(t *testing.T) {
 	if got != want {
 		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
 	}
+
+	// check marshaled message
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, msg)
+	}
+}
+
+// TestParseCLCProposalV2SMCBRelease1 tests parsing of a SMCv2.1 Proposal
+// message with SMCv2.1 fields and a virtual ISM device in the GID Area
+func TestParseCLCProposalV2SMCBRelease1(t *testing.T) {
+	// prepare smc-b (r + d) proposal v2 message:
+	// Eyecatcher, Type, Length, Version, Pathv2+Path, SenderPeerID
+	msgBytes := "e2d4c3d9" + "01" + "00ea" + "2" + "e" +
+		"394498039babcdef" +
+		// IBGID
+		"fe800000000000009a039bfffeabcdef" +
+		// IBMAC, IPAreaOffset, SMCDGID
+		"98039babcdef" + "0028" + "0123456789abcdef" +
+		// ISMv2VCHID, SMCv2Offset, reserved
+		"1234" + "0000" + "000000000000000000000000" +
+		// reserved
+		"00000000000000000000000000000000" +
+		// EIDNumber, GIDNumber, reserved3,
+		// Release+reserved4+SEIDInd, reserved5, SMCDv2Off
+		"01" + "03" + "00" + "11" + "0000" + "0040" +
+		// reserved6 16 bytes
+		"00000000000000000000000000000000" +
+		// MaxConns, MaxLinks, FeatureMask, reserved7 12 bytes
+		"ff" + "03" + "0001" + "000000000000000000000000" +
+		// EID 32 bytes
+		"546869734973534d4376324549443031" +
+		"00000000000000000000000000000000" +
+		// SEID 32 bytes
+		"546869734973534d4376324549443032" +
+		"00000000000000000000000000000000" +
+		// reserved8 16 bytes
+		"00000000000000000000000000000000" +
+		// GID 8 bytes, VCHID 2 bytes
+		"abcdef0123456789" + "0123" +
+		// virtual ISM: GID 8 bytes, VCHID 2 bytes
+		"0000000000000001" + "ffff" +
+		// virtual ISM: GID extension 8 bytes, VCHID 2 bytes
+		"0000000000000002" + "ffff" +
+		// Trailer
+		"e2d4c3d9"
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	proposal, proposalLen, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proposal.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check message length
+	if proposalLen != 234 {
+		t.Errorf("proposalLen = %d; want %d", proposalLen, 234)
+	}
+
+	// check output message without reserved fields
+	want := "Proposal: Eyecatcher: SMC-R, Type: 1 (Proposal), " +
+		"Length: 234, Version: 2, Pathv2: SMC-R + SMC-D, " +
+		"Path: No SMC-R/SMC-D, Peer ID: 14660@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, IP Area Offset: 40, " +
+		"SMC-D GID: 81985529216486895, ISMv2 VCHID: 4660, " +
+		"SMCv2 Extension Offset: 0, " +
+		"EID Number: 1, GID Number: 3, Release: 1, " +
+		"SEID Indicator: 1, SMC-Dv2 Extension Offset: 64, " +
+		"Max Connections: 255, Max Links: 3, " +
+		"Feature Mask: 0x1 (Emulated ISM Device), " +
+		"EID Area: [EID 0: ThisIsSMCv2EID01], " +
+		"SEID: ThisIsSMCv2EID02, " +
+		"GID Area: [GID 0: 12379813738877118345, VCHID 0: 291, " +
+		"GID 1: 1, GID Extension 1: 2, VCHID 1: 65535], " +
+		"Trailer: SMC-R"
+	got := proposal.String()
+	if got != want {
+		t.Errorf("proposal.String() = %s; want %s", got, want)
+	}
+
+	// check output message with reserved fields
+	want = "Proposal: Eyecatcher: SMC-R, Type: 1 (Proposal), " +
+		"Length: 234, Version: 2, Pathv2: SMC-R + SMC-D, " +
+		"Path: No SMC-R/SMC-D, Peer ID: 14660@98:03:9b:ab:cd:ef, " +
+		"SMC-R GID: fe80::9a03:9bff:feab:cdef, " +
+		"RoCE MAC: 98:03:9b:ab:cd:ef, IP Area Offset: 40, " +
+		"SMC-D GID: 81985529216486895, ISMv2 VCHID: 4660, " +
+		"SMCv2 Extension Offset: 0, " +
+		"Reserved: 0x00000000000000000000000000000000000000000000" +
+		"000000000000, " +
+		"EID Number: 1, GID Number: 3, Reserved: 0x0, Release: 1, " +
+		"Reserved: 0x0, SEID Indicator: 1, Reserved: 0x0000, " +
+		"SMC-Dv2 Extension Offset: 64, " +
+		"Reserved: 0x00000000000000000000000000000000, " +
+		"Max Connections: 255, Max Links: 3, " +
+		"Feature Mask: 0x1 (Emulated ISM Device), " +
+		"Reserved: 0x000000000000000000000000, " +
+		"EID Area: [EID 0: ThisIsSMCv2EID01], " +
+		"SEID: ThisIsSMCv2EID02, " +
+		"Reserved: 0x00000000000000000000000000000000, " +
+		"GID Area: [GID 0: 12379813738877118345, VCHID 0: 291, " +
+		"GID 1: 1, GID Extension 1: 2, VCHID 1: 65535], " +
+		"Trailer: SMC-R"
+	got = proposal.Reserved()
+	if got != want {
+		t.Errorf("proposal.Reserved() = %s; want %s", got, want)
+	}
+
+	// check ISM GIDs
+	gids := proposal.(*ProposalV2).ISMGIDs()
+	if len(gids) != 2 || gids[0].IsVirtual() || !gids[1].IsVirtual() ||
+		gids[1].GIDExt != 2 {
+		t.Errorf("ISMGIDs() = %v; want 1 native and 1 virtual GID",
+			gids)
+	}
+
+	// check marshaled message, marshal computes the SMCv2 Extension
+	// Offset from the message layout
+	wantMsg := testProposalV2Offset(msg, 28)
+	b, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, wantMsg) {
+		t.Errorf("proposal.Marshal() = %x; want %x", b, wantMsg)
+	}
+}
+
+func TestMarshalProposalV2Offsets(t *testing.T) {
+	// proposal with wrong offsets, marshal computes them from the layout
+	p := &ProposalV2{
+		Header: Header{Type: TypeProposal, Version: SMCv2,
+			Pathv2: SMCTypeB, Path: SMCTypeR},
+		IPAreaOffset: 1,
+		SMCv2Offset:  2,
+		SMCDv2Off:    3,
+		Prefix:       net.IPv4(127, 0, 0, 0),
+		PrefixLen:    8,
+		IPv6Prefixes: []IPv6Prefix{{net.ParseIP("fd00::"), 64}},
+		EIDNumber:    2,
+		GIDNumber:    1,
+	}
+	copy(p.Eyecatcher[:], SMCREyecatcher)
+	b, err := p.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// parse message and check offsets
+	var got ProposalV2
+	if err := got.Parse(b); err != nil {
+		t.Fatal(err)
+	}
+	if got.IPAreaOffset != 40 || got.SMCv2Offset != 28+8+17 ||
+		got.SMCDv2Off != 32+2*EIDLen {
+		t.Errorf("offsets = %d, %d, %d; want 40, 53, 96",
+			got.IPAreaOffset, got.SMCv2Offset, got.SMCDv2Off)
+	}
 }
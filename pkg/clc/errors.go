@@ -0,0 +1,44 @@
+package clc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CLC message parsing errors
+var (
+	ErrTooShort         = errors.New("message too short")
+	ErrTooBig           = errors.New("message too big")
+	ErrBadEyecatcher    = errors.New("invalid eyecatcher")
+	ErrBadTrailer       = errors.New("invalid trailer")
+	ErrUnknownType      = errors.New("unknown message type")
+	ErrOffsetOutOfRange = errors.New("offset out of range")
+	ErrCountTooBig      = errors.New("count too big")
+)
+
+// ParseError stores an error that occurred while parsing a CLC message and
+// the byte offset in the message where it occurred
+type ParseError struct {
+	Type   MsgType // type of the message
+	Offset int     // offset in the message
+	Err    error   // underlying error, e.g., ErrTooShort
+}
+
+// Error converts the parse error to a string
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Error parsing CLC %s at offset %d: %v", e.Type,
+		e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error of the parse error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// CLC handshake errors
+var (
+	ErrUnexpectedMessage    = errors.New("unexpected message")
+	ErrPathMismatch         = errors.New("path mismatch")
+	ErrVersionMismatch      = errors.New("version mismatch")
+	ErrFirstContactMismatch = errors.New("first contact mismatch")
+)
@@ -0,0 +1,112 @@
+package clc
+
+import (
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+// testParseError parses the message in msgBytes and checks if parsing
+// returns a ParseError with the wanted error and offset
+func testParseError(t *testing.T, msgBytes string, want error, offset int) {
+	msg, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// get message and parse it
+	m, _, err := NewMessage(msg)
+	if err == nil {
+		err = m.Parse(msg)
+	}
+
+	// check error
+	if !errors.Is(err, want) {
+		t.Errorf("err = %v; want %v", err, want)
+	}
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("err = %v; want ParseError", err)
+	}
+	if pe.Offset != offset {
+		t.Errorf("pe.Offset = %d; want %d", pe.Offset, offset)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	// decline message with length field bigger than the buffer
+	testParseError(t, "e2d4c3d904001c102525252525252500"+
+		"03030000000000", ErrTooShort, 23)
+
+	// decline message with length field smaller than a decline
+	testParseError(t, "e2d4c3d904001b102525252525252500"+
+		"0303000000000000e2d4c3", ErrTooShort, 27)
+
+	// decline message with invalid trailer
+	testParseError(t, "e2d4c3d904001c102525252525252500"+
+		"0303000000000000e2d4c3d8", ErrBadTrailer, 24)
+
+	// message that exceeds the maximum message size
+	testParseError(t, "e2d4c3d904041c10", ErrTooBig, 5)
+
+	// message with unknown message type
+	testParseError(t, "e2d4c3d905001c10", ErrUnknownType, 4)
+
+	// incomplete message header
+	testParseError(t, "e2d4c3d90400", ErrTooShort, 6)
+
+	// proposal message with IP area offset outside of message
+	testParseError(t, "e2d4c3d901003410b1a098039babcdef"+
+		"fe800000000000009a039bfffeabcdef"+
+		"98039babcdef00ff7f00000008000000"+
+		"e2d4c3d9", ErrOffsetOutOfRange, 295)
+
+	// proposal message with too big IPv6 prefix count
+	testParseError(t, "e2d4c3d901003410b1a098039babcdef"+
+		"fe800000000000009a039bfffeabcdef"+
+		"98039babcdef00007f00000008000001"+
+		"e2d4c3d9", ErrCountTooBig, 48)
+
+	// proposal v2 message with more EIDs than fit into the EID area
+	testParseError(t, "e2d4c3d901019c22394498039babcdef"+
+		"fe800000000000009a039bfffeabcdef"+
+		"98039babcdef00000000000000000000"+
+		"00000000"+strings.Repeat("00", 28)+
+		"09000000"+strings.Repeat("00", 36)+
+		strings.Repeat("00", 9*EIDLen)+
+		"e2d4c3d9", ErrCountTooBig, 120)
+
+	// proposal v2 message with more GIDs than fit into the GID area
+	testParseError(t, "e2d4c3d901010626394498039babcdef"+
+		"fe800000000000009a039bfffeabcdef"+
+		"98039babcdef00000000000000000000"+
+		"00000000"+strings.Repeat("00", 28)+
+		"00090000"+strings.Repeat("00", 36)+
+		strings.Repeat("00", SMCDv2ExtLen)+
+		strings.Repeat("00", 9*(8+2))+
+		"e2d4c3d9", ErrCountTooBig, 168)
+
+	// smc-r v2.1 confirm message with gid list but without the SMCv2.1
+	// fields in the first contact extension
+	testParseError(t, "e2d4c3d90300942898039babcdef0000"+
+		"fe800000000000009a039bfffeabcdef"+
+		"98039babcdef0000e50000187f010000"+
+		"0006230000000000f0a40000000d89a4"+
+		strings.Repeat("00", EIDLen+8)+
+		"00210000"+strings.Repeat("00", EIDLen)+
+		"00000000"+
+		"e2d4c3d9", ErrTooShort, 140)
+}
+
+func TestNewMessageNoCLC(t *testing.T) {
+	// buffers without eyecatcher are not an error
+	for _, buf := range [][]byte{nil, {0xE2}, make([]byte, 64)} {
+		m, l, err := NewMessage(buf)
+		if m != nil || l != 0 || err != nil {
+			t.Errorf("NewMessage() = %v, %d, %v; want nil, 0, nil",
+				m, l, err)
+		}
+	}
+}
@@ -29,6 +29,9 @@ func (e Eyecatcher) String() string {
 
 // HasEyecatcher checks if there is a SMC-R or SMC-D eyecatcher in buf
 func HasEyecatcher(buf []byte) bool {
+	if len(buf) < EyecatcherLen {
+		return false
+	}
 	if bytes.Compare(buf[:EyecatcherLen], SMCREyecatcher) == 0 {
 		return true
 	}
@@ -0,0 +1,209 @@
+package clc
+
+import "fmt"
+
+// CLC handshake outcomes
+const (
+	OutcomeNone     Outcome = iota // handshake not finished
+	OutcomeSMCR                    // SMC-R
+	OutcomeSMCD                    // SMC-D
+	OutcomeSMCRv2                  // SMC-Rv2
+	OutcomeSMCDv2                  // SMC-Dv2
+	OutcomeFallback                // fallback to TCP
+)
+
+// Outcome is the final outcome of a CLC handshake
+type Outcome uint8
+
+// String converts the outcome to a string
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeNone:
+		return "none"
+	case OutcomeSMCR:
+		return "SMC-R"
+	case OutcomeSMCD:
+		return "SMC-D"
+	case OutcomeSMCRv2:
+		return "SMC-Rv2"
+	case OutcomeSMCDv2:
+		return "SMC-Dv2"
+	case OutcomeFallback:
+		return "Fallback"
+	default:
+		return "unknown"
+	}
+}
+
+// Handshake tracks the CLC messages of a single SMC connection setup and
+// validates the protocol sequence: Proposal, then Accept or Decline, then
+// Confirm or Decline. A Decline is also accepted after a Confirm, e.g., if
+// the server fails to confirm the first link
+type Handshake struct {
+	Proposal Message
+	Accept   Message
+	Confirm  Message
+	Decline  Message
+}
+
+// msgHeader returns the header of the CLC message msg or nil if the message
+// does not contain a header
+func msgHeader(msg Message) *Header {
+	m, ok := msg.(interface{ header() *Header })
+	if !ok {
+		return nil
+	}
+	return m.header()
+}
+
+// handshakeError returns a new handshake error for a message of type typ
+func handshakeError(typ MsgType, err error) error {
+	return fmt.Errorf("Error in CLC handshake at %s: %w", typ, err)
+}
+
+// Add adds the next message from either direction of the connection to the
+// handshake. It returns an error wrapping ErrUnexpectedMessage and ignores
+// the message if it is not expected in the current state of the handshake.
+// If the message does not match previous messages, e.g., in path, version or
+// first contact flag, Add adds the message and returns an error wrapping
+// ErrPathMismatch, ErrVersionMismatch or ErrFirstContactMismatch
+func (h *Handshake) Add(msg Message) error {
+	hdr := msgHeader(msg)
+	if hdr == nil {
+		return handshakeError(0, ErrUnexpectedMessage)
+	}
+
+	switch hdr.Type {
+	case TypeProposal:
+		if h.Proposal != nil {
+			break
+		}
+		h.Proposal = msg
+		return nil
+	case TypeAccept:
+		if h.Proposal == nil || h.Accept != nil || h.Decline != nil {
+			break
+		}
+		h.Accept = msg
+		return h.checkAccept()
+	case TypeConfirm:
+		if h.Accept == nil || h.Confirm != nil || h.Decline != nil {
+			break
+		}
+		h.Confirm = msg
+		return h.checkConfirm()
+	case TypeDecline:
+		if h.Proposal == nil || h.Decline != nil {
+			break
+		}
+		h.Decline = msg
+		return nil
+	}
+	return handshakeError(hdr.Type, ErrUnexpectedMessage)
+}
+
+// offersPath checks if the path offered in a proposal contains path
+func offersPath(offered, path Path) bool {
+	switch path {
+	case SMCTypeR, SMCTypeD:
+		return offered == path || offered == SMCTypeB
+	default:
+		return false
+	}
+}
+
+// checkAccept checks if the accept message matches the proposal message
+func (h *Handshake) checkAccept() error {
+	p := msgHeader(h.Proposal)
+	a := msgHeader(h.Accept)
+
+	// check version, the accept must not use a higher version
+	if a.Version == 0 || a.Version > p.Version {
+		return handshakeError(a.Type, ErrVersionMismatch)
+	}
+
+	// check path, SMCv2 paths are in pathv2 of SMCv2 proposals
+	offered := p.Path
+	if a.Version == SMCv2 {
+		offered = p.Pathv2
+	}
+	if !offersPath(offered, a.Path) {
+		return handshakeError(a.Type, ErrPathMismatch)
+	}
+
+	return nil
+}
+
+// checkConfirm checks if the confirm message matches the accept message
+func (h *Handshake) checkConfirm() error {
+	a := msgHeader(h.Accept)
+	c := msgHeader(h.Confirm)
+
+	// check version
+	if c.Version != a.Version {
+		return handshakeError(c.Type, ErrVersionMismatch)
+	}
+
+	// check path
+	if c.Path != a.Path {
+		return handshakeError(c.Type, ErrPathMismatch)
+	}
+
+	// check first contact flag, only used in SMCv2 confirm messages
+	if c.Version == SMCv2 && c.Flag != a.Flag {
+		return handshakeError(c.Type, ErrFirstContactMismatch)
+	}
+
+	return nil
+}
+
+// Done checks if the handshake is finished
+func (h *Handshake) Done() bool {
+	return h.Confirm != nil || h.Decline != nil
+}
+
+// Outcome returns the outcome of the handshake
+func (h *Handshake) Outcome() Outcome {
+	if h.Decline != nil {
+		return OutcomeFallback
+	}
+	if h.Confirm == nil {
+		return OutcomeNone
+	}
+
+	c := msgHeader(h.Confirm)
+	switch {
+	case c.Version == SMCv2 && c.Path == SMCTypeR:
+		return OutcomeSMCRv2
+	case c.Version == SMCv2 && c.Path == SMCTypeD:
+		return OutcomeSMCDv2
+	case c.Path == SMCTypeR:
+		return OutcomeSMCR
+	case c.Path == SMCTypeD:
+		return OutcomeSMCD
+	default:
+		return OutcomeNone
+	}
+}
+
+// PeerDiagnosis returns the peer diagnosis of the decline message in the
+// handshake. It returns 0 if there is no decline message
+func (h *Handshake) PeerDiagnosis() PeerDiagnosis {
+	switch d := h.Decline.(type) {
+	case *Decline:
+		return d.PeerDiagnosis
+	case *DeclineV2:
+		return d.PeerDiagnosis
+	default:
+		return 0
+	}
+}
+
+// String converts the handshake outcome to a string
+func (h *Handshake) String() string {
+	if h.Outcome() == OutcomeFallback {
+		return fmt.Sprintf("Outcome: %s, Peer Diagnosis: %s",
+			h.Outcome(), h.PeerDiagnosis())
+	}
+	return fmt.Sprintf("Outcome: %s", h.Outcome())
+}
@@ -0,0 +1,143 @@
+package clc
+
+import (
+	"errors"
+	"testing"
+)
+
+// testHandshake adds msgs to a new handshake, checks if adding the last
+// message returns the wanted error and returns the handshake
+func testHandshake(t *testing.T, want error, msgs ...Message) *Handshake {
+	h := &Handshake{}
+	var err error
+	for _, msg := range msgs {
+		err = h.Add(msg)
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, want) {
+		t.Errorf("err = %v; want %v", err, want)
+	}
+	return h
+}
+
+func TestHandshakeOutcome(t *testing.T) {
+	proposalV1 := &Proposal{Header: Header{Type: TypeProposal,
+		Version: SMCv1, Path: SMCTypeB}}
+	proposalV2 := &ProposalV2{Header: Header{Type: TypeProposal,
+		Version: SMCv2, Pathv2: SMCTypeB, Path: SMCTypeR}}
+
+	// smc-r
+	h := testHandshake(t, nil, proposalV1,
+		&AcceptSMCR{Header: Header{Type: TypeAccept, Version: SMCv1,
+			Flag: 1, Path: SMCTypeR}},
+		&ConfirmSMCR{AcceptSMCR{Header: Header{Type: TypeConfirm,
+			Version: SMCv1, Path: SMCTypeR}}})
+	if !h.Done() || h.Outcome() != OutcomeSMCR {
+		t.Errorf("h.Outcome() = %s; want %s", h.Outcome(), OutcomeSMCR)
+	}
+
+	// smc-d
+	h = testHandshake(t, nil, proposalV1,
+		&AcceptSMCD{Header: Header{Type: TypeAccept, Version: SMCv1,
+			Path: SMCTypeD}},
+		&ConfirmSMCD{AcceptSMCD{Header: Header{Type: TypeConfirm,
+			Version: SMCv1, Path: SMCTypeD}}})
+	if h.Outcome() != OutcomeSMCD {
+		t.Errorf("h.Outcome() = %s; want %s", h.Outcome(), OutcomeSMCD)
+	}
+
+	// smc-rv2
+	h = testHandshake(t, nil, proposalV2,
+		&AcceptSMCRv2{Header: Header{Type: TypeAccept, Version: SMCv2,
+			Flag: 1, Path: SMCTypeR}},
+		&ConfirmSMCRv2{AcceptSMCRv2{Header: Header{Type: TypeConfirm,
+			Version: SMCv2, Flag: 1, Path: SMCTypeR}}})
+	if h.Outcome() != OutcomeSMCRv2 {
+		t.Errorf("h.Outcome() = %s; want %s", h.Outcome(),
+			OutcomeSMCRv2)
+	}
+
+	// smc-dv2
+	h = testHandshake(t, nil, proposalV2,
+		&AcceptSMCDv2{Header: Header{Type: TypeAccept, Version: SMCv2,
+			Path: SMCTypeD}},
+		&ConfirmSMCDv2{AcceptSMCDv2{Header: Header{Type: TypeConfirm,
+			Version: SMCv2, Path: SMCTypeD}}})
+	if h.Outcome() != OutcomeSMCDv2 {
+		t.Errorf("h.Outcome() = %s; want %s", h.Outcome(),
+			OutcomeSMCDv2)
+	}
+
+	// fallback after proposal
+	h = testHandshake(t, nil, proposalV2,
+		&DeclineV2{Header: Header{Type: TypeDecline, Version: SMCv2},
+			PeerDiagnosis: DeclineNoSMCDev})
+	want := "Outcome: Fallback, Peer Diagnosis: 0x3030000 " +
+		"(no SMC device found (R or D))"
+	if h.String() != want {
+		t.Errorf("h.String() = %s; want %s", h, want)
+	}
+
+	// fallback after confirm
+	h = testHandshake(t, nil, proposalV1,
+		&AcceptSMCR{Header: Header{Type: TypeAccept, Version: SMCv1,
+			Path: SMCTypeR}},
+		&ConfirmSMCR{AcceptSMCR{Header: Header{Type: TypeConfirm,
+			Version: SMCv1, Path: SMCTypeR}}},
+		&Decline{Header: Header{Type: TypeDecline, Version: SMCv1},
+			PeerDiagnosis: DeclineTimeoutCL})
+	if h.Outcome() != OutcomeFallback ||
+		h.PeerDiagnosis() != DeclineTimeoutCL {
+		t.Errorf("h = %s; want fallback with %s", h,
+			PeerDiagnosis(DeclineTimeoutCL))
+	}
+
+	// handshake not finished
+	h = testHandshake(t, nil, proposalV1)
+	if h.Done() || h.Outcome() != OutcomeNone {
+		t.Errorf("h.Outcome() = %s; want %s", h.Outcome(), OutcomeNone)
+	}
+}
+
+func TestHandshakeErrors(t *testing.T) {
+	proposalV1 := &Proposal{Header: Header{Type: TypeProposal,
+		Version: SMCv1, Path: SMCTypeD}}
+	proposalV2 := &ProposalV2{Header: Header{Type: TypeProposal,
+		Version: SMCv2, Pathv2: SMCTypeD, Path: SMCTypeN}}
+	acceptV1 := &AcceptSMCD{Header: Header{Type: TypeAccept,
+		Version: SMCv1, Path: SMCTypeD}}
+	acceptV2 := &AcceptSMCDv2{Header: Header{Type: TypeAccept,
+		Version: SMCv2, Flag: 1, Path: SMCTypeD}}
+
+	// unexpected messages
+	testHandshake(t, ErrUnexpectedMessage, acceptV1)
+	testHandshake(t, ErrUnexpectedMessage, proposalV1, proposalV1)
+	testHandshake(t, ErrUnexpectedMessage, proposalV1,
+		&ConfirmSMCD{AcceptSMCD{Header: Header{Type: TypeConfirm,
+			Version: SMCv1, Path: SMCTypeD}}})
+	testHandshake(t, ErrUnexpectedMessage, proposalV1,
+		&Decline{Header: Header{Type: TypeDecline}}, acceptV1)
+
+	// version mismatch
+	testHandshake(t, ErrVersionMismatch, proposalV1, acceptV2)
+	testHandshake(t, ErrVersionMismatch, proposalV2, acceptV2,
+		&ConfirmSMCD{AcceptSMCD{Header: Header{Type: TypeConfirm,
+			Version: SMCv1, Path: SMCTypeD}}})
+
+	// path mismatch
+	testHandshake(t, ErrPathMismatch, proposalV1,
+		&AcceptSMCR{Header: Header{Type: TypeAccept, Version: SMCv1,
+			Path: SMCTypeR}})
+	testHandshake(t, ErrPathMismatch, proposalV2, acceptV1)
+
+	// first contact mismatch
+	h := testHandshake(t, ErrFirstContactMismatch, proposalV2, acceptV2,
+		&ConfirmSMCDv2{AcceptSMCDv2{Header: Header{Type: TypeConfirm,
+			Version: SMCv2, Path: SMCTypeD}}})
+	if h.Outcome() != OutcomeSMCDv2 {
+		t.Errorf("h.Outcome() = %s; want %s", h.Outcome(),
+			OutcomeSMCDv2)
+	}
+}
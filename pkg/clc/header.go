@@ -85,7 +85,12 @@ type Header struct {
 }
 
 // Parse parses the CLC message header in buf
-func (h *Header) Parse(buf []byte) {
+func (h *Header) Parse(buf []byte) error {
+	// check if header is complete
+	if len(buf) < HeaderLen {
+		return &ParseError{Offset: len(buf), Err: ErrTooShort}
+	}
+
 	// eyecatcher
 	copy(h.Eyecatcher[:], buf[:EyecatcherLen])
 
@@ -102,6 +107,74 @@ func (h *Header) Parse(buf []byte) {
 	h.reserved = (bitfield & 0b00000100) >> 2
 	h.Pathv2 = Path((bitfield & 0b00001100) >> 2)
 	h.Path = Path(bitfield & 0b00000011)
+
+	return nil
+}
+
+// header returns the CLC message header; used to access the header of
+// messages that embed it
+func (h *Header) header() *Header {
+	return h
+}
+
+// parseError returns a new parse error for the message with offset and err
+func (h *Header) parseError(offset int, err error) error {
+	return &ParseError{Type: h.Type, Offset: offset, Err: err}
+}
+
+// checkLength checks if the message in buf is at least minLen bytes long and
+// if buf contains the whole message. It returns buf truncated to the message
+// length
+func (h *Header) checkLength(buf []byte, minLen int) ([]byte, error) {
+	if int(h.Length) < minLen {
+		return nil, h.parseError(int(h.Length), ErrTooShort)
+	}
+	if len(buf) < int(h.Length) {
+		return nil, h.parseError(len(buf), ErrTooShort)
+	}
+	return buf[:h.Length], nil
+}
+
+// marshal writes the CLC message header to buf using length as total message
+// length. It also writes the eyecatcher as message trailer at the end of buf
+func (h *Header) marshal(buf []byte, length int) error {
+	// check eyecatcher
+	if !HasEyecatcher(h.Eyecatcher[:]) {
+		return fmt.Errorf("Error marshaling CLC %s: %w", h.Type,
+			ErrBadEyecatcher)
+	}
+
+	// make sure message is not too big
+	if length > MaxMessageSize {
+		return fmt.Errorf("Error marshaling CLC %s: %w", h.Type,
+			ErrTooBig)
+	}
+
+	// eyecatcher
+	copy(buf[:EyecatcherLen], h.Eyecatcher[:])
+
+	// type
+	buf[4] = byte(h.Type)
+
+	// length
+	binary.BigEndian.PutUint16(buf[5:7], uint16(length))
+
+	// 1 byte bitfield: version, flag/reserved or pathv2, path
+	bitfield := (h.Version & 0b1111) << 4
+	if h.Type == TypeProposal && h.Version == SMCv2 {
+		// SMCv2 proposals use pathv2 instead of flag and reserved
+		bitfield |= (byte(h.Pathv2) & 0b11) << 2
+	} else {
+		bitfield |= (h.Flag & 0b1) << 3
+		bitfield |= (h.reserved & 0b1) << 2
+	}
+	bitfield |= byte(h.Path) & 0b11
+	buf[7] = bitfield
+
+	// trailer
+	copy(buf[length-TrailerLen:length], h.Eyecatcher[:])
+
+	return nil
 }
 
 // flagString() converts the flag bit in the message according to message type
@@ -117,8 +190,8 @@ func (h *Header) flagString() string {
 	case TypeAccept:
 		return fmt.Sprintf("First Contact: %d", h.Flag)
 	case TypeConfirm:
-		if h.Version == SMCv2 && h.Path == SMCTypeD {
-			// SMC-Dv2 uses first contact flag
+		if h.Version == SMCv2 {
+			// SMC-Rv2 and SMC-Dv2 use first contact flag
 			return fmt.Sprintf("First Contact: %d", h.Flag)
 		}
 		return fmt.Sprintf("Flag: %d", h.Flag)
@@ -0,0 +1,502 @@
+package clc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ErrBadJSON is the error of JSON that does not contain a valid CLC message
+var ErrBadJSON = errors.New("invalid JSON message")
+
+// jsonValue stores the raw and the decoded value of a message field in JSON
+type jsonValue struct {
+	Raw     interface{} `json:"raw"`
+	Decoded interface{} `json:"decoded"`
+}
+
+// unmarshalJSONRaw sets raw to the raw value of the message field in the
+// JSON data
+func unmarshalJSONRaw(data []byte, raw interface{}) error {
+	j := struct {
+		Raw interface{} `json:"raw"`
+	}{raw}
+	return json.Unmarshal(data, &j)
+}
+
+// unmarshalJSONHex sets buf to the raw hex value of the message field in the
+// JSON data. The raw value must have the length of buf
+func unmarshalJSONHex(data []byte, buf []byte) error {
+	var raw string
+	if err := unmarshalJSONRaw(data, &raw); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return err
+	}
+	if len(b) != len(buf) {
+		return fmt.Errorf("Error unmarshaling %q: %w", raw, ErrBadJSON)
+	}
+	copy(buf, b)
+	return nil
+}
+
+// MarshalJSON converts the peer ID to JSON
+func (p PeerID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{hex.EncodeToString(p[:]), p.String()})
+}
+
+// UnmarshalJSON converts JSON to the peer ID
+func (p *PeerID) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONHex(data, p[:])
+}
+
+// MarshalJSON converts the EID to JSON
+func (e EID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{hex.EncodeToString(e[:]), e.String()})
+}
+
+// UnmarshalJSON converts JSON to the EID
+func (e *EID) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONHex(data, e[:])
+}
+
+// MarshalJSON converts the IPv6 prefix to JSON
+func (p IPv6Prefix) MarshalJSON() ([]byte, error) {
+	raw := make([]byte, IPv6PrefixLen)
+	p.marshal(raw)
+	return json.Marshal(jsonValue{hex.EncodeToString(raw), p.String()})
+}
+
+// UnmarshalJSON converts JSON to the IPv6 prefix
+func (p *IPv6Prefix) UnmarshalJSON(data []byte) error {
+	raw := make([]byte, IPv6PrefixLen)
+	if err := unmarshalJSONHex(data, raw); err != nil {
+		return err
+	}
+	p.Prefix = net.IP(raw[:net.IPv6len])
+	p.PrefixLen = raw[net.IPv6len]
+	return nil
+}
+
+// MarshalJSON converts the RMBE size to JSON
+func (s RMBESize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{uint8(s), s.Size()})
+}
+
+// UnmarshalJSON converts JSON to the RMBE size
+func (s *RMBESize) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONRaw(data, (*uint8)(s))
+}
+
+// MarshalJSON converts the QP MTU to JSON
+func (m QPMTU) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{uint8(m), m.mtu()})
+}
+
+// UnmarshalJSON converts JSON to the QP MTU
+func (m *QPMTU) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONRaw(data, (*uint8)(m))
+}
+
+// MarshalJSON converts the peer diagnosis to JSON
+func (p PeerDiagnosis) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{uint32(p), p.description()})
+}
+
+// UnmarshalJSON converts JSON to the peer diagnosis
+func (p *PeerDiagnosis) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONRaw(data, (*uint32)(p))
+}
+
+// parseJSONEyecatcher converts the eyecatcher string s in JSON to an
+// eyecatcher
+func parseJSONEyecatcher(s string) (Eyecatcher, error) {
+	var e Eyecatcher
+	switch s {
+	case "SMC-R":
+		copy(e[:], SMCREyecatcher)
+	case "SMC-D":
+		copy(e[:], SMCDEyecatcher)
+	default:
+		return e, fmt.Errorf("Error unmarshaling eyecatcher %q: %w",
+			s, ErrBadEyecatcher)
+	}
+	return e, nil
+}
+
+// parseJSONPath converts the path string s in JSON to a path
+func parseJSONPath(s string) (Path, error) {
+	for _, p := range []Path{SMCTypeR, SMCTypeD, SMCTypeN, SMCTypeB} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("Error unmarshaling path %q: %w", s, ErrBadJSON)
+}
+
+// parseJSONOSType converts the OS type string s in JSON, e.g., "2 (Linux)",
+// to an OS type
+func parseJSONOSType(s string) (OSType, error) {
+	num, _, _ := strings.Cut(s, " ")
+	o, err := strconv.ParseUint(num, 10, 4)
+	if err != nil {
+		return 0, fmt.Errorf("Error unmarshaling OS type %q: %w", s,
+			ErrBadJSON)
+	}
+	return OSType(o), nil
+}
+
+// parseJSONIP converts the IP address string s in JSON to an IP address
+func parseJSONIP(s string) (net.IP, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("Error unmarshaling IP %q: %w", s,
+			ErrBadJSON)
+	}
+	return ip, nil
+}
+
+// parseJSONMAC converts the MAC address string s in JSON to a MAC address
+func parseJSONMAC(s string) (net.HardwareAddr, error) {
+	if s == "" {
+		return nil, nil
+	}
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshaling MAC %q: %w", s,
+			ErrBadJSON)
+	}
+	return mac, nil
+}
+
+// jsonReserved stores the reserved fields of a message in JSON
+type jsonReserved map[string]string
+
+// add adds the reserved field name with value to the reserved fields. If r
+// is nil, reserved fields are not included in JSON and add does nothing
+func (r jsonReserved) add(name string, value interface{}) {
+	if r == nil {
+		return
+	}
+	r[name] = fmt.Sprintf("%#x", value)
+}
+
+// get sets value to the reserved field name if it is present. The value is
+// a *byte, a byte slice with the length of the field, e.g., a slice of a
+// byte array, or a *[]byte
+func (r jsonReserved) get(name string, value interface{}) error {
+	s, ok := r[name]
+	if !ok {
+		return nil
+	}
+	s = strings.TrimPrefix(s, "0x")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("Error unmarshaling reserved field %s: %w",
+			name, ErrBadJSON)
+	}
+	switch v := value.(type) {
+	case *byte:
+		if len(buf) != 1 {
+			break
+		}
+		*v = buf[0]
+		return nil
+	case []byte:
+		if len(buf) != len(v) {
+			break
+		}
+		copy(v, buf)
+		return nil
+	case *[]byte:
+		*v = buf
+		return nil
+	}
+	return fmt.Errorf("Error unmarshaling reserved field %s: %w", name,
+		ErrBadJSON)
+}
+
+// getUint64 sets value to the reserved 64 bit field name if it is present
+func (r jsonReserved) getUint64(name string, value *uint64) error {
+	s, ok := r[name]
+	if !ok {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return fmt.Errorf("Error unmarshaling reserved field %s: %w",
+			name, ErrBadJSON)
+	}
+	*value = v
+	return nil
+}
+
+// jsonHeader stores the message header in JSON
+type jsonHeader struct {
+	Eyecatcher string `json:"eyecatcher"`
+	Type       uint8  `json:"type"`
+	Length     uint16 `json:"length"`
+	Version    uint8  `json:"version"`
+	Flag       uint8  `json:"flag"`
+	Pathv2     string `json:"pathv2,omitempty"`
+	Path       string `json:"path"`
+}
+
+// jsonType returns the type discriminator of the message in JSON, e.g.,
+// "accept_smcr_v2"
+func (h *Header) jsonType() string {
+	typ := strings.ToLower(h.Type.String())
+	if h.Type == TypeAccept || h.Type == TypeConfirm {
+		switch h.Path {
+		case SMCTypeR:
+			typ += "_smcr"
+		case SMCTypeD:
+			typ += "_smcd"
+		}
+	}
+	if h.Version == SMCv2 {
+		typ += "_v2"
+	}
+	return typ
+}
+
+// fromJSON sets the header to the header j in JSON with the reserved fields
+// in reserved
+func (h *Header) fromJSON(j *jsonHeader, reserved jsonReserved) error {
+	eyecatcher, err := parseJSONEyecatcher(j.Eyecatcher)
+	if err != nil {
+		return err
+	}
+	path, err := parseJSONPath(j.Path)
+	if err != nil {
+		return err
+	}
+	h.Eyecatcher = eyecatcher
+	h.Type = MsgType(j.Type)
+	h.Length = j.Length
+	h.Version = j.Version
+	h.Flag = j.Flag
+	h.Path = path
+	if h.Type == TypeProposal && h.Version == SMCv2 {
+		h.Pathv2, err = parseJSONPath(j.Pathv2)
+		return err
+	}
+	return reserved.get("header", &h.reserved)
+}
+
+// json returns the header in JSON and adds its reserved fields to reserved
+func (h *Header) json(reserved jsonReserved) jsonHeader {
+	j := jsonHeader{
+		Eyecatcher: h.Eyecatcher.String(),
+		Type:       uint8(h.Type),
+		Length:     h.Length,
+		Version:    h.Version,
+		Path:       h.Path.String(),
+	}
+	if h.Type == TypeProposal && h.Version == SMCv2 {
+		j.Pathv2 = h.Pathv2.String()
+		return j
+	}
+	j.Flag = h.Flag
+	reserved.add("header", h.reserved)
+	return j
+}
+
+// jsonMessage stores the common fields of a message in JSON
+type jsonMessage struct {
+	Type     string       `json:"type"`
+	Header   jsonHeader   `json:"header"`
+	Trailer  string       `json:"trailer"`
+	Reserved jsonReserved `json:"reserved,omitempty"`
+}
+
+// newJSONMessage returns the common fields of the message with header h and
+// trailer t in JSON. If reserved is true, the reserved fields are included
+func newJSONMessage(h *Header, t Trailer, reserved bool) jsonMessage {
+	j := jsonMessage{
+		Type:    h.jsonType(),
+		Trailer: t.String(),
+	}
+	if reserved {
+		j.Reserved = jsonReserved{}
+	}
+	j.Header = h.json(j.Reserved)
+	return j
+}
+
+// toMessage sets the header h and the trailer t of a message to the common
+// fields in JSON
+func (j *jsonMessage) toMessage(h *Header, t *Trailer) error {
+	if err := h.fromJSON(&j.Header, j.Reserved); err != nil {
+		return err
+	}
+	if h.jsonType() != j.Type {
+		return fmt.Errorf("Error unmarshaling CLC %s: type %q: %w",
+			h.Type, j.Type, ErrBadJSON)
+	}
+	trailer, err := parseJSONEyecatcher(j.Trailer)
+	if err != nil {
+		return err
+	}
+	*t = Trailer(trailer)
+	return nil
+}
+
+// jsonIPInfo stores the IP/prefix info of a proposal message in JSON
+type jsonIPInfo struct {
+	Prefix          string       `json:"prefix"`
+	PrefixLen       uint8        `json:"prefix_len"`
+	IPv6PrefixesCnt uint8        `json:"ipv6_prefixes_cnt"`
+	IPv6Prefixes    []IPv6Prefix `json:"ipv6_prefixes"`
+}
+
+// jsonFCE stores the first contact extension of a SMCv2 message in JSON
+type jsonFCE struct {
+	OSType   string `json:"os_type"`
+	Release  uint8  `json:"release"`
+	Hostname EID    `json:"hostname"`
+
+	// SMCv2.1 fields, only present if Release >= 1
+	MaxConns    *uint8  `json:"max_conns,omitempty"`
+	MaxLinks    *uint8  `json:"max_links,omitempty"`
+	FeatureMask *uint16 `json:"feature_mask,omitempty"`
+}
+
+// newJSONFCE returns the first contact extension in JSON. The SMCv2.1 fields
+// are only included if v2x is true
+func newJSONFCE(osType OSType, release uint8, hostname EID, v2x bool,
+	maxConns, maxLinks uint8, featureMask FeatureMask) *jsonFCE {
+	fce := &jsonFCE{
+		OSType:   osType.String(),
+		Release:  release,
+		Hostname: hostname,
+	}
+	if v2x {
+		mask := uint16(featureMask)
+		fce.MaxConns = &maxConns
+		fce.MaxLinks = &maxLinks
+		fce.FeatureMask = &mask
+	}
+	return fce
+}
+
+// toFCE sets the fields of a first contact extension to the first contact
+// extension in JSON
+func (j *jsonFCE) toFCE(osType *OSType, release *uint8, hostname *EID,
+	maxConns, maxLinks *uint8, featureMask *FeatureMask) error {
+	o, err := parseJSONOSType(j.OSType)
+	if err != nil {
+		return err
+	}
+	*osType, *release, *hostname = o, j.Release, j.Hostname
+	if j.MaxConns != nil {
+		*maxConns = *j.MaxConns
+	}
+	if j.MaxLinks != nil {
+		*maxLinks = *j.MaxLinks
+	}
+	if j.FeatureMask != nil {
+		*featureMask = FeatureMask(*j.FeatureMask)
+	}
+	return nil
+}
+
+// jsonIP converts the IP address ip to a string for JSON
+func jsonIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// jsonMarshaler is implemented by messages that can be converted to JSON
+// with or without reserved fields
+type jsonMarshaler interface {
+	marshalJSON(reserved bool) ([]byte, error)
+}
+
+// reservedJSON is a message that is converted to JSON including reserved
+// fields
+type reservedJSON struct {
+	msg Message
+}
+
+// MarshalJSON converts the message to JSON including reserved fields
+func (r reservedJSON) MarshalJSON() ([]byte, error) {
+	if m, ok := r.msg.(jsonMarshaler); ok {
+		return m.marshalJSON(true)
+	}
+	return json.Marshal(r.msg)
+}
+
+// ReservedJSON returns a json.Marshaler that converts msg to JSON including
+// its reserved fields under the key "reserved"
+func ReservedJSON(msg Message) json.Marshaler {
+	return reservedJSON{msg}
+}
+
+// newJSONMessageType returns an empty message for the type discriminator typ
+// in JSON or nil if typ is unknown
+func newJSONMessageType(typ string) Message {
+	switch typ {
+	case "proposal":
+		return &Proposal{}
+	case "proposal_v2":
+		return &ProposalV2{}
+	case "accept_smcr":
+		return &AcceptSMCR{}
+	case "accept_smcr_v2":
+		return &AcceptSMCRv2{}
+	case "accept_smcd":
+		return &AcceptSMCD{}
+	case "accept_smcd_v2":
+		return &AcceptSMCDv2{}
+	case "confirm_smcr":
+		return &ConfirmSMCR{}
+	case "confirm_smcr_v2":
+		return &ConfirmSMCRv2{}
+	case "confirm_smcd":
+		return &ConfirmSMCD{}
+	case "confirm_smcd_v2":
+		return &ConfirmSMCDv2{}
+	case "decline":
+		return &Decline{}
+	case "decline_v2":
+		return &DeclineV2{}
+	}
+	return nil
+}
+
+// UnmarshalJSON converts the JSON data of a message to a message of the type
+// in its "type" field. Reserved fields are restored if data contains them,
+// e.g., if it was created with ReservedJSON. The Raw bytes of the message are
+// not restored, use Marshal to convert the message to bytes
+func UnmarshalJSON(data []byte) (Message, error) {
+	var j struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	msg := newJSONMessageType(j.Type)
+	if msg == nil {
+		return nil, fmt.Errorf("Error unmarshaling CLC message type "+
+			"%q: %w", j.Type, ErrUnknownType)
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,292 @@
+package clc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	// prepare decline message
+	declineMsg := "e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9"
+	msg, err := hex.DecodeString(declineMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	decline, _, err := NewMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decline.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// check json output without reserved fields
+	want := `{"type":"decline","header":{"eyecatcher":"SMC-R",` +
+		`"type":4,"length":28,"version":1,"flag":0,` +
+		`"path":"SMC-R"},"trailer":"SMC-R",` +
+		`"sender_peer_id":{"raw":"2525252525252500",` +
+		`"decoded":"9509@25:25:25:25:25:00"},` +
+		`"peer_diagnosis":{"raw":50528256,` +
+		`"decoded":"no SMC device found (R or D)"}}`
+	b, err := json.Marshal(decline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("json.Marshal(decline) = %s; want %s", got, want)
+	}
+
+	// check json output with reserved fields
+	want = `{"type":"decline","header":{"eyecatcher":"SMC-R",` +
+		`"type":4,"length":28,"version":1,"flag":0,` +
+		`"path":"SMC-R"},"trailer":"SMC-R",` +
+		`"reserved":{"header":"0x0","reserved":"0x00000000"},` +
+		`"sender_peer_id":{"raw":"2525252525252500",` +
+		`"decoded":"9509@25:25:25:25:25:00"},` +
+		`"peer_diagnosis":{"raw":50528256,` +
+		`"decoded":"no SMC device found (R or D)"}}`
+	b, err = json.Marshal(ReservedJSON(decline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("json.Marshal(ReservedJSON(decline)) = %s; want %s",
+			got, want)
+	}
+}
+
+func TestMarshalJSONProposalV2(t *testing.T) {
+	// prepare smc-d only proposal v2 message with SMCv2.1 fields and a
+	// virtual ISM device
+	proposal := &ProposalV2{
+		Header: Header{Type: TypeProposal, Version: SMCv2,
+			Pathv2: SMCTypeD, Path: SMCTypeN},
+		EIDNumber: 1,
+		GIDNumber: 2,
+		Release:   SMCRelease1,
+		SEIDInd:   1,
+		MaxConns:  255,
+		MaxLinks:  3,
+	}
+	copy(proposal.Eyecatcher[:], SMCREyecatcher)
+	copy(proposal.Trailer[:], SMCREyecatcher)
+	copy(proposal.EIDArea[0][:], "ThisIsSMCv2EID01")
+	copy(proposal.SEID[:], "ThisIsSMCv2EID02")
+	proposal.GIDArea[0] = GIDEntry{GID: 1, VCHID: 0xffff}
+	proposal.GIDArea[1] = GIDEntry{GID: 2, VCHID: 0xffff}
+
+	// convert to json and back
+	b, err := json.Marshal(proposal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Type   string
+		IPInfo *struct{} `json:"ip_info"`
+		V2Ext  struct {
+			MaxConns uint8 `json:"max_conns"`
+			EIDArea  []struct {
+				Decoded string
+			} `json:"eid_area"`
+		} `json:"v2_ext"`
+		SMCDv2Ext struct {
+			SEID struct {
+				Decoded string
+			}
+			GIDArea []struct {
+				GID    uint64
+				GIDExt uint64 `json:"gid_ext"`
+			} `json:"gid_area"`
+		} `json:"smcdv2_ext"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// check json output
+	if got.Type != "proposal_v2" || got.IPInfo != nil {
+		t.Errorf("json.Marshal(proposal) = %s; want proposal_v2 "+
+			"without ip info", b)
+	}
+	if got.V2Ext.MaxConns != 255 || len(got.V2Ext.EIDArea) != 1 ||
+		got.V2Ext.EIDArea[0].Decoded != "ThisIsSMCv2EID01" {
+		t.Errorf("json.Marshal(proposal) = %s; want v2 extension", b)
+	}
+	if got.SMCDv2Ext.SEID.Decoded != "ThisIsSMCv2EID02" ||
+		len(got.SMCDv2Ext.GIDArea) != 1 ||
+		got.SMCDv2Ext.GIDArea[0].GIDExt != 2 {
+		t.Errorf("json.Marshal(proposal) = %s; want smc-dv2 extension",
+			b)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	// smc-d only proposal v2 message with a virtual ISM device
+	proposal := &ProposalV2{
+		Header: Header{Type: TypeProposal, Version: SMCv2,
+			Pathv2: SMCTypeD, Path: SMCTypeN},
+		EIDNumber: 1,
+		GIDNumber: 2,
+		Release:   SMCRelease1,
+		SEIDInd:   1,
+		MaxConns:  255,
+		MaxLinks:  3,
+	}
+	copy(proposal.Eyecatcher[:], SMCREyecatcher)
+	copy(proposal.EIDArea[0][:], "ThisIsSMCv2EID01")
+	copy(proposal.SEID[:], "ThisIsSMCv2EID02")
+	proposal.GIDArea[0] = GIDEntry{GID: 1, VCHID: 0xffff}
+	proposal.GIDArea[1] = GIDEntry{GID: 2, VCHID: 0xffff}
+	proposalV2, err := proposal.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msgBytes := range []string{
+		// decline, decline v2
+		"e2d4c3d904001c102525252525252500" +
+			"0303000000000000e2d4c3d9",
+		"e2d4c3d904001c202525252525252500" +
+			"0303000020000000e2d4c3d9",
+		// smc-r proposal with ipv4 prefix
+		"e2d4c3d901003410b1a098039babcdef" +
+			"fe800000000000009a039bfffeabcdef" +
+			"98039babcdef00007f00000008000000" +
+			"e2d4c3d9",
+		// smc-r and smc-d proposal with ipv6 prefix
+		"e2d4c3d901006d13394498039babcdef" +
+			"fe800000000000009a039bfffeabcdef" +
+			"98039babcdef00280123456789abcdef" +
+			"00000000000000000000000000000000" +
+			"00000000000000000000000000000000" +
+			"00000000000000010000000000000000" +
+			"000000000000000180e2d4c3d9",
+		hex.EncodeToString(proposalV2),
+		// smc-r accept, smc-d accept
+		"e2d4c3d902004418b1a098039babcdef" +
+			"fe800000000000009a039bfffeabcdef" +
+			"98039babcdef0000e40000157d010000" +
+			"0005230000000000f0a600000072f5fe" +
+			"e2d4c3d9",
+		"e2d4c3c4020030110123456789abcdef" +
+			"0123456789abcdefff100000ffffffff" +
+			"000000000000000000000000e2d4c3c4",
+		// smc-d v2 accept with SMCv2.1 first contact extension
+		"e2d4c3c4" + "02" + "0082" + "2" + "9" + "0123456789abcdef" +
+			"0123456789abcdef" + "ff" + "1" + "0" + "0000" +
+			"ffffffff" + "ffff" +
+			"546869734973534d4376324549443031" +
+			"00000000000000000000000000000000" +
+			"0000000000000002" +
+			"00" + "2" + "1" + "0000" +
+			"546869734973486f73746e616d653031" +
+			"00000000000000000000000000000000" +
+			"00" + "00" + "0001" + "00000000" + "0000000000000000" +
+			"e2d4c3c4",
+		// smc-r v2 confirm with first contact and gid list extensions
+		"e2d4c3d9" + "03" + "00b4" + "2" + "8" + "b1a098039babcdef" +
+			"fe800000000000009a039bfffeabcdef" +
+			"98039babcdef" + "0000e5" + "0000187f" + "01" +
+			"00000006" + "2" + "3" + "00" + "00000000f0a40000" +
+			"00" + "0d89a4" +
+			"546869734973534d4376324549443031" +
+			"00000000000000000000000000000000" +
+			"0000000000000000" +
+			"00" + "2" + "0" + "0000" +
+			"546869734973486f73746e616d653031" +
+			"00000000000000000000000000000000" +
+			"02" + "000000" +
+			"fd000000000000000000000000000001" +
+			"fd000000000000000000000000000002" +
+			"e2d4c3d9",
+	} {
+		msg, err := hex.DecodeString(msgBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, _, err := NewMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Parse(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		// convert json without reserved fields back to the same json
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := UnmarshalJSON(b)
+		if err != nil {
+			t.Fatalf("UnmarshalJSON(%s) = %v", b, err)
+		}
+		if reflect.TypeOf(got) != reflect.TypeOf(m) {
+			t.Errorf("UnmarshalJSON(%s) = %T; want %T", b, got,
+				m)
+		}
+		j, err := json.Marshal(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(j, b) {
+			t.Errorf("json.Marshal(UnmarshalJSON(%s)) = %s", b, j)
+		}
+
+		// convert json with reserved fields back to the same message
+		b, err = json.Marshal(ReservedJSON(m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = UnmarshalJSON(b)
+		if err != nil {
+			t.Fatalf("UnmarshalJSON(%s) = %v", b, err)
+		}
+		raw, err := got.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(raw, msg) {
+			t.Errorf("UnmarshalJSON(%s).Marshal() = %x; want %x", b,
+				raw, msg)
+		}
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	decline := `{"type":"decline","header":{"eyecatcher":"SMC-R",` +
+		`"type":4,"length":28,"version":1,"flag":0,` +
+		`"path":"SMC-R"},"trailer":"SMC-R",` +
+		`"sender_peer_id":{"raw":"2525252525252500"},` +
+		`"peer_diagnosis":{"raw":50528256}}`
+	for _, test := range []struct {
+		data string
+		err  error
+	}{
+		{strings.Replace(decline, `"decline"`, `"unknown"`, 1),
+			ErrUnknownType},
+		{strings.Replace(decline, `"decline"`, `"decline_v2"`, 1),
+			ErrBadJSON},
+		{strings.Replace(decline, `"type":4`, `"type":2`, 1),
+			ErrBadJSON},
+		{strings.Replace(decline, `"eyecatcher":"SMC-R"`,
+			`"eyecatcher":"Unknown"`, 1), ErrBadEyecatcher},
+		{strings.Replace(decline, `"2525252525252500"`, `"25"`, 1),
+			ErrBadJSON},
+	} {
+		_, err := UnmarshalJSON([]byte(test.data))
+		if !errors.Is(err, test.err) {
+			t.Errorf("UnmarshalJSON(%s) = %v; want %v", test.data,
+				err, test.err)
+		}
+	}
+}
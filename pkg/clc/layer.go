@@ -0,0 +1,112 @@
+package clc
+
+import (
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// LayerTypeSMCCLC is the gopacket layer type of SMC CLC messages
+var LayerTypeSMCCLC gopacket.LayerType
+
+func init() {
+	// register layer type in init to avoid an initialization cycle with
+	// the decoder
+	LayerTypeSMCCLC = gopacket.RegisterLayerType(1900,
+		gopacket.LayerTypeMetadata{
+			Name:    "SMCCLC",
+			Decoder: gopacket.DecodeFunc(decodeSMCCLC),
+		})
+}
+
+// CLC is a gopacket layer that contains a CLC message in the payload of a
+// TCP packet
+type CLC struct {
+	layers.BaseLayer
+	Message Message
+}
+
+// LayerType returns the layer type of the CLC layer
+func (c *CLC) LayerType() gopacket.LayerType {
+	return LayerTypeSMCCLC
+}
+
+// CanDecode returns the layer types the CLC layer can decode
+func (c *CLC) CanDecode() gopacket.LayerClass {
+	return LayerTypeSMCCLC
+}
+
+// NextLayerType returns the layer type of the data after the CLC message. If
+// there is another CLC message in the same TCP payload, it is also decoded as
+// a CLC layer
+func (c *CLC) NextLayerType() gopacket.LayerType {
+	if HasEyecatcher(c.Payload) {
+		return LayerTypeSMCCLC
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the CLC message at the beginning of data
+func (c *CLC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	msg, length, err := NewMessage(data)
+	if err != nil {
+		return err
+	}
+	if msg == nil {
+		df.SetTruncated()
+		return &ParseError{Offset: 0, Err: ErrBadEyecatcher}
+	}
+	if len(data) < int(length) {
+		df.SetTruncated()
+		return &ParseError{Type: MsgType(data[4]), Offset: len(data),
+			Err: ErrTooShort}
+	}
+	if err := msg.Parse(data[:length]); err != nil {
+		return err
+	}
+	c.Message = msg
+	c.Contents = data[:length]
+	c.Payload = data[length:]
+	return nil
+}
+
+// SerializeTo writes the marshaled CLC message to b
+func (c *CLC) SerializeTo(b gopacket.SerializeBuffer,
+	opts gopacket.SerializeOptions) error {
+	buf, err := c.Message.Marshal()
+	if err != nil {
+		return err
+	}
+	bytes, err := b.PrependBytes(len(buf))
+	if err != nil {
+		return err
+	}
+	copy(bytes, buf)
+	return nil
+}
+
+// decodeSMCCLC decodes the CLC message in data. If data does not start with
+// an eyecatcher, it is decoded as payload
+func decodeSMCCLC(data []byte, p gopacket.PacketBuilder) error {
+	if !HasEyecatcher(data) {
+		return gopacket.LayerTypePayload.Decode(data, p)
+	}
+	c := &CLC{}
+	if err := c.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(c)
+	if len(c.Payload) == 0 {
+		return nil
+	}
+	return p.NextDecoder(c.NextLayerType())
+}
+
+// RegisterTCPPort registers the CLC layer as the application layer of TCP
+// packets with port, so gopacket decodes TCP payloads starting with an
+// eyecatcher as CLC messages. Other payloads are still decoded as payload.
+// Note that gopacket only decodes TCP payloads with the registered layer type
+// if the DecodeStreamsAsDatagrams decode option is set. Otherwise, use
+// gopacket.NewPacket() with LayerTypeSMCCLC on the (reassembled) TCP payload
+func RegisterTCPPort(port layers.TCPPort) {
+	layers.RegisterTCPPortLayerType(port, LayerTypeSMCCLC)
+}
@@ -0,0 +1,97 @@
+package clc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+func TestLayerSMCCLC(t *testing.T) {
+	// prepare payload with a decline and an smc-d accept message
+	declineMsg := "e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9"
+	acceptMsg := "e2d4c3c4020030110123456789abcdef" +
+		"0123456789abcdefff100000ffffffff" +
+		"000000000000000000000000e2d4c3c4"
+	payload, err := hex.DecodeString(declineMsg + acceptMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// decode payload
+	packet := gopacket.NewPacket(payload, LayerTypeSMCCLC, gopacket.Default)
+	if packet.ErrorLayer() != nil {
+		t.Fatal(packet.ErrorLayer().Error())
+	}
+	var msgs []Message
+	for _, l := range packet.Layers() {
+		if c, ok := l.(*CLC); ok {
+			msgs = append(msgs, c.Message)
+		}
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("len(msgs) = %d; want %d", len(msgs), 2)
+	}
+	if _, ok := msgs[0].(*Decline); !ok {
+		t.Errorf("msgs[0] = %T; want *Decline", msgs[0])
+	}
+	if _, ok := msgs[1].(*AcceptSMCD); !ok {
+		t.Errorf("msgs[1] = %T; want *AcceptSMCD", msgs[1])
+	}
+
+	// decode other payload
+	packet = gopacket.NewPacket([]byte("GET / HTTP/1.1\r\n"),
+		LayerTypeSMCCLC, gopacket.Default)
+	if packet.Layer(LayerTypeSMCCLC) != nil ||
+		packet.ApplicationLayer() == nil {
+		t.Errorf("packet = %s; want payload only", packet)
+	}
+
+	// decode truncated message
+	packet = gopacket.NewPacket(payload[:20], LayerTypeSMCCLC,
+		gopacket.Default)
+	if packet.ErrorLayer() == nil {
+		t.Errorf("packet = %s; want decode error", packet)
+	}
+}
+
+func TestLayerSMCCLCSerialize(t *testing.T) {
+	// prepare decline message
+	declineMsg := "e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9"
+	msg, err := hex.DecodeString(declineMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	decline := &Decline{}
+	if err := decline.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// serialize tcp packet with clc message
+	tcp := &layers.TCP{SrcPort: 50000, DstPort: 50001, ACK: true,
+		Window: 1024}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, tcp,
+		&CLC{Message: decline}); err != nil {
+		t.Fatal(err)
+	}
+
+	// decode tcp packet with registered port
+	RegisterTCPPort(50001)
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeTCP,
+		gopacket.DecodeOptions{DecodeStreamsAsDatagrams: true})
+	l := packet.Layer(LayerTypeSMCCLC)
+	if l == nil {
+		t.Fatalf("packet = %s; want CLC layer", packet)
+	}
+	if !bytes.Equal(l.LayerContents(), msg) {
+		t.Errorf("l.LayerContents() = %x; want %x", l.LayerContents(),
+			msg)
+	}
+}
@@ -1,9 +1,6 @@
 package clc
 
-import (
-	"encoding/binary"
-	"log"
-)
+import "encoding/binary"
 
 const (
 	// MaxMessageSize is the maximum allowed CLC message size in bytes
@@ -13,7 +10,8 @@ const (
 
 // Message is a type for all clc messages
 type Message interface {
-	Parse([]byte)
+	Parse([]byte) error
+	Marshal() ([]byte, error)
 	String() string
 	Reserved() string
 	Dump() string
@@ -21,59 +19,71 @@ type Message interface {
 
 // NewMessage checks buf for a clc message and returns an empty message of
 // respective type and its length in bytes. Parse the new message before
-// actually using it
-func NewMessage(buf []byte) (Message, uint16) {
+// actually using it. If buf does not start with an eyecatcher, NewMessage
+// returns a nil message and no error
+func NewMessage(buf []byte) (Message, uint16, error) {
 	// check eyecatcher first
 	if !HasEyecatcher(buf) {
-		return nil, 0
+		return nil, 0, nil
+	}
+
+	// make sure header is complete
+	if len(buf) < HeaderLen {
+		return nil, 0, &ParseError{Offset: len(buf), Err: ErrTooShort}
 	}
 
 	// make sure message is not too big
+	typ := buf[4]
 	length := binary.BigEndian.Uint16(buf[5:7])
 	if length > MaxMessageSize {
-		log.Println("Error parsing CLC header: message too big")
-		errDump(buf[:HeaderLen])
-		return nil, 0
+		return nil, 0, &ParseError{Type: MsgType(typ), Offset: 5,
+			Err: ErrTooBig}
 	}
 
 	// return new (empty) message of correct type
-	typ := buf[4]
 	ver := buf[7] >> 4
 	path := Path(buf[7] & 0b00000011)
 	switch typ {
 	case TypeProposal:
 		if ver == SMCv2 {
-			return &ProposalV2{}, length
+			return &ProposalV2{}, length, nil
 		}
-		return &Proposal{}, length
+		return &Proposal{}, length, nil
 	case TypeAccept:
 		// check path to determine if it's smc-r or smc-d
 		switch path {
 		case SMCTypeR:
-			return &AcceptSMCR{}, length
+			if ver == SMCv2 {
+				return &AcceptSMCRv2{}, length, nil
+			}
+			return &AcceptSMCR{}, length, nil
 		case SMCTypeD:
 			if ver == SMCv2 {
-				return &AcceptSMCDv2{}, length
+				return &AcceptSMCDv2{}, length, nil
 			}
-			return &AcceptSMCD{}, length
+			return &AcceptSMCD{}, length, nil
 		}
 	case TypeConfirm:
 		// check path to determine if it's smc-r or smc-d
 		switch path {
 		case SMCTypeR:
-			return &ConfirmSMCR{}, length
+			if ver == SMCv2 {
+				return &ConfirmSMCRv2{}, length, nil
+			}
+			return &ConfirmSMCR{}, length, nil
 		case SMCTypeD:
 			if ver == SMCv2 {
-				return &ConfirmSMCDv2{}, length
+				return &ConfirmSMCDv2{}, length, nil
 			}
-			return &ConfirmSMCD{}, length
+			return &ConfirmSMCD{}, length, nil
 		}
 	case TypeDecline:
 		if ver == SMCv2 {
-			return &DeclineV2{}, length
+			return &DeclineV2{}, length, nil
 		}
-		return &Decline{}, length
+		return &Decline{}, length, nil
 	}
 
-	return nil, 0
+	return nil, 0, &ParseError{Type: MsgType(typ), Offset: 4,
+		Err: ErrUnknownType}
 }
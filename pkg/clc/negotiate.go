@@ -0,0 +1,453 @@
+package clc
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrNoProposal is returned by Negotiate if the message is not a proposal
+var ErrNoProposal = errors.New("message is not a proposal")
+
+// RoCEDevice stores the properties of a local RoCE device
+type RoCEDevice struct {
+	GID net.IP           // gid of ib_device port
+	MAC net.HardwareAddr // mac of ib_device port
+	V2  bool             // RoCEv2 capable, required for SMC-Rv2
+}
+
+// ISMDevice stores the properties of a local ISM device
+type ISMDevice struct {
+	GID    uint64 // ISM GID
+	GIDExt uint64 // GID extension, only used by virtual ISM devices
+	CHID   uint16 // (virtual) channel id
+	V2     bool   // ISMv2 capable, required for SMC-Dv2
+}
+
+// Capabilities stores the local capabilities of a SMC peer that are used
+// to negotiate the answer to a proposal
+type Capabilities struct {
+	PeerID   PeerID  // local peer id
+	Versions []uint8 // supported SMC versions, e.g., SMCv1 and SMCv2
+	Release  uint8   // supported SMCv2 release
+
+	// devices
+	RoCEDevices []RoCEDevice
+	ISMDevices  []ISMDevice
+
+	// SMCv2 enterprise ids, a zero SEID means no SEID
+	UEIDs []EID
+	SEID  EID
+
+	// IP prefixes of the local interface, used by SMCv1
+	Prefixes []net.IPNet
+
+	// first contact extension
+	Hostname    EID
+	MaxConns    uint8
+	MaxLinks    uint8
+	FeatureMask FeatureMask
+}
+
+// supports checks if the local peer supports SMC version
+func (c *Capabilities) supports(version uint8) bool {
+	for _, v := range c.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// ismV2Capable checks if there is an ISMv2 capable local device
+func (c *Capabilities) ismV2Capable() bool {
+	for _, d := range c.ISMDevices {
+		if d.V2 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPrefix checks if the IPv4 prefix or one of the IPv6 prefixes in the
+// proposal match one of the local prefixes. Like in Linux, the IPv6 prefixes
+// are only checked if they are present in the proposal
+func (c *Capabilities) matchPrefix(prefix IPv6Prefix,
+	ipv6Prefixes []IPv6Prefix) bool {
+	prefixes := []IPv6Prefix{prefix}
+	if len(ipv6Prefixes) > 0 {
+		prefixes = ipv6Prefixes
+	}
+	for _, p := range prefixes {
+		for _, l := range c.Prefixes {
+			ones, _ := l.Mask.Size()
+			if ones == int(p.PrefixLen) && l.Contains(p.Prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchEID returns the first EID in the peer's eids that is also a local
+// UEID. Like in the kernel, the order of the peer's EIDs takes precedence
+func (c *Capabilities) matchEID(eids []EID) (EID, bool) {
+	for _, e := range eids {
+		for _, l := range c.UEIDs {
+			if e == l {
+				return e, true
+			}
+		}
+	}
+	return EID{}, false
+}
+
+// negotiation stores the state of a proposal negotiation. The fields are
+// modeled after the smc_init_info of the Linux SMC server
+type negotiation struct {
+	caps *Capabilities
+	rc   PeerDiagnosis
+
+	// proposal
+	version      uint8
+	path         Path
+	pathv2       Path
+	prefix       IPv6Prefix
+	ipv6Prefixes []IPv6Prefix
+	v2           *ProposalV2
+
+	// remaining SMC-R and SMC-D versions
+	smcdV1, smcdV2 bool
+	smcrV1, smcrV2 bool
+}
+
+// storeRC stores the decline reason rc if there is no previous reason
+func (n *negotiation) storeRC(rc PeerDiagnosis) {
+	if n.rc == 0 {
+		n.rc = rc
+	}
+}
+
+// indicated checks if path contains typ
+func indicated(path, typ Path) bool {
+	return path == typ || path == SMCTypeB
+}
+
+// checkV2 checks the SMCv2 part of the proposal and returns the decline
+// reason if neither SMCv1 nor SMCv2 remain possible
+func (n *negotiation) checkV2() PeerDiagnosis {
+	n.smcdV1 = indicated(n.path, SMCTypeD) && n.caps.supports(SMCv1)
+	n.smcrV1 = indicated(n.path, SMCTypeR) && n.caps.supports(SMCv1)
+	if n.version > SMCv1 {
+		n.smcdV2 = indicated(n.pathv2, SMCTypeD)
+		n.smcrV2 = indicated(n.pathv2, SMCTypeR)
+	}
+
+	rc := PeerDiagnosis(0)
+	switch {
+	case !n.smcdV2 && !n.smcrV2:
+		rc = DeclinePeerNoSMC
+	case !n.caps.supports(SMCv2):
+		n.smcdV2, n.smcrV2 = false, false
+		rc = DeclineVersMismat
+	case n.v2.SMCv2Offset == 0:
+		n.smcdV2, n.smcrV2 = false, false
+		rc = DeclineNoV2Ext
+	}
+
+	if n.smcdV2 {
+		switch {
+		case !n.caps.ismV2Capable():
+			n.smcdV2 = false
+			rc = DeclineNoISM2Supp
+		case n.v2.SMCDv2Off == 0:
+			n.smcdV2 = false
+			rc = DeclineNoV2DExt
+		case n.v2.SEIDInd == 0 && n.v2.EIDNumber == 0:
+			n.smcdV2 = false
+			rc = DeclineNoUEID
+		}
+	}
+	if n.smcrV2 && n.v2.EIDNumber == 0 {
+		n.smcrV2 = false
+		rc = DeclineNoUEID
+	}
+
+	if !n.smcdV1 && !n.smcdV2 && !n.smcrV1 && !n.smcrV2 {
+		return rc
+	}
+	return 0
+}
+
+// peerEIDs returns the EIDs in the SMCv2 proposal
+func (n *negotiation) peerEIDs() []EID {
+	num := int(n.v2.EIDNumber)
+	if num > len(n.v2.EIDArea) {
+		num = len(n.v2.EIDArea)
+	}
+	return n.v2.EIDArea[:num]
+}
+
+// findISMv2 returns an SMC-Dv2 accept if there is a local ISMv2 device
+// matching one of the proposed ISM devices
+func (n *negotiation) findISMv2() Message {
+	if !n.smcdV2 {
+		return nil
+	}
+
+	// find local device matching the native or one of the other
+	// proposed devices
+	chids := []uint16{}
+	if n.v2.SMCDGID != 0 {
+		chids = append(chids, n.v2.ISMv2VCHID)
+	}
+	for _, gid := range n.v2.ISMGIDs() {
+		chids = append(chids, gid.VCHID)
+	}
+	var dev *ISMDevice
+	for _, chid := range chids {
+		for i, d := range n.caps.ISMDevices {
+			if d.V2 && d.CHID == chid {
+				dev = &n.caps.ISMDevices[i]
+				break
+			}
+		}
+		if dev != nil {
+			break
+		}
+	}
+	if dev == nil {
+		n.storeRC(DeclineNoSMCD2Dev)
+		return nil
+	}
+
+	// match system eid if indicated or user eids
+	var zero EID
+	eid := n.caps.SEID
+	if eid == zero || n.v2.SEIDInd == 0 || eid != n.v2.SEID {
+		var ok bool
+		if eid, ok = n.caps.matchEID(n.peerEIDs()); !ok {
+			return nil
+		}
+	}
+
+	a := &AcceptSMCDv2{
+		Header:     n.acceptHeader(SMCDEyecatcher, SMCv2, SMCTypeD),
+		GID:        dev.GID,
+		ISMv2VCHID: dev.CHID,
+		EID:        eid,
+		GIDExt:     dev.GIDExt,
+	}
+	a.OSType = Linux
+	a.Release, a.MaxConns, a.MaxLinks, a.FeatureMask = n.fce()
+	a.Hostname = n.caps.Hostname
+	return a
+}
+
+// findISMv1 returns an SMC-D accept if there is a local ISM device
+func (n *negotiation) findISMv1() Message {
+	if !n.smcdV1 {
+		return nil
+	}
+	if len(n.caps.ISMDevices) == 0 {
+		n.storeRC(DeclineNoSMCDDev)
+		return nil
+	}
+	return &AcceptSMCD{
+		Header: n.acceptHeader(SMCDEyecatcher, SMCv1, SMCTypeD),
+		GID:    n.caps.ISMDevices[0].GID,
+	}
+}
+
+// findRoCEv2 returns an SMC-Rv2 accept if there is a local RoCEv2 device
+func (n *negotiation) findRoCEv2() Message {
+	if !n.smcrV2 {
+		return nil
+	}
+	eid, ok := n.caps.matchEID(n.peerEIDs())
+	if !ok {
+		return nil
+	}
+	for _, d := range n.caps.RoCEDevices {
+		if !d.V2 {
+			continue
+		}
+		a := &AcceptSMCRv2{
+			Header: n.acceptHeader(SMCREyecatcher, SMCv2,
+				SMCTypeR),
+			SenderPeerID: n.caps.PeerID,
+			IBGID:        d.GID,
+			IBMAC:        d.MAC,
+			EID:          eid,
+		}
+		a.OSType = Linux
+		a.Release, a.MaxConns, a.MaxLinks, a.FeatureMask = n.fce()
+		a.Hostname = n.caps.Hostname
+		return a
+	}
+	n.storeRC(DeclineNoSMCRDev)
+	return nil
+}
+
+// findRoCEv1 returns an SMC-R accept if there is a local RoCE device
+func (n *negotiation) findRoCEv1() (Message, PeerDiagnosis) {
+	if !n.smcrV1 || len(n.caps.RoCEDevices) == 0 {
+		return nil, DeclineNoSMCDev
+	}
+	d := n.caps.RoCEDevices[0]
+	return &AcceptSMCR{
+		Header:       n.acceptHeader(SMCREyecatcher, SMCv1, SMCTypeR),
+		SenderPeerID: n.caps.PeerID,
+		IBGID:        d.GID,
+		IBMAC:        d.MAC,
+	}, 0
+}
+
+// findDevice finds a local device for the proposal and returns the accept
+// message or the decline reason. The order of the checks follows
+// smc_listen_find_device() in Linux
+func (n *negotiation) findDevice() (Message, PeerDiagnosis) {
+	// ISMv2
+	if a := n.findISMv2(); a != nil {
+		return a, 0
+	}
+
+	// IP prefix check for SMCv1
+	prefixRC := PeerDiagnosis(0)
+	if n.path != SMCTypeN && !n.caps.matchPrefix(n.prefix,
+		n.ipv6Prefixes) {
+		prefixRC = DeclineDiffPrefix
+		n.storeRC(prefixRC)
+	}
+
+	// ISMv1
+	if prefixRC == 0 {
+		if a := n.findISMv1(); a != nil {
+			return a, 0
+		}
+	}
+	if !indicated(n.path, SMCTypeR) && !indicated(n.pathv2, SMCTypeR) {
+		if n.rc != 0 {
+			return nil, n.rc
+		}
+		return nil, DeclineNoSMCDDev
+	}
+
+	// RoCEv2
+	if a := n.findRoCEv2(); a != nil {
+		return a, 0
+	}
+
+	// RoCEv1
+	if prefixRC != 0 {
+		return nil, prefixRC
+	}
+	a, rc := n.findRoCEv1()
+	if a != nil {
+		return a, 0
+	}
+	n.storeRC(rc)
+	return nil, n.rc
+}
+
+// acceptHeader returns the header of an accept message with first contact
+func (n *negotiation) acceptHeader(eyecatcher []byte, version uint8,
+	path Path) Header {
+	h := Header{
+		Type:    TypeAccept,
+		Version: version,
+		Flag:    1,
+		Path:    path,
+	}
+	copy(h.Eyecatcher[:], eyecatcher)
+	return h
+}
+
+// fce returns the negotiated release, maximum connections and links and
+// features for the first contact extension
+func (n *negotiation) fce() (uint8, uint8, uint8, FeatureMask) {
+	release := n.v2.Release
+	if n.caps.Release < release {
+		release = n.caps.Release
+	}
+	if release < SMCRelease1 {
+		return release, 0, 0, 0
+	}
+	maxConns, maxLinks := n.v2.MaxConns, n.v2.MaxLinks
+	if n.caps.MaxConns < maxConns {
+		maxConns = n.caps.MaxConns
+	}
+	if n.caps.MaxLinks < maxLinks {
+		maxLinks = n.caps.MaxLinks
+	}
+	return release, maxConns, maxLinks, n.v2.FeatureMask &
+		n.caps.FeatureMask
+}
+
+// decline returns a decline message with peer diagnosis rc
+func (n *negotiation) decline(rc PeerDiagnosis) Message {
+	h := Header{Type: TypeDecline, Version: SMCv1}
+	copy(h.Eyecatcher[:], SMCREyecatcher)
+	if n.version > SMCv1 {
+		h.Version = SMCv2
+		return &DeclineV2{
+			Header:        h,
+			SenderPeerID:  n.caps.PeerID,
+			PeerDiagnosis: rc,
+			OSType:        Linux,
+		}
+	}
+	return &Decline{
+		Header:        h,
+		SenderPeerID:  n.caps.PeerID,
+		PeerDiagnosis: rc,
+	}
+}
+
+// Negotiate computes the answer of a Linux SMC server with the local
+// capabilities caps to the Proposal or ProposalV2 message proposal. It
+// returns either the Accept message the server would send or a Decline
+// message with the PeerDiagnosis explaining the fallback. Fields of the
+// Accept message that depend on the connection, e.g., QP number, RMB and
+// DMB information, are not set. The Accept message always contains the
+// first contact flag
+func Negotiate(proposal Message, caps *Capabilities) (Message, error) {
+	n := &negotiation{caps: caps}
+	switch p := proposal.(type) {
+	case *Proposal:
+		n.version = p.Version
+		n.path = p.Path
+		n.pathv2 = SMCTypeN
+		n.prefix = IPv6Prefix{p.Prefix, p.PrefixLen}
+		n.ipv6Prefixes = p.IPv6Prefixes
+		n.v2 = &ProposalV2{}
+	case *ProposalV2:
+		n.version = p.Version
+		n.path = p.Path
+		n.pathv2 = p.Pathv2
+		n.prefix = IPv6Prefix{p.Prefix, p.PrefixLen}
+		n.ipv6Prefixes = p.IPv6Prefixes
+		n.v2 = p
+	default:
+		return nil, ErrNoProposal
+	}
+
+	// check SMCv2 and find device
+	rc := n.checkV2()
+	var msg Message
+	if rc == 0 {
+		msg, rc = n.findDevice()
+	}
+	if rc != 0 {
+		msg = n.decline(rc)
+	}
+
+	// marshal and parse the message to set the remaining header fields
+	buf, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.Parse(buf); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
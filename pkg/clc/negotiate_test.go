@@ -0,0 +1,241 @@
+package clc
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// testNegotiateDecline negotiates the proposal with caps and checks if the
+// result is a decline with the wanted peer diagnosis
+func testNegotiateDecline(t *testing.T, proposal Message, caps *Capabilities,
+	want PeerDiagnosis) {
+	msg, err := Negotiate(proposal, caps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got PeerDiagnosis
+	switch d := msg.(type) {
+	case *Decline:
+		got = d.PeerDiagnosis
+	case *DeclineV2:
+		got = d.PeerDiagnosis
+	default:
+		t.Fatalf("msg = %s; want decline", msg)
+	}
+	if got != want {
+		t.Errorf("PeerDiagnosis = %s; want %s", got, want)
+	}
+}
+
+// testEID returns an EID containing s
+func testEID(s string) EID {
+	var eid EID
+	copy(eid[:], s)
+	return eid
+}
+
+func TestNegotiateV1(t *testing.T) {
+	proposal := &Proposal{
+		Header: Header{Type: TypeProposal, Version: SMCv1,
+			Path: SMCTypeR},
+		Prefix:    net.IPv4(192, 168, 1, 0).To4(),
+		PrefixLen: 24,
+	}
+	_, prefix, _ := net.ParseCIDR("192.168.1.0/24")
+	_, otherPrefix, _ := net.ParseCIDR("192.168.2.0/24")
+	gid := net.ParseIP("fe80::9a03:9bff:feab:cdef")
+	caps := &Capabilities{
+		Versions:    []uint8{SMCv1},
+		RoCEDevices: []RoCEDevice{{GID: gid}},
+		Prefixes:    []net.IPNet{*prefix},
+	}
+
+	// smc-r accept
+	msg, err := Negotiate(proposal, caps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept, ok := msg.(*AcceptSMCR)
+	if !ok {
+		t.Fatalf("msg = %s; want SMC-R accept", msg)
+	}
+	if !accept.IBGID.Equal(gid) || accept.Flag != 1 {
+		t.Errorf("accept = %s; want GID %s and first contact",
+			accept, gid)
+	}
+
+	// prefix mismatch
+	caps.Prefixes = []net.IPNet{*otherPrefix}
+	testNegotiateDecline(t, proposal, caps, DeclineDiffPrefix)
+
+	// no device
+	caps.Prefixes = []net.IPNet{*prefix}
+	caps.RoCEDevices = nil
+	testNegotiateDecline(t, proposal, caps, DeclineNoSMCDev)
+
+	// smc-d without device
+	proposal.Path = SMCTypeD
+	testNegotiateDecline(t, proposal, caps, DeclineNoSMCDDev)
+
+	// not a proposal
+	if _, err := Negotiate(&Decline{}, caps); !errors.Is(err,
+		ErrNoProposal) {
+		t.Errorf("err = %v; want %v", err, ErrNoProposal)
+	}
+}
+
+func TestNegotiateV2(t *testing.T) {
+	newProposal := func() *ProposalV2 {
+		p := &ProposalV2{
+			Header: Header{Type: TypeProposal, Version: SMCv2,
+				Pathv2: SMCTypeB, Path: SMCTypeN},
+			SMCv2Offset: 4,
+			EIDNumber:   1,
+			GIDNumber:   1,
+			Release:     SMCRelease1,
+			SEIDInd:     1,
+			SMCDv2Off:   32,
+			MaxConns:    255,
+			MaxLinks:    3,
+			FeatureMask: FeatureEmulatedISMDev,
+			SEID:        testEID("SEID"),
+		}
+		p.EIDArea[0] = testEID("UEID")
+		p.GIDArea[0] = GIDEntry{GID: 1, VCHID: 0x1234}
+		return p
+	}
+	newCaps := func() *Capabilities {
+		return &Capabilities{
+			Versions: []uint8{SMCv1, SMCv2},
+			Release:  SMCRelease1,
+			ISMDevices: []ISMDevice{
+				{GID: 2, CHID: 0x1234, V2: true},
+			},
+			RoCEDevices: []RoCEDevice{
+				{GID: net.ParseIP("fd00::1"), V2: true},
+			},
+			UEIDs:    []EID{testEID("UEID")},
+			SEID:     testEID("SEID"),
+			MaxConns: 16,
+			MaxLinks: 2,
+		}
+	}
+
+	// smc-dv2 accept with seid
+	msg, err := Negotiate(newProposal(), newCaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	acceptD, ok := msg.(*AcceptSMCDv2)
+	if !ok {
+		t.Fatalf("msg = %s; want SMC-Dv2 accept", msg)
+	}
+	if acceptD.GID != 2 || acceptD.ISMv2VCHID != 0x1234 ||
+		acceptD.EID != testEID("SEID") || acceptD.Release != 1 ||
+		acceptD.MaxConns != 16 || acceptD.MaxLinks != 2 ||
+		acceptD.FeatureMask != 0 {
+		t.Errorf("accept = %s; want GID 2, VCHID 4660 and SEID",
+			acceptD)
+	}
+
+	// smc-rv2 accept if smc-d device does not match
+	caps := newCaps()
+	caps.ISMDevices[0].CHID = 0x4321
+	msg, err = Negotiate(newProposal(), caps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acceptR, ok := msg.(*AcceptSMCRv2)
+	if !ok {
+		t.Fatalf("msg = %s; want SMC-Rv2 accept", msg)
+	}
+	if acceptR.EID != testEID("UEID") {
+		t.Errorf("acceptR.EID = %s; want UEID", &acceptR.EID)
+	}
+
+	// no smc-dv2 device
+	p := newProposal()
+	p.Pathv2 = SMCTypeD
+	testNegotiateDecline(t, p, caps, DeclineNoSMCD2Dev)
+
+	// no v2 extension
+	p = newProposal()
+	p.SMCv2Offset = 0
+	testNegotiateDecline(t, p, newCaps(), DeclineNoV2Ext)
+
+	// no smc-dv2 extension
+	p = newProposal()
+	p.Pathv2 = SMCTypeD
+	p.SMCDv2Off = 0
+	testNegotiateDecline(t, p, newCaps(), DeclineNoV2DExt)
+
+	// smc-dv2 accept with ueid if there is no seid
+	p = newProposal()
+	p.Pathv2 = SMCTypeD
+	p.SEIDInd = 0
+	p.SEID = EID{}
+	msg, err = Negotiate(p, newCaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	acceptD, ok = msg.(*AcceptSMCDv2)
+	if !ok {
+		t.Fatalf("msg = %s; want SMC-Dv2 accept", msg)
+	}
+	if acceptD.EID != testEID("UEID") {
+		t.Errorf("acceptD.EID = %s; want UEID", &acceptD.EID)
+	}
+
+	// no seid and no ueid
+	p = newProposal()
+	p.Pathv2 = SMCTypeD
+	p.SEIDInd = 0
+	p.EIDNumber = 0
+	testNegotiateDecline(t, p, newCaps(), DeclineNoUEID)
+
+	// no ueid
+	p = newProposal()
+	p.Pathv2 = SMCTypeR
+	p.EIDNumber = 0
+	testNegotiateDecline(t, p, newCaps(), DeclineNoUEID)
+
+	// no ismv2 support
+	caps = newCaps()
+	caps.ISMDevices[0].V2 = false
+	p = newProposal()
+	p.Pathv2 = SMCTypeD
+	testNegotiateDecline(t, p, caps, DeclineNoISM2Supp)
+
+	// no smcv2 support
+	caps = newCaps()
+	caps.Versions = []uint8{SMCv1}
+	testNegotiateDecline(t, newProposal(), caps, DeclineVersMismat)
+
+	// no smc-rv2 device
+	caps = newCaps()
+	caps.RoCEDevices[0].V2 = false
+	p = newProposal()
+	p.Pathv2 = SMCTypeR
+	testNegotiateDecline(t, p, caps, DeclineNoSMCRDev)
+
+	// first ueid of the peer that matches if several ueids match
+	p = newProposal()
+	p.Pathv2 = SMCTypeR
+	p.EIDNumber = 2
+	p.EIDArea[0] = testEID("UEID2")
+	p.EIDArea[1] = testEID("UEID1")
+	caps = newCaps()
+	caps.UEIDs = []EID{testEID("UEID1"), testEID("UEID2")}
+	msg, err = Negotiate(p, caps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acceptR, ok = msg.(*AcceptSMCRv2)
+	if !ok {
+		t.Fatalf("msg = %s; want SMC-Rv2 accept", msg)
+	}
+	if acceptR.EID != testEID("UEID2") {
+		t.Errorf("acceptR.EID = %s; want UEID2", &acceptR.EID)
+	}
+}
@@ -0,0 +1,66 @@
+package clc
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Reader reads framed CLC messages from an underlying io.Reader, e.g., a TCP
+// connection before SMC takes over or a reassembled TCP stream
+type Reader struct {
+	r   io.Reader
+	buf [MaxMessageSize]byte
+}
+
+// NewReader returns a new Reader that reads CLC messages from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadMessage reads the next CLC message from the underlying reader and
+// returns it parsed. It returns io.EOF if the reader is at the end of the
+// stream before a new message starts and io.ErrUnexpectedEOF if the stream
+// ends within a message. If the data in the stream is not a valid CLC
+// message, ReadMessage returns a ParseError
+func (r *Reader) ReadMessage() (Message, error) {
+	// read header
+	if _, err := io.ReadFull(r.r, r.buf[:HeaderLen]); err != nil {
+		return nil, err
+	}
+
+	// check eyecatcher
+	if !HasEyecatcher(r.buf[:HeaderLen]) {
+		return nil, &ParseError{Offset: 0, Err: ErrBadEyecatcher}
+	}
+
+	// check length, make sure message is not too big or too small
+	typ := MsgType(r.buf[4])
+	length := int(binary.BigEndian.Uint16(r.buf[5:7]))
+	if length > MaxMessageSize {
+		return nil, &ParseError{Type: typ, Offset: 5, Err: ErrTooBig}
+	}
+	if length < HeaderLen+TrailerLen {
+		return nil, &ParseError{Type: typ, Offset: 5, Err: ErrTooShort}
+	}
+
+	// read rest of the message
+	if _, err := io.ReadFull(r.r, r.buf[HeaderLen:length]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	// parse message; use a copy of the buffer because messages keep a
+	// reference to the raw message bytes
+	buf := make([]byte, length)
+	copy(buf, r.buf[:length])
+	msg, _, err := NewMessage(buf)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.Parse(buf); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,86 @@
+package clc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestReaderReadMessage(t *testing.T) {
+	// prepare stream with a decline and an smc-d accept message
+	declineMsg := "e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9"
+	acceptMsg := "e2d4c3c4020030110123456789abcdef" +
+		"0123456789abcdefff100000ffffffff" +
+		"000000000000000000000000e2d4c3c4"
+	stream, err := hex.DecodeString(declineMsg + acceptMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(stream))
+
+	// read decline
+	msg, err := r.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.(*Decline); !ok {
+		t.Errorf("msg = %T; want *Decline", msg)
+	}
+
+	// read accept
+	msg, err = r.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.(*AcceptSMCD); !ok {
+		t.Errorf("msg = %T; want *AcceptSMCD", msg)
+	}
+
+	// end of stream
+	_, err = r.ReadMessage()
+	if err != io.EOF {
+		t.Errorf("err = %v; want %v", err, io.EOF)
+	}
+}
+
+// testReaderError reads a message from the stream in msgBytes and checks if
+// reading returns the wanted error
+func testReaderError(t *testing.T, msgBytes string, want error) {
+	stream, err := hex.DecodeString(msgBytes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(stream))
+	if _, err := r.ReadMessage(); !errors.Is(err, want) {
+		t.Errorf("err = %v; want %v", err, want)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	// stream ends within the header
+	testReaderError(t, "e2d4c3d904", io.ErrUnexpectedEOF)
+
+	// stream ends after the header
+	testReaderError(t, "e2d4c3d904001c10", io.ErrUnexpectedEOF)
+
+	// stream ends within the message
+	testReaderError(t, "e2d4c3d904001c102525252525252500",
+		io.ErrUnexpectedEOF)
+
+	// no eyecatcher
+	testReaderError(t, "0123456704001c10", ErrBadEyecatcher)
+
+	// message too big
+	testReaderError(t, "e2d4c3d9040fff10", ErrTooBig)
+
+	// message too short
+	testReaderError(t, "e2d4c3d904000410", ErrTooShort)
+
+	// invalid trailer
+	testReaderError(t, "e2d4c3d904001c102525252525252500"+
+		"0303000000000000e2d4c3d8", ErrBadTrailer)
+}
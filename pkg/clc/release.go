@@ -0,0 +1,50 @@
+package clc
+
+import "fmt"
+
+// SMCv2 release numbers
+const (
+	SMCRelease0 = 0 // SMCv2.0
+	SMCRelease1 = 1 // SMCv2.1
+)
+
+// SMCv2.1 constants
+const (
+	// FCEv2xLen is the length of the SMCv2.1 fields appended to the
+	// First Contact Extension in Accept and Confirm messages
+	FCEv2xLen = 16
+
+	// VirtualCHIDMin is the first CHID used by virtual ISM devices like
+	// loopback-ism; virtual ISM devices have an extended 128 bit GID
+	VirtualCHIDMin = 0xFF00
+
+	// FeatureEmulatedISMDev is the feature mask bit that indicates
+	// support of emulated/virtual ISM devices
+	FeatureEmulatedISMDev = 1 << 0
+)
+
+// isVirtualCHID checks if chid belongs to a virtual ISM device
+func isVirtualCHID(chid uint16) bool {
+	return chid >= VirtualCHIDMin
+}
+
+// FeatureMask stores the SMCv2.1 feature mask
+type FeatureMask uint16
+
+// String converts the feature mask to a string
+func (f FeatureMask) String() string {
+	features := ""
+	if f&FeatureEmulatedISMDev != 0 {
+		features = "Emulated ISM Device"
+	}
+	if f&^FeatureEmulatedISMDev != 0 {
+		if features != "" {
+			features += ", "
+		}
+		features += "unknown"
+	}
+	if features == "" {
+		features = "none"
+	}
+	return fmt.Sprintf("%#x (%s)", uint16(f), features)
+}
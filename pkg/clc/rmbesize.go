@@ -5,8 +5,12 @@ import "fmt"
 // RMBESize stores the SMC RMBE size
 type RMBESize uint8
 
+// Size returns the uncompressed RMBE size in bytes
+func (s RMBESize) Size() int {
+	return 1 << (s + 14)
+}
+
 // String converts rmbeSize to a string
 func (s RMBESize) String() string {
-	size := 1 << (s + 14)
-	return fmt.Sprintf("%d (%d)", s, size)
+	return fmt.Sprintf("%d (%d)", s, s.Size())
 }
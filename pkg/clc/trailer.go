@@ -1,7 +1,5 @@
 package clc
 
-import "log"
-
 const (
 	// TrailerLen is the length of a CLC message trailer
 	TrailerLen = EyecatcherLen
@@ -11,13 +9,12 @@ const (
 type Trailer Eyecatcher
 
 // Parse parses the CLC message trailer at the end of buf
-func (t *Trailer) Parse(buf []byte) {
+func (t *Trailer) Parse(buf []byte) error {
 	copy(t[:], buf[len(buf)-TrailerLen:])
 	if !HasEyecatcher(t[:]) {
-		log.Println("Error parsing CLC message: invalid trailer")
-		errDump(buf[len(buf)-TrailerLen:])
-		return
+		return ErrBadTrailer
 	}
+	return nil
 }
 
 // String converts the message trailer to a string
@@ -0,0 +1,23 @@
+package clc
+
+import "io"
+
+// Writer writes CLC messages to an underlying io.Writer
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a new Writer that writes CLC messages to w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteMessage marshals msg and writes it to the underlying writer
+func (w *Writer) WriteMessage(msg Message) error {
+	buf, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(buf)
+	return err
+}
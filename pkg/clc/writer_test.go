@@ -0,0 +1,44 @@
+package clc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"testing"
+)
+
+func TestWriterWriteMessage(t *testing.T) {
+	// prepare decline message
+	declineMsg := "e2d4c3d904001c102525252525252500" +
+		"0303000000000000e2d4c3d9"
+	msg, err := hex.DecodeString(declineMsg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	decline := &Decline{}
+	if err := decline.Parse(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// write message twice and read it back
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < 2; i++ {
+		if err := w.WriteMessage(decline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(buf.Bytes(), append(msg, msg...)) {
+		t.Errorf("buf = %x; want %x", buf.Bytes(), append(msg, msg...))
+	}
+	r := NewReader(&buf)
+	for i := 0; i < 2; i++ {
+		m, err := r.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.String() != decline.String() {
+			t.Errorf("m = %s; want %s", m, decline)
+		}
+	}
+}
@@ -1,12 +1,19 @@
 package llc
 
-import "encoding/hex"
+import (
+	"encoding/binary"
+	"encoding/hex"
+)
 
 // BaseMsg stores common message fields
 type BaseMsg struct {
 	Raw    []byte
 	Type   int
 	Length int
+
+	// Version is the LLC version of the message, 2 for SMC-Rv2 messages
+	// and 0 otherwise
+	Version int
 }
 
 // setRaw stores raw message bytes in the message
@@ -24,10 +31,26 @@ func (b *BaseMsg) SetBaseMsg(buffer []byte) {
 	b.Type = int(buffer[0])
 	buffer = buffer[1:]
 
+	// SMC-Rv2 message length is 2 bytes
+	if isV2(byte(b.Type)) {
+		b.Version = 2
+		b.Length = int(binary.BigEndian.Uint16(buffer[0:2]))
+		return
+	}
+
 	// Message length is 1 byte, should be equal to 44
 	b.Length = int(buffer[0])
 }
 
+// baseType returns the message type without the LLC version, e.g.,
+// TypeAddLink for SMC-Rv2 Add Link messages
+func (b *BaseMsg) baseType() int {
+	if b.Version == 2 {
+		return b.Type & 0x0F
+	}
+	return b.Type
+}
+
 // Hex converts the message to a hex dump string
 func (b *BaseMsg) Hex() string {
 	return hex.Dump(b.Raw)
@@ -37,3 +60,8 @@ func (b *BaseMsg) Hex() string {
 func (b *BaseMsg) GetType() int {
 	return b.Type
 }
+
+// raw returns the raw message bytes
+func (b *BaseMsg) raw() []byte {
+	return b.Raw
+}
@@ -2,6 +2,7 @@ package llc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 )
 
@@ -146,3 +147,84 @@ func ParseCDC(buffer []byte) *CDC {
 	c.Parse(buffer)
 	return &c
 }
+
+// jsonCDC stores the CDC message in JSON
+type jsonCDC struct {
+	jsonMessage
+	SeqNum             uint16 `json:"seq_num"`
+	AlertTkn           uint32 `json:"alert_token"`
+	ProdWrap           uint16 `json:"prod_wrap"`
+	ProdCurs           uint32 `json:"prod_curs"`
+	ConsWrap           uint16 `json:"cons_wrap"`
+	ConsCurs           uint32 `json:"cons_curs"`
+	WriterBlocked      bool   `json:"writer_blocked"`
+	UrgentDataPending  bool   `json:"urgent_data_pending"`
+	UrgentDataPresent  bool   `json:"urgent_data_present"`
+	ConsCursUpdate     bool   `json:"cons_curs_update_requested"`
+	FailoverValidation bool   `json:"failover_validation"`
+	SendingDone        bool   `json:"sending_done"`
+	PeerConnClosed     bool   `json:"peer_conn_closed"`
+	AbnormalClose      bool   `json:"abnormal_close"`
+}
+
+// marshalJSON converts the CDC message to JSON
+func (c *CDC) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonCDC{
+		jsonMessage:        c.newJSONMessage(reserved),
+		SeqNum:             c.SeqNum,
+		AlertTkn:           c.AlertTkn,
+		ProdWrap:           c.ProdWrap,
+		ProdCurs:           c.ProdCurs,
+		ConsWrap:           c.ConsWrap,
+		ConsCurs:           c.ConsCurs,
+		WriterBlocked:      c.B,
+		UrgentDataPending:  c.P,
+		UrgentDataPresent:  c.U,
+		ConsCursUpdate:     c.R,
+		FailoverValidation: c.F,
+		SendingDone:        c.D,
+		PeerConnClosed:     c.C,
+		AbnormalClose:      c.A,
+	}
+	j.Reserved.add("res1", c.res1)
+	j.Reserved.add("res2", c.res2)
+	j.Reserved.add("res3", c.res3)
+	j.Reserved.add("res4", c.res4)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the CDC message to JSON
+func (c *CDC) MarshalJSON() ([]byte, error) {
+	return c.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the CDC message
+func (c *CDC) UnmarshalJSON(data []byte) error {
+	var j jsonCDC
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := c.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	c.SeqNum = j.SeqNum
+	c.AlertTkn = j.AlertTkn
+	c.ProdWrap = j.ProdWrap
+	c.ProdCurs = j.ProdCurs
+	c.ConsWrap = j.ConsWrap
+	c.ConsCurs = j.ConsCurs
+	c.B = j.WriterBlocked
+	c.P = j.UrgentDataPending
+	c.U = j.UrgentDataPresent
+	c.R = j.ConsCursUpdate
+	c.F = j.FailoverValidation
+	c.D = j.SendingDone
+	c.C = j.PeerConnClosed
+	c.A = j.AbnormalClose
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": c.res1[:],
+		"res2": c.res2[:],
+		"res3": &c.res3,
+		"res4": c.res4[:],
+	})
+}
@@ -0,0 +1,328 @@
+package llc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ExchangeStatus is the status of a LLC request/reply exchange
+type ExchangeStatus uint8
+
+// LLC exchange status
+const (
+	// ExchangeReplied is a request with a reply
+	ExchangeReplied ExchangeStatus = iota + 1
+
+	// ExchangeMissingReply is a request without a reply
+	ExchangeMissingReply
+
+	// ExchangeDuplicateReply is another reply to an answered request
+	ExchangeDuplicateReply
+
+	// ExchangeUnexpectedReply is a reply without a request
+	ExchangeUnexpectedReply
+)
+
+// String converts the exchange status to a string
+func (e ExchangeStatus) String() string {
+	switch e {
+	case ExchangeReplied:
+		return "Replied"
+	case ExchangeMissingReply:
+		return "Missing Reply"
+	case ExchangeDuplicateReply:
+		return "Duplicate Reply"
+	case ExchangeUnexpectedReply:
+		return "Unexpected Reply"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint8(e))
+	}
+}
+
+// Exchange is a LLC request and its reply
+type Exchange struct {
+	Status ExchangeStatus
+
+	// Type is the LLC message type of the exchange
+	Type int
+
+	// Link is the ID of the link the messages were sent on
+	Link uint8
+
+	// Requester is the side of the link group that sent the request
+	Requester Side
+
+	// Request and Reply are the messages of the exchange. Request is nil
+	// for unexpected replies, Reply is nil for missing replies
+	Request     Message
+	Reply       Message
+	RequestTime time.Time
+	ReplyTime   time.Time
+}
+
+// RTT returns the round-trip time of the exchange or 0 if the request or the
+// reply is missing
+func (e *Exchange) RTT() time.Duration {
+	if e.Request == nil || e.Reply == nil {
+		return 0
+	}
+	return e.ReplyTime.Sub(e.RequestTime)
+}
+
+// String converts the exchange to a string
+func (e *Exchange) String() string {
+	return fmt.Sprintf("%s: Link: %d, Type: %d, RTT: %s", e.Status,
+		e.Link, e.Type, e.RTT())
+}
+
+// ExchangeHandler is called for every finished LLC exchange
+type ExchangeHandler func(exchange *Exchange)
+
+// LinkStats stores the LLC exchange statistics of a link
+type LinkStats struct {
+	Link uint8
+
+	// number of exchanges by status
+	Replied    int
+	Missing    int
+	Duplicate  int
+	Unexpected int
+
+	// round-trip times of replied exchanges
+	MinRTT   time.Duration
+	MaxRTT   time.Duration
+	TotalRTT time.Duration
+}
+
+// AvgRTT returns the average round-trip time of the replied exchanges
+func (s *LinkStats) AvgRTT() time.Duration {
+	if s.Replied == 0 {
+		return 0
+	}
+	return s.TotalRTT / time.Duration(s.Replied)
+}
+
+// String converts the link statistics to a string
+func (s *LinkStats) String() string {
+	return fmt.Sprintf("Link: %d, Replied: %d, Missing Replies: %d, "+
+		"Duplicate Replies: %d, Unexpected Replies: %d, "+
+		"RTT min/avg/max: %s/%s/%s", s.Link, s.Replied, s.Missing,
+		s.Duplicate, s.Unexpected, s.MinRTT, s.AvgRTT(), s.MaxRTT)
+}
+
+// add adds the exchange e to the statistics
+func (s *LinkStats) add(e *Exchange) {
+	switch e.Status {
+	case ExchangeReplied:
+		rtt := e.RTT()
+		if s.Replied == 0 || rtt < s.MinRTT {
+			s.MinRTT = rtt
+		}
+		if rtt > s.MaxRTT {
+			s.MaxRTT = rtt
+		}
+		s.TotalRTT += rtt
+		s.Replied++
+	case ExchangeMissingReply:
+		s.Missing++
+	case ExchangeDuplicateReply:
+		s.Duplicate++
+	case ExchangeUnexpectedReply:
+		s.Unexpected++
+	}
+}
+
+// exchangeKey identifies the request and reply messages of an exchange
+type exchangeKey struct {
+	link      uint8
+	requester Side
+	typ       int
+	id        [16]byte
+}
+
+// getExchangeKey returns the key of the exchange of msg sent on link by side
+// and if msg is a reply. Both sides send requests at the same time, e.g.,
+// Test Link requests with the same user data, so exchanges are identified by
+// the side that sent the request. Test Link messages are identified by their
+// user data, Confirm Link, Add Link and Delete Link messages by their link ID
+// and RKey messages by their (first) rkey. SMC-Rv2 Delete RKey replies do not
+// contain the rkeys, so these exchanges are only identified by the requester.
+// If msg is not part of an exchange, ok is false
+func getExchangeKey(link uint8, side Side, msg Message) (key exchangeKey,
+	reply, ok bool) {
+	key, reply, ok = getMsgKey(msg)
+	key.link = link
+	key.requester = side
+	if reply {
+		key.requester = side.Other()
+	}
+	return key, reply, ok
+}
+
+// getMsgKey returns the message type and id of the exchange key of msg and if
+// msg is a reply
+func getMsgKey(msg Message) (key exchangeKey, reply, ok bool) {
+	switch m := msg.(type) {
+	case *TestLink:
+		key.typ = TypeTestLink
+		key.id = m.UserData
+		return key, m.Reply, true
+	case *ConfirmLink:
+		key.typ = TypeConfirmLink
+		key.id[0] = m.Link
+		return key, m.Reply, true
+	case *AddLink:
+		key.typ = TypeAddLink
+		key.id[0] = m.Link
+		return key, m.Reply, true
+	case *DeleteLink:
+		key.typ = TypeDeleteLink
+		key.id[0] = m.Link
+		return key, m.Reply, true
+	case *ConfirmRKey:
+		key.typ = TypeConfirmRKey
+		binary.BigEndian.PutUint32(key.id[:], m.RKey)
+		return key, m.Reply, true
+	case *DeleteRKey:
+		key.typ = TypeDeleteRKey
+		if m.Version != 2 {
+			binary.BigEndian.PutUint32(key.id[:], m.RKeys[0])
+		}
+		return key, m.Reply, true
+	}
+	return key, false, false
+}
+
+// Correlator matches LLC requests with their replies and measures the
+// round-trip times of the exchanges per link
+type Correlator struct {
+	handler ExchangeHandler
+
+	// pending requests and replied exchanges
+	pending map[exchangeKey]*Exchange
+	replied map[exchangeKey]*Exchange
+
+	stats map[uint8]*LinkStats
+}
+
+// finish finishes the exchange e with status
+func (c *Correlator) finish(e *Exchange, status ExchangeStatus) {
+	e.Status = status
+	s := c.stats[e.Link]
+	if s == nil {
+		s = &LinkStats{Link: e.Link}
+		c.stats[e.Link] = s
+	}
+	s.add(e)
+	if c.handler != nil {
+		c.handler(e)
+	}
+}
+
+// Add adds the LLC message msg sent by side on the link with the link ID link
+// at time ts. If the side is unknown, requests of both sides with the same
+// key replace each other. Messages that are not part of request/reply
+// exchanges are ignored
+func (c *Correlator) Add(ts time.Time, link uint8, side Side, msg Message) {
+	key, reply, ok := getExchangeKey(link, side, msg)
+	if !ok {
+		return
+	}
+	if !reply {
+		// a new request replaces an unanswered request with the same
+		// key
+		if e := c.pending[key]; e != nil {
+			delete(c.pending, key)
+			c.finish(e, ExchangeMissingReply)
+		}
+		delete(c.replied, key)
+		c.pending[key] = &Exchange{
+			Type:        key.typ,
+			Link:        link,
+			Requester:   key.requester,
+			Request:     msg,
+			RequestTime: ts,
+		}
+		return
+	}
+
+	// reply
+	if e := c.pending[key]; e != nil {
+		delete(c.pending, key)
+		e.Reply = msg
+		e.ReplyTime = ts
+		c.replied[key] = e
+		c.finish(e, ExchangeReplied)
+		return
+	}
+	e := &Exchange{Type: key.typ, Link: link, Requester: key.requester,
+		Reply: msg, ReplyTime: ts}
+	if r := c.replied[key]; r != nil {
+		e.Request = r.Request
+		e.RequestTime = r.RequestTime
+		c.finish(e, ExchangeDuplicateReply)
+		return
+	}
+	c.finish(e, ExchangeUnexpectedReply)
+}
+
+// flushPending finishes the pending requests sent before t or all pending
+// requests if all is true as missing replies in the order of their requests
+func (c *Correlator) flushPending(t time.Time, all bool) {
+	var old []*Exchange
+	for key, e := range c.pending {
+		if all || e.RequestTime.Before(t) {
+			delete(c.pending, key)
+			old = append(old, e)
+		}
+	}
+	sort.Slice(old, func(i, j int) bool {
+		return old[i].RequestTime.Before(old[j].RequestTime)
+	})
+	for _, e := range old {
+		c.finish(e, ExchangeMissingReply)
+	}
+}
+
+// FlushOlderThan finishes pending requests sent before t as missing replies
+// and forgets replied exchanges finished before t
+func (c *Correlator) FlushOlderThan(t time.Time) {
+	c.flushPending(t, false)
+	for key, e := range c.replied {
+		if e.ReplyTime.Before(t) {
+			delete(c.replied, key)
+		}
+	}
+}
+
+// FlushAll finishes all pending requests as missing replies, e.g., at the end
+// of a capture
+func (c *Correlator) FlushAll() {
+	c.flushPending(time.Time{}, true)
+	c.replied = make(map[exchangeKey]*Exchange)
+}
+
+// Stats returns the exchange statistics of all links ordered by link ID
+func (c *Correlator) Stats() []*LinkStats {
+	stats := make([]*LinkStats, 0, len(c.stats))
+	for _, s := range c.stats {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Link < stats[j].Link
+	})
+	return stats
+}
+
+// NewCorrelator returns a new Correlator that passes finished exchanges to
+// handler. The handler may be nil
+func NewCorrelator(handler ExchangeHandler) *Correlator {
+	return &Correlator{
+		handler: handler,
+		pending: make(map[exchangeKey]*Exchange),
+		replied: make(map[exchangeKey]*Exchange),
+		stats:   make(map[uint8]*LinkStats),
+	}
+}
@@ -0,0 +1,119 @@
+package llc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCorrelator(t *testing.T) {
+	var got []string
+	c := NewCorrelator(func(e *Exchange) {
+		got = append(got, e.String())
+	})
+	ts := time.Unix(0, 0)
+	ms := time.Millisecond
+
+	// test link exchanges on link 1 with rtt 1ms and 3ms
+	c.Add(ts, 1, SideUnknown, &TestLink{UserData: [16]byte{1}})
+	c.Add(ts.Add(1*ms), 1, SideUnknown, &TestLink{Reply: true,
+		UserData: [16]byte{1}})
+	c.Add(ts.Add(2*ms), 1, SideUnknown, &TestLink{UserData: [16]byte{2}})
+	c.Add(ts.Add(5*ms), 1, SideUnknown, &TestLink{Reply: true,
+		UserData: [16]byte{2}})
+
+	// duplicate and unexpected replies
+	c.Add(ts.Add(6*ms), 1, SideUnknown, &TestLink{Reply: true,
+		UserData: [16]byte{2}})
+	c.Add(ts.Add(7*ms), 1, SideUnknown, &TestLink{Reply: true,
+		UserData: [16]byte{3}})
+
+	// add link on link 1 with reply, confirm rkey on link 2 without reply
+	c.Add(ts.Add(8*ms), 1, SideUnknown, &AddLink{Link: 2})
+	c.Add(ts.Add(10*ms), 1, SideUnknown, &AddLink{Reply: true, Link: 2})
+	c.Add(ts.Add(11*ms), 2, SideUnknown, &ConfirmRKey{RKey: 4097})
+	c.Add(ts.Add(12*ms), 2, SideUnknown, &TestLink{UserData: [16]byte{4}})
+
+	// other messages are ignored
+	c.Add(ts.Add(13*ms), 2, SideUnknown, &CDC{})
+	c.FlushOlderThan(ts.Add(12 * ms))
+	c.FlushAll()
+
+	want := []string{
+		"Replied: Link: 1, Type: 7, RTT: 1ms",
+		"Replied: Link: 1, Type: 7, RTT: 3ms",
+		"Duplicate Reply: Link: 1, Type: 7, RTT: 4ms",
+		"Unexpected Reply: Link: 1, Type: 7, RTT: 0s",
+		"Replied: Link: 1, Type: 2, RTT: 2ms",
+		"Missing Reply: Link: 2, Type: 6, RTT: 0s",
+		"Missing Reply: Link: 2, Type: 7, RTT: 0s",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("exchanges = %q; want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("exchange %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+
+	// check statistics
+	wantStats := []string{
+		"Link: 1, Replied: 3, Missing Replies: 0, " +
+			"Duplicate Replies: 1, Unexpected Replies: 1, " +
+			"RTT min/avg/max: 1ms/2ms/3ms",
+		"Link: 2, Replied: 0, Missing Replies: 2, " +
+			"Duplicate Replies: 0, Unexpected Replies: 0, " +
+			"RTT min/avg/max: 0s/0s/0s",
+	}
+	stats := c.Stats()
+	if len(stats) != len(wantStats) {
+		t.Fatalf("stats = %v; want %q", stats, wantStats)
+	}
+	for i := range wantStats {
+		if stats[i].String() != wantStats[i] {
+			t.Errorf("stats %d = %q; want %q", i, stats[i],
+				wantStats[i])
+		}
+	}
+}
+
+func TestCorrelatorSides(t *testing.T) {
+	var got []string
+	c := NewCorrelator(func(e *Exchange) {
+		got = append(got, fmt.Sprintf("%s, Requester: %s", e,
+			e.Requester))
+	})
+	ts := time.Unix(0, 0)
+	ms := time.Millisecond
+
+	// both sides send test link requests with the same user data
+	c.Add(ts, 1, SideServer, &TestLink{})
+	c.Add(ts.Add(1*ms), 1, SideClient, &TestLink{})
+	c.Add(ts.Add(2*ms), 1, SideServer, &TestLink{Reply: true})
+	c.Add(ts.Add(4*ms), 1, SideClient, &TestLink{Reply: true})
+
+	// both sides delete rmbs with the same rkey
+	c.Add(ts.Add(5*ms), 1, SideClient,
+		&DeleteRKey{Count: 1, RKeys: [8]uint32{1}})
+	c.Add(ts.Add(6*ms), 1, SideServer,
+		&DeleteRKey{Count: 1, RKeys: [8]uint32{1}})
+	c.Add(ts.Add(7*ms), 1, SideClient,
+		&DeleteRKey{Reply: true, RKeys: [8]uint32{1}})
+	c.FlushAll()
+
+	want := []string{
+		"Replied: Link: 1, Type: 7, RTT: 1ms, Requester: client",
+		"Replied: Link: 1, Type: 7, RTT: 4ms, Requester: server",
+		"Replied: Link: 1, Type: 9, RTT: 1ms, Requester: server",
+		"Missing Reply: Link: 1, Type: 9, RTT: 0s, Requester: client",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("exchanges = %q; want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("exchange %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package llc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrBadJSON is the error of JSON that does not contain a valid LLC message
+var ErrBadJSON = errors.New("invalid JSON message")
+
+// jsonValue stores the raw and the decoded value of a message field in JSON
+type jsonValue struct {
+	Raw     interface{} `json:"raw"`
+	Decoded interface{} `json:"decoded"`
+}
+
+// unmarshalJSONRaw sets raw to the raw value of the message field in the
+// JSON data
+func unmarshalJSONRaw(data []byte, raw interface{}) error {
+	j := struct {
+		Raw interface{} `json:"raw"`
+	}{raw}
+	return json.Unmarshal(data, &j)
+}
+
+// MarshalJSON converts the QP MTU to JSON
+func (m QPMTU) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{uint8(m), m.mtu()})
+}
+
+// UnmarshalJSON converts JSON to the QP MTU
+func (m *QPMTU) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONRaw(data, (*uint8)(m))
+}
+
+// MarshalJSON converts the add link reason code to JSON
+func (r AddLinkRsnCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{uint8(r), r.description()})
+}
+
+// UnmarshalJSON converts JSON to the add link reason code
+func (r *AddLinkRsnCode) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONRaw(data, (*uint8)(r))
+}
+
+// MarshalJSON converts the delete link reason code to JSON
+func (d DelLinkRsnCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValue{uint32(d), d.description()})
+}
+
+// UnmarshalJSON converts JSON to the delete link reason code
+func (d *DelLinkRsnCode) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONRaw(data, (*uint32)(d))
+}
+
+// jsonReserved stores the reserved fields of a message in JSON
+type jsonReserved map[string]string
+
+// add adds the reserved field name with value to the reserved fields. If r
+// is nil, reserved fields are not included in JSON and add does nothing
+func (r jsonReserved) add(name string, value interface{}) {
+	if r == nil {
+		return
+	}
+	r[name] = fmt.Sprintf("%#x", value)
+}
+
+// get sets value to the reserved field name if it is present. The value is
+// a *byte, a byte slice with the length of the field, e.g., a slice of a
+// byte array, or a *[]byte
+func (r jsonReserved) get(name string, value interface{}) error {
+	s, ok := r[name]
+	if !ok {
+		return nil
+	}
+	s = strings.TrimPrefix(s, "0x")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("Error unmarshaling reserved field %s: %w",
+			name, ErrBadJSON)
+	}
+	switch v := value.(type) {
+	case *byte:
+		if len(buf) != 1 {
+			break
+		}
+		*v = buf[0]
+		return nil
+	case []byte:
+		if len(buf) != len(v) {
+			break
+		}
+		copy(v, buf)
+		return nil
+	case *[]byte:
+		*v = buf
+		return nil
+	}
+	return fmt.Errorf("Error unmarshaling reserved field %s: %w", name,
+		ErrBadJSON)
+}
+
+// getAll sets the values of all reserved fields in fields by name, see get
+func (r jsonReserved) getAll(fields map[string]interface{}) error {
+	for name, value := range fields {
+		if err := r.get(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonHeader stores the common message fields in JSON
+type jsonHeader struct {
+	Type    int `json:"type"`
+	Length  int `json:"length"`
+	Version int `json:"version,omitempty"`
+}
+
+// jsonMessage stores the common fields of a message in JSON
+type jsonMessage struct {
+	Type     string       `json:"type"`
+	Header   jsonHeader   `json:"header"`
+	Reserved jsonReserved `json:"reserved,omitempty"`
+}
+
+// jsonType returns the type discriminator of the message in JSON, e.g.,
+// "add_link"
+func (b *BaseMsg) jsonType() string {
+	switch b.baseType() {
+	case TypeConfirmLink:
+		return "confirm_link"
+	case TypeAddLink:
+		return "add_link"
+	case TypeAddLinkCont:
+		return "add_link_cont"
+	case TypeDeleteLink:
+		return "delete_link"
+	case TypeRequestAddLink:
+		return "request_add_link"
+	case TypeConfirmRKey:
+		return "confirm_rkey"
+	case TypeTestLink:
+		return "test_link"
+	case TypeConfirmRKeyCont:
+		return "confirm_rkey_cont"
+	case TypeDeleteRKey:
+		return "delete_rkey"
+	case TypeCDC:
+		return "cdc"
+	default:
+		return "other"
+	}
+}
+
+// newJSONMessage returns the common fields of the message b in JSON. If
+// reserved is true, the reserved fields are included
+func (b *BaseMsg) newJSONMessage(reserved bool) jsonMessage {
+	j := jsonMessage{
+		Type: b.jsonType(),
+		Header: jsonHeader{
+			Type:    b.Type,
+			Length:  b.Length,
+			Version: b.Version,
+		},
+	}
+	if reserved {
+		j.Reserved = jsonReserved{}
+	}
+	return j
+}
+
+// fromJSON sets the common message fields to the common fields j in JSON
+func (b *BaseMsg) fromJSON(j *jsonMessage) error {
+	b.Type = j.Header.Type
+	b.Length = j.Header.Length
+	b.Version = j.Header.Version
+	if b.jsonType() != j.Type {
+		return fmt.Errorf("Error unmarshaling LLC type %d: type %q: %w",
+			b.Type, j.Type, ErrBadJSON)
+	}
+	return nil
+}
+
+// jsonIP converts the IP address ip to a string for JSON
+func jsonIP(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// jsonHex converts buf to a hex string for JSON
+func jsonHex(buf []byte) string {
+	return hex.EncodeToString(buf)
+}
+
+// parseJSONIP converts the IP address string s in JSON to an IP address
+func parseJSONIP(s string) (net.IP, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("Error unmarshaling IP %q: %w", s,
+			ErrBadJSON)
+	}
+	return ip, nil
+}
+
+// parseJSONMAC converts the MAC address string s in JSON to a MAC address
+func parseJSONMAC(s string) (net.HardwareAddr, error) {
+	if s == "" {
+		return nil, nil
+	}
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshaling MAC %q: %w", s,
+			ErrBadJSON)
+	}
+	return mac, nil
+}
+
+// parseJSONHex converts the hex string s in JSON to bytes
+func parseJSONHex(s string) ([]byte, error) {
+	buf, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshaling hex %q: %w", s,
+			ErrBadJSON)
+	}
+	return buf, nil
+}
+
+// jsonMarshaler is implemented by messages that can be converted to JSON
+// with or without reserved fields
+type jsonMarshaler interface {
+	marshalJSON(reserved bool) ([]byte, error)
+}
+
+// reservedJSON is a message that is converted to JSON including reserved
+// fields
+type reservedJSON struct {
+	msg Message
+}
+
+// MarshalJSON converts the message to JSON including reserved fields
+func (r reservedJSON) MarshalJSON() ([]byte, error) {
+	if m, ok := r.msg.(jsonMarshaler); ok {
+		return m.marshalJSON(true)
+	}
+	return json.Marshal(r.msg)
+}
+
+// ReservedJSON returns a json.Marshaler that converts msg to JSON including
+// its reserved fields under the key "reserved"
+func ReservedJSON(msg Message) json.Marshaler {
+	return reservedJSON{msg}
+}
+
+// newJSONMessageType returns an empty message for the type discriminator typ
+// in JSON or nil if typ is unknown
+func newJSONMessageType(typ string) Message {
+	switch typ {
+	case "confirm_link":
+		return &ConfirmLink{}
+	case "add_link":
+		return &AddLink{}
+	case "add_link_cont":
+		return &AddLinkCont{}
+	case "delete_link":
+		return &DeleteLink{}
+	case "request_add_link":
+		return &RequestAddLink{}
+	case "confirm_rkey":
+		return &ConfirmRKey{}
+	case "test_link":
+		return &TestLink{}
+	case "confirm_rkey_cont":
+		return &ConfirmRKeyCont{}
+	case "delete_rkey":
+		return &DeleteRKey{}
+	case "cdc":
+		return &CDC{}
+	case "smcd_cdc":
+		return &SMCDCDC{}
+	case "other":
+		return &Other{}
+	}
+	return nil
+}
+
+// UnmarshalJSON converts the JSON data of a message to a message of the type
+// in its "type" field. Reserved fields are restored if data contains them,
+// e.g., if it was created with ReservedJSON. The Raw bytes of the message are
+// only restored for other messages
+func UnmarshalJSON(data []byte) (Message, error) {
+	var j struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	msg := newJSONMessageType(j.Type)
+	if msg == nil {
+		return nil, fmt.Errorf("Error unmarshaling LLC message type "+
+			"%q: %w", j.Type, ErrBadJSON)
+	}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,204 @@
+package llc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	// create bytes of a message
+	msg := "02 2c 00 00 98 03 9b ab  cd ef 00 00 fe 80 00 00" +
+		"00 00 00 00 9a 03 9b ff  fe ab cd ef 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	a := ParseLLC(bytes)
+
+	// check json output without reserved fields
+	want := `{"type":"add_link","header":{"type":2,"length":44},` +
+		`"rsn_code":{"raw":0,"decoded":"unknown"},"reply":false,` +
+		`"reject":false,"sender_mac":"98:03:9b:ab:cd:ef",` +
+		`"sender_gid":"fe80::9a03:9bff:feab:cdef","sender_qp":0,` +
+		`"link":0,"mtu":{"raw":0,"decoded":0},"psn":0}`
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("json.Marshal(a) = %s; want %s", got, want)
+	}
+
+	// check json output with reserved fields
+	want = `{"type":"add_link","header":{"type":2,"length":44},` +
+		`"reserved":{"res1":"0x0","res2":"0x0","res3":"0x0",` +
+		`"res4":"0x00000000000000000000","res5":"0x0000"},` +
+		`"rsn_code":{"raw":0,"decoded":"unknown"},"reply":false,` +
+		`"reject":false,"sender_mac":"98:03:9b:ab:cd:ef",` +
+		`"sender_gid":"fe80::9a03:9bff:feab:cdef","sender_qp":0,` +
+		`"link":0,"mtu":{"raw":0,"decoded":0},"psn":0}`
+	b, err = json.Marshal(ReservedJSON(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("json.Marshal(ReservedJSON(a)) = %s; want %s", got,
+			want)
+	}
+
+	// check type discriminator of all messages
+	for typ, want := range map[byte]string{
+		TypeConfirmLink:     "confirm_link",
+		TypeAddLinkCont:     "add_link_cont",
+		TypeDeleteLink:      "delete_link",
+		TypeConfirmRKey:     "confirm_rkey",
+		TypeTestLink:        "test_link",
+		TypeConfirmRKeyCont: "confirm_rkey_cont",
+		TypeDeleteRKey:      "delete_rkey",
+		TypeCDC:             "cdc",
+		0:                   "other",
+	} {
+		bytes[0] = typ
+		b, err := json.Marshal(ReservedJSON(ParseLLC(bytes)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m struct{ Type string }
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != want {
+			t.Errorf("type = %s; want %s", m.Type, want)
+		}
+	}
+}
+
+// testJSONMessages returns messages of all types with non-zero fields
+func testJSONMessages(t *testing.T) []Message {
+	// fill a message with a byte pattern
+	buf := make([]byte, LLCMsgLen)
+	for i := range buf {
+		buf[i] = byte(i*37 + 11)
+	}
+	buf[1] = LLCMsgLen
+
+	var msgs []Message
+	for _, typ := range []byte{
+		TypeConfirmLink,
+		TypeAddLink,
+		TypeAddLinkCont,
+		TypeDeleteLink,
+		TypeRequestAddLink,
+		TypeConfirmRKey,
+		TypeTestLink,
+		TypeConfirmRKeyCont,
+		TypeDeleteRKey,
+		TypeCDC,
+	} {
+		buf[0] = typ
+		msgs = append(msgs, ParseLLC(buf))
+	}
+
+	// SMC-Rv2 add link message with one rkey pair
+	msg := "22 00 58 00 98 03 9b ab  cd ef 00 00 fe 80 00 00" +
+		"00 00 00 00 9a 03 9b ff  fe ab cd ef 00 00 65 02" +
+		"03 00 00 01 00 00 00 00  00 00 00 00" +
+		"80 00 fe 80 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 01 00 00 00 00 00 00  00 00 00 01" +
+		"00 00 10 01 00 00 20 01  00 00 00 00 00 00 20 00"
+	v2, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs = append(msgs, ParseLLC(v2))
+
+	// SMC-D CDC message and other message
+	buf[0] = TypeCDC
+	msgs = append(msgs, ParseSMCDCDC(buf), ParseLLC(buf[:10]))
+	return msgs
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	for _, m := range testJSONMessages(t) {
+		for _, j := range []json.Marshaler{
+			m.(json.Marshaler),
+			ReservedJSON(m),
+		} {
+			want, err := json.Marshal(j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := UnmarshalJSON(want)
+			if err != nil {
+				t.Fatalf("UnmarshalJSON(%s): %v", want, err)
+			}
+			if reflect.TypeOf(u) != reflect.TypeOf(m) {
+				t.Errorf("UnmarshalJSON(%s) type = %T; want %T",
+					want, u, m)
+			}
+
+			// convert the message back to JSON, with reserved
+			// fields if they were included
+			if _, ok := j.(reservedJSON); ok {
+				j = ReservedJSON(u)
+			} else {
+				j = u.(json.Marshaler)
+			}
+			got, err := json.Marshal(j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("round trip = %s; want %s", got, want)
+			}
+		}
+	}
+
+	// other messages keep their raw bytes
+	o := ParseOther([]byte{1, 2, 3})
+	b, err := json.Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := UnmarshalJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(u.(*Other).Raw, o.Raw) {
+		t.Errorf("raw = %x; want %x", u.(*Other).Raw, o.Raw)
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	for _, data := range []string{
+		// unknown type
+		`{"type":"foo","header":{"type":1,"length":44}}`,
+
+		// type does not match the message type in the header
+		`{"type":"cdc","header":{"type":1,"length":44}}`,
+		`{"type":"smcd_cdc","header":{"type":1,"length":44}}`,
+
+		// invalid fields and reserved fields
+		`{"type":"confirm_link","header":{"type":1,"length":44},` +
+			`"sender_gid":"foo"}`,
+		`{"type":"test_link","header":{"type":7,"length":44},` +
+			`"user_data":"0102"}`,
+		`{"type":"cdc","header":{"type":254,"length":44},` +
+			`"reserved":{"res1":"0x010203"}}`,
+	} {
+		_, err := UnmarshalJSON([]byte(data))
+		if !errors.Is(err, ErrBadJSON) {
+			t.Errorf("UnmarshalJSON(%s) = %v; want %v", data, err,
+				ErrBadJSON)
+		}
+	}
+}
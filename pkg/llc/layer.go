@@ -0,0 +1,95 @@
+package llc
+
+import (
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+// LayerTypeLLC and LayerTypeCDC are the gopacket layer types of LLC and CDC
+// messages
+var (
+	LayerTypeLLC gopacket.LayerType
+	LayerTypeCDC gopacket.LayerType
+)
+
+func init() {
+	// register layer types in init to avoid an initialization cycle with
+	// the decoder
+	LayerTypeLLC = gopacket.RegisterLayerType(1920,
+		gopacket.LayerTypeMetadata{
+			Name:    "SMCLLC",
+			Decoder: gopacket.DecodeFunc(decodeLLC),
+		})
+	LayerTypeCDC = gopacket.RegisterLayerType(1921,
+		gopacket.LayerTypeMetadata{
+			Name:    "SMCCDC",
+			Decoder: gopacket.DecodeFunc(decodeLLC),
+		})
+}
+
+// rawMessage is implemented by messages that store their raw message bytes
+type rawMessage interface {
+	raw() []byte
+}
+
+// LLC is a gopacket layer that contains a LLC or CDC message in the payload
+// of a RoCE packet
+type LLC struct {
+	layers.BaseLayer
+	Message Message
+}
+
+// LayerType returns the layer type of the LLC layer, LayerTypeCDC for CDC
+// messages and LayerTypeLLC for all other messages
+func (l *LLC) LayerType() gopacket.LayerType {
+	if l.Message != nil && l.Message.GetType() == TypeCDC {
+		return LayerTypeCDC
+	}
+	return LayerTypeLLC
+}
+
+// CanDecode returns the layer types the LLC layer can decode
+func (l *LLC) CanDecode() gopacket.LayerClass {
+	return gopacket.NewLayerClass([]gopacket.LayerType{
+		LayerTypeLLC,
+		LayerTypeCDC,
+	})
+}
+
+// NextLayerType returns the layer type of the data after the LLC message
+func (l *LLC) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the LLC message in data
+func (l *LLC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	l.Message = ParseLLC(data)
+	l.Contents = data
+	l.Payload = nil
+	return nil
+}
+
+// SerializeTo writes the raw bytes of the LLC message to b
+func (l *LLC) SerializeTo(b gopacket.SerializeBuffer,
+	opts gopacket.SerializeOptions) error {
+	var raw []byte
+	if r, ok := l.Message.(rawMessage); ok {
+		raw = r.raw()
+	}
+	bytes, err := b.PrependBytes(len(raw))
+	if err != nil {
+		return err
+	}
+	copy(bytes, raw)
+	return nil
+}
+
+// decodeLLC decodes the LLC message in data
+func decodeLLC(data []byte, p gopacket.PacketBuilder) error {
+	l := &LLC{}
+	if err := l.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(l)
+	return nil
+}
@@ -0,0 +1,62 @@
+package llc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+)
+
+func TestLayerLLC(t *testing.T) {
+	// create bytes of a test link and a cdc message
+	testLink := "07 2c 00 80 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+	cdc := "fe 2c 00 01 00 00 00 03  00 00 00 00 00 00 00 0d" +
+		"00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+
+	for _, test := range []struct {
+		msg       string
+		layerType gopacket.LayerType
+		msgType   int
+	}{
+		{testLink, LayerTypeLLC, TypeTestLink},
+		{cdc, LayerTypeCDC, TypeCDC},
+	} {
+		msg, err := hex.DecodeString(strings.Join(
+			strings.Fields(test.msg), ""))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// decode message
+		packet := gopacket.NewPacket(msg, LayerTypeLLC,
+			gopacket.Default)
+		if packet.ErrorLayer() != nil {
+			t.Fatal(packet.ErrorLayer().Error())
+		}
+		l, ok := packet.Layer(test.layerType).(*LLC)
+		if !ok {
+			t.Fatalf("packet has no %s layer", test.layerType)
+		}
+		if got := l.Message.GetType(); got != test.msgType {
+			t.Errorf("l.Message.GetType() = %d; want %d", got,
+				test.msgType)
+		}
+
+		// serialize message
+		buf := gopacket.NewSerializeBuffer()
+		err = gopacket.SerializeLayers(buf,
+			gopacket.SerializeOptions{}, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), msg) {
+			t.Errorf("buf = %x; want %x", buf.Bytes(), msg)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package llc
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// EventType is the type of a link group event
+type EventType uint8
+
+// link group event types
+const (
+	EventLinkUp EventType = iota + 1
+	EventLinkDown
+	EventRKeyAdded
+	EventRKeyDeleted
+)
+
+// String converts the event type to a string
+func (e EventType) String() string {
+	switch e {
+	case EventLinkUp:
+		return "Link Up"
+	case EventLinkDown:
+		return "Link Down"
+	case EventRKeyAdded:
+		return "RKey Added"
+	case EventRKeyDeleted:
+		return "RKey Deleted"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint8(e))
+	}
+}
+
+// Event is a change in a link group
+type Event struct {
+	Type EventType
+
+	// Link is the ID of the link that changed
+	Link uint8
+
+	// RKey and VAddr are the rkey and virtual address of RKey events
+	RKey  uint32
+	VAddr uint64
+
+	// RsnCode is the reason code of link down events
+	RsnCode DelLinkRsnCode
+}
+
+// String converts the event to a string
+func (e *Event) String() string {
+	switch e.Type {
+	case EventLinkUp:
+		return fmt.Sprintf("%s: Link: %d", e.Type, e.Link)
+	case EventLinkDown:
+		return fmt.Sprintf("%s: Link: %d, Reason Code: %s", e.Type,
+			e.Link, e.RsnCode)
+	default:
+		return fmt.Sprintf("%s: Link: %d, RKey: %d, VAddr: %#x", e.Type,
+			e.Link, e.RKey, e.VAddr)
+	}
+}
+
+// EventHandler is called for the events of a link group
+type EventHandler func(event *Event)
+
+// Side is the side of a link group that sent a LLC message. The server sends
+// the requests of Confirm Link and Add Link messages, the client the replies.
+// Both sides send requests of the other message types
+type Side uint8
+
+// link group sides
+const (
+	SideUnknown Side = iota
+	SideServer
+	SideClient
+)
+
+// String converts the side to a string
+func (s Side) String() string {
+	switch s {
+	case SideUnknown:
+		return "unknown"
+	case SideServer:
+		return "server"
+	case SideClient:
+		return "client"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint8(s))
+	}
+}
+
+// Other returns the other side of the link group, i.e., the receiver of a
+// message sent by side s. The other side of an unknown side is unknown
+func (s Side) Other() Side {
+	switch s {
+	case SideServer:
+		return SideClient
+	case SideClient:
+		return SideServer
+	default:
+		return SideUnknown
+	}
+}
+
+// LinkPeer stores one end of a link
+type LinkPeer struct {
+	MAC        net.HardwareAddr
+	GID        net.IP
+	QP         uint32
+	LinkUserID uint32
+}
+
+// Link stores a link of a link group
+type Link struct {
+	ID  uint8
+	Up  bool
+	MTU QPMTU
+
+	// Server sends the requests of Confirm Link and Add Link messages,
+	// Client sends the replies
+	Server LinkPeer
+	Client LinkPeer
+
+	// RKeys stores the virtual addresses of the RMBs registered on the
+	// link by their rkeys
+	RKeys map[uint32]uint64
+}
+
+// rmbSpecs stores the rkeys of the same RMB on all links and the side of the
+// link group that registered the RMB
+type rmbSpecs struct {
+	side  Side
+	specs []RMBSpec
+}
+
+// LinkGroup tracks the state of a SMC-R link group from its LLC messages
+type LinkGroup struct {
+	MaxLinks uint8
+	Links    map[uint8]*Link
+
+	// rmbs stores the RMBs of both sides of the link group
+	rmbs    []*rmbSpecs
+	handler EventHandler
+}
+
+// emit passes the event to the handler
+func (l *LinkGroup) emit(event *Event) {
+	if l.handler != nil {
+		l.handler(event)
+	}
+}
+
+// link returns the link with id and creates it if it does not exist
+func (l *LinkGroup) link(id uint8) *Link {
+	link := l.Links[id]
+	if link == nil {
+		link = &Link{ID: id, RKeys: make(map[uint32]uint64)}
+		l.Links[id] = link
+	}
+	return link
+}
+
+// linkUp sets the link with id to up
+func (l *LinkGroup) linkUp(id uint8) {
+	link := l.link(id)
+	if link.Up {
+		return
+	}
+	link.Up = true
+	l.emit(&Event{Type: EventLinkUp, Link: id})
+}
+
+// linkDown sets the link with id to down and removes its rkeys
+func (l *LinkGroup) linkDown(id uint8, rsn DelLinkRsnCode) {
+	link := l.Links[id]
+	if link == nil || !link.Up {
+		return
+	}
+	link.Up = false
+	for _, rmb := range l.rmbs {
+		rmb.specs = removeLink(rmb.specs, id)
+	}
+	link.RKeys = make(map[uint32]uint64)
+	l.emit(&Event{Type: EventLinkDown, Link: id, RsnCode: rsn})
+}
+
+// removeLink removes the rmb specifications of link id from specs
+func removeLink(specs []RMBSpec, id uint8) []RMBSpec {
+	kept := specs[:0]
+	for _, spec := range specs {
+		if spec.Link != id {
+			kept = append(kept, spec)
+		}
+	}
+	return kept
+}
+
+// addRKey adds the rmb specification spec to the rmb with index i
+func (l *LinkGroup) addRKey(i int, spec RMBSpec) {
+	l.rmbs[i].specs = append(l.rmbs[i].specs, spec)
+	if spec.Link != 0 {
+		l.link(spec.Link).RKeys[spec.RKey] = spec.VAddr
+	}
+	l.emit(&Event{Type: EventRKeyAdded, Link: spec.Link, RKey: spec.RKey,
+		VAddr: spec.VAddr})
+}
+
+// findRMB returns the index of the rmb of side with rkey on link id or -1. If
+// id is 0, the rkey is searched on all links. If side is unknown, the rkey is
+// searched in the rmbs of both sides
+func (l *LinkGroup) findRMB(side Side, id uint8, rkey uint32) int {
+	for i, rmb := range l.rmbs {
+		if side != SideUnknown && rmb.side != side {
+			continue
+		}
+		for _, spec := range rmb.specs {
+			if (id == 0 || spec.Link == id) && spec.RKey == rkey {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// deleteRMB deletes the rmb with index i and the rkeys of all its links
+func (l *LinkGroup) deleteRMB(i int) {
+	rmb := l.rmbs[i]
+	l.rmbs = append(l.rmbs[:i], l.rmbs[i+1:]...)
+	for _, spec := range rmb.specs {
+		if link := l.Links[spec.Link]; link != nil {
+			delete(link.RKeys, spec.RKey)
+		}
+		l.emit(&Event{Type: EventRKeyDeleted, Link: spec.Link,
+			RKey: spec.RKey, VAddr: spec.VAddr})
+	}
+}
+
+// msgLink returns the link ID id of the link a message was sent on. If id is
+// 0 and the link group has only one link, it returns the ID of this link
+func (l *LinkGroup) msgLink(id uint8) uint8 {
+	if id != 0 || len(l.Links) != 1 {
+		return id
+	}
+	for link := range l.Links {
+		id = link
+	}
+	return id
+}
+
+// addConfirmLink adds the confirm link message c
+func (l *LinkGroup) addConfirmLink(c *ConfirmLink) {
+	link := l.link(c.Link)
+	peer := LinkPeer{
+		MAC:        c.SenderMAC,
+		GID:        c.SenderGID,
+		QP:         c.SenderQP,
+		LinkUserID: c.SenderLinkUserID,
+	}
+	if !c.Reply {
+		link.Server = peer
+		if c.MaxLinks != 0 {
+			l.MaxLinks = c.MaxLinks
+		}
+		return
+	}
+	link.Client = peer
+	l.linkUp(c.Link)
+}
+
+// addAddLink adds the add link message a sent on link id by side
+func (l *LinkGroup) addAddLink(id uint8, side Side, a *AddLink) {
+	if a.Reject {
+		if link := l.Links[a.Link]; link != nil && !link.Up {
+			delete(l.Links, a.Link)
+		}
+		return
+	}
+	link := l.Links[a.Link]
+	if !a.Reply && link != nil && !link.Up {
+		// link ID of a deleted link is reused
+		delete(l.Links, a.Link)
+	}
+	link = l.link(a.Link)
+	peer := LinkPeer{
+		MAC: a.SenderMAC,
+		GID: a.SenderGID,
+		QP:  a.SenderQP,
+	}
+	if a.Reply {
+		link.Client = peer
+	} else {
+		link.Server = peer
+	}
+	link.MTU = a.MTU
+
+	// SMC-Rv2 add link messages contain the rkeys of the new link
+	l.addRKeyPairs(id, side, a.Link, a.RKeyPairs)
+}
+
+// addRKeyPairs adds the new rkeys of the link newLink in pairs to the RMBs
+// of side with their reference rkeys on link id
+func (l *LinkGroup) addRKeyPairs(id uint8, side Side, newLink uint8,
+	pairs []RKeyPair) {
+	for _, pair := range pairs {
+		rmb := l.findRMB(side, id, pair.ReferenceRKey)
+		if rmb == -1 {
+			continue
+		}
+		l.addRKey(rmb, RMBSpec{Link: newLink, RKey: pair.NewRKey,
+			VAddr: pair.NewVAddr})
+	}
+}
+
+// addAddLinkCont adds the rkeys of the new link in the add link continuation
+// message a sent by side
+func (l *LinkGroup) addAddLinkCont(id uint8, side Side, a *AddLinkCont) {
+	n := min(int(a.NumRTokens), len(a.RKeyPairs))
+	l.addRKeyPairs(id, side, a.Link, a.RKeyPairs[:n])
+}
+
+// addDeleteLink adds the delete link message d
+func (l *LinkGroup) addDeleteLink(d *DeleteLink) {
+	if !d.All {
+		l.linkDown(d.Link, d.RsnCode)
+		return
+	}
+	ids := make([]int, 0, len(l.Links))
+	for id := range l.Links {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		l.linkDown(uint8(id), d.RsnCode)
+	}
+}
+
+// addConfirmRKey adds the rkeys of a new RMB in the confirm rkey message c
+// sent on link id by side
+func (l *LinkGroup) addConfirmRKey(id uint8, side Side, c *ConfirmRKey) {
+	if c.Reply {
+		// the reply echoes the rkey of the request of the other side
+		if !c.Reject {
+			return
+		}
+		if rmb := l.findRMB(side.Other(), id, c.RKey); rmb != -1 {
+			l.deleteRMB(rmb)
+		}
+		return
+	}
+	l.rmbs = append(l.rmbs, &rmbSpecs{side: side})
+	rmb := len(l.rmbs) - 1
+	l.addRKey(rmb, RMBSpec{Link: id, RKey: c.RKey, VAddr: c.VAddr})
+	for i := 0; i < int(c.NumTkns) && i < len(c.OtherRMBs); i++ {
+		l.addRKey(rmb, c.OtherRMBs[i])
+	}
+}
+
+// addConfirmRKeyCont adds the rkeys of other links in the confirm rkey
+// continuation message c sent by side to the last RMB of side
+func (l *LinkGroup) addConfirmRKeyCont(side Side, c *ConfirmRKeyCont) {
+	if c.Reply {
+		return
+	}
+	rmb := len(l.rmbs) - 1
+	for rmb >= 0 && side != SideUnknown && l.rmbs[rmb].side != side {
+		rmb--
+	}
+	if rmb == -1 {
+		return
+	}
+	for i := 0; i < int(c.NumTkns) && i < len(c.OtherRMBs); i++ {
+		l.addRKey(rmb, c.OtherRMBs[i])
+	}
+}
+
+// addDeleteRKey removes the RMBs of the rkeys on link id in the delete rkey
+// message d sent by side
+func (l *LinkGroup) addDeleteRKey(id uint8, side Side, d *DeleteRKey) {
+	if d.Reply {
+		return
+	}
+	for _, rkey := range d.DeletedRKeys() {
+		if rmb := l.findRMB(side, id, rkey); rmb != -1 {
+			l.deleteRMB(rmb)
+		}
+	}
+}
+
+// Add adds the next LLC message msg of the link group sent by side on the
+// link with the link ID id. The link ID is needed for the rkeys in Confirm
+// RKey, Add Link, Add Link Continuation and Delete RKey messages that refer to
+// the link the message is sent on. If id is 0, the link is unknown. Both sides
+// register RMBs, the side is needed to find the RMBs of these messages. If
+// the side is unknown, the RMBs of both sides are mixed
+func (l *LinkGroup) Add(id uint8, side Side, msg Message) {
+	id = l.msgLink(id)
+	switch m := msg.(type) {
+	case *ConfirmLink:
+		l.addConfirmLink(m)
+	case *AddLink:
+		l.addAddLink(id, side, m)
+	case *AddLinkCont:
+		l.addAddLinkCont(id, side, m)
+	case *DeleteLink:
+		l.addDeleteLink(m)
+	case *ConfirmRKey:
+		l.addConfirmRKey(id, side, m)
+	case *ConfirmRKeyCont:
+		l.addConfirmRKeyCont(side, m)
+	case *DeleteRKey:
+		l.addDeleteRKey(id, side, m)
+	}
+}
+
+// NewLinkGroup returns a new LinkGroup that passes its events to handler
+func NewLinkGroup(handler EventHandler) *LinkGroup {
+	return &LinkGroup{
+		Links:   make(map[uint8]*Link),
+		handler: handler,
+	}
+}
@@ -0,0 +1,130 @@
+package llc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestLinkGroup(t *testing.T) {
+	var events []string
+	l := NewLinkGroup(func(event *Event) {
+		events = append(events, event.String())
+	})
+
+	// first link, confirm link request and reply
+	l.Add(0, SideUnknown, &ConfirmLink{SenderGID: net.ParseIP("fe80::2"),
+		SenderQP: 100, Link: 1, SenderLinkUserID: 5, MaxLinks: 3})
+	l.Add(0, SideUnknown, &ConfirmLink{Reply: true,
+		SenderGID: net.ParseIP("fe80::1"), SenderQP: 200, Link: 1,
+		SenderLinkUserID: 6})
+
+	// new rmb on first link
+	l.Add(0, SideUnknown, &ConfirmRKey{RKey: 4097, VAddr: 0x1000})
+	l.Add(0, SideUnknown, &ConfirmRKey{Reply: true})
+
+	// second link with rkey of the rmb
+	l.Add(1, SideUnknown, &AddLink{SenderGID: net.ParseIP("fe80::4"),
+		SenderQP: 101, Link: 2, MTU: 3})
+	l.Add(1, SideUnknown, &AddLink{Reply: true,
+		SenderGID: net.ParseIP("fe80::3"), SenderQP: 201, Link: 2,
+		MTU: 3})
+	l.Add(1, SideUnknown, &AddLinkCont{Link: 2, NumRTokens: 1,
+		RKeyPairs: [2]RKeyPair{{ReferenceRKey: 4097, NewRKey: 8193,
+			NewVAddr: 0x2000}}})
+	l.Add(2, SideUnknown, &ConfirmLink{SenderGID: net.ParseIP("fe80::4"),
+		SenderQP: 101, Link: 2})
+	l.Add(2, SideUnknown, &ConfirmLink{Reply: true,
+		SenderGID: net.ParseIP("fe80::3"), SenderQP: 201, Link: 2})
+
+	// check link group
+	if l.MaxLinks != 3 || len(l.Links) != 2 {
+		t.Fatalf("max links = %d, links = %d; want 3, 2", l.MaxLinks,
+			len(l.Links))
+	}
+	link := l.Links[2]
+	if !link.Up || link.Server.QP != 101 || link.Client.QP != 201 ||
+		link.MTU != 3 || link.RKeys[8193] != 0x2000 {
+		t.Errorf("unexpected link 2: %+v", link)
+	}
+	if got := l.Links[1].Server.LinkUserID; got != 5 {
+		t.Errorf("link user id = %d; want 5", got)
+	}
+
+	// first link fails, rmb is removed
+	l.Add(2, SideUnknown, &DeleteLink{Link: 1, RsnCode: 0x00010000})
+	l.Add(2, SideUnknown, &DeleteLink{Reply: true, Link: 1})
+	l.Add(2, SideUnknown, &DeleteRKey{Count: 1, RKeys: [8]uint32{8193}})
+	if len(l.Links[2].RKeys) != 0 {
+		t.Errorf("rkeys = %v; want none", l.Links[2].RKeys)
+	}
+
+	// check events
+	want := []string{
+		"Link Up: Link: 1",
+		"RKey Added: Link: 1, RKey: 4097, VAddr: 0x1000",
+		"RKey Added: Link: 2, RKey: 8193, VAddr: 0x2000",
+		"Link Up: Link: 2",
+		"Link Down: Link: 1, Reason Code: 65536 (Lost path)",
+		"RKey Deleted: Link: 2, RKey: 8193, VAddr: 0x2000",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %q; want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %q; want %q", i, events[i], want[i])
+		}
+	}
+}
+
+func TestLinkGroupAddLinkV2(t *testing.T) {
+	l := NewLinkGroup(nil)
+
+	// first link with rmb
+	l.Add(1, SideUnknown, &ConfirmLink{Link: 1})
+	l.Add(1, SideUnknown, &ConfirmLink{Reply: true, Link: 1})
+	l.Add(1, SideUnknown, &ConfirmRKey{RKey: 4097, VAddr: 0x1000})
+
+	// second link with rkey of the rmb in the SMC-Rv2 add link message
+	l.Add(1, SideUnknown, &AddLink{BaseMsg: BaseMsg{Version: 2}, Link: 2,
+		NumRKeys: 1, RKeyPairs: []RKeyPair{{ReferenceRKey: 4097,
+			NewRKey: 8193, NewVAddr: 0x2000}}})
+	if got := l.Links[2].RKeys[8193]; got != 0x2000 {
+		t.Errorf("rkey 8193 vaddr = %#x; want 0x2000", got)
+	}
+}
+
+func TestLinkGroupConfirmRKeySides(t *testing.T) {
+	var events []string
+	l := NewLinkGroup(func(event *Event) {
+		events = append(events, event.String())
+	})
+	l.Add(1, SideServer, &ConfirmLink{Link: 1})
+	l.Add(1, SideClient, &ConfirmLink{Reply: true, Link: 1})
+
+	// both sides register an rmb at the same time, the client rejects the
+	// server's rmb after the server accepted the client's rmb
+	l.Add(1, SideServer, &ConfirmRKey{RKey: 1, VAddr: 0x1000})
+	l.Add(1, SideClient, &ConfirmRKey{RKey: 2, VAddr: 0x2000})
+	l.Add(1, SideServer, &ConfirmRKey{Reply: true, RKey: 2})
+	l.Add(1, SideClient, &ConfirmRKey{Reply: true, Reject: true,
+		RKey: 1})
+	if _, ok := l.Links[1].RKeys[2]; !ok || len(l.Links[1].RKeys) != 1 {
+		t.Errorf("rkeys = %v; want only rkey 2", l.Links[1].RKeys)
+	}
+
+	want := []string{
+		"Link Up: Link: 1",
+		"RKey Added: Link: 1, RKey: 1, VAddr: 0x1000",
+		"RKey Added: Link: 1, RKey: 2, VAddr: 0x2000",
+		"RKey Deleted: Link: 1, RKey: 1, VAddr: 0x1000",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %q; want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %q; want %q", i, events[i], want[i])
+		}
+	}
+}
@@ -1,25 +1,66 @@
 package llc
 
+import "encoding/binary"
+
 const (
 	// llc/cdc messages are 44 bytes long
 	// llc messages are 44 bytes long
 	LLCMsgLen = 44
 	CDCMsgLen = 44
 
+	// SMC-Rv2 llc messages are 44 up to 8192 bytes long
+	LLCv2MaxMsgLen = 8192
+
 	// LLC message types
 	TypeConfirmLink     = 1
 	TypeAddLink         = 2
 	TypeAddLinkCont     = 3
 	TypeDeleteLink      = 4
+	TypeRequestAddLink  = 5
 	TypeConfirmRKey     = 6
 	TypeTestLink        = 7
 	TypeConfirmRKeyCont = 8
 	TypeDeleteRKey      = 9
 	TypeCDC             = 0xFE
+
+	// SMC-Rv2 LLC message types contain the LLC version 2 in the high
+	// nibble of the type byte, taken from the linux code
+	TypeConfirmLinkV2    = 0x21
+	TypeAddLinkV2        = 0x22
+	TypeDeleteLinkV2     = 0x24
+	TypeRequestAddLinkV2 = 0x25
+	TypeConfirmRKeyV2    = 0x26
+	TypeTestLinkV2       = 0x27
+	TypeDeleteRKeyV2     = 0x29
 )
 
+// isV2 checks if the message type typ is a SMC-Rv2 LLC message type
+func isV2(typ byte) bool {
+	return typ>>4 == 2
+}
+
+// v2Length returns the length of the SMC-Rv2 LLC message in buffer or 0 if
+// buffer does not contain a valid SMC-Rv2 LLC message
+func v2Length(buffer []byte) int {
+	if len(buffer) < LLCMsgLen || !isV2(buffer[0]) {
+		return 0
+	}
+	length := int(binary.BigEndian.Uint16(buffer[1:3]))
+	if length < LLCMsgLen || length > LLCv2MaxMsgLen ||
+		length > len(buffer) {
+		return 0
+	}
+	return length
+}
+
 // ParseLLC parses the LLC message in buffer
 func ParseLLC(buffer []byte) Message {
+	// SMC-Rv2 llc messages contain their length and can be longer than
+	// 44 bytes
+	if length := v2Length(buffer); length > 0 {
+		return parseLLCv2(buffer[:length])
+	}
+
 	// llc messages are 44 byte long, treat other lengths as type other
 	if len(buffer) != LLCMsgLen {
 		return ParseOther(buffer)
@@ -34,12 +75,14 @@ func ParseLLC(buffer []byte) Message {
 		return ParseAddLinkCont(buffer)
 	case TypeDeleteLink:
 		return ParseDeleteLink(buffer)
+	case TypeRequestAddLink:
+		return ParseRequestAddLink(buffer)
 	case TypeConfirmRKey:
 		return ParseConfirmRKey(buffer)
 	case TypeConfirmRKeyCont:
 		return ParseConfirmRKeyCont(buffer)
 	case TypeDeleteRKey:
-		return parseDeleteRKey(buffer)
+		return ParseDeleteRKey(buffer)
 	case TypeTestLink:
 		return ParseTestLink(buffer)
 	case TypeCDC:
@@ -48,3 +91,27 @@ func ParseLLC(buffer []byte) Message {
 		return ParseOther(buffer)
 	}
 }
+
+// parseLLCv2 parses the SMC-Rv2 LLC message in buffer. Except for Add Link,
+// Request Add Link and Delete RKey messages, the v2 messages have the same
+// layout as the v1 messages in their first 44 bytes
+func parseLLCv2(buffer []byte) Message {
+	switch buffer[0] {
+	case TypeConfirmLinkV2:
+		return ParseConfirm(buffer[:LLCMsgLen])
+	case TypeAddLinkV2:
+		return ParseAddLink(buffer)
+	case TypeDeleteLinkV2:
+		return ParseDeleteLink(buffer[:LLCMsgLen])
+	case TypeRequestAddLinkV2:
+		return ParseRequestAddLink(buffer)
+	case TypeConfirmRKeyV2:
+		return ParseConfirmRKey(buffer[:LLCMsgLen])
+	case TypeTestLinkV2:
+		return ParseTestLink(buffer[:LLCMsgLen])
+	case TypeDeleteRKeyV2:
+		return ParseDeleteRKey(buffer)
+	default:
+		return ParseOther(buffer)
+	}
+}
@@ -1,6 +1,8 @@
 package llc
 
 import (
+	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"net"
 )
@@ -8,46 +10,51 @@ import (
 // QPMTU stores the compressed MTU of a QP, taken from smc-clc
 type QPMTU uint8
 
-// String converts qpMTU to a string including the uncompressed MTU, taken from
-// smc-clc
-func (m QPMTU) String() string {
-	var mtu string
-
+// mtu returns the uncompressed MTU or 0 if the MTU is reserved
+func (m QPMTU) mtu() int {
 	switch m {
 	case 1:
-		mtu = "256"
+		return 256
 	case 2:
-		mtu = "512"
+		return 512
 	case 3:
-		mtu = "1024"
+		return 1024
 	case 4:
-		mtu = "2048"
+		return 2048
 	case 5:
-		mtu = "4096"
+		return 4096
 	default:
-		mtu = "reserved"
+		return 0
 	}
+}
 
-	return fmt.Sprintf("%d (%s)", m, mtu)
+// String converts qpMTU to a string including the uncompressed MTU, taken from
+// smc-clc
+func (m QPMTU) String() string {
+	if m.mtu() == 0 {
+		return fmt.Sprintf("%d (reserved)", m)
+	}
+	return fmt.Sprintf("%d (%d)", m, m.mtu())
 }
 
 // AddLinkRsnCode stores the reason code of LLC add link messages
 type AddLinkRsnCode uint8
 
-// String converts the reason code to a string
-func (r AddLinkRsnCode) String() string {
-	var rsn string
-
+// description returns the description of the reason code
+func (r AddLinkRsnCode) description() string {
 	switch r {
 	case 1:
-		rsn = "no alternate path available"
+		return "no alternate path available"
 	case 2:
-		rsn = "invalid MTU value specified"
+		return "invalid MTU value specified"
 	default:
-		rsn = "unknown"
+		return "unknown"
 	}
+}
 
-	return fmt.Sprintf("%d (%s)", r, rsn)
+// String converts the reason code to a string
+func (r AddLinkRsnCode) String() string {
+	return fmt.Sprintf("%d (%s)", r, r.description())
 }
 
 // AddLink stores a LLC add link message
@@ -67,19 +74,35 @@ type AddLink struct {
 	MTU       QPMTU
 	PSN       uint32
 	res4      [10]byte
+
+	// SMC-Rv2 add link extension after the first 44 bytes
+	Direct          bool
+	res6            byte
+	res7            byte
+	ClientTargetGID net.IP
+	res8            [8]byte
+	NumRKeys        uint16
+	RKeyPairs       []RKeyPair
 }
 
+// addLinkV2ExtLen is the length of the SMC-Rv2 add link extension without
+// the rkey pairs
+const addLinkV2ExtLen = 28
+
 // Parse fills the addLink fields from the LLC add link message in buffer
 func (a *AddLink) Parse(buffer []byte) {
-	// init base message fields
+	// init base message fields, the SMC-Rv2 add link extension follows
+	// after the first 44 bytes
 	a.SetBaseMsg(buffer)
-	buffer = buffer[2:]
+	buffer = buffer[2:LLCMsgLen]
 
 	// Reserved are first 4 bits in this byte
-	a.res1 = buffer[0] >> 4
-
 	// Reason Code are the last 4 bits in this byte
-	a.RsnCode = AddLinkRsnCode(buffer[0] & 0b00001111)
+	// In SMC-Rv2 messages, this byte is part of the length
+	if a.Version != 2 {
+		a.res1 = buffer[0] >> 4
+		a.RsnCode = AddLinkRsnCode(buffer[0] & 0b00001111)
+	}
 	buffer = buffer[1:]
 
 	// Reply flag is the first bit in this byte
@@ -132,15 +155,90 @@ func (a *AddLink) Parse(buffer []byte) {
 
 	// Rest of message is reserved
 	copy(a.res4[:], buffer[:])
+
+	// SMC-Rv2 add link extension
+	if a.Version == 2 {
+		a.parseV2Ext(a.Raw[LLCMsgLen:])
+	}
+}
+
+// parseV2Ext fills the SMC-Rv2 add link extension fields from buffer
+func (a *AddLink) parseV2Ext(buffer []byte) {
+	if len(buffer) < addLinkV2ExtLen {
+		return
+	}
+
+	// Direct link is the first bit in this byte
+	a.Direct = (buffer[0] & 0b10000000) > 0
+
+	// Remainder of this byte is reserved
+	a.res6 = buffer[0] & 0b01111111
+	buffer = buffer[1:]
+
+	// Reserved 1 byte
+	a.res7 = buffer[0]
+	buffer = buffer[1:]
+
+	// client target GID is an 16 bytes IPv6 address
+	a.ClientTargetGID = make(net.IP, net.IPv6len)
+	copy(a.ClientTargetGID[:], buffer[0:16])
+	buffer = buffer[16:]
+
+	// Reserved 8 bytes
+	copy(a.res8[:], buffer[0:8])
+	buffer = buffer[8:]
+
+	// Number of RKeys is 2 bytes
+	a.NumRKeys = binary.BigEndian.Uint16(buffer[0:2])
+	buffer = buffer[2:]
+
+	// RKey/RToken pairs are each 16 bytes
+	for i := 0; i < int(a.NumRKeys) && len(buffer) >= 16; i++ {
+		var pair RKeyPair
+		pair.Parse(buffer)
+		a.RKeyPairs = append(a.RKeyPairs, pair)
+		buffer = buffer[16:]
+	}
+}
+
+// v2String converts the SMC-Rv2 add link extension to a string
+func (a *AddLink) v2String() string {
+	if a.Version != 2 {
+		return ""
+	}
+	var pairs string
+	for i := range a.RKeyPairs {
+		pairs += fmt.Sprintf(", RKey Pair %d: %s", i+1, &a.RKeyPairs[i])
+	}
+	return fmt.Sprintf(", Direct: %t, Client Target GID: %s, "+
+		"Number of RKeys: %d%s", a.Direct, a.ClientTargetGID,
+		a.NumRKeys, pairs)
+}
+
+// v2Reserved converts the SMC-Rv2 add link extension to a string including
+// reserved fields
+func (a *AddLink) v2Reserved() string {
+	if a.Version != 2 {
+		return ""
+	}
+	var pairs string
+	for i := range a.RKeyPairs {
+		pairs += fmt.Sprintf(", RKey Pair %d: %s", i+1, &a.RKeyPairs[i])
+	}
+	return fmt.Sprintf(", Direct: %t, Reserved: %#x, Reserved: %#x, "+
+		"Client Target GID: %s, Reserved: %#x, Number of RKeys: %d%s",
+		a.Direct, a.res6, a.res7, a.ClientTargetGID, a.res8,
+		a.NumRKeys, pairs)
 }
 
 // String converts the LLC add link message to string
 func (a *AddLink) String() string {
 	aFmt := "LLC Add Link: Type: %d, Length: %d, Reason Code: %s, " +
 		"Reply: %t, Rejection: %t, Sender MAC: %s, Sender GID: %s, " +
-		"Sender QP: %d, Link: %d, MTU: %s, Initial PSN: %d\n"
+		"Sender QP: %d, Link: %d, MTU: %s, Initial PSN: %d%s\n"
 	return fmt.Sprintf(aFmt, a.Type, a.Length, a.RsnCode, a.Reply, a.Reject,
-		a.SenderMAC, a.SenderGID, a.SenderQP, a.Link, a.MTU, a.PSN)
+		a.SenderMAC, a.SenderGID, a.SenderQP, a.Link, a.MTU, a.PSN,
+		a.v2String())
 }
 
 // Reserved converts the LLC add link message to string including reserved
@@ -149,10 +247,10 @@ func (a *AddLink) Reserved() string {
 	aFmt := "LLC Add Link: Type: %d, Length: %d, Reserved: %#x, " +
 		"Reason Code: %s, Reply: %t, Rejection: %t, Reserved: %#x, " +
 		"Sender MAC: %s, Sender GID: %s, Sender QP: %d, Link: %d, " +
-		"Reserved: %#x, MTU: %s, Initial PSN: %d, Reserved: %#x\n"
+		"Reserved: %#x, MTU: %s, Initial PSN: %d, Reserved: %#x%s\n"
 	return fmt.Sprintf(aFmt, a.Type, a.Length, a.res1, a.RsnCode, a.Reply,
 		a.Reject, a.res2, a.SenderMAC, a.SenderGID, a.SenderQP, a.Link,
-		a.res3, a.MTU, a.PSN, a.res4)
+		a.res3, a.MTU, a.PSN, a.res4, a.v2Reserved())
 }
 
 // ParseAddLink parses and prints the LLC add link message in buffer
@@ -161,3 +259,107 @@ func ParseAddLink(buffer []byte) *AddLink {
 	add.Parse(buffer)
 	return &add
 }
+
+// jsonAddLink stores the LLC add link message in JSON
+type jsonAddLink struct {
+	jsonMessage
+	RsnCode   AddLinkRsnCode `json:"rsn_code"`
+	Reply     bool           `json:"reply"`
+	Reject    bool           `json:"reject"`
+	SenderMAC string         `json:"sender_mac"`
+	SenderGID string         `json:"sender_gid"`
+	SenderQP  uint32         `json:"sender_qp"`
+	Link      uint8          `json:"link"`
+	MTU       QPMTU          `json:"mtu"`
+	PSN       uint32         `json:"psn"`
+
+	// SMC-Rv2 add link extension
+	Direct          bool       `json:"direct,omitempty"`
+	ClientTargetGID string     `json:"client_target_gid,omitempty"`
+	NumRKeys        uint16     `json:"num_rkeys,omitempty"`
+	RKeyPairs       []RKeyPair `json:"rkey_pairs,omitempty"`
+}
+
+// marshalJSON converts the LLC add link message to JSON
+func (a *AddLink) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonAddLink{
+		jsonMessage: a.newJSONMessage(reserved),
+		RsnCode:     a.RsnCode,
+		Reply:       a.Reply,
+		Reject:      a.Reject,
+		SenderMAC:   a.SenderMAC.String(),
+		SenderGID:   jsonIP(a.SenderGID),
+		SenderQP:    a.SenderQP,
+		Link:        a.Link,
+		MTU:         a.MTU,
+		PSN:         a.PSN,
+		Direct:      a.Direct,
+		NumRKeys:    a.NumRKeys,
+		RKeyPairs:   a.RKeyPairs,
+	}
+	if a.ClientTargetGID != nil {
+		j.ClientTargetGID = jsonIP(a.ClientTargetGID)
+	}
+	j.Reserved.add("res1", a.res1)
+	j.Reserved.add("res2", a.res2)
+	j.Reserved.add("res3", a.res3)
+	j.Reserved.add("res4", a.res4)
+	j.Reserved.add("res5", a.res5)
+	if a.Version == 2 {
+		j.Reserved.add("res6", a.res6)
+		j.Reserved.add("res7", a.res7)
+		j.Reserved.add("res8", a.res8)
+	}
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC add link message to JSON
+func (a *AddLink) MarshalJSON() ([]byte, error) {
+	return a.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC add link message
+func (a *AddLink) UnmarshalJSON(data []byte) error {
+	var j jsonAddLink
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := a.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	senderMAC, err := parseJSONMAC(j.SenderMAC)
+	if err != nil {
+		return err
+	}
+	senderGID, err := parseJSONIP(j.SenderGID)
+	if err != nil {
+		return err
+	}
+	clientTargetGID, err := parseJSONIP(j.ClientTargetGID)
+	if err != nil {
+		return err
+	}
+	a.RsnCode = j.RsnCode
+	a.Reply = j.Reply
+	a.Reject = j.Reject
+	a.SenderMAC = senderMAC
+	a.SenderGID = senderGID
+	a.SenderQP = j.SenderQP
+	a.Link = j.Link
+	a.MTU = j.MTU
+	a.PSN = j.PSN
+	a.Direct = j.Direct
+	a.ClientTargetGID = clientTargetGID
+	a.NumRKeys = j.NumRKeys
+	a.RKeyPairs = j.RKeyPairs
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &a.res1,
+		"res2": &a.res2,
+		"res3": &a.res3,
+		"res4": a.res4[:],
+		"res5": a.res5[:],
+		"res6": &a.res6,
+		"res7": &a.res7,
+		"res8": a.res8[:],
+	})
+}
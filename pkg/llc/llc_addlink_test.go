@@ -65,3 +65,44 @@ func TestAddLink(t *testing.T) {
 		t.Errorf("a.GetType() = %d; want %d", gotType, wantType)
 	}
 }
+
+func TestAddLinkV2(t *testing.T) {
+	// create bytes of a message with the SMC-Rv2 extension and one rkey
+	// pair
+	msg := "22 00 58 00 98 03 9b ab  cd ef 00 00 fe 80 00 00" +
+		"00 00 00 00 9a 03 9b ff  fe ab cd ef 00 00 65 02" +
+		"03 00 00 01 00 00 00 00  00 00 00 00" +
+		"80 00 fe 80 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 01 00 00 00 00 00 00  00 00 00 01" +
+		"00 00 10 01 00 00 20 01  00 00 00 00 00 00 20 00"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	a, ok := ParseLLC(bytes).(*AddLink)
+	if !ok {
+		t.Fatalf("ParseLLC() did not return an add link message")
+	}
+
+	// test String()
+	want := "LLC Add Link: Type: 34, Length: 88, " +
+		"Reason Code: 0 (unknown), Reply: false, Rejection: false, " +
+		"Sender MAC: 98:03:9b:ab:cd:ef, " +
+		"Sender GID: fe80::9a03:9bff:feab:cdef, " +
+		"Sender QP: 101, Link: 2, MTU: 3 (1024), Initial PSN: 1, " +
+		"Direct: true, Client Target GID: fe80::1, " +
+		"Number of RKeys: 1, RKey Pair 1: [Reference RKey: 4097, " +
+		"New RKey: 8193, New Virtual Address: 0x2000]\n"
+	got := a.String()
+	if got != want {
+		t.Errorf("a.String() = %s; want %s", got, want)
+	}
+
+	// test Version and GetType()
+	if a.Version != 2 || a.GetType() != TypeAddLinkV2 {
+		t.Errorf("version = %d, type = %d; want 2, %d", a.Version,
+			a.GetType(), TypeAddLinkV2)
+	}
+}
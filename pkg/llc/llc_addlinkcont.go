@@ -2,14 +2,15 @@ package llc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 )
 
 // RKeyPair stores a RKey/RToken pair
 type RKeyPair struct {
-	ReferenceRKey uint32
-	NewRKey       uint32
-	NewVAddr      uint64
+	ReferenceRKey uint32 `json:"reference_rkey"`
+	NewRKey       uint32 `json:"new_rkey"`
+	NewVAddr      uint64 `json:"new_vaddr"`
 }
 
 // Parse fills the rkeyPair fields from the buffer
@@ -123,3 +124,54 @@ func ParseAddLinkCont(buffer []byte) *AddLinkCont {
 	addCont.Parse(buffer)
 	return &addCont
 }
+
+// jsonAddLinkCont stores the LLC add link continuation message in JSON
+type jsonAddLinkCont struct {
+	jsonMessage
+	Reply      bool        `json:"reply"`
+	Link       uint8       `json:"link"`
+	NumRTokens uint8       `json:"num_rtokens"`
+	RKeyPairs  [2]RKeyPair `json:"rkey_pairs"`
+}
+
+// marshalJSON converts the LLC add link continuation message to JSON
+func (a *AddLinkCont) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonAddLinkCont{
+		jsonMessage: a.newJSONMessage(reserved),
+		Reply:       a.Reply,
+		Link:        a.Link,
+		NumRTokens:  a.NumRTokens,
+		RKeyPairs:   a.RKeyPairs,
+	}
+	j.Reserved.add("res1", a.res1)
+	j.Reserved.add("res2", a.res2)
+	j.Reserved.add("res3", a.res3)
+	j.Reserved.add("res4", a.res4)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC add link continuation message to JSON
+func (a *AddLinkCont) MarshalJSON() ([]byte, error) {
+	return a.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC add link continuation message
+func (a *AddLinkCont) UnmarshalJSON(data []byte) error {
+	var j jsonAddLinkCont
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := a.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	a.Reply = j.Reply
+	a.Link = j.Link
+	a.NumRTokens = j.NumRTokens
+	a.RKeyPairs = j.RKeyPairs
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &a.res1,
+		"res2": &a.res2,
+		"res3": a.res3[:],
+		"res4": a.res4[:],
+	})
+}
@@ -2,6 +2,7 @@ package llc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"net"
 )
@@ -28,8 +29,10 @@ func (c *ConfirmLink) Parse(buffer []byte) {
 	c.SetBaseMsg(buffer)
 	buffer = buffer[2:]
 
-	// Reserved 1 byte
-	c.res1 = buffer[0]
+	// Reserved 1 byte, part of the length in SMC-Rv2 messages
+	if c.Version != 2 {
+		c.res1 = buffer[0]
+	}
 	buffer = buffer[1:]
 
 	// Reply is first bit in this byte
@@ -99,3 +102,69 @@ func ParseConfirm(buffer []byte) *ConfirmLink {
 	confirm.Parse(buffer)
 	return &confirm
 }
+
+// jsonConfirmLink stores the LLC confirm link message in JSON
+type jsonConfirmLink struct {
+	jsonMessage
+	Reply            bool   `json:"reply"`
+	SenderMAC        string `json:"sender_mac"`
+	SenderGID        string `json:"sender_gid"`
+	SenderQP         uint32 `json:"sender_qp"`
+	Link             uint8  `json:"link"`
+	SenderLinkUserID uint32 `json:"sender_link_user_id"`
+	MaxLinks         uint8  `json:"max_links"`
+}
+
+// marshalJSON converts the LLC confirm link message to JSON
+func (c *ConfirmLink) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonConfirmLink{
+		jsonMessage:      c.newJSONMessage(reserved),
+		Reply:            c.Reply,
+		SenderMAC:        c.SenderMAC.String(),
+		SenderGID:        jsonIP(c.SenderGID),
+		SenderQP:         c.SenderQP,
+		Link:             c.Link,
+		SenderLinkUserID: c.SenderLinkUserID,
+		MaxLinks:         c.MaxLinks,
+	}
+	j.Reserved.add("res1", c.res1)
+	j.Reserved.add("res2", c.res2)
+	j.Reserved.add("res3", c.res3)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC confirm link message to JSON
+func (c *ConfirmLink) MarshalJSON() ([]byte, error) {
+	return c.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC confirm link message
+func (c *ConfirmLink) UnmarshalJSON(data []byte) error {
+	var j jsonConfirmLink
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := c.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	senderMAC, err := parseJSONMAC(j.SenderMAC)
+	if err != nil {
+		return err
+	}
+	senderGID, err := parseJSONIP(j.SenderGID)
+	if err != nil {
+		return err
+	}
+	c.Reply = j.Reply
+	c.SenderMAC = senderMAC
+	c.SenderGID = senderGID
+	c.SenderQP = j.SenderQP
+	c.Link = j.Link
+	c.SenderLinkUserID = j.SenderLinkUserID
+	c.MaxLinks = j.MaxLinks
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &c.res1,
+		"res2": &c.res2,
+		"res3": c.res3[:],
+	})
+}
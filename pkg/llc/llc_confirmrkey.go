@@ -2,14 +2,15 @@ package llc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 )
 
 // RMBSpec stores another RMB specificiation
 type RMBSpec struct {
-	Link  uint8
-	RKey  uint32
-	VAddr uint64
+	Link  uint8  `json:"link"`
+	RKey  uint32 `json:"rkey"`
+	VAddr uint64 `json:"vaddr"`
 }
 
 // Parse fills the rmbSpec fields from buffer
@@ -51,8 +52,10 @@ func (c *ConfirmRKey) Parse(buffer []byte) {
 	c.SetBaseMsg(buffer)
 	buffer = buffer[2:]
 
-	// Reserved 1 byte
-	c.res1 = buffer[0]
+	// Reserved 1 byte, part of the length in SMC-Rv2 messages
+	if c.Version != 2 {
+		c.res1 = buffer[0]
+	}
 	buffer = buffer[1:]
 
 	// Reply is first bit in this byte
@@ -138,3 +141,63 @@ func ParseConfirmRKey(buffer []byte) *ConfirmRKey {
 	confirm.Parse(buffer)
 	return &confirm
 }
+
+// jsonConfirmRKey stores the LLC confirm RKey message in JSON
+type jsonConfirmRKey struct {
+	jsonMessage
+	Reply     bool       `json:"reply"`
+	Reject    bool       `json:"reject"`
+	Retry     bool       `json:"retry"`
+	NumTkns   uint8      `json:"num_tkns"`
+	RKey      uint32     `json:"rkey"`
+	VAddr     uint64     `json:"vaddr"`
+	OtherRMBs [2]RMBSpec `json:"other_rmbs"`
+}
+
+// marshalJSON converts the LLC confirm RKey message to JSON
+func (c *ConfirmRKey) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonConfirmRKey{
+		jsonMessage: c.newJSONMessage(reserved),
+		Reply:       c.Reply,
+		Reject:      c.Reject,
+		Retry:       c.Retry,
+		NumTkns:     c.NumTkns,
+		RKey:        c.RKey,
+		VAddr:       c.VAddr,
+		OtherRMBs:   c.OtherRMBs,
+	}
+	j.Reserved.add("res1", c.res1)
+	j.Reserved.add("res2", c.res2)
+	j.Reserved.add("res3", c.res3)
+	j.Reserved.add("res4", c.res4)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC confirm RKey message to JSON
+func (c *ConfirmRKey) MarshalJSON() ([]byte, error) {
+	return c.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC confirm RKey message
+func (c *ConfirmRKey) UnmarshalJSON(data []byte) error {
+	var j jsonConfirmRKey
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := c.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	c.Reply = j.Reply
+	c.Reject = j.Reject
+	c.Retry = j.Retry
+	c.NumTkns = j.NumTkns
+	c.RKey = j.RKey
+	c.VAddr = j.VAddr
+	c.OtherRMBs = j.OtherRMBs
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &c.res1,
+		"res2": &c.res2,
+		"res3": &c.res3,
+		"res4": &c.res4,
+	})
+}
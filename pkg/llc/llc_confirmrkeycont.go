@@ -1,6 +1,9 @@
 package llc
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // ConfirmRKeyCont stores a LLC confirm rkey continuation message
 type ConfirmRKeyCont struct {
@@ -54,8 +57,10 @@ func (c *ConfirmRKeyCont) Parse(buffer []byte) {
 		buffer = buffer[13:]
 	}
 
-	// Rest of message is reserved
-	c.res4 = buffer[0]
+	// Rest of message is reserved, if present
+	if len(buffer) > 0 {
+		c.res4 = buffer[0]
+	}
 }
 
 // String converts the confirm RKey continuation message to a string
@@ -98,3 +103,54 @@ func ParseConfirmRKeyCont(buffer []byte) *ConfirmRKeyCont {
 	confirmCont.Parse(buffer)
 	return &confirmCont
 }
+
+// jsonConfirmRKeyCont stores the LLC confirm RKey continuation message in JSON
+type jsonConfirmRKeyCont struct {
+	jsonMessage
+	Reply     bool       `json:"reply"`
+	Reject    bool       `json:"reject"`
+	NumTkns   uint8      `json:"num_tkns"`
+	OtherRMBs [3]RMBSpec `json:"other_rmbs"`
+}
+
+// marshalJSON converts the LLC confirm RKey continuation message to JSON
+func (c *ConfirmRKeyCont) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonConfirmRKeyCont{
+		jsonMessage: c.newJSONMessage(reserved),
+		Reply:       c.Reply,
+		Reject:      c.Reject,
+		NumTkns:     c.NumTkns,
+		OtherRMBs:   c.OtherRMBs,
+	}
+	j.Reserved.add("res1", c.res1)
+	j.Reserved.add("res2", c.res2)
+	j.Reserved.add("res3", c.res3)
+	j.Reserved.add("res4", c.res4)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC confirm RKey continuation message to JSON
+func (c *ConfirmRKeyCont) MarshalJSON() ([]byte, error) {
+	return c.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC confirm RKey continuation message
+func (c *ConfirmRKeyCont) UnmarshalJSON(data []byte) error {
+	var j jsonConfirmRKeyCont
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := c.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	c.Reply = j.Reply
+	c.Reject = j.Reject
+	c.NumTkns = j.NumTkns
+	c.OtherRMBs = j.OtherRMBs
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &c.res1,
+		"res2": &c.res2,
+		"res3": &c.res3,
+		"res4": &c.res4,
+	})
+}
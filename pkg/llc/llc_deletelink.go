@@ -2,14 +2,15 @@ package llc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 )
 
 // DelLinkRsnCode stores the reason code of a delete link message
 type DelLinkRsnCode uint32
 
-// String converts the delete link reason code to a string
-func (d DelLinkRsnCode) String() string {
+// description returns the description of the delete link reason code
+func (d DelLinkRsnCode) description() string {
 	var rsn string
 
 	switch d {
@@ -29,11 +30,18 @@ func (d DelLinkRsnCode) String() string {
 		rsn = "Asymmetric link no longer needed"
 	case 0x00100000:
 		rsn = "Unknown link ID (no link)"
+	case 0x00200000:
+		rsn = "Unknown link group (no link group)"
 	default:
 		rsn = "unknown"
 	}
 
-	return fmt.Sprintf("%d (%s)", d, rsn)
+	return rsn
+}
+
+// String converts the delete link reason code to a string
+func (d DelLinkRsnCode) String() string {
+	return fmt.Sprintf("%d (%s)", d, d.description())
 }
 
 // delteLink stores a LLC delete link message
@@ -55,8 +63,10 @@ func (d *DeleteLink) Parse(buffer []byte) {
 	d.SetBaseMsg(buffer)
 	buffer = buffer[2:]
 
-	// Reserved 1 byte
-	d.res1 = buffer[0]
+	// Reserved 1 byte, part of the length in SMC-Rv2 messages
+	if d.Version != 2 {
+		d.res1 = buffer[0]
+	}
 	buffer = buffer[1:]
 
 	// Reply is first bit in this byte
@@ -108,3 +118,55 @@ func ParseDeleteLink(buffer []byte) *DeleteLink {
 	del.Parse(buffer)
 	return &del
 }
+
+// jsonDeleteLink stores the LLC delete link message in JSON
+type jsonDeleteLink struct {
+	jsonMessage
+	Reply   bool           `json:"reply"`
+	All     bool           `json:"all"`
+	Orderly bool           `json:"orderly"`
+	Link    uint8          `json:"link"`
+	RsnCode DelLinkRsnCode `json:"rsn_code"`
+}
+
+// marshalJSON converts the LLC delete link message to JSON
+func (d *DeleteLink) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonDeleteLink{
+		jsonMessage: d.newJSONMessage(reserved),
+		Reply:       d.Reply,
+		All:         d.All,
+		Orderly:     d.Orderly,
+		Link:        d.Link,
+		RsnCode:     d.RsnCode,
+	}
+	j.Reserved.add("res1", d.res1)
+	j.Reserved.add("res2", d.res2)
+	j.Reserved.add("res3", d.res3)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC delete link message to JSON
+func (d *DeleteLink) MarshalJSON() ([]byte, error) {
+	return d.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC delete link message
+func (d *DeleteLink) UnmarshalJSON(data []byte) error {
+	var j jsonDeleteLink
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := d.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	d.Reply = j.Reply
+	d.All = j.All
+	d.Orderly = j.Orderly
+	d.Link = j.Link
+	d.RsnCode = j.RsnCode
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &d.res1,
+		"res2": &d.res2,
+		"res3": d.res3[:],
+	})
+}
@@ -2,53 +2,66 @@ package llc
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 )
 
-// deleteRKey stores a LLC delete RKey message
-type deleteRKey struct {
+// DeleteRKey stores a LLC delete RKey message
+type DeleteRKey struct {
 	BaseMsg
 	res1      byte
-	reply     bool
+	Reply     bool
 	res2      byte
-	reject    bool // negative response
+	Reject    bool // negative response
 	res3      byte
-	count     uint8
-	errorMask byte
+	Count     uint8
+	ErrorMask byte
 	res4      [2]byte
-	rkeys     [8]uint32
+	RKeys     [8]uint32
 	res5      [4]byte
+
+	// SMC-Rv2 messages contain the number of invalid rkeys instead of the
+	// error mask and a variable number of rkeys instead of RKeys
+	NumInvalRKeys uint8
+	RKeysV2       []uint32
 }
 
-// Parse fills the deleteRKey fields from the delete RKey message in buffer
-func (d *deleteRKey) Parse(buffer []byte) {
+// Parse fills the DeleteRKey fields from the delete RKey message in buffer
+func (d *DeleteRKey) Parse(buffer []byte) {
 	// init base message fields
 	d.SetBaseMsg(buffer)
 	buffer = buffer[2:]
 
-	// Reserved 1 byte
-	d.res1 = buffer[0]
+	// Reserved 1 byte, part of the length in SMC-Rv2 messages
+	if d.Version != 2 {
+		d.res1 = buffer[0]
+	}
 	buffer = buffer[1:]
 
 	// Reply is first bit in this byte
-	d.reply = (buffer[0] & 0b10000000) > 0
+	d.Reply = (buffer[0] & 0b10000000) > 0
 
 	// Reserved is the next bit in this byte
 	d.res2 = (buffer[0] & 0b01000000) >> 6
 
 	// Negative response flag is the next bit in this byte
-	d.reject = (buffer[0] & 0b00100000) > 0
+	d.Reject = (buffer[0] & 0b00100000) > 0
 
 	// Remainder of this byte is reserved
 	d.res3 = buffer[0] & 0b00011111
 	buffer = buffer[1:]
 
 	// Count is 1 byte
-	d.count = buffer[0]
+	d.Count = buffer[0]
 	buffer = buffer[1:]
 
+	if d.Version == 2 {
+		d.parseV2(buffer)
+		return
+	}
+
 	// Error Mask is 1 byte
-	d.errorMask = buffer[0]
+	d.ErrorMask = buffer[0]
 	buffer = buffer[1:]
 
 	// Reserved are 2 bytes
@@ -65,8 +78,8 @@ func (d *deleteRKey) Parse(buffer []byte) {
 	// * Sixth deleted RKey
 	// * Seventh deleted RKey
 	// * Eighth deleted RKey
-	for i := range d.rkeys {
-		d.rkeys[i] = binary.BigEndian.Uint32(buffer[0:4])
+	for i := range d.RKeys {
+		d.RKeys[i] = binary.BigEndian.Uint32(buffer[0:4])
 		buffer = buffer[4:]
 	}
 
@@ -74,41 +87,174 @@ func (d *deleteRKey) Parse(buffer []byte) {
 	copy(d.res5[:], buffer[:])
 }
 
+// parseV2 fills the SMC-Rv2 DeleteRKey fields from the rest of the delete
+// RKey message in buffer after the count
+func (d *DeleteRKey) parseV2(buffer []byte) {
+	// Number of invalid RKeys is 1 byte
+	d.NumInvalRKeys = buffer[0]
+	buffer = buffer[1:]
+
+	// Reserved are 2 bytes
+	copy(d.res4[:], buffer[0:2])
+	buffer = buffer[2:]
+
+	// Deleted RKeys are each 4 bytes, Count RKeys follow until the end of
+	// the message
+	for i := 0; i < int(d.Count) && len(buffer) >= 4; i++ {
+		d.RKeysV2 = append(d.RKeysV2,
+			binary.BigEndian.Uint32(buffer[0:4]))
+		buffer = buffer[4:]
+	}
+}
+
+// DeletedRKeys returns the rkeys to delete in the message, i.e., the first
+// Count RKeys or the RKeysV2 of SMC-Rv2 messages
+func (d *DeleteRKey) DeletedRKeys() []uint32 {
+	if d.Version == 2 {
+		return d.RKeysV2
+	}
+	return d.RKeys[:min(int(d.Count), len(d.RKeys))]
+}
+
+// v2String converts the SMC-Rv2 delete RKey message to a string
+func (d *DeleteRKey) v2String() string {
+	var rkeys string
+
+	for i, rkey := range d.RKeysV2 {
+		rkeys += fmt.Sprintf(", RKey %d: %d", i, rkey)
+	}
+
+	dFmt := "LLC Delete RKey: Type: %d, Length: %d, " +
+		"Reply: %t, Negative Response: %t, Count: %d, " +
+		"Invalid RKeys: %d%s\n"
+	return fmt.Sprintf(dFmt, d.Type, d.Length, d.Reply, d.Reject, d.Count,
+		d.NumInvalRKeys, rkeys)
+}
+
+// v2Reserved converts the SMC-Rv2 delete RKey message to a string including
+// reserved fields
+func (d *DeleteRKey) v2Reserved() string {
+	var rkeys string
+
+	for i, rkey := range d.RKeysV2 {
+		rkeys += fmt.Sprintf(", RKey %d: %d", i, rkey)
+	}
+
+	dFmt := "LLC Delete RKey: Type: %d, Length: %d, " +
+		"Reply: %t, Reserved: %#x, " +
+		"Negative Response: %t, Reserved: %#x, " +
+		"Count: %d, Invalid RKeys: %d, Reserved: %#x%s\n"
+	return fmt.Sprintf(dFmt, d.Type, d.Length, d.Reply, d.res2,
+		d.Reject, d.res3, d.Count, d.NumInvalRKeys, d.res4, rkeys)
+}
+
 // String converts the delete RKey message to a string
-func (d *deleteRKey) String() string {
+func (d *DeleteRKey) String() string {
+	if d.Version == 2 {
+		return d.v2String()
+	}
+
 	var rkeys string
 
-	for i := range d.rkeys {
-		rkeys += fmt.Sprintf(", RKey %d: %d", i, d.rkeys[i])
+	for i := range d.RKeys {
+		rkeys += fmt.Sprintf(", RKey %d: %d", i, d.RKeys[i])
 	}
 
 	dFmt := "LLC Delete RKey: Type: %d, Length: %d, " +
 		"Reply: %t, Negative Response: %t, Count: %d, " +
 		"Error Mask: %#b%s\n"
-	return fmt.Sprintf(dFmt, d.Type, d.Length, d.reply, d.reject, d.count,
-		d.errorMask, rkeys)
+	return fmt.Sprintf(dFmt, d.Type, d.Length, d.Reply, d.Reject, d.Count,
+		d.ErrorMask, rkeys)
 }
 
 // Reserved converts the delete RKey message to a string including reserved
 // fields
-func (d *deleteRKey) Reserved() string {
+func (d *DeleteRKey) Reserved() string {
+	if d.Version == 2 {
+		return d.v2Reserved()
+	}
+
 	var rkeys string
 
-	for i := range d.rkeys {
-		rkeys += fmt.Sprintf("RKey %d: %d, ", i, d.rkeys[i])
+	for i := range d.RKeys {
+		rkeys += fmt.Sprintf("RKey %d: %d, ", i, d.RKeys[i])
 	}
 
 	dFmt := "LLC Delete RKey: Type: %d, Length: %d, " +
 		"Reserved: %#x, Reply: %t, Reserved: %#x, " +
 		"Negative Response: %t, Reserved: %#x, " +
 		"Count: %d, Error Mask: %#b, Reserved: %#x, %sReserved: %#x\n"
-	return fmt.Sprintf(dFmt, d.Type, d.Length, d.res1, d.reply, d.res2,
-		d.reject, d.res3, d.count, d.errorMask, d.res4, rkeys, d.res5)
+	return fmt.Sprintf(dFmt, d.Type, d.Length, d.res1, d.Reply, d.res2,
+		d.Reject, d.res3, d.Count, d.ErrorMask, d.res4, rkeys, d.res5)
 }
 
-// parseDeleteRKey parses the LLC delete RKey message in buffer
-func parseDeleteRKey(buffer []byte) *deleteRKey {
-	var del deleteRKey
+// ParseDeleteRKey parses the LLC delete RKey message in buffer
+func ParseDeleteRKey(buffer []byte) *DeleteRKey {
+	var del DeleteRKey
 	del.Parse(buffer)
 	return &del
 }
+
+// jsonDeleteRKey stores the LLC delete RKey message in JSON
+type jsonDeleteRKey struct {
+	jsonMessage
+	Reply     bool      `json:"reply"`
+	Reject    bool      `json:"reject"`
+	Count     uint8     `json:"count"`
+	ErrorMask byte      `json:"error_mask"`
+	RKeys     [8]uint32 `json:"rkeys"`
+
+	NumInvalRKeys uint8    `json:"num_inval_rkeys,omitempty"`
+	RKeysV2       []uint32 `json:"rkeys_v2,omitempty"`
+}
+
+// marshalJSON converts the LLC delete RKey message to JSON
+func (d *DeleteRKey) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonDeleteRKey{
+		jsonMessage: d.newJSONMessage(reserved),
+		Reply:       d.Reply,
+		Reject:      d.Reject,
+		Count:       d.Count,
+		ErrorMask:   d.ErrorMask,
+		RKeys:       d.RKeys,
+
+		NumInvalRKeys: d.NumInvalRKeys,
+		RKeysV2:       d.RKeysV2,
+	}
+	j.Reserved.add("res1", d.res1)
+	j.Reserved.add("res2", d.res2)
+	j.Reserved.add("res3", d.res3)
+	j.Reserved.add("res4", d.res4)
+	j.Reserved.add("res5", d.res5)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC delete RKey message to JSON
+func (d *DeleteRKey) MarshalJSON() ([]byte, error) {
+	return d.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC delete RKey message
+func (d *DeleteRKey) UnmarshalJSON(data []byte) error {
+	var j jsonDeleteRKey
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := d.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	d.Reply = j.Reply
+	d.Reject = j.Reject
+	d.Count = j.Count
+	d.ErrorMask = j.ErrorMask
+	d.RKeys = j.RKeys
+	d.NumInvalRKeys = j.NumInvalRKeys
+	d.RKeysV2 = j.RKeysV2
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &d.res1,
+		"res2": &d.res2,
+		"res3": &d.res3,
+		"res4": d.res4[:],
+		"res5": d.res5[:],
+	})
+}
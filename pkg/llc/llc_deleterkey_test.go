@@ -26,7 +26,7 @@ func TestDeleteRKey(t *testing.T) {
 	}
 
 	// parse message
-	d := parseDeleteRKey(bytes)
+	d := ParseDeleteRKey(bytes)
 
 	// test String()
 	want = "LLC Delete RKey: Type: 9, Length: 44, Reply: false, " +
@@ -65,3 +65,55 @@ func TestDeleteRKey(t *testing.T) {
 	}
 
 }
+
+func TestDeleteRKeyV2(t *testing.T) {
+	// create bytes of a negative reply with 10 rkeys and 2 invalid rkeys
+	msg := "29 00 30 a0 0a 02 00 00  00 00 00 01 00 00 00 02" +
+		"00 00 00 03 00 00 00 04  00 00 00 05 00 00 00 06" +
+		"00 00 00 07 00 00 00 08  00 00 00 09 00 00 00 0a"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	d, ok := ParseLLC(bytes).(*DeleteRKey)
+	if !ok {
+		t.Fatalf("ParseLLC() did not return a delete rkey message")
+	}
+
+	// test String()
+	want := "LLC Delete RKey: Type: 41, Length: 48, Reply: true, " +
+		"Negative Response: true, Count: 10, Invalid RKeys: 2, " +
+		"RKey 0: 1, RKey 1: 2, RKey 2: 3, RKey 3: 4, RKey 4: 5, " +
+		"RKey 5: 6, RKey 6: 7, RKey 7: 8, RKey 8: 9, RKey 9: 10\n"
+	got := d.String()
+	if got != want {
+		t.Errorf("d.String() = %s; want %s", got, want)
+	}
+
+	// test Reserved()
+	want = "LLC Delete RKey: Type: 41, Length: 48, Reply: true, " +
+		"Reserved: 0x0, Negative Response: true, Reserved: 0x0, " +
+		"Count: 10, Invalid RKeys: 2, Reserved: 0x0000, " +
+		"RKey 0: 1, RKey 1: 2, RKey 2: 3, RKey 3: 4, RKey 4: 5, " +
+		"RKey 5: 6, RKey 6: 7, RKey 7: 8, RKey 8: 9, RKey 9: 10\n"
+	got = d.Reserved()
+	if got != want {
+		t.Errorf("d.Reserved() = %s; want %s", got, want)
+	}
+
+	// test DeletedRKeys()
+	rkeys := d.DeletedRKeys()
+	if len(rkeys) != 10 || rkeys[0] != 1 || rkeys[9] != 10 {
+		t.Errorf("d.DeletedRKeys() = %v; want [1 ... 10]", rkeys)
+	}
+
+	// count bigger than the message only returns the rkeys in the message
+	bytes[4] = 20
+	d = ParseDeleteRKey(bytes)
+	if len(d.DeletedRKeys()) != 10 {
+		t.Errorf("len(d.DeletedRKeys()) = %d; want 10",
+			len(d.DeletedRKeys()))
+	}
+}
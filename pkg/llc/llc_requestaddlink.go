@@ -0,0 +1,168 @@
+package llc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+)
+
+// requestAddLinkLen is the length of the request add link message without
+// the GIDs
+const requestAddLinkLen = 28
+
+// RequestAddLink stores a LLC request add link message. The client sends it
+// to ask the server for an additional link, SMC-Rv2 messages also contain
+// the GIDs of the client's devices
+type RequestAddLink struct {
+	BaseMsg
+	res1     byte
+	Reply    bool
+	res2     byte
+	res3     [20]byte
+	GIDCount uint8
+	res4     [3]byte
+	GIDs     []net.IP
+	res5     []byte
+}
+
+// Parse fills the request add link fields from the LLC request add link
+// message in buffer
+func (r *RequestAddLink) Parse(buffer []byte) {
+	// init base message fields
+	r.SetBaseMsg(buffer)
+	buffer = buffer[2:]
+
+	// Reserved 1 byte, part of the length in SMC-Rv2 messages
+	if r.Version != 2 {
+		r.res1 = buffer[0]
+	}
+	buffer = buffer[1:]
+
+	// Reply is first bit in this byte
+	r.Reply = (buffer[0] & 0b10000000) > 0
+
+	// Remainder of this byte is reserved
+	r.res2 = buffer[0] & 0b01111111
+	buffer = buffer[1:]
+
+	// Reserved 20 bytes
+	copy(r.res3[:], buffer[0:20])
+	buffer = buffer[20:]
+
+	// GID count is 1 byte
+	r.GIDCount = buffer[0]
+	buffer = buffer[1:]
+
+	// Reserved 3 bytes
+	copy(r.res4[:], buffer[0:3])
+	buffer = buffer[3:]
+
+	// GIDs are each 16 bytes IPv6 addresses, only in SMC-Rv2 messages
+	if r.Version == 2 {
+		for i := 0; i < int(r.GIDCount) && len(buffer) >= 16; i++ {
+			gid := make(net.IP, net.IPv6len)
+			copy(gid[:], buffer[0:16])
+			r.GIDs = append(r.GIDs, gid)
+			buffer = buffer[16:]
+		}
+	}
+
+	// Rest of message is reserved
+	r.res5 = buffer[:]
+}
+
+// gids converts the GIDs to a string
+func (r *RequestAddLink) gids() string {
+	var gids string
+	for i, gid := range r.GIDs {
+		gids += fmt.Sprintf(", GID %d: %s", i+1, gid)
+	}
+	return gids
+}
+
+// String converts the request add link message to a string
+func (r *RequestAddLink) String() string {
+	rFmt := "LLC Request Add Link: Type: %d, Length: %d, Reply: %t, " +
+		"GID Count: %d%s\n"
+	return fmt.Sprintf(rFmt, r.Type, r.Length, r.Reply, r.GIDCount,
+		r.gids())
+}
+
+// Reserved converts the request add link message to a string including
+// reserved fields
+func (r *RequestAddLink) Reserved() string {
+	rFmt := "LLC Request Add Link: Type: %d, Length: %d, Reserved: %#x, " +
+		"Reply: %t, Reserved: %#x, Reserved: %#x, GID Count: %d, " +
+		"Reserved: %#x%s, Reserved: %#x\n"
+	return fmt.Sprintf(rFmt, r.Type, r.Length, r.res1, r.Reply, r.res2,
+		r.res3, r.GIDCount, r.res4, r.gids(), r.res5)
+}
+
+// ParseRequestAddLink parses the LLC request add link message in buffer
+func ParseRequestAddLink(buffer []byte) *RequestAddLink {
+	var req RequestAddLink
+	req.Parse(buffer)
+	return &req
+}
+
+// jsonRequestAddLink stores the LLC request add link message in JSON
+type jsonRequestAddLink struct {
+	jsonMessage
+	Reply    bool     `json:"reply"`
+	GIDCount uint8    `json:"gid_count"`
+	GIDs     []string `json:"gids,omitempty"`
+}
+
+// marshalJSON converts the LLC request add link message to JSON
+func (r *RequestAddLink) marshalJSON(reserved bool) ([]byte, error) {
+	gids := make([]string, 0, len(r.GIDs))
+	for _, gid := range r.GIDs {
+		gids = append(gids, jsonIP(gid))
+	}
+	j := jsonRequestAddLink{
+		jsonMessage: r.newJSONMessage(reserved),
+		Reply:       r.Reply,
+		GIDCount:    r.GIDCount,
+		GIDs:        gids,
+	}
+	j.Reserved.add("res1", r.res1)
+	j.Reserved.add("res2", r.res2)
+	j.Reserved.add("res3", r.res3)
+	j.Reserved.add("res4", r.res4)
+	j.Reserved.add("res5", r.res5)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC request add link message to JSON
+func (r *RequestAddLink) MarshalJSON() ([]byte, error) {
+	return r.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC request add link message
+func (r *RequestAddLink) UnmarshalJSON(data []byte) error {
+	var j jsonRequestAddLink
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := r.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	var gids []net.IP
+	for _, s := range j.GIDs {
+		gid, err := parseJSONIP(s)
+		if err != nil {
+			return err
+		}
+		gids = append(gids, gid)
+	}
+	r.Reply = j.Reply
+	r.GIDCount = j.GIDCount
+	r.GIDs = gids
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &r.res1,
+		"res2": &r.res2,
+		"res3": r.res3[:],
+		"res4": r.res4[:],
+		"res5": &r.res5,
+	})
+}
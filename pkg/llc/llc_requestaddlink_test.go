@@ -0,0 +1,89 @@
+package llc
+
+import (
+	"encoding/hex"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestRequestAddLink(t *testing.T) {
+	var want, got string
+
+	// create bytes of a message
+	msgHex := "00000000  05 2c 00 80 00 00 00 00  " +
+		"00 00 00 00 00 00 00 00  |.,..............|\n" +
+		"00000010  00 00 00 00 00 00 00 00  " +
+		"00 00 00 00 00 00 00 00  |................|\n" +
+		"00000020  00 00 00 00 00 00 00 00  " +
+		"00 00 00 00              |............|\n"
+	msg := "05 2c 00 80 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	r := ParseRequestAddLink(bytes)
+
+	// test String()
+	want = "LLC Request Add Link: Type: 5, Length: 44, Reply: true, " +
+		"GID Count: 0\n"
+	got = r.String()
+	if got != want {
+		t.Errorf("r.String() = %s; want %s", got, want)
+	}
+
+	// test Reserved()
+	want = "LLC Request Add Link: Type: 5, Length: 44, Reserved: 0x0, " +
+		"Reply: true, Reserved: 0x0, " +
+		"Reserved: 0x0000000000000000000000000000000000000000, " +
+		"GID Count: 0, Reserved: 0x000000, " +
+		"Reserved: 0x00000000000000000000000000000000\n"
+	got = r.Reserved()
+	if got != want {
+		t.Errorf("r.Reserved() = %s; want %s", got, want)
+	}
+
+	// test Hex()
+	want = msgHex
+	got = r.Hex()
+	if got != want {
+		t.Errorf("r.Hex() = %s; want %s", got, want)
+	}
+
+	// test GetType()
+	wantType := TypeRequestAddLink
+	gotType := r.GetType()
+	if gotType != wantType {
+		t.Errorf("r.GetType() = %d; want %d", gotType, wantType)
+	}
+}
+
+func TestRequestAddLinkV2(t *testing.T) {
+	// create bytes of a message with two GIDs
+	msg := "25 00 3c 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  02 00 00 00" +
+		"fe 80 00 00 00 00 00 00  00 00 00 00 00 00 00 01" +
+		"fe 80 00 00 00 00 00 00  00 00 00 00 00 00 00 02"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	r, ok := ParseLLC(bytes).(*RequestAddLink)
+	if !ok {
+		t.Fatalf("ParseLLC() did not return a request add link message")
+	}
+
+	// test String()
+	want := "LLC Request Add Link: Type: 37, Length: 60, Reply: false, " +
+		"GID Count: 2, GID 1: fe80::1, GID 2: fe80::2\n"
+	got := r.String()
+	if got != want {
+		t.Errorf("r.String() = %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,38 @@
+package llc
+
+import (
+	"encoding/hex"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestParseLLCv2(t *testing.T) {
+	// create bytes of a SMC-Rv2 test link message
+	msg := "27 00 2c 80 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// test v2 message
+	tl, ok := ParseLLC(bytes).(*TestLink)
+	if !ok {
+		t.Fatalf("ParseLLC() did not return a test link message")
+	}
+	if tl.Version != 2 || tl.Length != 44 || !tl.Reply {
+		t.Errorf("version = %d, length = %d, reply = %t; "+
+			"want 2, 44, true", tl.Version, tl.Length, tl.Reply)
+	}
+	if got := tl.jsonType(); got != "test_link" {
+		t.Errorf("tl.jsonType() = %s; want test_link", got)
+	}
+
+	// test v2 message with a length longer than the buffer
+	bytes[2] = 0x2d
+	if got := ParseLLC(bytes).GetType(); got != TypeOther {
+		t.Errorf("ParseLLC().GetType() = %d; want %d", got, TypeOther)
+	}
+}
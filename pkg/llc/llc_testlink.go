@@ -1,6 +1,9 @@
 package llc
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // TestLink stores a LLC test link message
 type TestLink struct {
@@ -18,8 +21,10 @@ func (t *TestLink) Parse(buffer []byte) {
 	t.SetBaseMsg(buffer)
 	buffer = buffer[2:]
 
-	// Reserved 1 byte
-	t.res1 = buffer[0]
+	// Reserved 1 byte, part of the length in SMC-Rv2 messages
+	if t.Version != 2 {
+		t.res1 = buffer[0]
+	}
 	buffer = buffer[1:]
 
 	// Reply is first bit in this byte
@@ -59,3 +64,54 @@ func ParseTestLink(buffer []byte) *TestLink {
 	test.Parse(buffer)
 	return &test
 }
+
+// jsonTestLink stores the LLC test link message in JSON
+type jsonTestLink struct {
+	jsonMessage
+	Reply    bool   `json:"reply"`
+	UserData string `json:"user_data"`
+}
+
+// marshalJSON converts the LLC test link message to JSON
+func (t *TestLink) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonTestLink{
+		jsonMessage: t.newJSONMessage(reserved),
+		Reply:       t.Reply,
+		UserData:    jsonHex(t.UserData[:]),
+	}
+	j.Reserved.add("res1", t.res1)
+	j.Reserved.add("res2", t.res2)
+	j.Reserved.add("res3", t.res3)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the LLC test link message to JSON
+func (t *TestLink) MarshalJSON() ([]byte, error) {
+	return t.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the LLC test link message
+func (t *TestLink) UnmarshalJSON(data []byte) error {
+	var j jsonTestLink
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := t.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	userData, err := parseJSONHex(j.UserData)
+	if err != nil {
+		return err
+	}
+	if len(userData) != len(t.UserData) {
+		return fmt.Errorf("Error unmarshaling user data %q: %w",
+			j.UserData, ErrBadJSON)
+	}
+	t.Reply = j.Reply
+	copy(t.UserData[:], userData)
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": &t.res1,
+		"res2": &t.res2,
+		"res3": t.res3[:],
+	})
+}
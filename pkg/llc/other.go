@@ -1,5 +1,7 @@
 package llc
 
+import "encoding/json"
+
 const (
 	// TypeOther is the internal message type for other/non-LLC messages
 	TypeOther = 0x101
@@ -33,3 +35,41 @@ func ParseOther(buffer []byte) *Other {
 	o.Parse(buffer)
 	return &o
 }
+
+// jsonOther stores the other message in JSON
+type jsonOther struct {
+	jsonMessage
+	Payload string `json:"payload"`
+}
+
+// marshalJSON converts the other message to JSON
+func (o *Other) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonOther{
+		jsonMessage: o.newJSONMessage(reserved),
+		Payload:     jsonHex(o.Raw),
+	}
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the other message to JSON
+func (o *Other) MarshalJSON() ([]byte, error) {
+	return o.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the other message. The payload is restored
+// as the raw message bytes
+func (o *Other) UnmarshalJSON(data []byte) error {
+	var j jsonOther
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if err := o.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	raw, err := parseJSONHex(j.Payload)
+	if err != nil {
+		return err
+	}
+	o.Raw = raw
+	return nil
+}
@@ -0,0 +1,321 @@
+package llc
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FindingType is the type of a RKey audit finding
+type FindingType uint8
+
+// RKey audit finding types
+const (
+	// FindingRejected is a negative Confirm RKey or Delete RKey reply
+	FindingRejected FindingType = iota + 1
+
+	// FindingUnexpectedReply is a reply without a request
+	FindingUnexpectedReply
+
+	// FindingNoReply is a request without a reply
+	FindingNoReply
+
+	// FindingBadContinuation is a Confirm RKey Continuation without a
+	// request or with the wrong number of tokens
+	FindingBadContinuation
+
+	// FindingUnknownRKey is a Delete RKey request for an unknown rkey
+	FindingUnknownRKey
+
+	// FindingDeleteFailed is an rkey in the error mask of a Delete RKey
+	// reply
+	FindingDeleteFailed
+
+	// FindingNotDeleted is an rkey that is never deleted
+	FindingNotDeleted
+)
+
+// String converts the finding type to a string
+func (f FindingType) String() string {
+	switch f {
+	case FindingRejected:
+		return "Rejected"
+	case FindingUnexpectedReply:
+		return "Unexpected Reply"
+	case FindingNoReply:
+		return "No Reply"
+	case FindingBadContinuation:
+		return "Bad Continuation"
+	case FindingUnknownRKey:
+		return "Unknown RKey"
+	case FindingDeleteFailed:
+		return "Delete Failed"
+	case FindingNotDeleted:
+		return "Not Deleted"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint8(f))
+	}
+}
+
+// Finding is a problem found by the RKey auditor
+type Finding struct {
+	Type FindingType
+
+	// Side is the side of the link group that sent the request of the
+	// finding or registered its rkey
+	Side Side
+
+	// RKey is the rkey of the finding or 0 if the finding is not about a
+	// specific rkey
+	RKey uint32
+
+	// Msg is the message that caused the finding or nil
+	Msg Message
+
+	// Detail describes the finding
+	Detail string
+}
+
+// String converts the finding to a string
+func (f *Finding) String() string {
+	if f.Side == SideUnknown {
+		return fmt.Sprintf("%s: RKey: %d, %s", f.Type, f.RKey, f.Detail)
+	}
+	return fmt.Sprintf("%s: Side: %s, RKey: %d, %s", f.Type, f.Side,
+		f.RKey, f.Detail)
+}
+
+// rkeyFlow stores the RKey state of the requests sent by one side of a link
+// group
+type rkeyFlow struct {
+	side Side
+
+	// rmbs of the side by all their rkeys
+	rkeys map[uint32][]RMBSpec
+
+	// pending confirm rkey request and its remaining tokens
+	confirm   *ConfirmRKey
+	specs     []RMBSpec
+	remaining int
+
+	// pending delete rkey request
+	del *DeleteRKey
+}
+
+// RKeyAuditor checks the RKey life cycle in the LLC messages of a link group:
+// it matches the requests and replies of Confirm RKey, Confirm RKey
+// Continuation and Delete RKey messages and reports problems as findings.
+// Both sides of the link group register and delete their rkeys independently,
+// so requests and rkeys are tracked per side
+type RKeyAuditor struct {
+	flows    map[Side]*rkeyFlow
+	findings []*Finding
+}
+
+// flow returns the rkey flow of the requests sent by side
+func (a *RKeyAuditor) flow(side Side) *rkeyFlow {
+	f := a.flows[side]
+	if f == nil {
+		f = &rkeyFlow{
+			side:  side,
+			rkeys: make(map[uint32][]RMBSpec),
+		}
+		a.flows[side] = f
+	}
+	return f
+}
+
+// report adds a finding of the requests sent by side
+func (a *RKeyAuditor) report(typ FindingType, side Side, rkey uint32,
+	msg Message, format string, args ...interface{}) {
+	a.findings = append(a.findings, &Finding{
+		Type:   typ,
+		Side:   side,
+		RKey:   rkey,
+		Msg:    msg,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// addConfirmRKey adds the confirm rkey message c sent by side
+func (a *RKeyAuditor) addConfirmRKey(side Side, c *ConfirmRKey) {
+	if !c.Reply {
+		f := a.flow(side)
+		if f.confirm != nil {
+			a.report(FindingNoReply, side, f.confirm.RKey,
+				f.confirm, "Confirm RKey request without reply")
+		}
+		f.confirm = c
+		f.specs = []RMBSpec{{RKey: c.RKey, VAddr: c.VAddr}}
+		f.remaining = int(c.NumTkns)
+		for i := 0; i < f.remaining && i < len(c.OtherRMBs); i++ {
+			f.specs = append(f.specs, c.OtherRMBs[i])
+		}
+		f.remaining -= len(f.specs) - 1
+		return
+	}
+
+	// reply to a request of the other side
+	f := a.flow(side.Other())
+	req := f.confirm
+	f.confirm = nil
+	if req == nil {
+		a.report(FindingUnexpectedReply, f.side, c.RKey, c,
+			"Confirm RKey reply without request")
+		return
+	}
+	if f.remaining > 0 {
+		a.report(FindingBadContinuation, f.side, req.RKey, req,
+			"Confirm RKey Continuation missing, "+
+				"Remaining Tokens: %d", f.remaining)
+	}
+	if c.Reject {
+		a.report(FindingRejected, f.side, req.RKey, c,
+			"Confirm RKey rejected, Configuration Retry: %t",
+			c.Retry)
+		return
+	}
+	for _, spec := range f.specs {
+		f.rkeys[spec.RKey] = f.specs
+	}
+}
+
+// addConfirmRKeyCont adds the confirm rkey continuation message c sent by
+// side
+func (a *RKeyAuditor) addConfirmRKeyCont(side Side, c *ConfirmRKeyCont) {
+	if c.Reply {
+		// replies are not sent for continuation messages
+		a.report(FindingUnexpectedReply, side.Other(), 0, c,
+			"Confirm RKey Continuation reply")
+		return
+	}
+	f := a.flow(side)
+	if f.confirm == nil {
+		a.report(FindingBadContinuation, side, 0, c,
+			"Confirm RKey Continuation without request")
+		return
+	}
+	if int(c.NumTkns) != f.remaining {
+		a.report(FindingBadContinuation, side, f.confirm.RKey, c,
+			"Confirm RKey Continuation with %d tokens, expected %d",
+			c.NumTkns, f.remaining)
+	}
+	for i := 0; i < int(c.NumTkns) && i < len(c.OtherRMBs); i++ {
+		f.specs = append(f.specs, c.OtherRMBs[i])
+		f.remaining--
+	}
+}
+
+// addDeleteRKey adds the delete rkey message d sent by side
+func (a *RKeyAuditor) addDeleteRKey(side Side, d *DeleteRKey) {
+	if !d.Reply {
+		f := a.flow(side)
+		if f.del != nil {
+			a.report(FindingNoReply, side, 0, f.del,
+				"Delete RKey request without reply")
+		}
+		f.del = d
+		for _, rkey := range d.DeletedRKeys() {
+			specs, ok := f.rkeys[rkey]
+			if !ok {
+				a.report(FindingUnknownRKey, side, rkey, d,
+					"Delete RKey of unknown rkey")
+				continue
+			}
+			for _, spec := range specs {
+				delete(f.rkeys, spec.RKey)
+			}
+		}
+		return
+	}
+
+	// reply to a request of the other side
+	f := a.flow(side.Other())
+	req := f.del
+	f.del = nil
+	if req == nil {
+		a.report(FindingUnexpectedReply, f.side, 0, d,
+			"Delete RKey reply without request")
+		return
+	}
+	if d.Version == 2 {
+		// SMC-Rv2 replies only contain the number of invalid rkeys
+		if d.Reject || d.NumInvalRKeys > 0 {
+			a.report(FindingRejected, f.side, 0, d,
+				"Delete RKey rejected, Invalid RKeys: %d",
+				d.NumInvalRKeys)
+		}
+		return
+	}
+	if d.Reject {
+		a.report(FindingRejected, f.side, 0, d,
+			"Delete RKey rejected, Error Mask: %#08b", d.ErrorMask)
+	}
+
+	// error mask bits are in the order of the rkeys in the request,
+	// starting with the most significant bit
+	for i := 0; i < int(req.Count) && i < len(req.RKeys); i++ {
+		if d.ErrorMask&(0b10000000>>i) != 0 {
+			a.report(FindingDeleteFailed, f.side, req.RKeys[i], d,
+				"Delete RKey failed, Error Mask: %#08b",
+				d.ErrorMask)
+		}
+	}
+}
+
+// Add adds the next LLC message msg of the link group sent by side. If the
+// side is unknown, the requests and rkeys of both sides are mixed. Other
+// message types are ignored
+func (a *RKeyAuditor) Add(side Side, msg Message) {
+	switch m := msg.(type) {
+	case *ConfirmRKey:
+		a.addConfirmRKey(side, m)
+	case *ConfirmRKeyCont:
+		a.addConfirmRKeyCont(side, m)
+	case *DeleteRKey:
+		a.addDeleteRKey(side, m)
+	}
+}
+
+// Findings returns the findings so far
+func (a *RKeyAuditor) Findings() []*Finding {
+	return a.findings
+}
+
+// Finish reports pending requests and rkeys that were never deleted, e.g.,
+// at the end of a capture, and returns all findings
+func (a *RKeyAuditor) Finish() []*Finding {
+	for _, side := range []Side{SideUnknown, SideServer, SideClient} {
+		f := a.flows[side]
+		if f == nil {
+			continue
+		}
+		if f.confirm != nil {
+			a.report(FindingNoReply, side, f.confirm.RKey,
+				f.confirm, "Confirm RKey request without reply")
+		}
+		if f.del != nil {
+			a.report(FindingNoReply, side, 0, f.del,
+				"Delete RKey request without reply")
+		}
+
+		// report remaining rkeys ordered by rkey
+		rkeys := make([]int, 0, len(f.rkeys))
+		for rkey := range f.rkeys {
+			rkeys = append(rkeys, int(rkey))
+		}
+		sort.Ints(rkeys)
+		for _, rkey := range rkeys {
+			a.report(FindingNotDeleted, side, uint32(rkey), nil,
+				"RKey never deleted")
+		}
+	}
+	a.flows = make(map[Side]*rkeyFlow)
+	return a.findings
+}
+
+// NewRKeyAuditor returns a new RKeyAuditor
+func NewRKeyAuditor() *RKeyAuditor {
+	return &RKeyAuditor{
+		flows: make(map[Side]*rkeyFlow),
+	}
+}
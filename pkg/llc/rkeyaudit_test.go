@@ -0,0 +1,98 @@
+package llc
+
+import "testing"
+
+func TestRKeyAuditor(t *testing.T) {
+	a := NewRKeyAuditor()
+
+	// registration with continuation, accepted
+	a.Add(SideUnknown, &ConfirmRKey{NumTkns: 3, RKey: 1,
+		OtherRMBs: [2]RMBSpec{{Link: 2, RKey: 2}, {Link: 3, RKey: 3}}})
+	a.Add(SideUnknown, &ConfirmRKeyCont{NumTkns: 1, OtherRMBs: [3]RMBSpec{
+		{Link: 4, RKey: 4}}})
+	a.Add(SideUnknown, &ConfirmRKey{Reply: true})
+
+	// rejected registration
+	a.Add(SideUnknown, &ConfirmRKey{RKey: 5})
+	a.Add(SideUnknown, &ConfirmRKey{Reply: true, Reject: true, Retry: true})
+
+	// registration with missing continuation
+	a.Add(SideUnknown, &ConfirmRKey{NumTkns: 3, RKey: 6,
+		OtherRMBs: [2]RMBSpec{{Link: 2, RKey: 9}, {Link: 3, RKey: 10}}})
+	a.Add(SideUnknown, &ConfirmRKey{Reply: true})
+
+	// continuation and reply without request
+	a.Add(SideUnknown, &ConfirmRKeyCont{NumTkns: 1})
+	a.Add(SideUnknown, &ConfirmRKey{Reply: true, RKey: 7})
+
+	// delete of rkey 1, unknown rkey 8 and failed delete of rkey 1
+	a.Add(SideUnknown, &DeleteRKey{Count: 2, RKeys: [8]uint32{1, 8}})
+	a.Add(SideUnknown, &DeleteRKey{Reply: true, ErrorMask: 0b10000000})
+
+	// rkeys 6, 9 and 10 are never deleted
+	want := []string{
+		"Rejected: RKey: 5, Confirm RKey rejected, " +
+			"Configuration Retry: true",
+		"Bad Continuation: RKey: 6, " +
+			"Confirm RKey Continuation missing, " +
+			"Remaining Tokens: 1",
+		"Bad Continuation: RKey: 0, " +
+			"Confirm RKey Continuation without request",
+		"Unexpected Reply: RKey: 7, Confirm RKey reply without request",
+		"Unknown RKey: RKey: 8, Delete RKey of unknown rkey",
+		"Delete Failed: RKey: 1, Delete RKey failed, " +
+			"Error Mask: 0b10000000",
+		"Not Deleted: RKey: 6, RKey never deleted",
+		"Not Deleted: RKey: 9, RKey never deleted",
+		"Not Deleted: RKey: 10, RKey never deleted",
+	}
+	got := a.Finish()
+	if len(got) != len(want) {
+		t.Fatalf("findings = %v; want %q", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("finding %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRKeyAuditorSides(t *testing.T) {
+	a := NewRKeyAuditor()
+
+	// both sides register an rmb with the same rkey at the same time
+	a.Add(SideServer, &ConfirmRKey{RKey: 1})
+	a.Add(SideClient, &ConfirmRKey{RKey: 1})
+	a.Add(SideClient, &ConfirmRKey{Reply: true, RKey: 1})
+	a.Add(SideServer, &ConfirmRKey{Reply: true, RKey: 1})
+
+	// both sides delete their rmbs at the same time, the client's delete
+	// fails
+	a.Add(SideServer, &DeleteRKey{Count: 1, RKeys: [8]uint32{1}})
+	a.Add(SideClient, &DeleteRKey{Count: 1, RKeys: [8]uint32{1}})
+	a.Add(SideServer, &DeleteRKey{Reply: true, ErrorMask: 0b10000000})
+	a.Add(SideClient, &DeleteRKey{Reply: true})
+
+	// client registers another rmb, the server deletes it
+	a.Add(SideClient, &ConfirmRKey{RKey: 2})
+	a.Add(SideServer, &ConfirmRKey{Reply: true, RKey: 2})
+	a.Add(SideServer, &DeleteRKey{Count: 1, RKeys: [8]uint32{2}})
+	a.Add(SideClient, &DeleteRKey{Reply: true})
+
+	want := []string{
+		"Delete Failed: Side: client, RKey: 1, Delete RKey failed, " +
+			"Error Mask: 0b10000000",
+		"Unknown RKey: Side: server, RKey: 2, " +
+			"Delete RKey of unknown rkey",
+		"Not Deleted: Side: client, RKey: 2, RKey never deleted",
+	}
+	got := a.Finish()
+	if len(got) != len(want) {
+		t.Fatalf("findings = %v; want %q", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("finding %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
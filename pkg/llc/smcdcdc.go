@@ -0,0 +1,228 @@
+package llc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// SMC-D cdc messages are 32 bytes long
+	SMCDCDCMsgLen = 32
+)
+
+// SMCDCDC stores a SMC-D CDC message. SMC-D writes CDC messages into the
+// first bytes of the peer's DMB via ISM. The message does not contain a
+// token, the connection is identified by the DMB token from the CLC messages
+type SMCDCDC struct {
+	BaseMsg
+	res1     [7]byte
+	ProdWrap uint16
+	ProdCurs uint32
+	res2     [2]byte
+	ConsWrap uint16
+	ConsCurs uint32
+	B        bool
+	P        bool
+	U        bool
+	R        bool
+	F        bool
+	res3     byte
+	D        bool
+	C        bool
+	A        bool
+	res4     [9]byte
+}
+
+// Parse fills the SMC-D cdc fields from the SMC-D CDC message in buffer
+func (c *SMCDCDC) Parse(buffer []byte) {
+	// save raw message bytes
+	c.setRaw(buffer)
+
+	// Message type is 1 byte, there is no length field
+	c.Type = int(buffer[0])
+	c.Length = len(buffer)
+	buffer = buffer[1:]
+
+	// Reserved are 7 bytes
+	copy(c.res1[:], buffer[0:7])
+	buffer = buffer[7:]
+
+	// Producer cursor wrap sequence number is 2 bytes
+	c.ProdWrap = binary.BigEndian.Uint16(buffer[0:2])
+	buffer = buffer[2:]
+
+	// Producer cursor is 4 bytes
+	c.ProdCurs = binary.BigEndian.Uint32(buffer[0:4])
+	buffer = buffer[4:]
+
+	// Reserved are 2 bytes
+	copy(c.res2[:], buffer[0:2])
+	buffer = buffer[2:]
+
+	// Consumer cursor wrap sequence number is 2 bytes
+	c.ConsWrap = binary.BigEndian.Uint16(buffer[0:2])
+	buffer = buffer[2:]
+
+	// Consumer cursor is 4 bytes
+	c.ConsCurs = binary.BigEndian.Uint32(buffer[0:4])
+	buffer = buffer[4:]
+
+	// B-bit/Writer blocked indicator is the first bit in this byte
+	c.B = (buffer[0] & 0b10000000) > 0
+
+	// P-bit/Urgent data pending is next bit in this byte
+	c.P = (buffer[0] & 0b01000000) > 0
+
+	// U-bit/Urgent data present is next bit in this byte
+	c.U = (buffer[0] & 0b00100000) > 0
+
+	// R-bit/Request for consumer cursor update is next bit in this byte
+	c.R = (buffer[0] & 0b00010000) > 0
+
+	// F-bit/Failover validation indicator is next bit in this byte
+	c.F = (buffer[0] & 0b00001000) > 0
+
+	// Reserved are the remaining bits in this byte
+	c.res3 = buffer[0] & 0b00000111
+	buffer = buffer[1:]
+
+	// D-bit/Sending done indicator is the first bit in this byte
+	c.D = (buffer[0] & 0b10000000) > 0
+
+	// C-bit/PeerConnectionClosed indicator is the next bit in this byte
+	c.C = (buffer[0] & 0b01000000) > 0
+
+	// A-bit/Abnormal close indicator is the next bit in this byte
+	c.A = (buffer[0] & 0b00100000) > 0
+
+	// Reserved are the remaining bits in this byte
+	c.res4[0] = buffer[0] & 0b00011111
+	buffer = buffer[1:]
+
+	// Rest of message is reserved
+	copy(c.res4[1:], buffer[:])
+}
+
+// String converts the SMC-D cdc message into a string
+func (c *SMCDCDC) String() string {
+	cFmt := "SMC-D CDC: Type: %d, Producer Wrap: %d, " +
+		"Producer Cursor: %d, Consumer Wrap: %d, " +
+		"Consumer Cursor: %d, Writer Blocked: %t, " +
+		"Urgent Data Pending: %t, Urgent Data Present: %t, " +
+		"Request for Consumer Cursor Update: %t, " +
+		"Failover Validation: %t, Sending Done: %t, " +
+		"Peer Connection Closed: %t, Abnormal Close: %t\n"
+	return fmt.Sprintf(cFmt, c.Type, c.ProdWrap, c.ProdCurs, c.ConsWrap,
+		c.ConsCurs, c.B, c.P, c.U, c.R, c.F, c.D, c.C, c.A)
+}
+
+// Reserved converts the SMC-D cdc message into a string including reserved
+// fields
+func (c *SMCDCDC) Reserved() string {
+	cFmt := "SMC-D CDC: Type: %d, Reserved: %#x, Producer Wrap: %d, " +
+		"Producer Cursor: %d, Reserved: %#x, Consumer Wrap: %d, " +
+		"Consumer Cursor: %d, Writer Blocked: %t, " +
+		"Urgent Data Pending: %t, Urgent Data Present: %t, " +
+		"Request for Consumer Cursor Update: %t, " +
+		"Failover Validation: %t, Reserved: %#x, Sending Done: %t, " +
+		"Peer Connection Closed: %t, Abnormal Close: %t, " +
+		"Reserved: %#x\n"
+	return fmt.Sprintf(cFmt, c.Type, c.res1, c.ProdWrap, c.ProdCurs,
+		c.res2, c.ConsWrap, c.ConsCurs, c.B, c.P, c.U, c.R, c.F, c.res3,
+		c.D, c.C, c.A, c.res4)
+}
+
+// ParseSMCDCDC parses the SMC-D CDC message in buffer. It returns nil if
+// buffer is too short
+func ParseSMCDCDC(buffer []byte) *SMCDCDC {
+	if len(buffer) < SMCDCDCMsgLen {
+		return nil
+	}
+	var c SMCDCDC
+	c.Parse(buffer[:SMCDCDCMsgLen])
+	return &c
+}
+
+// jsonSMCDCDC stores the SMC-D CDC message in JSON
+type jsonSMCDCDC struct {
+	jsonMessage
+	ProdWrap           uint16 `json:"prod_wrap"`
+	ProdCurs           uint32 `json:"prod_curs"`
+	ConsWrap           uint16 `json:"cons_wrap"`
+	ConsCurs           uint32 `json:"cons_curs"`
+	WriterBlocked      bool   `json:"writer_blocked"`
+	UrgentDataPending  bool   `json:"urgent_data_pending"`
+	UrgentDataPresent  bool   `json:"urgent_data_present"`
+	ConsCursUpdate     bool   `json:"cons_curs_update_requested"`
+	FailoverValidation bool   `json:"failover_validation"`
+	SendingDone        bool   `json:"sending_done"`
+	PeerConnClosed     bool   `json:"peer_conn_closed"`
+	AbnormalClose      bool   `json:"abnormal_close"`
+}
+
+// marshalJSON converts the SMC-D CDC message to JSON
+func (c *SMCDCDC) marshalJSON(reserved bool) ([]byte, error) {
+	j := jsonSMCDCDC{
+		jsonMessage:        c.newJSONMessage(reserved),
+		ProdWrap:           c.ProdWrap,
+		ProdCurs:           c.ProdCurs,
+		ConsWrap:           c.ConsWrap,
+		ConsCurs:           c.ConsCurs,
+		WriterBlocked:      c.B,
+		UrgentDataPending:  c.P,
+		UrgentDataPresent:  c.U,
+		ConsCursUpdate:     c.R,
+		FailoverValidation: c.F,
+		SendingDone:        c.D,
+		PeerConnClosed:     c.C,
+		AbnormalClose:      c.A,
+	}
+	j.Type = "smcd_cdc"
+	j.Reserved.add("res1", c.res1)
+	j.Reserved.add("res2", c.res2)
+	j.Reserved.add("res3", c.res3)
+	j.Reserved.add("res4", c.res4)
+	return json.Marshal(j)
+}
+
+// MarshalJSON converts the SMC-D CDC message to JSON
+func (c *SMCDCDC) MarshalJSON() ([]byte, error) {
+	return c.marshalJSON(false)
+}
+
+// UnmarshalJSON converts JSON to the SMC-D CDC message
+func (c *SMCDCDC) UnmarshalJSON(data []byte) error {
+	var j jsonSMCDCDC
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	// SMC-D CDC messages have the message type of CDC messages
+	if j.Type != "smcd_cdc" {
+		return fmt.Errorf("Error unmarshaling SMC-D CDC: type %q: %w",
+			j.Type, ErrBadJSON)
+	}
+	j.Type = "cdc"
+	if err := c.fromJSON(&j.jsonMessage); err != nil {
+		return err
+	}
+	c.ProdWrap = j.ProdWrap
+	c.ProdCurs = j.ProdCurs
+	c.ConsWrap = j.ConsWrap
+	c.ConsCurs = j.ConsCurs
+	c.B = j.WriterBlocked
+	c.P = j.UrgentDataPending
+	c.U = j.UrgentDataPresent
+	c.R = j.ConsCursUpdate
+	c.F = j.FailoverValidation
+	c.D = j.SendingDone
+	c.C = j.PeerConnClosed
+	c.A = j.AbnormalClose
+	return j.Reserved.getAll(map[string]interface{}{
+		"res1": c.res1[:],
+		"res2": c.res2[:],
+		"res3": &c.res3,
+		"res4": c.res4[:],
+	})
+}
@@ -0,0 +1,77 @@
+package llc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestSMCDCDC(t *testing.T) {
+	var want, got string
+
+	// create bytes of a message
+	msgHex := "00000000  fe 00 00 00 00 00 00 00  " +
+		"00 01 00 00 00 0d 00 00  |................|\n" +
+		"00000010  00 00 00 00 00 05 80 40  " +
+		"00 00 00 00 00 00 00 00  |.......@........|\n"
+	msg := "fe 00 00 00 00 00 00 00  00 01 00 00 00 0d 00 00" +
+		"00 00 00 00 00 05 80 40  00 00 00 00 00 00 00 00"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// parse message
+	cdc := ParseSMCDCDC(bytes)
+
+	// test String()
+	want = "SMC-D CDC: Type: 254, Producer Wrap: 1, " +
+		"Producer Cursor: 13, Consumer Wrap: 0, Consumer Cursor: 5, " +
+		"Writer Blocked: true, Urgent Data Pending: false, " +
+		"Urgent Data Present: false, " +
+		"Request for Consumer Cursor Update: false, " +
+		"Failover Validation: false, Sending Done: false, " +
+		"Peer Connection Closed: true, Abnormal Close: false\n"
+	got = cdc.String()
+	if got != want {
+		t.Errorf("cdc.String() = %s; want %s", got, want)
+	}
+
+	// test Reserved()
+	want = "SMC-D CDC: Type: 254, Reserved: 0x00000000000000, " +
+		"Producer Wrap: 1, Producer Cursor: 13, Reserved: 0x0000, " +
+		"Consumer Wrap: 0, Consumer Cursor: 5, " +
+		"Writer Blocked: true, Urgent Data Pending: false, " +
+		"Urgent Data Present: false, " +
+		"Request for Consumer Cursor Update: false, " +
+		"Failover Validation: false, Reserved: 0x0, " +
+		"Sending Done: false, Peer Connection Closed: true, " +
+		"Abnormal Close: false, Reserved: 0x000000000000000000\n"
+	got = cdc.Reserved()
+	if got != want {
+		t.Errorf("cdc.Reserved() = %s; want %s", got, want)
+	}
+
+	// test Hex()
+	want = msgHex
+	got = cdc.Hex()
+	if got != want {
+		t.Errorf("cdc.Hex() = %s; want %s", got, want)
+	}
+
+	// test JSON type
+	b, err := json.Marshal(cdc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), `{"type":"smcd_cdc",`) {
+		t.Errorf("json.Marshal(cdc) = %s; want type smcd_cdc", b)
+	}
+
+	// test short message
+	if c := ParseSMCDCDC(bytes[:SMCDCDCMsgLen-1]); c != nil {
+		t.Errorf("ParseSMCDCDC() with short buffer = %v; want nil", c)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+
+	"github.com/gopacket/gopacket/layers"
 )
 
 const (
@@ -178,6 +180,7 @@ func (o Opcode) String() string {
 
 // BTH stores an ib base transport header
 type BTH struct {
+	layers.BaseLayer
 	Raw    []byte
 	Opcode Opcode
 	SE     bool
@@ -250,6 +253,36 @@ func (b *BTH) Parse(buffer []byte) {
 	b.PSN |= uint32(buffer[2])
 }
 
+// marshal writes the bth fields to the base transport header in buffer
+func (b *BTH) marshal(buffer []byte) {
+	buffer[0] = byte(b.Opcode)
+	buffer[1] = (b.Pad&0b11)<<4 | b.TVer&0b00001111
+	if b.SE {
+		buffer[1] |= 0b10000000
+	}
+	if b.M {
+		buffer[1] |= 0b01000000
+	}
+	binary.BigEndian.PutUint16(buffer[2:4], b.PKey)
+	buffer[4] = b.res1 & 0b00111111
+	if b.FECN {
+		buffer[4] |= 0b10000000
+	}
+	if b.BECN {
+		buffer[4] |= 0b01000000
+	}
+	buffer[5] = byte(b.DestQP >> 16)
+	buffer[6] = byte(b.DestQP >> 8)
+	buffer[7] = byte(b.DestQP)
+	buffer[8] = b.res2 & 0b01111111
+	if b.A {
+		buffer[8] |= 0b10000000
+	}
+	buffer[9] = byte(b.PSN >> 16)
+	buffer[10] = byte(b.PSN >> 8)
+	buffer[11] = byte(b.PSN)
+}
+
 // String converts the base transport header to a string
 func (b *BTH) String() string {
 	bfmt := "BTH: OpCode: %s, SE: %t, M: %t, Pad: %d, TVer: %d, " +
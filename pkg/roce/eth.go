@@ -0,0 +1,544 @@
+package roce
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gopacket/gopacket/layers"
+)
+
+const (
+	// internal message type
+	typeETH = 0x104
+
+	// lengths of the extended transport headers
+	RDETHLen        = 4
+	DETHLen         = 8
+	XRCETHLen       = 4
+	RETHLen         = 16
+	AtomicETHLen    = 28
+	AETHLen         = 4
+	AtomicAckETHLen = 8
+	ImmDtLen        = 4
+	IETHLen         = 4
+)
+
+// extended transport headers required by an opcode
+const (
+	hdrRDETH = 1 << iota
+	hdrDETH
+	hdrXRCETH
+	hdrRETH
+	hdrAtomicETH
+	hdrAETH
+	hdrAtomicAckETH
+	hdrImmDt
+	hdrIETH
+)
+
+// transport returns the transport service of the opcode, e.g., 0b000 for RC
+func (o Opcode) transport() Opcode {
+	return o >> 5
+}
+
+// operation returns the operation of the opcode without transport service
+func (o Opcode) operation() Opcode {
+	return o & 0b11111
+}
+
+// IsSend returns whether the opcode is a SEND operation
+func (o Opcode) IsSend() bool {
+	switch o.transport() {
+	case 0b000, 0b001, 0b010, 0b101:
+		op := o.operation()
+		return op <= 0x05 || op == 0x16 || op == 0x17
+	case 0b011:
+		op := o.operation()
+		return op == 0x04 || op == 0x05
+	}
+	return false
+}
+
+// IsRDMAWrite returns whether the opcode is a RDMA WRITE operation
+func (o Opcode) IsRDMAWrite() bool {
+	switch o.transport() {
+	case 0b000, 0b001, 0b010, 0b101:
+		op := o.operation()
+		return op >= 0x06 && op <= 0x0B
+	}
+	return false
+}
+
+// IsRDMAReadResponse returns whether the opcode is a RDMA READ response
+func (o Opcode) IsRDMAReadResponse() bool {
+	switch o.transport() {
+	case 0b000, 0b010, 0b101:
+		op := o.operation()
+		return op >= 0x0D && op <= 0x10
+	}
+	return false
+}
+
+// IsLast returns whether the opcode is the last or only packet of a SEND,
+// RDMA WRITE or RDMA READ response message
+func (o Opcode) IsLast() bool {
+	switch o.operation() {
+	case 0x02, 0x03, 0x04, 0x05, 0x08, 0x09, 0x0A, 0x0B, 0x0F, 0x10,
+		0x16, 0x17:
+		return true
+	}
+	return false
+}
+
+// IsFirst returns whether the opcode is the first packet of a SEND, RDMA
+// WRITE or RDMA READ response message that consists of multiple packets
+func (o Opcode) IsFirst() bool {
+	switch o.operation() {
+	case 0x00, 0x06, 0x0D:
+		return true
+	}
+	return false
+}
+
+// IsOnly returns whether the opcode is the only packet of a SEND, RDMA WRITE
+// or RDMA READ response message
+func (o Opcode) IsOnly() bool {
+	switch o.operation() {
+	case 0x04, 0x05, 0x0A, 0x0B, 0x10, 0x17:
+		return true
+	}
+	return false
+}
+
+// extHeaders returns the extended transport headers required by the opcode
+func (o Opcode) extHeaders() int {
+	op := o.operation()
+	hdrs := 0
+
+	// transport specific headers
+	switch o.transport() {
+	case 0b000, 0b001:
+		// RC, UC
+	case 0b010:
+		// RD: requests also contain a DETH
+		hdrs |= hdrRDETH
+		if op < 0x0D || op > 0x12 {
+			hdrs |= hdrDETH
+		}
+	case 0b011:
+		// UD: only SEND Only (with Immediate)
+		switch op {
+		case 0x04:
+			return hdrDETH
+		case 0x05:
+			return hdrDETH | hdrImmDt
+		}
+		return 0
+	case 0b101:
+		// XRC: requests also contain a XRCETH
+		if op < 0x0D || op > 0x12 {
+			hdrs |= hdrXRCETH
+		}
+	default:
+		// CNP, manufacturer specific
+		return 0
+	}
+
+	// operation specific headers
+	switch op {
+	case 0x03, 0x05, 0x09:
+		// SEND Last/Only with Immediate, RDMA WRITE Last with Immediate
+		hdrs |= hdrImmDt
+	case 0x06, 0x0A, 0x0C:
+		// RDMA WRITE First/Only, RDMA READ Request
+		hdrs |= hdrRETH
+	case 0x0B:
+		// RDMA WRITE Only with Immediate
+		hdrs |= hdrRETH | hdrImmDt
+	case 0x0D, 0x0F, 0x10, 0x11:
+		// RDMA READ Response First/Last/Only, Acknowledge
+		hdrs |= hdrAETH
+	case 0x12:
+		// ATOMIC Acknowledge
+		hdrs |= hdrAETH | hdrAtomicAckETH
+	case 0x13, 0x14:
+		// CmpSwap, FetchAdd
+		hdrs |= hdrAtomicETH
+	case 0x16, 0x17:
+		// SEND Last/Only with Invalidate
+		hdrs |= hdrIETH
+	}
+	return hdrs
+}
+
+// extHeadersLen returns the length of the extended transport headers hdrs
+func extHeadersLen(hdrs int) int {
+	length := 0
+	for _, h := range []struct {
+		flag int
+		len  int
+	}{
+		{hdrRDETH, RDETHLen},
+		{hdrDETH, DETHLen},
+		{hdrXRCETH, XRCETHLen},
+		{hdrRETH, RETHLen},
+		{hdrAtomicETH, AtomicETHLen},
+		{hdrAETH, AETHLen},
+		{hdrAtomicAckETH, AtomicAckETHLen},
+		{hdrImmDt, ImmDtLen},
+		{hdrIETH, IETHLen},
+	} {
+		if hdrs&h.flag != 0 {
+			length += h.len
+		}
+	}
+	return length
+}
+
+// RDETH stores a reliable datagram extended transport header
+type RDETH struct {
+	res    byte
+	EECnxt uint32
+}
+
+// DETH stores a datagram extended transport header
+type DETH struct {
+	QKey  uint32
+	res   byte
+	SrcQP uint32
+}
+
+// XRCETH stores a XRC extended transport header
+type XRCETH struct {
+	res    byte
+	XRCSRQ uint32
+}
+
+// RETH stores a RDMA extended transport header
+type RETH struct {
+	VirtualAddr uint64
+	RKey        uint32
+	DMALen      uint32
+}
+
+// AtomicETH stores an atomic extended transport header
+type AtomicETH struct {
+	VirtualAddr uint64
+	RKey        uint32
+	SwapAddData uint64
+	CompareData uint64
+}
+
+// AETH stores an ACK extended transport header
+type AETH struct {
+	Syndrome uint8
+	MSN      uint32
+}
+
+// AtomicAckETH stores an atomic ACK extended transport header
+type AtomicAckETH struct {
+	OrigRemDt uint64
+}
+
+// ImmDt stores an immediate data extended transport header
+type ImmDt struct {
+	Data uint32
+}
+
+// IETH stores an invalidate extended transport header
+type IETH struct {
+	RKey uint32
+}
+
+// ETH stores the extended transport headers after the bth. Only the headers
+// required by the opcode in the bth are set
+type ETH struct {
+	layers.BaseLayer
+	Raw          []byte
+	opcode       Opcode
+	RDETH        *RDETH
+	DETH         *DETH
+	XRCETH       *XRCETH
+	RETH         *RETH
+	AtomicETH    *AtomicETH
+	AETH         *AETH
+	AtomicAckETH *AtomicAckETH
+	ImmDt        *ImmDt
+	IETH         *IETH
+}
+
+// uint24 converts the 3 bytes in buffer to an uint32
+func uint24(buffer []byte) uint32 {
+	return uint32(buffer[0])<<16 | uint32(buffer[1])<<8 | uint32(buffer[2])
+}
+
+// putUint24 writes the lower 3 bytes of v to buffer
+func putUint24(buffer []byte, v uint32) {
+	buffer[0] = byte(v >> 16)
+	buffer[1] = byte(v >> 8)
+	buffer[2] = byte(v)
+}
+
+// parse fills the eth fields from the extended transport headers required by
+// opcode in buffer
+func (e *ETH) parse(opcode Opcode, buffer []byte) {
+	hdrs := opcode.extHeaders()
+	e.opcode = opcode
+
+	// save raw header bytes
+	e.Raw = make([]byte, extHeadersLen(hdrs))
+	copy(e.Raw[:], buffer[:])
+
+	if hdrs&hdrRDETH != 0 {
+		e.RDETH = &RDETH{
+			res:    buffer[0],
+			EECnxt: uint24(buffer[1:4]),
+		}
+		buffer = buffer[RDETHLen:]
+	}
+	if hdrs&hdrDETH != 0 {
+		e.DETH = &DETH{
+			QKey:  binary.BigEndian.Uint32(buffer[0:4]),
+			res:   buffer[4],
+			SrcQP: uint24(buffer[5:8]),
+		}
+		buffer = buffer[DETHLen:]
+	}
+	if hdrs&hdrXRCETH != 0 {
+		e.XRCETH = &XRCETH{
+			res:    buffer[0],
+			XRCSRQ: uint24(buffer[1:4]),
+		}
+		buffer = buffer[XRCETHLen:]
+	}
+	if hdrs&hdrRETH != 0 {
+		e.RETH = &RETH{
+			VirtualAddr: binary.BigEndian.Uint64(buffer[0:8]),
+			RKey:        binary.BigEndian.Uint32(buffer[8:12]),
+			DMALen:      binary.BigEndian.Uint32(buffer[12:16]),
+		}
+		buffer = buffer[RETHLen:]
+	}
+	if hdrs&hdrAtomicETH != 0 {
+		e.AtomicETH = &AtomicETH{
+			VirtualAddr: binary.BigEndian.Uint64(buffer[0:8]),
+			RKey:        binary.BigEndian.Uint32(buffer[8:12]),
+			SwapAddData: binary.BigEndian.Uint64(buffer[12:20]),
+			CompareData: binary.BigEndian.Uint64(buffer[20:28]),
+		}
+		buffer = buffer[AtomicETHLen:]
+	}
+	if hdrs&hdrAETH != 0 {
+		e.AETH = &AETH{
+			Syndrome: buffer[0],
+			MSN:      uint24(buffer[1:4]),
+		}
+		buffer = buffer[AETHLen:]
+	}
+	if hdrs&hdrAtomicAckETH != 0 {
+		e.AtomicAckETH = &AtomicAckETH{
+			OrigRemDt: binary.BigEndian.Uint64(buffer[0:8]),
+		}
+		buffer = buffer[AtomicAckETHLen:]
+	}
+	if hdrs&hdrImmDt != 0 {
+		e.ImmDt = &ImmDt{
+			Data: binary.BigEndian.Uint32(buffer[0:4]),
+		}
+		buffer = buffer[ImmDtLen:]
+	}
+	if hdrs&hdrIETH != 0 {
+		e.IETH = &IETH{
+			RKey: binary.BigEndian.Uint32(buffer[0:4]),
+		}
+	}
+}
+
+// Len returns the length of the extended transport headers in bytes
+func (e *ETH) Len() int {
+	return extHeadersLen(e.hdrs())
+}
+
+// hdrs returns the extended transport headers set in e
+func (e *ETH) hdrs() int {
+	hdrs := 0
+	if e.RDETH != nil {
+		hdrs |= hdrRDETH
+	}
+	if e.DETH != nil {
+		hdrs |= hdrDETH
+	}
+	if e.XRCETH != nil {
+		hdrs |= hdrXRCETH
+	}
+	if e.RETH != nil {
+		hdrs |= hdrRETH
+	}
+	if e.AtomicETH != nil {
+		hdrs |= hdrAtomicETH
+	}
+	if e.AETH != nil {
+		hdrs |= hdrAETH
+	}
+	if e.AtomicAckETH != nil {
+		hdrs |= hdrAtomicAckETH
+	}
+	if e.ImmDt != nil {
+		hdrs |= hdrImmDt
+	}
+	if e.IETH != nil {
+		hdrs |= hdrIETH
+	}
+	return hdrs
+}
+
+// marshal writes the extended transport headers to buffer
+func (e *ETH) marshal(buffer []byte) {
+	if e.RDETH != nil {
+		buffer[0] = e.RDETH.res
+		putUint24(buffer[1:4], e.RDETH.EECnxt)
+		buffer = buffer[RDETHLen:]
+	}
+	if e.DETH != nil {
+		binary.BigEndian.PutUint32(buffer[0:4], e.DETH.QKey)
+		buffer[4] = e.DETH.res
+		putUint24(buffer[5:8], e.DETH.SrcQP)
+		buffer = buffer[DETHLen:]
+	}
+	if e.XRCETH != nil {
+		buffer[0] = e.XRCETH.res
+		putUint24(buffer[1:4], e.XRCETH.XRCSRQ)
+		buffer = buffer[XRCETHLen:]
+	}
+	if e.RETH != nil {
+		binary.BigEndian.PutUint64(buffer[0:8], e.RETH.VirtualAddr)
+		binary.BigEndian.PutUint32(buffer[8:12], e.RETH.RKey)
+		binary.BigEndian.PutUint32(buffer[12:16], e.RETH.DMALen)
+		buffer = buffer[RETHLen:]
+	}
+	if e.AtomicETH != nil {
+		a := e.AtomicETH
+		binary.BigEndian.PutUint64(buffer[0:8], a.VirtualAddr)
+		binary.BigEndian.PutUint32(buffer[8:12], a.RKey)
+		binary.BigEndian.PutUint64(buffer[12:20], a.SwapAddData)
+		binary.BigEndian.PutUint64(buffer[20:28], a.CompareData)
+		buffer = buffer[AtomicETHLen:]
+	}
+	if e.AETH != nil {
+		buffer[0] = e.AETH.Syndrome
+		putUint24(buffer[1:4], e.AETH.MSN)
+		buffer = buffer[AETHLen:]
+	}
+	if e.AtomicAckETH != nil {
+		binary.BigEndian.PutUint64(buffer[0:8], e.AtomicAckETH.OrigRemDt)
+		buffer = buffer[AtomicAckETHLen:]
+	}
+	if e.ImmDt != nil {
+		binary.BigEndian.PutUint32(buffer[0:4], e.ImmDt.Data)
+		buffer = buffer[ImmDtLen:]
+	}
+	if e.IETH != nil {
+		binary.BigEndian.PutUint32(buffer[0:4], e.IETH.RKey)
+	}
+}
+
+// headerStrings returns the extended transport headers as strings. If
+// reserved is true, reserved fields are included
+func (e *ETH) headerStrings(reserved bool) []string {
+	var hdrs []string
+	if e.RDETH != nil {
+		if reserved {
+			hdrs = append(hdrs, fmt.Sprintf(
+				"RDETH: Reserved: %#x, EE Context: %d",
+				e.RDETH.res, e.RDETH.EECnxt))
+		} else {
+			hdrs = append(hdrs, fmt.Sprintf("RDETH: EE Context: %d",
+				e.RDETH.EECnxt))
+		}
+	}
+	if e.DETH != nil {
+		if reserved {
+			hdrs = append(hdrs, fmt.Sprintf(
+				"DETH: Q_Key: %#x, Reserved: %#x, Source QP: %d",
+				e.DETH.QKey, e.DETH.res, e.DETH.SrcQP))
+		} else {
+			hdrs = append(hdrs, fmt.Sprintf(
+				"DETH: Q_Key: %#x, Source QP: %d", e.DETH.QKey,
+				e.DETH.SrcQP))
+		}
+	}
+	if e.XRCETH != nil {
+		if reserved {
+			hdrs = append(hdrs, fmt.Sprintf(
+				"XRCETH: Reserved: %#x, XRC SRQ: %d",
+				e.XRCETH.res, e.XRCETH.XRCSRQ))
+		} else {
+			hdrs = append(hdrs, fmt.Sprintf("XRCETH: XRC SRQ: %d",
+				e.XRCETH.XRCSRQ))
+		}
+	}
+	if e.RETH != nil {
+		hdrs = append(hdrs, fmt.Sprintf(
+			"RETH: Virtual Address: %#x, R_Key: %d, DMA Length: %d",
+			e.RETH.VirtualAddr, e.RETH.RKey, e.RETH.DMALen))
+	}
+	if e.AtomicETH != nil {
+		hdrs = append(hdrs, fmt.Sprintf("AtomicETH: "+
+			"Virtual Address: %#x, R_Key: %d, Swap/Add Data: %#x, "+
+			"Compare Data: %#x", e.AtomicETH.VirtualAddr,
+			e.AtomicETH.RKey, e.AtomicETH.SwapAddData,
+			e.AtomicETH.CompareData))
+	}
+	if e.AETH != nil {
+		hdrs = append(hdrs, fmt.Sprintf("AETH: Syndrome: %#x, MSN: %d",
+			e.AETH.Syndrome, e.AETH.MSN))
+	}
+	if e.AtomicAckETH != nil {
+		hdrs = append(hdrs, fmt.Sprintf(
+			"AtomicAckETH: Original Remote Data: %#x",
+			e.AtomicAckETH.OrigRemDt))
+	}
+	if e.ImmDt != nil {
+		hdrs = append(hdrs, fmt.Sprintf("ImmDt: %#x", e.ImmDt.Data))
+	}
+	if e.IETH != nil {
+		hdrs = append(hdrs, fmt.Sprintf("IETH: R_Key: %d", e.IETH.RKey))
+	}
+	return hdrs
+}
+
+// String converts the extended transport headers to a string
+func (e *ETH) String() string {
+	return strings.Join(e.headerStrings(false), "\n") + "\n"
+}
+
+// Reserved converts the extended transport headers to a string including
+// reserved fields
+func (e *ETH) Reserved() string {
+	return strings.Join(e.headerStrings(true), "\n") + "\n"
+}
+
+// Hex converts the extended transport headers to a hex dump string
+func (e *ETH) Hex() string {
+	return hex.Dump(e.Raw)
+}
+
+// GetType returns the type of the extended transport headers
+func (e *ETH) GetType() int {
+	return typeETH
+}
+
+// ParseETH parses the extended transport headers required by opcode in
+// buffer. It returns nil if the opcode does not require extended transport
+// headers or buffer is too short
+func ParseETH(opcode Opcode, buffer []byte) *ETH {
+	hdrs := opcode.extHeaders()
+	if hdrs == 0 || len(buffer) < extHeadersLen(hdrs) {
+		return nil
+	}
+	var e ETH
+	e.parse(opcode, buffer)
+	return &e
+}
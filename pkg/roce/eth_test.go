@@ -0,0 +1,118 @@
+package roce
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/hwipl/smc-go/pkg/llc"
+)
+
+// testRoCEv2Bytes returns the bytes of a RoCEv2 packet with the bth and hex
+// string msg of the extended transport headers and payload
+func testRoCEv2Bytes(bth *BTH, msg string) []byte {
+	payload, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+	buf := make([]byte, BTHLen+len(payload)+ICRCLen)
+	bth.marshal(buf)
+	copy(buf[BTHLen:], payload)
+	return buf
+}
+
+func TestETHRDMAWrite(t *testing.T) {
+	var want, got string
+
+	// create RC RDMA WRITE Only message with RETH and 8 bytes of data
+	bth := &BTH{Opcode: 0b01010, PKey: 0xffff, DestQP: 263, PSN: 1}
+	msg := "00 00 00 00 12 34 56 78  00 00 10 01 00 00 00 08" +
+		"01 02 03 04 05 06 07 08"
+	roce := ParseRoCEv2(testRoCEv2Bytes(bth, msg))
+
+	// check payload
+	if roce.LLC != nil {
+		t.Errorf("roce.LLC = %v; want nil", roce.LLC)
+	}
+	wantData := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	if !bytes.Equal(roce.Data, wantData) {
+		t.Errorf("roce.Data = %v; want %v", roce.Data, wantData)
+	}
+
+	// test String()
+	want = "RETH: Virtual Address: 0x12345678, R_Key: 4097, " +
+		"DMA Length: 8\n"
+	got = roce.ETH.String()
+	if got != want {
+		t.Errorf("eth.String() = %s; want %s", got, want)
+	}
+
+	// test Hex()
+	want = "00000000  00 00 00 00 12 34 56 78  00 00 10 01 00 00 00 08" +
+		"  |.....4Vx........|\n"
+	got = roce.ETH.Hex()
+	if got != want {
+		t.Errorf("eth.Hex() = %s; want %s", got, want)
+	}
+
+	// test GetType()
+	if got := roce.ETH.GetType(); got != typeETH {
+		t.Errorf("eth.GetType() = %d; want %d", got, typeETH)
+	}
+
+	// test marshal()
+	buf := make([]byte, roce.ETH.Len())
+	roce.ETH.marshal(buf)
+	if !bytes.Equal(buf, roce.ETH.Raw) {
+		t.Errorf("eth.marshal() = %v; want %v", buf, roce.ETH.Raw)
+	}
+}
+
+func TestETHAck(t *testing.T) {
+	// create RC Acknowledge message with AETH
+	bth := &BTH{Opcode: 0b10001, PKey: 0xffff, DestQP: 263, PSN: 1}
+	roce := ParseRoCEv2(testRoCEv2Bytes(bth, "1f 00 00 2a"))
+	if roce.LLC != nil || roce.Data != nil {
+		t.Errorf("roce payload = %v, %v; want nil", roce.LLC, roce.Data)
+	}
+
+	// test String()
+	want := "AETH: Syndrome: 0x1f, MSN: 42\n"
+	got := roce.ETH.String()
+	if got != want {
+		t.Errorf("eth.String() = %s; want %s", got, want)
+	}
+}
+
+func TestETHSendImm(t *testing.T) {
+	// create RC SEND Only with Immediate message with ImmDt and link msg
+	bth := &BTH{Opcode: 0b00101, PKey: 0xffff, DestQP: 263, PSN: 1}
+	msg := "de ad be ef" +
+		"07 2c 00 80 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+	roce := ParseRoCEv2(testRoCEv2Bytes(bth, msg))
+
+	// check headers and payload
+	if roce.ETH == nil || roce.ETH.ImmDt == nil ||
+		roce.ETH.ImmDt.Data != 0xdeadbeef {
+		t.Fatalf("roce.ETH = %v; want ImmDt 0xdeadbeef", roce.ETH)
+	}
+	if _, ok := roce.LLC.(*llc.TestLink); !ok {
+		t.Errorf("roce.LLC = %T; want *llc.TestLink", roce.LLC)
+	}
+}
+
+func TestETHNone(t *testing.T) {
+	// SEND Only does not require extended transport headers
+	if eth := ParseETH(0b00100, []byte{1, 2, 3, 4}); eth != nil {
+		t.Errorf("ParseETH() = %v; want nil", eth)
+	}
+
+	// buffer too short for RETH
+	if eth := ParseETH(0b01010, []byte{1, 2, 3, 4}); eth != nil {
+		t.Errorf("ParseETH() = %v; want nil", eth)
+	}
+}
@@ -0,0 +1,180 @@
+package roce
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+)
+
+const (
+	// udpLen is the length of the udp header
+	udpLen = 8
+
+	// ip protocol number of udp
+	udpProtocol = 17
+)
+
+// ICRC errors
+var (
+	ErrBadICRC     = errors.New("invalid icrc")
+	ErrNoHeaders   = errors.New("missing network headers")
+	ErrBadIPHeader = errors.New("invalid ip header")
+)
+
+// icrcLRH is the masked local routing header at the start of the icrc
+// calculation. There is no LRH in RoCE packets, so all its bits are masked
+var icrcLRH = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// ComputeICRC computes the invariant CRC of the RoCE packet in data. For
+// RoCEv1 packets, data starts with the GRH. For RoCEv2 packets, data starts
+// with the IPv4 or IPv6 header followed by the UDP header. In both cases, the
+// BTH and the payload follow, but not the icrc itself. The variant fields of
+// the headers are masked as defined in the InfiniBand specification
+func ComputeICRC(data []byte) (uint32, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("Error computing ICRC: %w", ErrTooShort)
+	}
+
+	// determine length of headers
+	version := data[0] >> 4
+	hdrLen := 0
+	switch version {
+	case 4:
+		// RoCEv2 over IPv4
+		hdrLen = int(data[0]&0x0f)*4 + udpLen
+	case 6:
+		// RoCEv1 or RoCEv2 over IPv6
+		hdrLen = GRHLen
+		if len(data) > 6 && data[6] == udpProtocol {
+			hdrLen += udpLen
+		}
+	default:
+		return 0, fmt.Errorf("Error computing ICRC: %w", ErrBadIPHeader)
+	}
+	udp := hdrLen > GRHLen || version == 4
+	hdrLen += BTHLen
+	if len(data) < hdrLen {
+		return 0, fmt.Errorf("Error computing ICRC: %w", ErrTooShort)
+	}
+
+	// mask variant header fields in a copy of the headers
+	hdr := make([]byte, hdrLen)
+	copy(hdr, data)
+	if version == 4 {
+		// type of service, time to live, header checksum
+		hdr[1] = 0xff
+		hdr[8] = 0xff
+		hdr[10] = 0xff
+		hdr[11] = 0xff
+	} else {
+		// traffic class, flow label, hop limit
+		hdr[0] |= 0x0f
+		hdr[1] = 0xff
+		hdr[2] = 0xff
+		hdr[3] = 0xff
+		hdr[7] = 0xff
+	}
+	if udp {
+		// udp checksum
+		hdr[hdrLen-BTHLen-2] = 0xff
+		hdr[hdrLen-BTHLen-1] = 0xff
+	}
+	// bth fecn, becn and reserved bits
+	hdr[hdrLen-BTHLen+4] = 0xff
+
+	// compute crc over masked lrh, masked headers and payload
+	crc := crc32.NewIEEE()
+	crc.Write(icrcLRH)
+	crc.Write(hdr)
+	crc.Write(data[hdrLen:])
+	return crc.Sum32(), nil
+}
+
+// verifyICRC checks the icrc at the end of the RoCE packet in data. See
+// ComputeICRC for the start of data
+func verifyICRC(data []byte) error {
+	if len(data) < ICRCLen {
+		return fmt.Errorf("Error verifying ICRC: %w", ErrTooShort)
+	}
+	icrc, err := ComputeICRC(data[:len(data)-ICRCLen])
+	if err != nil {
+		return err
+	}
+	if icrc != binary.LittleEndian.Uint32(data[len(data)-ICRCLen:]) {
+		return ErrBadICRC
+	}
+	return nil
+}
+
+// Verify checks the icrc of the RoCE packet. It returns ErrNoHeaders if the
+// packet was parsed without its IP and UDP headers with ParseRoCEv2
+func (r *RoCE) Verify() error {
+	if r.data == nil {
+		return ErrNoHeaders
+	}
+	return verifyICRC(r.data)
+}
+
+// VerifyPacket checks the icrc of the RoCE packet decoded by gopacket
+func VerifyPacket(packet gopacket.Packet) error {
+	if packet.Layer(LayerTypeICRC) == nil {
+		return fmt.Errorf("Error verifying ICRC: %w", ErrTooShort)
+	}
+	if packet.Metadata().Truncated {
+		return fmt.Errorf("Error verifying ICRC: %w", ErrTooShort)
+	}
+	var network gopacket.Layer = packet.Layer(LayerTypeGRH)
+	if network == nil {
+		network = packet.NetworkLayer()
+	}
+	if network == nil {
+		return ErrNoHeaders
+	}
+	data := append(append([]byte{}, network.LayerContents()...),
+		network.LayerPayload()...)
+	return verifyICRC(data)
+}
+
+// SerializeLayers serializes layers to w like gopacket.SerializeLayers. If
+// opts.ComputeChecksums is set and the last layer is an ICRC layer, it also
+// computes the icrc of the RoCE packet and stores it in the ICRC layer
+func SerializeLayers(w gopacket.SerializeBuffer,
+	opts gopacket.SerializeOptions,
+	layerList ...gopacket.SerializableLayer) error {
+	var icrc *ICRC
+	computed := false
+	if len(layerList) > 0 {
+		icrc, _ = layerList[len(layerList)-1].(*ICRC)
+	}
+
+	w.Clear()
+	for i := len(layerList) - 1; i >= 0; i-- {
+		layer := layerList[i]
+		if err := layer.SerializeTo(w, opts); err != nil {
+			return err
+		}
+		w.PushLayer(layer.LayerType())
+
+		// compute icrc after serializing the (inner) network layer
+		if !opts.ComputeChecksums || icrc == nil || computed {
+			continue
+		}
+		switch layer.LayerType() {
+		case LayerTypeGRH, layers.LayerTypeIPv4, layers.LayerTypeIPv6:
+			computed = true
+			data := w.Bytes()
+			crc, err := ComputeICRC(data[:len(data)-ICRCLen])
+			if err != nil {
+				return err
+			}
+			icrc.CRC = crc
+			binary.LittleEndian.PutUint32(data[len(data)-ICRCLen:],
+				crc)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package roce
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/hwipl/smc-go/pkg/llc"
+)
+
+// testICRCMessage returns a llc test link message for the icrc tests
+func testICRCMessage() *llc.LLC {
+	msg := make([]byte, llc.LLCMsgLen)
+	msg[0] = llc.TypeTestLink
+	msg[1] = llc.LLCMsgLen
+	return &llc.LLC{Message: llc.ParseLLC(msg)}
+}
+
+func TestICRCRoCEv2(t *testing.T) {
+	// serialize packet with icrc
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4(10, 0, 0, 1),
+		DstIP:    net.IPv4(10, 0, 0, 2),
+	}
+	udp := &layers.UDP{SrcPort: 50000, DstPort: RoCEv2UDPPort}
+	udp.SetNetworkLayerForChecksum(ip)
+	bth := &BTH{Opcode: 0b100, PKey: 0xffff, DestQP: 263, PSN: 1}
+	icrc := &ICRC{}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	err := SerializeLayers(buf, opts, ip, udp, bth, testICRCMessage(),
+		icrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icrc.CRC == 0 {
+		t.Errorf("icrc.CRC = 0; want computed icrc")
+	}
+
+	// verify packet and parsed packet
+	data := buf.Bytes()
+	packet := gopacket.NewPacket(data, layers.LayerTypeIPv4,
+		gopacket.Default)
+	if err := VerifyPacket(packet); err != nil {
+		t.Errorf("VerifyPacket() = %v; want nil", err)
+	}
+	if err := ParseRoCEv2IP(data).Verify(); err != nil {
+		t.Errorf("Verify() = %v; want nil", err)
+	}
+	if err := ParseRoCEv2(data[20+8:]).Verify(); err != ErrNoHeaders {
+		t.Errorf("Verify() = %v; want %v", err, ErrNoHeaders)
+	}
+
+	// variant fields do not change the icrc: tos, ttl, ip checksum, udp
+	// checksum, fecn/becn
+	for _, i := range []int{1, 8, 10, 11, 20 + 6, 20 + 7, 20 + 8 + 4} {
+		variant := append([]byte{}, data...)
+		variant[i] ^= 0xc0
+		if err := ParseRoCEv2IP(variant).Verify(); err != nil {
+			t.Errorf("Verify() with byte %d changed = %v; want nil",
+				i, err)
+		}
+	}
+
+	// invariant fields change the icrc: ip address, bth dest qp, payload
+	for _, i := range []int{12, 20 + 8 + 7, 20 + 8 + 12} {
+		invariant := append([]byte{}, data...)
+		invariant[i] ^= 0xc0
+		err := ParseRoCEv2IP(invariant).Verify()
+		if !errors.Is(err, ErrBadICRC) {
+			t.Errorf("Verify() with byte %d changed = %v; want %v",
+				i, err, ErrBadICRC)
+		}
+	}
+
+	// truncated packet
+	truncated := data[:len(data)-1]
+	if err := ParseRoCEv2IP(truncated).Verify(); err == nil {
+		t.Errorf("Verify() of truncated packet = nil; want error")
+	}
+}
+
+func TestICRCRoCEv1(t *testing.T) {
+	// serialize packet with icrc
+	grh := &GRH{
+		Version:    6,
+		NextHeader: BTHNextHeader,
+		HopLimit:   1,
+		SrcIP:      net.ParseIP("fe80::1"),
+		DstIP:      net.ParseIP("fe80::2"),
+	}
+	bth := &BTH{Opcode: 0b100, PKey: 0xffff, DestQP: 263, PSN: 1}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	err := SerializeLayers(buf, opts, grh, bth, testICRCMessage(),
+		&ICRC{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify packet and parsed packet
+	data := buf.Bytes()
+	packet := gopacket.NewPacket(data, LayerTypeGRH, gopacket.Default)
+	if err := VerifyPacket(packet); err != nil {
+		t.Errorf("VerifyPacket() = %v; want nil", err)
+	}
+	if err := ParseRoCEv1(data).Verify(); err != nil {
+		t.Errorf("Verify() = %v; want nil", err)
+	}
+
+	// variant fields do not change the icrc: traffic class, flow label,
+	// hop limit
+	for _, i := range []int{0, 1, 2, 3, 7} {
+		variant := append([]byte{}, data...)
+		variant[i] ^= 0x0f
+		if err := ParseRoCEv1(variant).Verify(); err != nil {
+			t.Errorf("Verify() with byte %d changed = %v; want nil",
+				i, err)
+		}
+	}
+
+	// invariant fields change the icrc: payload length, gid
+	for _, i := range []int{5, 8} {
+		invariant := append([]byte{}, data...)
+		invariant[i] ^= 0x0f
+		err := ParseRoCEv1(invariant).Verify()
+		if !errors.Is(err, ErrBadICRC) {
+			t.Errorf("Verify() with byte %d changed = %v; want %v",
+				i, err, ErrBadICRC)
+		}
+	}
+}
+
+func TestICRCVectors(t *testing.T) {
+	// SMC-R CDC messages in SEND Only packets. The icrcs were computed
+	// independently of ComputeICRC like the rxe driver of the linux
+	// kernel: crc32 with the seed 0xdebb20e3, i.e., the crc state after
+	// the masked lrh, over the masked headers and the payload
+	cdc := "fe2c00010000002a" + strings.Repeat("00", 36)
+	for _, test := range []struct {
+		name  string
+		first gopacket.LayerType
+		data  string
+		icrc  uint32
+	}{
+		{
+			name:  "RoCEv2",
+			first: layers.LayerTypeIPv4,
+			data: "450200581a2b400040110c660a0000010a000002" +
+				"c35012b700440000" +
+				"0440ffff0000010780000001" + cdc + "90f15872",
+			icrc: 0x7258f190,
+		},
+		{
+			name:  "RoCEv1",
+			first: LayerTypeGRH,
+			data: "60000000003c1b01" +
+				"fe800000000000000000000000000001" +
+				"fe800000000000000000000000000002" +
+				"0440ffff0000010780000001" + cdc + "3969aac6",
+			icrc: 0xc6aa6939,
+		},
+	} {
+		data, err := hex.DecodeString(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		icrc, err := ComputeICRC(data[:len(data)-ICRCLen])
+		if err != nil || icrc != test.icrc {
+			t.Errorf("%s: ComputeICRC() = %08x, %v; want %08x",
+				test.name, icrc, err, test.icrc)
+		}
+		packet := gopacket.NewPacket(data, test.first, gopacket.Default)
+		if err := VerifyPacket(packet); err != nil {
+			t.Errorf("%s: VerifyPacket() = %v; want nil", test.name,
+				err)
+		}
+		r := ParseRoCEv1(data)
+		if test.first == layers.LayerTypeIPv4 {
+			r = ParseRoCEv2IP(data)
+		}
+		if err := r.Verify(); err != nil {
+			t.Errorf("%s: Verify() = %v; want nil", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package roce
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/hwipl/smc-go/pkg/llc"
+)
+
+const (
+	// ICRCLen is the length of the icrc
+	ICRCLen = 4
+)
+
+// ErrTooShort is returned when decoding a RoCE layer from too few bytes
+var ErrTooShort = errors.New("packet too short")
+
+// gopacket layer types of RoCE headers
+var (
+	LayerTypeGRH  gopacket.LayerType
+	LayerTypeBTH  gopacket.LayerType
+	LayerTypeICRC gopacket.LayerType
+	LayerTypeETH  gopacket.LayerType
+)
+
+func init() {
+	// register layer types in init to avoid an initialization cycle with
+	// the decoders
+	LayerTypeGRH = gopacket.RegisterLayerType(1910,
+		gopacket.LayerTypeMetadata{
+			Name:    "GRH",
+			Decoder: gopacket.DecodeFunc(decodeGRH),
+		})
+	LayerTypeBTH = gopacket.RegisterLayerType(1911,
+		gopacket.LayerTypeMetadata{
+			Name:    "BTH",
+			Decoder: gopacket.DecodeFunc(decodeBTH),
+		})
+	LayerTypeICRC = gopacket.RegisterLayerType(1912,
+		gopacket.LayerTypeMetadata{
+			Name:    "ICRC",
+			Decoder: gopacket.DecodeFunc(decodeICRC),
+		})
+
+	// extended transport headers depend on the opcode in the bth, so
+	// they are decoded by the bth decoder
+	LayerTypeETH = gopacket.RegisterLayerType(1913,
+		gopacket.LayerTypeMetadata{
+			Name: "ETH",
+		})
+
+	// decode RoCEv1 ethernet frames and RoCEv2 udp packets
+	layers.EthernetTypeMetadata[RoCEv1EtherType] = layers.EnumMetadata{
+		DecodeWith: LayerTypeGRH,
+		Name:       "RoCEv1",
+		LayerType:  LayerTypeGRH,
+	}
+	layers.RegisterUDPPortLayerType(RoCEv2UDPPort, LayerTypeBTH)
+}
+
+// LayerType returns the layer type of the GRH layer
+func (g *GRH) LayerType() gopacket.LayerType {
+	return LayerTypeGRH
+}
+
+// CanDecode returns the layer types the GRH layer can decode
+func (g *GRH) CanDecode() gopacket.LayerClass {
+	return LayerTypeGRH
+}
+
+// NextLayerType returns the layer type of the data after the GRH
+func (g *GRH) NextLayerType() gopacket.LayerType {
+	if g.NextHeader == BTHNextHeader {
+		return LayerTypeBTH
+	}
+	return gopacket.LayerTypePayload
+}
+
+// NetworkFlow returns the flow of the source and destination GIDs
+func (g *GRH) NetworkFlow() gopacket.Flow {
+	return (*layers.IPv6)(g).NetworkFlow()
+}
+
+// DecodeFromBytes decodes the global routing header in data
+func (g *GRH) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	return (*layers.IPv6)(g).DecodeFromBytes(data, df)
+}
+
+// SerializeTo writes the global routing header to b
+func (g *GRH) SerializeTo(b gopacket.SerializeBuffer,
+	opts gopacket.SerializeOptions) error {
+	return (*layers.IPv6)(g).SerializeTo(b, opts)
+}
+
+// decodeGRH decodes the global routing header in data
+func decodeGRH(data []byte, p gopacket.PacketBuilder) error {
+	g := &GRH{}
+	if err := g.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(g)
+	p.SetNetworkLayer(g)
+	return p.NextDecoder(g.NextLayerType())
+}
+
+// LayerType returns the layer type of the BTH layer
+func (b *BTH) LayerType() gopacket.LayerType {
+	return LayerTypeBTH
+}
+
+// CanDecode returns the layer types the BTH layer can decode
+func (b *BTH) CanDecode() gopacket.LayerClass {
+	return LayerTypeBTH
+}
+
+// NextLayerType returns the layer type of the data after the BTH
+func (b *BTH) NextLayerType() gopacket.LayerType {
+	if b.Opcode.extHeaders() != 0 {
+		return LayerTypeETH
+	}
+	return payloadLayerType(b.Opcode)
+}
+
+// payloadLayerType returns the layer type of the payload of a packet with
+// opcode. Only payloads of SEND Only operations contain complete LLC messages
+func payloadLayerType(opcode Opcode) gopacket.LayerType {
+	if opcode.IsSend() && opcode.IsOnly() {
+		return llc.LayerTypeLLC
+	}
+	return gopacket.LayerTypePayload
+}
+
+// DecodeFromBytes decodes the base transport header in data. The payload
+// of the BTH layer contains the message and the icrc
+func (b *BTH) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < BTHLen {
+		df.SetTruncated()
+		return fmt.Errorf("Error decoding BTH: %w", ErrTooShort)
+	}
+	b.Parse(data[:BTHLen])
+	b.Contents = data[:BTHLen]
+	b.Payload = data[BTHLen:]
+	return nil
+}
+
+// SerializeTo writes the base transport header to b
+func (b *BTH) SerializeTo(sb gopacket.SerializeBuffer,
+	opts gopacket.SerializeOptions) error {
+	bytes, err := sb.PrependBytes(BTHLen)
+	if err != nil {
+		return err
+	}
+	b.marshal(bytes)
+	return nil
+}
+
+// decodeBTH decodes the base transport header in data followed by the
+// extended transport headers, the payload and the icrc at the end of data
+func decodeBTH(data []byte, p gopacket.PacketBuilder) error {
+	b := &BTH{}
+	if err := b.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(b)
+	if len(b.Payload) < ICRCLen {
+		p.SetTruncated()
+		return fmt.Errorf("Error decoding ICRC: %w", ErrTooShort)
+	}
+	payload := b.Payload[:len(b.Payload)-ICRCLen]
+
+	// decode extended transport headers
+	if b.Opcode.extHeaders() != 0 {
+		e := &ETH{}
+		if err := e.decode(b.Opcode, payload, p); err != nil {
+			return err
+		}
+		p.AddLayer(e)
+		payload = e.Payload
+	}
+
+	// remove padding
+	if int(b.Pad) <= len(payload) {
+		payload = payload[:len(payload)-int(b.Pad)]
+	}
+
+	// decode payload: llc message in SEND operations, data otherwise
+	if len(payload) > 0 {
+		switch payloadLayerType(b.Opcode) {
+		case llc.LayerTypeLLC:
+			l := &llc.LLC{}
+			if err := l.DecodeFromBytes(payload, p); err != nil {
+				return err
+			}
+			p.AddLayer(l)
+		default:
+			pl := gopacket.Payload(payload)
+			p.AddLayer(&pl)
+			p.SetApplicationLayer(&pl)
+		}
+	}
+
+	// decode icrc
+	return decodeICRC(b.Payload[len(b.Payload)-ICRCLen:], p)
+}
+
+// LayerType returns the layer type of the ETH layer
+func (e *ETH) LayerType() gopacket.LayerType {
+	return LayerTypeETH
+}
+
+// CanDecode returns the layer types the ETH layer can decode
+func (e *ETH) CanDecode() gopacket.LayerClass {
+	return LayerTypeETH
+}
+
+// NextLayerType returns the layer type of the data after the extended
+// transport headers
+func (e *ETH) NextLayerType() gopacket.LayerType {
+	return payloadLayerType(e.opcode)
+}
+
+// decode decodes the extended transport headers required by opcode in data
+func (e *ETH) decode(opcode Opcode, data []byte,
+	df gopacket.DecodeFeedback) error {
+	length := extHeadersLen(opcode.extHeaders())
+	if len(data) < length {
+		df.SetTruncated()
+		return fmt.Errorf("Error decoding ETH: %w", ErrTooShort)
+	}
+	e.parse(opcode, data)
+	e.Contents = data[:length]
+	e.Payload = data[length:]
+	return nil
+}
+
+// SerializeTo writes the extended transport headers to b
+func (e *ETH) SerializeTo(b gopacket.SerializeBuffer,
+	opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(e.Len())
+	if err != nil {
+		return err
+	}
+	e.marshal(bytes)
+	return nil
+}
+
+// ICRC is a gopacket layer that contains the invariant CRC at the end of
+// RoCE packets. Use SerializeLayers to compute the CRC when serializing a
+// RoCE packet and VerifyPacket to check it in a decoded packet
+type ICRC struct {
+	layers.BaseLayer
+	CRC uint32
+}
+
+// LayerType returns the layer type of the ICRC layer
+func (i *ICRC) LayerType() gopacket.LayerType {
+	return LayerTypeICRC
+}
+
+// CanDecode returns the layer types the ICRC layer can decode
+func (i *ICRC) CanDecode() gopacket.LayerClass {
+	return LayerTypeICRC
+}
+
+// NextLayerType returns the layer type of the data after the ICRC
+func (i *ICRC) NextLayerType() gopacket.LayerType {
+	return gopacket.LayerTypeZero
+}
+
+// DecodeFromBytes decodes the icrc in data
+func (i *ICRC) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < ICRCLen {
+		df.SetTruncated()
+		return fmt.Errorf("Error decoding ICRC: %w", ErrTooShort)
+	}
+	i.CRC = binary.LittleEndian.Uint32(data[:ICRCLen])
+	i.Contents = data[:ICRCLen]
+	i.Payload = data[ICRCLen:]
+	return nil
+}
+
+// SerializeTo writes the icrc to b
+func (i *ICRC) SerializeTo(b gopacket.SerializeBuffer,
+	opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(ICRCLen)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(bytes, i.CRC)
+	return nil
+}
+
+// decodeICRC decodes the icrc in data
+func decodeICRC(data []byte, p gopacket.PacketBuilder) error {
+	i := &ICRC{}
+	if err := i.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(i)
+	return nil
+}
@@ -0,0 +1,200 @@
+package roce
+
+import (
+	"bytes"
+	"encoding/hex"
+	"log"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/hwipl/smc-go/pkg/llc"
+)
+
+// testLayerTypes checks the layer types of the packet
+func testLayerTypes(t *testing.T, packet gopacket.Packet,
+	want []gopacket.LayerType) {
+	if packet.ErrorLayer() != nil {
+		t.Fatal(packet.ErrorLayer().Error())
+	}
+	var got []gopacket.LayerType
+	for _, l := range packet.Layers() {
+		got = append(got, l.LayerType())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("layers = %v; want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("layers = %v; want %v", got, want)
+		}
+	}
+}
+
+func TestLayerRoCEv2(t *testing.T) {
+	// create test link message
+	msg := "07 2c 00 80 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// serialize packet
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4(10, 0, 0, 1),
+		DstIP:    net.IPv4(10, 0, 0, 2),
+	}
+	udp := &layers.UDP{SrcPort: 50000, DstPort: RoCEv2UDPPort}
+	udp.SetNetworkLayerForChecksum(ip)
+	bth := &BTH{Opcode: 0b100, SE: true, PKey: 0xffff, DestQP: 263,
+		A: true, PSN: 8071316}
+	l := &llc.LLC{Message: llc.ParseLLC(bytes)}
+	icrc := &ICRC{CRC: 0x12345678}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	err = gopacket.SerializeLayers(buf, opts, eth, ip, udp, bth, l, icrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decode packet
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet,
+		gopacket.Default)
+	testLayerTypes(t, packet, []gopacket.LayerType{
+		layers.LayerTypeEthernet,
+		layers.LayerTypeIPv4,
+		layers.LayerTypeUDP,
+		LayerTypeBTH,
+		llc.LayerTypeLLC,
+		LayerTypeICRC,
+	})
+	gotBTH := packet.Layer(LayerTypeBTH).(*BTH)
+	if got, want := gotBTH.String(), bth.String(); got != want {
+		t.Errorf("bth = %s; want %s", got, want)
+	}
+	gotLLC := packet.Layer(llc.LayerTypeLLC).(*llc.LLC)
+	if _, ok := gotLLC.Message.(*llc.TestLink); !ok {
+		t.Errorf("llc.Message = %T; want *llc.TestLink", gotLLC.Message)
+	}
+	gotICRC := packet.Layer(LayerTypeICRC).(*ICRC)
+	if gotICRC.CRC != icrc.CRC {
+		t.Errorf("icrc.CRC = %#x; want %#x", gotICRC.CRC, icrc.CRC)
+	}
+}
+
+func TestLayerRoCEv1(t *testing.T) {
+	// create cdc message
+	msg := "fe 2c 00 01 00 00 00 03  00 00 00 00 00 00 00 0d" +
+		"00 00 00 00 00 00 00 00  00 00 00 00 00 00 00 00" +
+		"00 00 00 00 00 00 00 00  00 00 00 00"
+	bytes, err := hex.DecodeString(strings.Join(strings.Fields(msg), ""))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// serialize packet
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: RoCEv1EtherType,
+	}
+	grh := &GRH{
+		Version:    6,
+		NextHeader: BTHNextHeader,
+		HopLimit:   1,
+		SrcIP:      net.ParseIP("fe80::1"),
+		DstIP:      net.ParseIP("fe80::2"),
+	}
+	bth := &BTH{Opcode: 0b100, PKey: 0xffff, DestQP: 263, PSN: 1}
+	l := &llc.LLC{Message: llc.ParseLLC(bytes)}
+	icrc := &ICRC{CRC: 0x12345678}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	err = gopacket.SerializeLayers(buf, opts, eth, grh, bth, l, icrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decode packet
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet,
+		gopacket.Default)
+	testLayerTypes(t, packet, []gopacket.LayerType{
+		layers.LayerTypeEthernet,
+		LayerTypeGRH,
+		LayerTypeBTH,
+		llc.LayerTypeCDC,
+		LayerTypeICRC,
+	})
+	if got, want := packet.NetworkLayer().NetworkFlow().String(),
+		"fe80::1->fe80::2"; got != want {
+		t.Errorf("network flow = %s; want %s", got, want)
+	}
+	gotGRH := packet.Layer(LayerTypeGRH).(*GRH)
+	want := uint16(BTHLen + llc.CDCMsgLen + ICRCLen)
+	if got := gotGRH.Length; got != want {
+		t.Errorf("grh.Length = %d; want %d", got, want)
+	}
+}
+
+func TestLayerRDMAWrite(t *testing.T) {
+	// serialize RC RDMA WRITE Only packet with RETH and data
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.IPv4(10, 0, 0, 1),
+		DstIP:    net.IPv4(10, 0, 0, 2),
+	}
+	udp := &layers.UDP{SrcPort: 50000, DstPort: RoCEv2UDPPort}
+	udp.SetNetworkLayerForChecksum(ip)
+	bth := &BTH{Opcode: 0b01010, PKey: 0xffff, DestQP: 263, PSN: 1}
+	eth := &ETH{RETH: &RETH{VirtualAddr: 0x1000, RKey: 42, DMALen: 4}}
+	data := gopacket.Payload{1, 2, 3, 4}
+	icrc := &ICRC{}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	err := SerializeLayers(buf, opts, ip, udp, bth, eth, data, icrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// decode packet
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeIPv4,
+		gopacket.Default)
+	testLayerTypes(t, packet, []gopacket.LayerType{
+		layers.LayerTypeIPv4,
+		layers.LayerTypeUDP,
+		LayerTypeBTH,
+		LayerTypeETH,
+		gopacket.LayerTypePayload,
+		LayerTypeICRC,
+	})
+	gotETH := packet.Layer(LayerTypeETH).(*ETH)
+	if got, want := gotETH.String(), eth.String(); got != want {
+		t.Errorf("eth = %s; want %s", got, want)
+	}
+	if got := packet.ApplicationLayer().Payload(); !bytes.Equal(got, data) {
+		t.Errorf("payload = %v; want %v", got, data)
+	}
+	if err := VerifyPacket(packet); err != nil {
+		t.Error(err)
+	}
+}
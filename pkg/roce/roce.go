@@ -8,6 +8,9 @@ const (
 
 	// RoCEv2UDPPort is the UDP port number used for RoCEv2
 	RoCEv2UDPPort = 4791
+
+	// ipv4MinLen is the length of an IPv4 header without options
+	ipv4MinLen = 20
 )
 
 // RoCE stores a roce message
@@ -15,8 +18,38 @@ type RoCE struct {
 	Type string
 	GRH  *GRH
 	BTH  *BTH
+	ETH  *ETH
 	LLC  llc.Message
+	Data []byte
 	ICRC []byte
+
+	// headers and payload of the packet for icrc verification
+	data []byte
+}
+
+// parsePayload parses the extended transport headers and the payload after
+// the bth in buffer. Only the payload of SEND Only operations is parsed as LLC
+// message, the payload of other operations like RDMA WRITE or SEND messages
+// that consist of multiple packets is kept as data
+func (r *RoCE) parsePayload(buffer []byte) {
+	// extended transport headers required by the opcode
+	if r.ETH = ParseETH(r.BTH.Opcode, buffer); r.ETH != nil {
+		buffer = buffer[r.ETH.Len():]
+	}
+
+	// remove padding
+	if int(r.BTH.Pad) <= len(buffer) {
+		buffer = buffer[:len(buffer)-int(r.BTH.Pad)]
+	}
+
+	// parse payload
+	if r.BTH.Opcode.IsSend() && r.BTH.Opcode.IsOnly() {
+		r.LLC = llc.ParseLLC(buffer)
+		return
+	}
+	if len(buffer) > 0 {
+		r.Data = buffer
+	}
 }
 
 // ParseRoCEv1 parses the RoCEv1 packet in buffer
@@ -25,6 +58,7 @@ func ParseRoCEv1(buffer []byte) *RoCE {
 
 	// set type string
 	roce.Type = "RoCEv1"
+	roce.data = buffer
 
 	// Global Routing Header (GRH) is 40 bytes (it's an IPv6 header)
 	roce.GRH = ParseGRH(buffer[:GRHLen])
@@ -38,8 +72,8 @@ func ParseRoCEv1(buffer []byte) *RoCE {
 	roce.ICRC = buffer[len(buffer)-4:]
 	buffer = buffer[:len(buffer)-4]
 
-	// parse payload
-	roce.LLC = llc.ParseLLC(buffer)
+	// parse extended transport headers and payload
+	roce.parsePayload(buffer)
 
 	return &roce
 }
@@ -59,8 +93,38 @@ func ParseRoCEv2(buffer []byte) *RoCE {
 	roce.ICRC = buffer[len(buffer)-4:]
 	buffer = buffer[:len(buffer)-4]
 
-	// parse payload
-	roce.LLC = llc.ParseLLC(buffer)
+	// parse extended transport headers and payload
+	roce.parsePayload(buffer)
 
 	return &roce
 }
+
+// ParseRoCEv2IP parses the RoCEv2 packet in buffer that starts with the IPv4
+// or IPv6 header. Other than ParseRoCEv2, the icrc of the returned packet can
+// be checked with Verify(). IPv6 extension headers are not supported, the
+// UDP header must directly follow the IPv6 header. It returns nil if buffer
+// is too short for the IP header, UDP header, BTH and icrc or if the packet
+// is not a UDP packet
+func ParseRoCEv2IP(buffer []byte) *RoCE {
+	// skip ip and udp headers
+	hdrLen := 0
+	switch {
+	case len(buffer) >= ipv4MinLen && buffer[0]>>4 == 4 &&
+		buffer[9] == udpProtocol:
+		hdrLen = int(buffer[0]&0x0f)*4 + udpLen
+		if hdrLen < ipv4MinLen+udpLen {
+			return nil
+		}
+	case len(buffer) >= GRHLen && buffer[0]>>4 == 6 &&
+		buffer[6] == udpProtocol:
+		hdrLen = GRHLen + udpLen
+	default:
+		return nil
+	}
+	if len(buffer) < hdrLen+BTHLen+ICRCLen {
+		return nil
+	}
+	roce := ParseRoCEv2(buffer[hdrLen:])
+	roce.data = buffer
+	return roce
+}
@@ -0,0 +1,55 @@
+package roce
+
+import "testing"
+
+func TestParseRoCEv2IPInvalid(t *testing.T) {
+	// ipv4 header with udp, udp header, bth and icrc
+	ipv4 := make([]byte, 20+8+BTHLen+ICRCLen)
+	ipv4[0] = 0x45
+	ipv4[9] = udpProtocol
+	if ParseRoCEv2IP(ipv4) == nil {
+		t.Fatalf("ParseRoCEv2IP() = nil; want packet")
+	}
+
+	// ipv6 header with udp, udp header, bth and icrc
+	ipv6 := make([]byte, GRHLen+8+BTHLen+ICRCLen)
+	ipv6[0] = 0x60
+	ipv6[6] = udpProtocol
+	if ParseRoCEv2IP(ipv6) == nil {
+		t.Fatalf("ParseRoCEv2IP() = nil; want packet")
+	}
+
+	// ipv6 with hop-by-hop options header
+	ext := append([]byte{}, ipv6...)
+	ext[6] = 0
+	ext = append(ext, make([]byte, 8)...)
+
+	// ipv4 header length below minimum
+	ihl := append([]byte{}, ipv4...)
+	ihl[0] = 0x44
+
+	// ipv4 header length beyond buffer
+	options := append([]byte{}, ipv4...)
+	options[0] = 0x4f
+
+	// tcp instead of udp
+	tcp := append([]byte{}, ipv4...)
+	tcp[9] = 6
+
+	for _, buf := range [][]byte{
+		nil,
+		{0x45},
+		ipv4[:len(ipv4)-1],
+		ipv6[:len(ipv6)-1],
+		ipv6[:10],
+		ext,
+		ihl,
+		options,
+		tcp,
+		{0x00, 0x00},
+	} {
+		if r := ParseRoCEv2IP(buf); r != nil {
+			t.Errorf("ParseRoCEv2IP(%x) = %v; want nil", buf, r)
+		}
+	}
+}
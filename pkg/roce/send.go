@@ -0,0 +1,55 @@
+package roce
+
+import "github.com/hwipl/smc-go/pkg/llc"
+
+// send stores the payload of a SEND message that consists of multiple packets
+type send struct {
+	data []byte
+	psn  uint32
+}
+
+// SendAssembler reassembles the LLC messages in SEND messages that consist of
+// multiple packets, e.g., large SMC-Rv2 LLC messages
+type SendAssembler struct {
+	// pending SEND messages by destination QP
+	sends map[uint32]*send
+}
+
+// Add adds the RoCE packet r. If r is the last packet of a SEND message, the
+// LLC message reassembled from all packets of the SEND message is stored in
+// r.LLC. SEND messages with missing packets are dropped
+func (s *SendAssembler) Add(r *RoCE) {
+	opcode := r.BTH.Opcode
+	if !opcode.IsSend() || opcode.IsOnly() {
+		return
+	}
+	qp := r.BTH.DestQP
+	if opcode.IsFirst() {
+		s.sends[qp] = &send{
+			data: append([]byte{}, r.Data...),
+			psn:  r.BTH.PSN,
+		}
+		return
+	}
+
+	// middle or last packet, psn is 24 bits
+	p := s.sends[qp]
+	if p == nil || r.BTH.PSN != (p.psn+1)&0xFFFFFF ||
+		len(p.data)+len(r.Data) > llc.LLCv2MaxMsgLen {
+		delete(s.sends, qp)
+		return
+	}
+	p.data = append(p.data, r.Data...)
+	p.psn = r.BTH.PSN
+	if opcode.IsLast() {
+		delete(s.sends, qp)
+		r.LLC = llc.ParseLLC(p.data)
+	}
+}
+
+// NewSendAssembler returns a new SendAssembler
+func NewSendAssembler() *SendAssembler {
+	return &SendAssembler{
+		sends: make(map[uint32]*send),
+	}
+}
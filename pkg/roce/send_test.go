@@ -0,0 +1,55 @@
+package roce
+
+import (
+	"testing"
+
+	"github.com/hwipl/smc-go/pkg/llc"
+)
+
+func TestSendAssembler(t *testing.T) {
+	// create a SMC-Rv2 request add link message with four GIDs
+	msg := make([]byte, 92)
+	msg[0] = llc.TypeRequestAddLinkV2
+	msg[2] = 92
+	msg[24] = 4
+
+	// split message into SEND First, Middle and Last packets
+	packets := func(psn uint32) []*RoCE {
+		return []*RoCE{
+			{BTH: &BTH{Opcode: 0x00, DestQP: 263, PSN: psn},
+				Data: msg[:32]},
+			{BTH: &BTH{Opcode: 0x01, DestQP: 263, PSN: psn + 1},
+				Data: msg[32:64]},
+			{BTH: &BTH{Opcode: 0x02, DestQP: 263, PSN: psn + 2},
+				Data: msg[64:]},
+		}
+	}
+
+	// test complete message, psn wraps around
+	s := NewSendAssembler()
+	p := packets(0xFFFFFF)
+	p[1].BTH.PSN, p[2].BTH.PSN = 0, 1
+	for _, r := range p {
+		s.Add(r)
+	}
+	if p[0].LLC != nil || p[1].LLC != nil {
+		t.Errorf("LLC message in first or middle packet")
+	}
+	r, ok := p[2].LLC.(*llc.RequestAddLink)
+	if !ok {
+		t.Fatalf("last packet LLC = %v; want request add link", p[2].LLC)
+	}
+	if r.Length != 92 || len(r.GIDs) != 4 {
+		t.Errorf("length = %d, gids = %d; want 92, 4", r.Length,
+			len(r.GIDs))
+	}
+
+	// test message with missing middle packet
+	p = packets(10)
+	s.Add(p[0])
+	s.Add(p[2])
+	if p[2].LLC != nil {
+		t.Errorf("LLC message with missing packet = %v; want nil",
+			p[2].LLC)
+	}
+}
@@ -0,0 +1,38 @@
+package smcr
+
+import (
+	"net"
+
+	"github.com/hwipl/smc-go/pkg/clc"
+)
+
+// smcrInfo stores the SMC-R fields of a CLC Accept or Confirm message
+type smcrInfo struct {
+	header *clc.Header
+	gid    net.IP
+	qpn    int
+	psn    int
+	rkey   uint32
+	addr   uint64
+	idx    uint8
+	size   clc.RMBESize
+	token  uint32
+}
+
+// getSMCRInfo returns the SMC-R fields of the CLC message msg or nil if msg
+// is not a SMC-R Accept or Confirm message
+func getSMCRInfo(msg clc.Message) *smcrInfo {
+	switch m := msg.(type) {
+	case *clc.AcceptSMCR:
+		return &smcrInfo{&m.Header, m.IBGID, m.QPN, m.PSN, m.RMBRKey,
+			m.RMBDMAAddr, m.RMBEIdx, m.RMBESize, m.RMBEAlertToken}
+	case *clc.ConfirmSMCR:
+		return getSMCRInfo(&m.AcceptSMCR)
+	case *clc.AcceptSMCRv2:
+		return &smcrInfo{&m.Header, m.IBGID, m.QPN, m.PSN, m.RMBRKey,
+			m.RMBDMAAddr, m.RMBEIdx, m.RMBESize, m.RMBEAlertToken}
+	case *clc.ConfirmSMCRv2:
+		return getSMCRInfo(&m.AcceptSMCRv2)
+	}
+	return nil
+}
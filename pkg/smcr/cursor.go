@@ -0,0 +1,372 @@
+package smcr
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+// CursorEventType is the type of a CDC cursor event
+type CursorEventType uint8
+
+// CDC cursor event types
+const (
+	// CursorRegression is a producer or consumer cursor that moved
+	// backwards
+	CursorRegression CursorEventType = iota + 1
+
+	// CursorWrapMismatch is a producer cursor that is behind the consumer
+	// cursor or more than the RMBE size ahead of it, or a cursor outside
+	// of the RMBE
+	CursorWrapMismatch
+
+	// CursorSeqGap is a CDC message that does not follow the sequence
+	// number of the previous CDC message
+	CursorSeqGap
+
+	// CursorWriterBlocked is the start of a stall, the writer set the B
+	// flag because the RMBE of the receiver is full
+	CursorWriterBlocked
+
+	// CursorWriterUnblocked is the end of a stall
+	CursorWriterUnblocked
+
+	// CursorUrgentData is urgent data pending or present
+	CursorUrgentData
+
+	// CursorSendingDone is the D flag, the writer will not send more data
+	CursorSendingDone
+
+	// CursorPeerConnClosed is the C flag, the writer closed the connection
+	CursorPeerConnClosed
+
+	// CursorAbnormalClose is the A flag, the writer aborted the connection
+	CursorAbnormalClose
+)
+
+// String converts the cursor event type to a string
+func (c CursorEventType) String() string {
+	switch c {
+	case CursorRegression:
+		return "Cursor Regression"
+	case CursorWrapMismatch:
+		return "Wrap Mismatch"
+	case CursorSeqGap:
+		return "Sequence Number Gap"
+	case CursorWriterBlocked:
+		return "Writer Blocked"
+	case CursorWriterUnblocked:
+		return "Writer Unblocked"
+	case CursorUrgentData:
+		return "Urgent Data"
+	case CursorSendingDone:
+		return "Sending Done"
+	case CursorPeerConnClosed:
+		return "Peer Connection Closed"
+	case CursorAbnormalClose:
+		return "Abnormal Close"
+	default:
+		return fmt.Sprintf("Unknown (%d)", uint8(c))
+	}
+}
+
+// CursorEvent is a flow-control anomaly or a connection life cycle event
+// found in the CDC messages of a SMC-R connection
+type CursorEvent struct {
+	Type      CursorEventType
+	Timestamp time.Time
+
+	// Token is the alert token of the RMBE of the flow
+	Token uint32
+
+	// Flow is the flow of the event
+	Flow *CursorFlow
+
+	// InFlight and Window are the bytes in flight and the free window of
+	// the flow after the CDC message
+	InFlight int
+	Window   int
+
+	// Duration is the duration of the stall of writer unblocked events
+	Duration time.Duration
+
+	// Detail describes the event
+	Detail string
+}
+
+// String converts the cursor event to a string
+func (c *CursorEvent) String() string {
+	s := fmt.Sprintf("%s: Token: %d, In Flight: %d, Window: %d", c.Type,
+		c.Token, c.InFlight, c.Window)
+	if c.Type == CursorWriterUnblocked {
+		s += fmt.Sprintf(", Duration: %s", c.Duration)
+	}
+	if c.Detail != "" {
+		s += ", " + c.Detail
+	}
+	return s
+}
+
+// CursorEventHandler is called for the events of the cursor tracker
+type CursorEventHandler func(event *CursorEvent)
+
+// CursorFlow stores the cursors of one direction of a SMC-R connection, i.e.,
+// the data written into the RMBE with the alert token Token
+type CursorFlow struct {
+	// network and transport flows of the TCP connection in the direction
+	// of the data
+	Net, Transport gopacket.Flow
+
+	Token uint32
+	Size  int
+
+	// producer cursor of the writer and consumer cursor of the receiver
+	ProdWrap uint16
+	ProdCurs uint32
+	ConsWrap uint16
+	ConsCurs uint32
+
+	// sequence number of the last CDC message of the writer
+	SeqNum uint16
+
+	// Blocked is set while the writer is blocked
+	Blocked bool
+
+	seq          bool
+	blockedSince time.Time
+
+	// close flags seen in the CDC messages of the writer
+	done, closed, aborted bool
+}
+
+// InFlight returns the number of bytes written to the RMBE and not consumed
+// by the receiver yet
+func (f *CursorFlow) InFlight() int {
+	return int(int16(f.ProdWrap-f.ConsWrap))*f.Size + int(f.ProdCurs) -
+		int(f.ConsCurs)
+}
+
+// Window returns the number of free bytes in the RMBE
+func (f *CursorFlow) Window() int {
+	return f.Size - f.InFlight()
+}
+
+// String converts the cursor flow to a string
+func (f *CursorFlow) String() string {
+	src, dst := f.Net.Endpoints()
+	sport, dport := f.Transport.Endpoints()
+	return fmt.Sprintf("%s:%s -> %s:%s: Token: %d, Size: %d, "+
+		"Producer: %d/%d, Consumer: %d/%d, In Flight: %d, Window: %d",
+		src, sport, dst, dport, f.Token, f.Size, f.ProdWrap, f.ProdCurs,
+		f.ConsWrap, f.ConsCurs, f.InFlight(), f.Window())
+}
+
+// CursorTracker follows the producer and consumer cursors in the CDC messages
+// of SMC-R connections, computes the bytes in flight and the free window of
+// each direction and reports flow-control anomalies and connection life
+// cycle events. It uses the index to find the connections of the messages,
+// the caller must add all packets to the index before passing them to the
+// cursor tracker
+type CursorTracker struct {
+	index   *Index
+	handler CursorEventHandler
+
+	// flows by connection side
+	flows map[rmbeKey]*CursorFlow
+}
+
+// AddCLC adds the CLC message msg sent in the TCP connection with the network
+// and transport flows net and transport. SMC-R Accept and Confirm messages
+// announce the RMBE size and alert token of their sender
+func (c *CursorTracker) AddCLC(net, transport gopacket.Flow, msg clc.Message) {
+	info := getSMCRInfo(msg)
+	if info == nil {
+		return
+	}
+	conn := c.index.Conn(net, transport)
+	if conn == nil {
+		return
+	}
+
+	// the rmbe receives the data of the other direction. Accept messages
+	// are sent by the server, confirm messages by the client
+	client := info.header.Type != clc.TypeAccept
+	c.flows[rmbeKey{conn, client}] = &CursorFlow{
+		Net:       net.Reverse(),
+		Transport: transport.Reverse(),
+		Token:     info.token,
+		Size:      info.size.Size(),
+	}
+
+	// alert tokens are reused by new connections in the link group,
+	// remove the flows of old connections with the same tokens
+	lgr := conn.LinkGroup
+	if lgr == nil {
+		return
+	}
+	for _, old := range lgr.Conns {
+		if old == conn {
+			continue
+		}
+		if old.ServerToken == conn.ServerToken {
+			delete(c.flows, rmbeKey{old, false})
+		}
+		if old.ClientToken == conn.ClientToken {
+			delete(c.flows, rmbeKey{old, true})
+		}
+	}
+}
+
+// emit passes a new event of flow f to the handler
+func (c *CursorTracker) emit(ts time.Time, f *CursorFlow, typ CursorEventType,
+	format string, args ...interface{}) {
+	e := &CursorEvent{
+		Type:      typ,
+		Timestamp: ts,
+		Token:     f.Token,
+		Flow:      f,
+		InFlight:  f.InFlight(),
+		Window:    f.Window(),
+		Detail:    fmt.Sprintf(format, args...),
+	}
+	if typ == CursorWriterUnblocked {
+		e.Duration = ts.Sub(f.blockedSince)
+	}
+	if c.handler != nil {
+		c.handler(e)
+	}
+}
+
+// isBefore checks if the cursor wrap/curs is before the cursor oldWrap/oldCurs
+func isBefore(wrap uint16, curs uint32, oldWrap uint16, oldCurs uint32) bool {
+	diff := int16(wrap - oldWrap)
+	return diff < 0 || diff == 0 && curs < oldCurs
+}
+
+// check reports cursors of flow f outside of the rmbe and producer cursors
+// behind or too far ahead of the consumer cursor
+func (c *CursorTracker) check(ts time.Time, f *CursorFlow) {
+	if int(f.ProdCurs) > f.Size || int(f.ConsCurs) > f.Size {
+		c.emit(ts, f, CursorWrapMismatch, "Cursor outside of RMBE")
+		return
+	}
+	inFlight := f.InFlight()
+	if inFlight < 0 {
+		c.emit(ts, f, CursorWrapMismatch,
+			"Consumer cursor ahead of producer cursor")
+	}
+	if inFlight > f.Size {
+		c.emit(ts, f, CursorWrapMismatch,
+			"Producer cursor overruns consumer cursor")
+	}
+}
+
+// produce updates the producer cursor of flow f from the cdc message m
+func (c *CursorTracker) produce(ts time.Time, f *CursorFlow, m *llc.CDC) {
+	if f.seq && m.SeqNum != f.SeqNum+1 && !m.F {
+		c.emit(ts, f, CursorSeqGap, "Sequence Number: %d, Expected: %d",
+			m.SeqNum, f.SeqNum+1)
+	}
+	f.SeqNum, f.seq = m.SeqNum, true
+
+	if isBefore(m.ProdWrap, m.ProdCurs, f.ProdWrap, f.ProdCurs) {
+		c.emit(ts, f, CursorRegression, "Producer Cursor: %d/%d, "+
+			"Previous: %d/%d", m.ProdWrap, m.ProdCurs, f.ProdWrap,
+			f.ProdCurs)
+	}
+	f.ProdWrap, f.ProdCurs = m.ProdWrap, m.ProdCurs
+	c.check(ts, f)
+}
+
+// consume updates the consumer cursor of flow f from the cdc message m of
+// the receiver
+func (c *CursorTracker) consume(ts time.Time, f *CursorFlow, m *llc.CDC) {
+	if isBefore(m.ConsWrap, m.ConsCurs, f.ConsWrap, f.ConsCurs) {
+		c.emit(ts, f, CursorRegression, "Consumer Cursor: %d/%d, "+
+			"Previous: %d/%d", m.ConsWrap, m.ConsCurs, f.ConsWrap,
+			f.ConsCurs)
+	}
+	f.ConsWrap, f.ConsCurs = m.ConsWrap, m.ConsCurs
+	c.check(ts, f)
+}
+
+// flags reports the changes of the flags in the cdc message m of the writer
+// of flow f
+func (c *CursorTracker) flags(ts time.Time, f *CursorFlow, m *llc.CDC) {
+	switch {
+	case m.B && !f.Blocked:
+		f.Blocked, f.blockedSince = true, ts
+		c.emit(ts, f, CursorWriterBlocked, "")
+	case !m.B && f.Blocked:
+		f.Blocked = false
+		c.emit(ts, f, CursorWriterUnblocked, "")
+	}
+	if m.P || m.U {
+		c.emit(ts, f, CursorUrgentData, "Pending: %t, Present: %t",
+			m.P, m.U)
+	}
+	if m.D && !f.done {
+		f.done = true
+		c.emit(ts, f, CursorSendingDone, "")
+	}
+	if m.C && !f.closed {
+		f.closed = true
+		c.emit(ts, f, CursorPeerConnClosed, "")
+	}
+	if m.A && !f.aborted {
+		f.aborted = true
+		c.emit(ts, f, CursorAbnormalClose, "")
+	}
+}
+
+// AddRoCE adds the RoCE packet r with the network flow flow captured at ts.
+// The producer cursor in a CDC message refers to the RMBE of the receiver
+// with the alert token in the message, the consumer cursor to the RMBE of
+// the sender
+func (c *CursorTracker) AddRoCE(ts time.Time, flow gopacket.Flow,
+	r *roce.RoCE) {
+	m, ok := r.LLC.(*llc.CDC)
+	if !ok {
+		return
+	}
+	dst := NewQP(net.IP(flow.Dst().Raw()), r.BTH.DestQP)
+	conn := c.index.ConnByAlertToken(dst, m.AlertTkn)
+	if conn == nil {
+		return
+	}
+	client := conn.LinkGroup.isClient(dst)
+	f := c.flows[rmbeKey{conn, client}]
+	if f == nil {
+		return
+	}
+	c.produce(ts, f, m)
+	if p := c.flows[rmbeKey{conn, !client}]; p != nil {
+		c.consume(ts, p, m)
+	}
+	c.flags(ts, f, m)
+}
+
+// Flow returns the flow into the RMBE with the alert token on the side of
+// the QP dst or nil
+func (c *CursorTracker) Flow(dst QP, token uint32) *CursorFlow {
+	conn := c.index.ConnByAlertToken(dst, token)
+	if conn == nil {
+		return nil
+	}
+	return c.flows[rmbeKey{conn, conn.LinkGroup.isClient(dst)}]
+}
+
+// NewCursorTracker returns a new CursorTracker that uses index and passes its
+// events to handler. The handler may be nil
+func NewCursorTracker(index *Index, handler CursorEventHandler) *CursorTracker {
+	return &CursorTracker{
+		index:   index,
+		handler: handler,
+		flows:   make(map[rmbeKey]*CursorFlow),
+	}
+}
@@ -0,0 +1,155 @@
+package smcr
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+// testCursorCLC adds the accept message of the server with QP 263 and the
+// confirm message of the client with QP 264 of the connection with client
+// port 0xc350 and 16KB rmbes to the index x and the cursor tracker c
+func testCursorCLC(x *Index, c *CursorTracker, srvToken, cltToken uint32) {
+	netFlow, transport := testFlows(0x50)
+	accept := &clc.AcceptSMCR{
+		Header:         clc.Header{Type: clc.TypeAccept, Flag: 1},
+		IBGID:          net.IPv4(10, 0, 0, 2),
+		QPN:            263,
+		RMBEAlertToken: srvToken,
+	}
+	confirm := &clc.ConfirmSMCR{AcceptSMCR: clc.AcceptSMCR{
+		Header:         clc.Header{Type: clc.TypeConfirm},
+		IBGID:          net.IPv4(10, 0, 0, 1),
+		QPN:            264,
+		RMBEAlertToken: cltToken,
+	}}
+	for _, m := range []struct {
+		net, transport gopacket.Flow
+		msg            clc.Message
+	}{
+		{netFlow.Reverse(), transport.Reverse(), accept},
+		{netFlow, transport, confirm},
+	} {
+		x.AddCLC(m.net, m.transport, m.msg)
+		c.AddCLC(m.net, m.transport, m.msg)
+	}
+}
+
+// testCursorCDC adds the CDC message m sent by the client or the server of
+// the connection from testCursorCLC to the cursor tracker c
+func testCursorCDC(c *CursorTracker, ts time.Time, client bool, m *llc.CDC) {
+	flow, qp := testRoCEFlow(1, 2), uint32(263)
+	if !client {
+		flow, qp = testRoCEFlow(2, 1), 264
+	}
+	c.AddRoCE(ts, flow, &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: qp},
+		LLC: m,
+	})
+}
+
+func TestCursorTracker(t *testing.T) {
+	var events []*CursorEvent
+	x := NewIndex()
+	c := NewCursorTracker(x, func(event *CursorEvent) {
+		events = append(events, event)
+	})
+
+	// server and client announce their 16KB rmbes
+	testCursorCLC(x, c, 7, 9)
+	cltNet, _ := testFlows(0x50)
+
+	// client writes 1000 bytes, server consumes 600 bytes
+	ts := time.Unix(1, 0)
+	testCursorCDC(c, ts, true, &llc.CDC{SeqNum: 1, AlertTkn: 7,
+		ProdCurs: 1000})
+	testCursorCDC(c, ts, false, &llc.CDC{SeqNum: 1, AlertTkn: 9,
+		ConsCurs: 600})
+	f := c.Flow(NewQP(net.IPv4(10, 0, 0, 2), 263), 7)
+	if f == nil || f.Net != cltNet || f.InFlight() != 400 ||
+		f.Window() != 16384-400 {
+		t.Fatalf("unexpected flow: %v", f)
+	}
+	if len(events) != 0 {
+		t.Fatalf("events = %v; want none", events)
+	}
+
+	// client skips a sequence number, moves its producer cursor
+	// backwards and is blocked
+	testCursorCDC(c, ts, true, &llc.CDC{SeqNum: 3, AlertTkn: 7,
+		ProdCurs: 500, B: true})
+
+	// client is unblocked and closes the connection
+	testCursorCDC(c, ts.Add(2*time.Second), true, &llc.CDC{SeqNum: 4,
+		AlertTkn: 7, ProdCurs: 1200, C: true})
+	if f.InFlight() != 600 {
+		t.Errorf("in flight = %d; want 600", f.InFlight())
+	}
+
+	// check events
+	want := []string{
+		"Sequence Number Gap: Token: 7, In Flight: 400, " +
+			"Window: 15984, Sequence Number: 3, Expected: 2",
+		"Cursor Regression: Token: 7, In Flight: 400, Window: 15984, " +
+			"Producer Cursor: 0/500, Previous: 0/1000",
+		"Wrap Mismatch: Token: 7, In Flight: -100, Window: 16484, " +
+			"Consumer cursor ahead of producer cursor",
+		"Writer Blocked: Token: 7, In Flight: -100, Window: 16484",
+		"Writer Unblocked: Token: 7, In Flight: 600, Window: 15784, " +
+			"Duration: 2s",
+		"Peer Connection Closed: Token: 7, In Flight: 600, " +
+			"Window: 15784",
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v; want %q", events, want)
+	}
+	for i := range want {
+		if got := events[i].String(); got != want[i] {
+			t.Errorf("event %d = %q; want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestCursorTrackerEqualTokens(t *testing.T) {
+	var events []*CursorEvent
+	x := NewIndex()
+	c := NewCursorTracker(x, func(event *CursorEvent) {
+		events = append(events, event)
+	})
+
+	// client and server use the same alert token for their rmbes
+	testCursorCLC(x, c, 7, 7)
+
+	// client writes 1000 bytes, server consumes 600 bytes, writes 300
+	// bytes and is blocked, client consumes 100 bytes
+	ts := time.Unix(1, 0)
+	testCursorCDC(c, ts, true, &llc.CDC{SeqNum: 1, AlertTkn: 7,
+		ProdCurs: 1000})
+	testCursorCDC(c, ts, false, &llc.CDC{SeqNum: 1, AlertTkn: 7,
+		ProdCurs: 300, ConsCurs: 600, B: true})
+	testCursorCDC(c, ts, true, &llc.CDC{SeqNum: 2, AlertTkn: 7,
+		ProdCurs: 1000, ConsCurs: 100})
+
+	netFlow, _ := testFlows(0x50)
+	srv := c.Flow(NewQP(net.IPv4(10, 0, 0, 2), 263), 7)
+	if srv == nil || srv.Net != netFlow || srv.InFlight() != 400 {
+		t.Errorf("unexpected server flow: %v", srv)
+	}
+	clt := c.Flow(NewQP(net.IPv4(10, 0, 0, 1), 264), 7)
+	if clt == nil || clt.Net != netFlow.Reverse() ||
+		clt.InFlight() != 200 {
+		t.Errorf("unexpected client flow: %v", clt)
+	}
+
+	// only the server is blocked
+	if len(events) != 1 || events[0].Type != CursorWriterBlocked ||
+		events[0].Flow != clt {
+		t.Errorf("events = %v; want writer blocked of client flow",
+			events)
+	}
+}
@@ -0,0 +1,275 @@
+package smcr
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+const (
+	// delLinkLostPath is the delete link reason code of a failed link
+	delLinkLostPath = 0x00010000
+
+	// failoverWindow is the time CDC messages with the failover validation
+	// flag are kept before the Delete Link message of their incident
+	failoverWindow = time.Second
+)
+
+// MovedConn is a connection that moved to another link during a failover
+type MovedConn struct {
+	// Time of the first CDC message with the failover validation flag
+	Time time.Time
+
+	// Token is the alert token of the CDC message, Conn is its connection
+	// or nil if the CLC messages of the connection were not captured
+	Token uint32
+	Conn  *Conn
+}
+
+// Incident is a link failover in a SMC-R link group: a failed link is
+// deleted with reason code "Lost path", its connections move to another link
+// and a replacement link may be added
+type Incident struct {
+	LinkGroup *LinkGroup
+
+	// FailedLink is the link ID of the deleted link, Start the time of the
+	// first Delete Link message
+	FailedLink uint8
+	RsnCode    llc.DelLinkRsnCode
+	Start      time.Time
+
+	// Moved are the connections that moved in the order of their first
+	// CDC messages with the failover validation flag. Connections can move
+	// shortly before the Delete Link message
+	Moved []*MovedConn
+
+	// NewLink is the link ID of the replacement link or 0 if no link was
+	// added. Added and Confirmed are the times of the Add Link request and
+	// the Confirm Link reply
+	NewLink   uint8
+	Added     time.Time
+	Confirmed time.Time
+
+	// Complete is set if the replacement link was confirmed or rejected
+	Complete bool
+}
+
+// since returns the time ts relative to the start of the incident
+func (i *Incident) since(ts time.Time) string {
+	d := ts.Sub(i.Start)
+	if d < 0 {
+		return d.String()
+	}
+	return "+" + d.String()
+}
+
+// String converts the incident to a multi-line report
+func (i *Incident) String() string {
+	var b strings.Builder
+	lgr := 0
+	if i.LinkGroup != nil {
+		lgr = i.LinkGroup.ID
+	}
+	fmt.Fprintf(&b, "Failover: Link Group: %d, Failed Link: %d, "+
+		"Reason Code: %s, Start: %s, Complete: %t\n", lgr, i.FailedLink,
+		i.RsnCode, i.Start.Format(time.RFC3339Nano), i.Complete)
+	for _, m := range i.Moved {
+		conn := "unknown"
+		if m.Conn != nil {
+			src, dst := m.Conn.Net.Endpoints()
+			sport, dport := m.Conn.Transport.Endpoints()
+			conn = fmt.Sprintf("%s:%s -> %s:%s", src, sport, dst,
+				dport)
+		}
+		fmt.Fprintf(&b, "  Moved: %s, Token: %d, Connection: %s\n",
+			i.since(m.Time), m.Token, conn)
+	}
+	switch {
+	case i.NewLink == 0:
+		fmt.Fprintf(&b, "  Replacement Link: none\n")
+	case i.Confirmed.IsZero():
+		fmt.Fprintf(&b, "  Replacement Link: %d, Added: %s\n",
+			i.NewLink, i.since(i.Added))
+	default:
+		fmt.Fprintf(&b, "  Replacement Link: %d, Added: %s, "+
+			"Confirmed: %s\n", i.NewLink, i.since(i.Added),
+			i.since(i.Confirmed))
+	}
+	return b.String()
+}
+
+// IncidentHandler is called for finished failover incidents
+type IncidentHandler func(incident *Incident)
+
+// FailoverAnalyzer finds link failovers in the LLC and CDC messages of SMC-R
+// link groups. It uses the index to find the link groups and connections of
+// the messages, the caller must add all packets to the index before passing
+// them to the analyzer
+type FailoverAnalyzer struct {
+	index   *Index
+	handler IncidentHandler
+
+	// ongoing incidents by link group
+	incidents map[*LinkGroup]*Incident
+
+	// moved connections by link group without an incident. The Linux
+	// kernel moves the connections before it sends the Delete Link
+	// message
+	early map[*LinkGroup][]*MovedConn
+}
+
+// finish finishes the incident of the link group lgr
+func (a *FailoverAnalyzer) finish(lgr *LinkGroup) {
+	i := a.incidents[lgr]
+	if i == nil {
+		return
+	}
+	delete(a.incidents, lgr)
+	if a.handler != nil {
+		a.handler(i)
+	}
+}
+
+// addDeleteLink adds the delete link message d of the link group lgr
+func (a *FailoverAnalyzer) addDeleteLink(ts time.Time, lgr *LinkGroup,
+	d *llc.DeleteLink) {
+	if d.RsnCode != delLinkLostPath || d.All {
+		return
+	}
+	if i := a.incidents[lgr]; i != nil {
+		if i.FailedLink == d.Link {
+			// reply or repeated request
+			return
+		}
+		a.finish(lgr)
+	}
+	i := &Incident{
+		LinkGroup:  lgr,
+		FailedLink: d.Link,
+		RsnCode:    d.RsnCode,
+		Start:      ts,
+	}
+	for _, m := range a.early[lgr] {
+		if ts.Sub(m.Time) <= failoverWindow {
+			i.Moved = append(i.Moved, m)
+		}
+	}
+	delete(a.early, lgr)
+	a.incidents[lgr] = i
+}
+
+// addMoved adds the connection conn with the alert token moved at ts to moved
+// if it is not in moved yet
+func addMoved(moved []*MovedConn, ts time.Time, token uint32,
+	conn *Conn) []*MovedConn {
+	for _, m := range moved {
+		if m.Token == token && m.Conn == conn {
+			return moved
+		}
+	}
+	return append(moved, &MovedConn{
+		Time:  ts,
+		Token: token,
+		Conn:  conn,
+	})
+}
+
+// addCDC adds the cdc message c sent to the QP qp of the link group lgr
+func (a *FailoverAnalyzer) addCDC(ts time.Time, lgr *LinkGroup, qp QP,
+	c *llc.CDC) {
+	if !c.F {
+		return
+	}
+	conn := a.index.ConnByAlertToken(qp, c.AlertTkn)
+	if i := a.incidents[lgr]; i != nil {
+		i.Moved = addMoved(i.Moved, ts, c.AlertTkn, conn)
+		return
+	}
+
+	// keep the connection until the Delete Link message arrives and
+	// forget connections outside of the failover window
+	early := a.early[lgr][:0]
+	for _, m := range a.early[lgr] {
+		if ts.Sub(m.Time) <= failoverWindow {
+			early = append(early, m)
+		}
+	}
+	a.early[lgr] = addMoved(early, ts, c.AlertTkn, conn)
+}
+
+// addAddLink adds the add link message m of the link group lgr
+func (a *FailoverAnalyzer) addAddLink(ts time.Time, lgr *LinkGroup,
+	m *llc.AddLink) {
+	i := a.incidents[lgr]
+	if i == nil {
+		return
+	}
+	if m.Reject {
+		i.Complete = true
+		a.finish(lgr)
+		return
+	}
+	if i.NewLink == 0 {
+		i.NewLink, i.Added = m.Link, ts
+	}
+}
+
+// addConfirmLink adds the confirm link message c of the link group lgr
+func (a *FailoverAnalyzer) addConfirmLink(ts time.Time, lgr *LinkGroup,
+	c *llc.ConfirmLink) {
+	i := a.incidents[lgr]
+	if i == nil || i.NewLink == 0 || c.Link != i.NewLink || !c.Reply {
+		return
+	}
+	i.Confirmed, i.Complete = ts, true
+	a.finish(lgr)
+}
+
+// AddRoCE adds the RoCE packet r captured at ts with the network flow flow,
+// i.e., the GIDs of RoCEv1 packets or the IP addresses of RoCEv2 packets
+func (a *FailoverAnalyzer) AddRoCE(ts time.Time, flow gopacket.Flow,
+	r *roce.RoCE) {
+	if r.LLC == nil {
+		return
+	}
+	qp := NewQP(net.IP(flow.Dst().Raw()), r.BTH.DestQP)
+	lgr := a.index.LinkGroup(qp)
+	if lgr == nil {
+		return
+	}
+	switch m := r.LLC.(type) {
+	case *llc.DeleteLink:
+		a.addDeleteLink(ts, lgr, m)
+	case *llc.CDC:
+		a.addCDC(ts, lgr, qp, m)
+	case *llc.AddLink:
+		a.addAddLink(ts, lgr, m)
+	case *llc.ConfirmLink:
+		a.addConfirmLink(ts, lgr, m)
+	}
+}
+
+// FlushAll finishes all ongoing incidents, e.g., at the end of a capture
+func (a *FailoverAnalyzer) FlushAll() {
+	for _, lgr := range a.index.LinkGroups() {
+		a.finish(lgr)
+	}
+	a.early = make(map[*LinkGroup][]*MovedConn)
+}
+
+// NewFailoverAnalyzer returns a new FailoverAnalyzer that uses index and
+// passes finished incidents to handler. The handler may be nil
+func NewFailoverAnalyzer(index *Index,
+	handler IncidentHandler) *FailoverAnalyzer {
+	return &FailoverAnalyzer{
+		index:     index,
+		handler:   handler,
+		incidents: make(map[*LinkGroup]*Incident),
+		early:     make(map[*LinkGroup][]*MovedConn),
+	}
+}
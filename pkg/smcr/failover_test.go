@@ -0,0 +1,158 @@
+package smcr
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+func TestFailoverAnalyzer(t *testing.T) {
+	var incidents []*Incident
+	x := NewIndex()
+	a := NewFailoverAnalyzer(x, func(incident *Incident) {
+		incidents = append(incidents, incident)
+	})
+
+	// add the llc message msg sent to the QP dst/qp at ms milliseconds
+	start := time.Unix(1, 0).UTC()
+	add := func(ms int, src, dst net.IP, qp uint32, msg llc.Message) {
+		flow := gopacket.NewFlow(layers.EndpointIPv4, src.To4(),
+			dst.To4())
+		r := &roce.RoCE{
+			BTH: &roce.BTH{Opcode: 0b00100, DestQP: qp},
+			LLC: msg,
+		}
+		x.AddRoCE(flow, r)
+		a.AddRoCE(start.Add(time.Duration(ms)*time.Millisecond), flow,
+			r)
+	}
+	srv1, clt1 := net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 1)
+	srv2, clt2 := net.IPv4(10, 0, 1, 2), net.IPv4(10, 0, 1, 1)
+	srv3, clt3 := net.IPv4(10, 0, 2, 2), net.IPv4(10, 0, 2, 1)
+
+	// link group with two links and two connections
+	testCLC(x, 1, true, 100, 200, 1, 2)
+	add(0, srv1, clt1, 200, &llc.ConfirmLink{SenderGID: srv1,
+		SenderQP: 100, Link: 1})
+	add(0, srv1, clt1, 200, &llc.AddLink{SenderGID: srv2, SenderQP: 101,
+		Link: 2})
+	add(0, clt1, srv1, 100, &llc.AddLink{Reply: true, SenderGID: clt2,
+		SenderQP: 201, Link: 2})
+	testCLC(x, 2, false, 100, 200, 3, 4)
+
+	// first link fails, connections move to second link
+	add(10, srv2, clt2, 201, &llc.DeleteLink{Link: 1,
+		RsnCode: 0x00010000})
+	add(11, clt2, srv2, 101, &llc.DeleteLink{Reply: true, Link: 1,
+		RsnCode: 0x00010000})
+	add(12, srv2, clt2, 201, &llc.CDC{AlertTkn: 2, F: true})
+	add(12, srv2, clt2, 201, &llc.CDC{AlertTkn: 2, F: true})
+	add(13, clt2, srv2, 101, &llc.CDC{AlertTkn: 3, F: true})
+	add(14, clt2, srv2, 101, &llc.CDC{AlertTkn: 99, F: true})
+
+	// replacement link
+	add(20, srv2, clt2, 201, &llc.AddLink{SenderGID: srv3, SenderQP: 102,
+		Link: 3})
+	add(21, clt2, srv2, 101, &llc.AddLink{Reply: true, SenderGID: clt3,
+		SenderQP: 202, Link: 3})
+	add(22, srv3, clt3, 202, &llc.ConfirmLink{SenderGID: srv3,
+		SenderQP: 102, Link: 3})
+	if len(incidents) != 0 {
+		t.Fatalf("incident finished before confirm link reply")
+	}
+	add(23, clt3, srv3, 102, &llc.ConfirmLink{Reply: true,
+		SenderGID: clt3, SenderQP: 202, Link: 3})
+
+	// check report
+	if len(incidents) != 1 {
+		t.Fatalf("got %d incidents; want 1", len(incidents))
+	}
+	want := "Failover: Link Group: 1, Failed Link: 1, " +
+		"Reason Code: 65536 (Lost path), " +
+		"Start: 1970-01-01T00:00:01.01Z, Complete: true\n" +
+		"  Moved: +2ms, Token: 2, " +
+		"Connection: 10.0.0.1:49921 -> 10.0.0.2:12345\n" +
+		"  Moved: +3ms, Token: 3, " +
+		"Connection: 10.0.0.1:49922 -> 10.0.0.2:12345\n" +
+		"  Moved: +4ms, Token: 99, Connection: unknown\n" +
+		"  Replacement Link: 3, Added: +10ms, Confirmed: +13ms\n"
+	if got := incidents[0].String(); got != want {
+		t.Errorf("incident = %s; want %s", got, want)
+	}
+
+	// failed link without replacement is reported at the end
+	add(30, srv2, clt2, 201, &llc.DeleteLink{Link: 3,
+		RsnCode: 0x00010000})
+	a.FlushAll()
+	if len(incidents) != 2 || incidents[1].Complete ||
+		incidents[1].FailedLink != 3 {
+		t.Errorf("unexpected incidents: %v", incidents)
+	}
+}
+
+func TestFailoverAnalyzerEarlyCDC(t *testing.T) {
+	var incidents []*Incident
+	x := NewIndex()
+	a := NewFailoverAnalyzer(x, func(incident *Incident) {
+		incidents = append(incidents, incident)
+	})
+
+	// add the llc message msg sent to the QP dst/qp at ms milliseconds
+	start := time.Unix(1, 0).UTC()
+	add := func(ms int, src, dst net.IP, qp uint32, msg llc.Message) {
+		flow := gopacket.NewFlow(layers.EndpointIPv4, src.To4(),
+			dst.To4())
+		r := &roce.RoCE{
+			BTH: &roce.BTH{Opcode: 0b00100, DestQP: qp},
+			LLC: msg,
+		}
+		x.AddRoCE(flow, r)
+		a.AddRoCE(start.Add(time.Duration(ms)*time.Millisecond), flow,
+			r)
+	}
+	srv1, clt1 := net.IPv4(10, 0, 0, 2), net.IPv4(10, 0, 0, 1)
+	srv2, clt2 := net.IPv4(10, 0, 1, 2), net.IPv4(10, 0, 1, 1)
+
+	// link group with two links and one connection
+	testCLC(x, 1, true, 100, 200, 1, 2)
+	add(0, srv1, clt1, 200, &llc.ConfirmLink{SenderGID: srv1,
+		SenderQP: 100, Link: 1})
+	add(0, srv1, clt1, 200, &llc.AddLink{SenderGID: srv2, SenderQP: 101,
+		Link: 2})
+	add(0, clt1, srv1, 100, &llc.AddLink{Reply: true, SenderGID: clt2,
+		SenderQP: 201, Link: 2})
+
+	// old cdc message with failover flag outside of the window
+	add(10, srv2, clt2, 201, &llc.CDC{AlertTkn: 98, F: true})
+
+	// first link fails, the connection moves to the second link before
+	// the delete link message like in the Linux kernel
+	add(2000, srv2, clt2, 201, &llc.CDC{AlertTkn: 2, F: true})
+	add(2001, srv2, clt2, 201, &llc.CDC{AlertTkn: 2, F: true})
+	add(2002, srv2, clt2, 201, &llc.DeleteLink{Link: 1,
+		RsnCode: 0x00010000})
+	add(2003, clt2, srv2, 101, &llc.DeleteLink{Reply: true, Link: 1,
+		RsnCode: 0x00010000})
+	add(2004, clt2, srv2, 101, &llc.CDC{AlertTkn: 99, F: true})
+	a.FlushAll()
+
+	// check report
+	if len(incidents) != 1 {
+		t.Fatalf("got %d incidents; want 1", len(incidents))
+	}
+	want := "Failover: Link Group: 1, Failed Link: 1, " +
+		"Reason Code: 65536 (Lost path), " +
+		"Start: 1970-01-01T00:00:03.002Z, Complete: false\n" +
+		"  Moved: -2ms, Token: 2, " +
+		"Connection: 10.0.0.1:49921 -> 10.0.0.2:12345\n" +
+		"  Moved: +2ms, Token: 99, Connection: unknown\n" +
+		"  Replacement Link: none\n"
+	if got := incidents[0].String(); got != want {
+		t.Errorf("incident = %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,311 @@
+package smcr
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/gopacket/gopacket"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+// QP identifies a RoCE queue pair by the GID of its device and its number
+type QP struct {
+	GID string
+	Num uint32
+}
+
+// NewQP returns the QP with the GID gid and the QP number num. IPv4
+// addresses of RoCEv2 packets are converted to IPv4-mapped GIDs
+func NewQP(gid net.IP, num uint32) QP {
+	return QP{GID: gid.To16().String(), Num: num}
+}
+
+// String converts the QP to a string
+func (q QP) String() string {
+	return fmt.Sprintf("%s/%d", q.GID, q.Num)
+}
+
+// Link stores the QPs of a link in a SMC-R link group
+type Link struct {
+	// ID is the link ID from LLC messages or 0 if it is unknown
+	ID uint8
+
+	// QPs of the link on the client and server of the link group
+	ClientQP, ServerQP QP
+}
+
+// LinkGroup stores the links and connections of a SMC-R link group
+type LinkGroup struct {
+	// ID is a unique number of the link group assigned by the index
+	ID int
+
+	Links []*Link
+	Conns []*Conn
+}
+
+// Link returns the link with QP qp in the link group or nil
+func (l *LinkGroup) Link(qp QP) *Link {
+	for _, link := range l.Links {
+		if link.ClientQP == qp || link.ServerQP == qp {
+			return link
+		}
+	}
+	return nil
+}
+
+// linkByID returns the link with the link ID id in the link group or nil
+func (l *LinkGroup) linkByID(id uint8) *Link {
+	for _, link := range l.Links {
+		if link.ID == id {
+			return link
+		}
+	}
+	return nil
+}
+
+// isClient checks if the QP qp belongs to the client of the link group
+func (l *LinkGroup) isClient(qp QP) bool {
+	link := l.Link(qp)
+	return link != nil && link.ClientQP == qp
+}
+
+// Sender returns the side of the link group that sent a message to the QP
+// dst or llc.SideUnknown if dst is not in the link group
+func (l *LinkGroup) Sender(dst QP) llc.Side {
+	link := l.Link(dst)
+	switch {
+	case link == nil:
+		return llc.SideUnknown
+	case link.ClientQP == dst:
+		return llc.SideServer
+	default:
+		return llc.SideClient
+	}
+}
+
+// Conn stores the cross-layer information of a SMC-R connection
+type Conn struct {
+	// network and transport flows of the TCP connection from client to
+	// server
+	Net, Transport gopacket.Flow
+
+	// QPs, packet sequence numbers and RMBE alert tokens of the client
+	// and the server from the CLC Confirm and Accept messages
+	ClientQP, ServerQP       QP
+	ClientPSN, ServerPSN     int
+	ClientToken, ServerToken uint32
+
+	// link group of the connection
+	LinkGroup *LinkGroup
+
+	accept, confirm bool
+	firstContact    bool
+}
+
+// String converts the connection to a string
+func (c *Conn) String() string {
+	src, dst := c.Net.Endpoints()
+	sport, dport := c.Transport.Endpoints()
+	lgr := 0
+	if c.LinkGroup != nil {
+		lgr = c.LinkGroup.ID
+	}
+	return fmt.Sprintf("%s:%s -> %s:%s, Client QP: %s, Server QP: %s, "+
+		"Client Token: %d, Server Token: %d, Link Group: %d", src,
+		sport, dst, dport, c.ClientQP, c.ServerQP, c.ClientToken,
+		c.ServerToken, lgr)
+}
+
+// connKey identifies a TCP connection by its flows from client to server
+type connKey struct {
+	net, transport gopacket.Flow
+}
+
+// Index links the objects of SMC-R connections across layers: TCP
+// connections with their CLC messages, the QPs of RoCE packets, the link
+// groups from LLC messages and the alert tokens of CDC messages
+type Index struct {
+	conns      map[connKey]*Conn
+	linkGroups []*LinkGroup
+	qps        map[QP]*LinkGroup
+}
+
+// AddCLC adds the CLC message msg sent in the TCP connection with the network
+// and transport flows net and transport
+func (x *Index) AddCLC(net, transport gopacket.Flow, msg clc.Message) {
+	info := getSMCRInfo(msg)
+	if info == nil {
+		return
+	}
+
+	// accept messages are sent by the server, confirm messages by the
+	// client
+	accept := info.header.Type == clc.TypeAccept
+	if accept {
+		net, transport = net.Reverse(), transport.Reverse()
+	}
+	key := connKey{net, transport}
+	c := x.conns[key]
+	if c == nil {
+		c = &Conn{Net: net, Transport: transport}
+		x.conns[key] = c
+	}
+	qp := NewQP(info.gid, uint32(info.qpn))
+	if accept {
+		c.accept = true
+		c.firstContact = info.header.Flag == 1
+		c.ServerQP, c.ServerPSN, c.ServerToken = qp, info.psn,
+			info.token
+	} else {
+		c.confirm = true
+		c.ClientQP, c.ClientPSN, c.ClientToken = qp, info.psn,
+			info.token
+	}
+	if c.accept && c.confirm && c.LinkGroup == nil {
+		x.addConn(c)
+	}
+}
+
+// addConn adds the connection c with both QPs to a link group
+func (x *Index) addConn(c *Conn) {
+	lgr := x.qps[c.ServerQP]
+	if lgr == nil {
+		lgr = x.qps[c.ClientQP]
+	}
+	if lgr == nil || c.firstContact {
+		// first connection of a new link group
+		lgr = &LinkGroup{ID: len(x.linkGroups) + 1}
+		x.linkGroups = append(x.linkGroups, lgr)
+	}
+	if lgr.Link(c.ServerQP) == nil {
+		x.addLink(lgr, &Link{ClientQP: c.ClientQP, ServerQP: c.ServerQP})
+	}
+	c.LinkGroup = lgr
+	lgr.Conns = append(lgr.Conns, c)
+}
+
+// addLink adds the link to the link group lgr
+func (x *Index) addLink(lgr *LinkGroup, link *Link) {
+	lgr.Links = append(lgr.Links, link)
+	x.qps[link.ClientQP] = lgr
+	x.qps[link.ServerQP] = lgr
+}
+
+// AddRoCE adds the RoCE packet r with the network flow flow, i.e., the GIDs
+// of RoCEv1 packets or the IP addresses of RoCEv2 packets. LLC Confirm Link
+// and Add Link messages add links to the link group of the destination QP
+func (x *Index) AddRoCE(flow gopacket.Flow, r *roce.RoCE) {
+	dst := NewQP(net.IP(flow.Dst().Raw()), r.BTH.DestQP)
+	lgr := x.qps[dst]
+	if lgr == nil {
+		return
+	}
+
+	// sender is on the other side of the destination QP
+	client := !lgr.isClient(dst)
+	switch m := r.LLC.(type) {
+	case *llc.ConfirmLink:
+		// confirm link messages are sent over the link they confirm
+		link := lgr.Link(dst)
+		if link == nil {
+			return
+		}
+		link.ID = m.Link
+		x.setQP(lgr, link, client, NewQP(m.SenderGID, m.SenderQP))
+	case *llc.AddLink:
+		if m.Reject {
+			return
+		}
+		link := lgr.linkByID(m.Link)
+		if link == nil {
+			link = &Link{ID: m.Link}
+			lgr.Links = append(lgr.Links, link)
+		}
+		x.setQP(lgr, link, client, NewQP(m.SenderGID, m.SenderQP))
+	}
+}
+
+// setQP sets the client or server QP of the link in the link group lgr and
+// removes the QP it replaces from the index
+func (x *Index) setQP(lgr *LinkGroup, link *Link, client bool, qp QP) {
+	old := &link.ServerQP
+	if client {
+		old = &link.ClientQP
+	}
+	if *old != qp && *old != (QP{}) && x.qps[*old] == lgr {
+		delete(x.qps, *old)
+	}
+	*old = qp
+	x.qps[qp] = lgr
+}
+
+// Conn returns the connection with the network and transport flows net and
+// transport in either direction or nil if there is no such connection
+func (x *Index) Conn(net, transport gopacket.Flow) *Conn {
+	if c := x.conns[connKey{net, transport}]; c != nil {
+		return c
+	}
+	return x.conns[connKey{net.Reverse(), transport.Reverse()}]
+}
+
+// Conns returns all connections with complete CLC exchanges ordered by link
+// group
+func (x *Index) Conns() []*Conn {
+	conns := make([]*Conn, 0, len(x.conns))
+	for _, lgr := range x.linkGroups {
+		conns = append(conns, lgr.Conns...)
+	}
+	return conns
+}
+
+// LinkGroup returns the link group of the QP qp or nil if qp is unknown
+func (x *Index) LinkGroup(qp QP) *LinkGroup {
+	return x.qps[qp]
+}
+
+// LinkGroups returns all link groups
+func (x *Index) LinkGroups() []*LinkGroup {
+	return x.linkGroups
+}
+
+// ConnByAlertToken returns the connection that owns the RMBE with the alert
+// token on the side of the QP qp, i.e., the destination QP of a CDC message.
+// It returns nil if there is no such connection
+func (x *Index) ConnByAlertToken(qp QP, token uint32) *Conn {
+	lgr := x.qps[qp]
+	if lgr == nil {
+		return nil
+	}
+
+	// search newest connections first, alert tokens are reused
+	client := lgr.isClient(qp)
+	for i := len(lgr.Conns) - 1; i >= 0; i-- {
+		c := lgr.Conns[i]
+		if client && c.ClientToken == token ||
+			!client && c.ServerToken == token {
+			return c
+		}
+	}
+	return nil
+}
+
+// ConnByCDC returns the connection of the CDC message in the RoCE packet r
+// with the network flow flow or nil if there is no such connection
+func (x *Index) ConnByCDC(flow gopacket.Flow, r *roce.RoCE) *Conn {
+	cdc, ok := r.LLC.(*llc.CDC)
+	if !ok {
+		return nil
+	}
+	dst := NewQP(net.IP(flow.Dst().Raw()), r.BTH.DestQP)
+	return x.ConnByAlertToken(dst, cdc.AlertTkn)
+}
+
+// NewIndex returns a new empty Index
+func NewIndex() *Index {
+	return &Index{
+		conns: make(map[connKey]*Conn),
+		qps:   make(map[QP]*LinkGroup),
+	}
+}
@@ -0,0 +1,156 @@
+package smcr
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+// testFlows returns the network and transport flows of a TCP connection from
+// client to server with client port port
+func testFlows(port byte) (gopacket.Flow, gopacket.Flow) {
+	netFlow := gopacket.NewFlow(layers.EndpointIPv4,
+		net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4())
+	transport := gopacket.NewFlow(layers.EndpointTCPPort,
+		[]byte{0xc3, port}, []byte{0x30, 0x39})
+	return netFlow, transport
+}
+
+// testCLC adds the accept and confirm messages of a connection to the index
+func testCLC(x *Index, port byte, first bool, srvQP, cltQP int,
+	srvToken, cltToken uint32) {
+	netFlow, transport := testFlows(port)
+	accept := &clc.AcceptSMCR{
+		Header:         clc.Header{Type: clc.TypeAccept},
+		IBGID:          net.IPv4(10, 0, 0, 2),
+		QPN:            srvQP,
+		RMBEAlertToken: srvToken,
+	}
+	if first {
+		accept.Header.Flag = 1
+	}
+	confirm := &clc.ConfirmSMCR{AcceptSMCR: clc.AcceptSMCR{
+		Header:         clc.Header{Type: clc.TypeConfirm},
+		IBGID:          net.IPv4(10, 0, 0, 1),
+		QPN:            cltQP,
+		RMBEAlertToken: cltToken,
+	}}
+	x.AddCLC(netFlow.Reverse(), transport.Reverse(), accept)
+	x.AddCLC(netFlow, transport, confirm)
+}
+
+// testRoCEFlow returns the network flow of a RoCEv2 packet from src to dst
+func testRoCEFlow(src, dst byte) gopacket.Flow {
+	return gopacket.NewFlow(layers.EndpointIPv4,
+		net.IPv4(10, 0, 0, src).To4(), net.IPv4(10, 0, 0, dst).To4())
+}
+
+func TestIndex(t *testing.T) {
+	x := NewIndex()
+
+	// first connection creates link group
+	testCLC(x, 1, true, 100, 200, 1, 2)
+	netFlow, transport := testFlows(1)
+	c := x.Conn(netFlow.Reverse(), transport.Reverse())
+	if c == nil || c.LinkGroup == nil || c.LinkGroup.ID != 1 {
+		t.Fatalf("connection not in link group 1: %v", c)
+	}
+
+	// server confirms first link
+	x.AddRoCE(testRoCEFlow(2, 1), &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: 200},
+		LLC: &llc.ConfirmLink{SenderGID: net.IPv4(10, 0, 0, 2),
+			SenderQP: 100, Link: 1},
+	})
+	if got := c.LinkGroup.Links[0].ID; got != 1 {
+		t.Errorf("link ID = %d; want 1", got)
+	}
+
+	// server adds second link over first link, client replies
+	x.AddRoCE(testRoCEFlow(2, 1), &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: 200},
+		LLC: &llc.AddLink{SenderGID: net.IPv4(10, 0, 1, 2),
+			SenderQP: 101, Link: 2},
+	})
+	x.AddRoCE(testRoCEFlow(1, 2), &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: 100},
+		LLC: &llc.AddLink{Reply: true, SenderGID: net.IPv4(10, 0, 1, 1),
+			SenderQP: 201, Link: 2},
+	})
+	cltQP := NewQP(net.IPv4(10, 0, 1, 1), 201)
+	srvQP := NewQP(net.IPv4(10, 0, 1, 2), 101)
+	if x.LinkGroup(cltQP) != c.LinkGroup ||
+		x.LinkGroup(srvQP) != c.LinkGroup {
+		t.Fatalf("second link not in link group")
+	}
+	link := c.LinkGroup.linkByID(2)
+	if link == nil || link.ClientQP != cltQP || link.ServerQP != srvQP {
+		t.Errorf("link = %v; want %s, %s", link, cltQP, srvQP)
+	}
+	if got := c.LinkGroup.Sender(cltQP); got != llc.SideServer {
+		t.Errorf("Sender(%s) = %s; want server", cltQP, got)
+	}
+	if got := c.LinkGroup.Sender(srvQP); got != llc.SideClient {
+		t.Errorf("Sender(%s) = %s; want client", srvQP, got)
+	}
+
+	// second connection joins link group
+	testCLC(x, 2, false, 100, 200, 3, 4)
+	if len(x.LinkGroups()) != 1 || len(x.Conns()) != 2 {
+		t.Fatalf("got %d link groups, %d conns; want 1, 2",
+			len(x.LinkGroups()), len(x.Conns()))
+	}
+
+	// cdc messages over second link to the client and the server
+	cdc := &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: 201},
+		LLC: &llc.CDC{AlertTkn: 2},
+	}
+	flow := gopacket.NewFlow(layers.EndpointIPv4,
+		net.IPv4(10, 0, 1, 2).To4(), net.IPv4(10, 0, 1, 1).To4())
+	if got := x.ConnByCDC(flow, cdc); got != c {
+		t.Errorf("ConnByCDC() = %v; want %v", got, c)
+	}
+	cdc.BTH.DestQP = 101
+	cdc.LLC = &llc.CDC{AlertTkn: 3}
+	netFlow, transport = testFlows(2)
+	if got := x.ConnByCDC(flow.Reverse(), cdc); got == nil ||
+		got != x.Conn(netFlow, transport) {
+		t.Errorf("ConnByCDC() = %v; want second connection", got)
+	}
+
+	// unknown alert token
+	cdc.LLC = &llc.CDC{AlertTkn: 2}
+	if got := x.ConnByCDC(flow.Reverse(), cdc); got != nil {
+		t.Errorf("ConnByCDC() = %v; want nil", got)
+	}
+
+	// server adds second link again with a new QP, the old QP is removed
+	x.AddRoCE(testRoCEFlow(2, 1), &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: 200},
+		LLC: &llc.AddLink{SenderGID: net.IPv4(10, 0, 1, 2),
+			SenderQP: 102, Link: 2},
+	})
+	newQP := NewQP(net.IPv4(10, 0, 1, 2), 102)
+	if x.LinkGroup(srvQP) != nil || x.LinkGroup(newQP) != c.LinkGroup {
+		t.Errorf("old QP %s in index or new QP %s not in index",
+			srvQP, newQP)
+	}
+
+	// confirm link to a QP of the link group without a link is ignored
+	unknownQP := NewQP(net.IPv4(10, 0, 0, 1), 300)
+	x.qps[unknownQP] = c.LinkGroup
+	x.AddRoCE(testRoCEFlow(2, 1), &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: 300},
+		LLC: &llc.ConfirmLink{SenderGID: net.IPv4(10, 0, 0, 2),
+			SenderQP: 100, Link: 3},
+	})
+	if link := c.LinkGroup.linkByID(3); link != nil {
+		t.Errorf("link = %v; want nil", link)
+	}
+}
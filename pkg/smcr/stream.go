@@ -0,0 +1,416 @@
+package smcr
+
+import (
+	"net"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+// StreamData stores application data of one direction of a SMC-R connection
+type StreamData struct {
+	// network and transport flows of the TCP connection in the direction
+	// of the data, i.e., from the writer to the owner of the RMBE
+	Net, Transport gopacket.Flow
+
+	// Timestamp is the time of the CDC message that made the data
+	// available to the receiver
+	Timestamp time.Time
+
+	// Data is the application data
+	Data []byte
+
+	// Skipped is the number of bytes lost before Data, e.g., because the
+	// producer cursor advanced by more than the RMBE size
+	Skipped int
+
+	// Missing is the number of bytes in Data that were not seen in
+	// captured RDMA writes. They are set to zero in Data
+	Missing int
+}
+
+// StreamHandler is called for application data reconstructed from RDMA
+// writes and CDC messages. Data of more than 1 MiB is passed to the handler
+// in multiple parts
+type StreamHandler func(data *StreamData)
+
+const (
+	// rmbePageSize is the size of the parts of a rmbe that are allocated
+	// when data is written to them
+	rmbePageSize = 64 * 1024
+
+	// maxStreamData is the maximum length of the data in StreamData
+	maxStreamData = 1024 * 1024
+)
+
+// rmbePage stores the content of a part of a rmbe and a bitmap of the bytes
+// written to it
+type rmbePage struct {
+	buf  []byte
+	seen []uint64
+}
+
+// isSeen checks if the byte at offset i was written
+func (p *rmbePage) isSeen(i int) bool {
+	return p.seen[i/64]&(1<<(i%64)) != 0
+}
+
+// setSeen sets the byte at offset i to written or not written
+func (p *rmbePage) setSeen(i int, seen bool) {
+	if seen {
+		p.seen[i/64] |= 1 << (i % 64)
+		return
+	}
+	p.seen[i/64] &^= 1 << (i % 64)
+}
+
+// rmbeKey identifies the rmbe of the client or the server of a connection.
+// Both sides allocate their alert tokens independently, so the tokens of a
+// connection can be equal
+type rmbeKey struct {
+	conn   *Conn
+	client bool
+}
+
+// rmbe stores a RMB element that receives the data of one direction of a
+// SMC-R connection and the data written into it
+type rmbe struct {
+	net, transport gopacket.Flow
+	key            rmbeKey
+	size           int
+
+	// virtual addresses of the rmbe start by rkey of the links
+	addrs map[uint32]uint64
+
+	// content of the rmbe by page index. The size of the rmbe is from the
+	// wire, so pages are only allocated when data is written to them
+	pages map[int]*rmbePage
+
+	// producer cursor of the last cdc message
+	wrap uint16
+	curs uint32
+}
+
+// contains checks if the virtual address addr for rkey is in the rmbe
+func (r *rmbe) contains(rkey uint32, addr uint64) bool {
+	start, ok := r.addrs[rkey]
+	return ok && addr >= start && addr < start+uint64(r.size)
+}
+
+// page returns the page of the rmbe that contains offset or nil if it does
+// not exist. If alloc is true, a missing page is allocated
+func (r *rmbe) page(offset int, alloc bool) *rmbePage {
+	i := offset / rmbePageSize
+	p := r.pages[i]
+	if p == nil && alloc {
+		n := min(rmbePageSize, r.size-i*rmbePageSize)
+		p = &rmbePage{
+			buf:  make([]byte, n),
+			seen: make([]uint64, (n+63)/64),
+		}
+		r.pages[i] = p
+	}
+	return p
+}
+
+// write writes data to the rmbe at offset
+func (r *rmbe) write(offset int, data []byte) {
+	for len(data) > 0 {
+		offset %= r.size
+		p := r.page(offset, true)
+		start := offset % rmbePageSize
+		n := copy(p.buf[start:], data)
+		for i := start; i < start+n; i++ {
+			p.setSeen(i, true)
+		}
+		data = data[n:]
+		offset += n
+	}
+}
+
+// read reads len(data) bytes from the rmbe at offset into data and returns the
+// number of bytes that were not written. Read bytes are marked as not written
+func (r *rmbe) read(offset int, data []byte) int {
+	missing := 0
+	for i := range data {
+		offset %= r.size
+		p := r.page(offset, false)
+		if p == nil || !p.isSeen(offset%rmbePageSize) {
+			missing++
+		} else {
+			data[i] = p.buf[offset%rmbePageSize]
+			p.setSeen(offset%rmbePageSize, false)
+		}
+		offset++
+	}
+	return missing
+}
+
+// produce advances the producer cursor to wrap and curs and returns the new
+// data between the old and the new cursor in parts of at most maxStreamData
+// bytes
+func (r *rmbe) produce(ts time.Time, wrap uint16,
+	curs uint32) []*StreamData {
+	diff := int(uint16(wrap-r.wrap))*r.size + int(curs) - int(r.curs)
+	if diff <= 0 || int(curs) > r.size {
+		// old or invalid cursor
+		return nil
+	}
+	offset := int(r.curs)
+	r.wrap, r.curs = wrap, curs
+
+	skipped := 0
+	if diff > r.size {
+		// data was overwritten before it was announced
+		skipped = diff - r.size
+		offset = (offset + skipped) % r.size
+		diff = r.size
+	}
+	var parts []*StreamData
+	for diff > 0 {
+		n := min(diff, maxStreamData)
+		data := &StreamData{
+			Net:       r.net,
+			Transport: r.transport,
+			Timestamp: ts,
+			Data:      make([]byte, n),
+			Skipped:   skipped,
+		}
+		data.Missing = r.read(offset, data.Data)
+		parts = append(parts, data)
+		skipped = 0
+		offset = (offset + n) % r.size
+		diff -= n
+	}
+	return parts
+}
+
+// write stores an ongoing multi-packet RDMA write
+type write struct {
+	rmbe   *rmbe
+	offset int
+}
+
+// Reconstructor rebuilds the application byte streams of SMC-R connections.
+// It maps RDMA writes to the RMBEs announced in CLC Accept and Confirm
+// messages and LLC Confirm RKey messages and passes the data to the handler
+// when CDC messages advance the producer cursors. It uses the index to find
+// the connections of the messages, the caller must add all packets to the
+// index before passing them to the reconstructor
+type Reconstructor struct {
+	index   *Index
+	handler StreamHandler
+
+	// rmbes by rkey and by connection side
+	rkeys map[uint32][]*rmbe
+	rmbes map[rmbeKey]*rmbe
+
+	// rmb specifications of the same rmb on all links by rkey
+	aliases map[uint32][]llc.RMBSpec
+
+	// ongoing RDMA writes by destination QP
+	writes map[uint32]*write
+}
+
+// AddCLC adds the CLC message msg sent in the TCP connection with the network
+// and transport flows net and transport. SMC-R Accept and Confirm messages
+// announce the RMBE of their sender
+func (r *Reconstructor) AddCLC(net, transport gopacket.Flow, msg clc.Message) {
+	info := getSMCRInfo(msg)
+	if info == nil {
+		return
+	}
+	conn := r.index.Conn(net, transport)
+	if conn == nil {
+		return
+	}
+	rkey, addr := info.rkey, info.addr
+
+	// the rmbe receives the data of the other direction. Accept messages
+	// are sent by the server, confirm messages by the client. The RMBE
+	// index starts at 1
+	key := rmbeKey{conn, info.header.Type != clc.TypeAccept}
+	e := &rmbe{
+		net:       net.Reverse(),
+		transport: transport.Reverse(),
+		key:       key,
+		size:      info.size.Size(),
+		addrs:     make(map[uint32]uint64),
+		pages:     make(map[int]*rmbePage),
+	}
+	if info.idx > 0 {
+		addr += uint64(info.idx-1) * uint64(e.size)
+	}
+
+	// RMBs are reused by new connections, remove old rmbes
+	if old := r.rmbes[key]; old != nil {
+		r.removeRMBE(old)
+	}
+	if old := r.findRMBE(rkey, addr); old != nil {
+		r.removeRMBE(old)
+	}
+	r.rmbes[key] = e
+	r.addRKey(e, rkey, addr)
+
+	// add the rkeys of the other links announced in confirm rkey messages
+	for _, spec := range r.aliases[rkey] {
+		if spec.RKey != rkey {
+			r.addRKey(e, spec.RKey, addr-r.rmbAddr(rkey)+spec.VAddr)
+		}
+	}
+}
+
+// rmbAddr returns the virtual address of the RMB with rkey in the confirm
+// rkey message or 0 if there is no confirm rkey message for rkey
+func (r *Reconstructor) rmbAddr(rkey uint32) uint64 {
+	for _, spec := range r.aliases[rkey] {
+		if spec.RKey == rkey {
+			return spec.VAddr
+		}
+	}
+	return 0
+}
+
+// removeRMBE removes the rmbe e
+func (r *Reconstructor) removeRMBE(e *rmbe) {
+	for rkey := range e.addrs {
+		rmbes := r.rkeys[rkey][:0]
+		for _, o := range r.rkeys[rkey] {
+			if o != e {
+				rmbes = append(rmbes, o)
+			}
+		}
+		if len(rmbes) == 0 {
+			delete(r.rkeys, rkey)
+			continue
+		}
+		r.rkeys[rkey] = rmbes
+	}
+	if r.rmbes[e.key] == e {
+		delete(r.rmbes, e.key)
+	}
+	for qp, w := range r.writes {
+		if w.rmbe == e {
+			delete(r.writes, qp)
+		}
+	}
+}
+
+// addRKey adds the rmbe starting at virtual address addr with rkey
+func (r *Reconstructor) addRKey(e *rmbe, rkey uint32, addr uint64) {
+	e.addrs[rkey] = addr
+	r.rkeys[rkey] = append(r.rkeys[rkey], e)
+}
+
+// addConfirmRKey adds the rkeys of the same RMB on all links in the LLC
+// confirm rkey message c
+func (r *Reconstructor) addConfirmRKey(c *llc.ConfirmRKey) {
+	if c.Reply || c.Reject {
+		return
+	}
+	specs := []llc.RMBSpec{{RKey: c.RKey, VAddr: c.VAddr}}
+	for i := 0; i < int(c.NumTkns) && i < len(c.OtherRMBs); i++ {
+		specs = append(specs, c.OtherRMBs[i])
+	}
+	for _, spec := range specs {
+		r.aliases[spec.RKey] = specs
+	}
+
+	// add the new rkeys to rmbes that are already known
+	for _, e := range r.rkeys[c.RKey] {
+		offset := e.addrs[c.RKey] - c.VAddr
+		for _, spec := range specs[1:] {
+			if _, ok := e.addrs[spec.RKey]; !ok {
+				r.addRKey(e, spec.RKey, spec.VAddr+offset)
+			}
+		}
+	}
+}
+
+// findRMBE returns the rmbe that contains the virtual address addr for rkey
+func (r *Reconstructor) findRMBE(rkey uint32, addr uint64) *rmbe {
+	for _, e := range r.rkeys[rkey] {
+		if e.contains(rkey, addr) {
+			return e
+		}
+	}
+	return nil
+}
+
+// addWrite adds the payload of the RDMA write packet p
+func (r *Reconstructor) addWrite(p *roce.RoCE) {
+	w := r.writes[p.BTH.DestQP]
+	if p.ETH != nil && p.ETH.RETH != nil {
+		// first or only packet of the write
+		reth := p.ETH.RETH
+		w = nil
+		if e := r.findRMBE(reth.RKey, reth.VirtualAddr); e != nil {
+			w = &write{
+				rmbe:   e,
+				offset: int(reth.VirtualAddr - e.addrs[reth.RKey]),
+			}
+		}
+	}
+	if w == nil {
+		delete(r.writes, p.BTH.DestQP)
+		return
+	}
+	w.rmbe.write(w.offset, p.Data)
+	w.offset += len(p.Data)
+	if p.BTH.Opcode.IsLast() {
+		delete(r.writes, p.BTH.DestQP)
+		return
+	}
+	r.writes[p.BTH.DestQP] = w
+}
+
+// addCDC adds the CDC message c sent to the QP dst and passes new data to the
+// handler
+func (r *Reconstructor) addCDC(ts time.Time, dst QP, c *llc.CDC) {
+	conn := r.index.ConnByAlertToken(dst, c.AlertTkn)
+	if conn == nil {
+		return
+	}
+	e := r.rmbes[rmbeKey{conn, conn.LinkGroup.isClient(dst)}]
+	if e == nil {
+		return
+	}
+	for _, data := range e.produce(ts, c.ProdWrap, c.ProdCurs) {
+		r.handler(data)
+	}
+}
+
+// AddRoCE adds the RoCE packet p captured at time ts with the network flow
+// flow, i.e., the GIDs of RoCEv1 packets or the IP addresses of RoCEv2
+// packets. It handles RDMA writes and the LLC Confirm RKey and CDC messages
+// in SEND operations
+func (r *Reconstructor) AddRoCE(ts time.Time, flow gopacket.Flow,
+	p *roce.RoCE) {
+	if p.BTH.Opcode.IsRDMAWrite() {
+		r.addWrite(p)
+		return
+	}
+	switch m := p.LLC.(type) {
+	case *llc.ConfirmRKey:
+		r.addConfirmRKey(m)
+	case *llc.CDC:
+		dst := NewQP(net.IP(flow.Dst().Raw()), p.BTH.DestQP)
+		r.addCDC(ts, dst, m)
+	}
+}
+
+// NewReconstructor returns a new Reconstructor that uses index and passes
+// reconstructed data to handler
+func NewReconstructor(index *Index, handler StreamHandler) *Reconstructor {
+	return &Reconstructor{
+		index:   index,
+		handler: handler,
+		rkeys:   make(map[uint32][]*rmbe),
+		rmbes:   make(map[rmbeKey]*rmbe),
+		aliases: make(map[uint32][]llc.RMBSpec),
+		writes:  make(map[uint32]*write),
+	}
+}
@@ -0,0 +1,237 @@
+package smcr
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopacket/gopacket"
+	"github.com/hwipl/smc-go/pkg/clc"
+	"github.com/hwipl/smc-go/pkg/llc"
+	"github.com/hwipl/smc-go/pkg/roce"
+)
+
+// testWrite returns a RDMA write packet with opcode, reth and data
+func testWrite(opcode roce.Opcode, reth *roce.RETH, data string) *roce.RoCE {
+	r := &roce.RoCE{
+		BTH:  &roce.BTH{Opcode: opcode, DestQP: 263},
+		Data: []byte(data),
+	}
+	if reth != nil {
+		r.ETH = &roce.ETH{RETH: reth}
+	}
+	return r
+}
+
+// testCDC returns a CDC packet with alert token and producer cursor
+func testCDC(token uint32, wrap uint16, curs uint32) *roce.RoCE {
+	return &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100, DestQP: 263},
+		LLC: &llc.CDC{AlertTkn: token, ProdWrap: wrap, ProdCurs: curs},
+	}
+}
+
+// testStreamCLC adds the accept message of the server with QP 263 and the
+// confirm message of the client with QP 264 of the connection with client
+// port 0xc350 to the index x and the reconstructor r
+func testStreamCLC(x *Index, r *Reconstructor, accept *clc.AcceptSMCR,
+	confirm *clc.AcceptSMCR) {
+	accept.Header = clc.Header{Type: clc.TypeAccept, Flag: 1}
+	accept.IBGID, accept.QPN = net.IPv4(10, 0, 0, 2), 263
+	c := &clc.ConfirmSMCR{AcceptSMCR: *confirm}
+	c.Header = clc.Header{Type: clc.TypeConfirm}
+	c.IBGID, c.QPN = net.IPv4(10, 0, 0, 1), 264
+	netFlow, transport := testFlows(0x50)
+	for _, m := range []struct {
+		net, transport gopacket.Flow
+		msg            clc.Message
+	}{
+		{netFlow.Reverse(), transport.Reverse(), accept},
+		{netFlow, transport, c},
+	} {
+		x.AddCLC(m.net, m.transport, m.msg)
+		r.AddCLC(m.net, m.transport, m.msg)
+	}
+}
+
+func TestReconstructor(t *testing.T) {
+	var got []*StreamData
+	x := NewIndex()
+	r := NewReconstructor(x, func(data *StreamData) {
+		got = append(got, data)
+	})
+
+	// server announces its rmbe in the accept message, the second rmbe in
+	// the rmb starts after the first 16KB rmbe
+	srvNet, srvTransport := testFlows(0x50)
+	srvNet, srvTransport = srvNet.Reverse(), srvTransport.Reverse()
+	testStreamCLC(x, r, &clc.AcceptSMCR{
+		RMBRKey:        4097,
+		RMBEIdx:        2,
+		RMBEAlertToken: 7,
+		RMBESize:       0,
+		RMBDMAAddr:     0x100000,
+	}, &clc.AcceptSMCR{RMBRKey: 9999, RMBEIdx: 1, RMBEAlertToken: 8,
+		RMBDMAAddr: 0x900000})
+	flow := testRoCEFlow(1, 2)
+	base := uint64(0x100000 + 16384)
+
+	// client writes "hello " and "world" in two writes and sends cdc
+	ts := time.Unix(1, 0)
+	r.AddRoCE(ts, flow, testWrite(0b01010, &roce.RETH{VirtualAddr: base,
+		RKey: 4097, DMALen: 6}, "hello "))
+	r.AddRoCE(ts, flow, testWrite(0b00110, &roce.RETH{
+		VirtualAddr: base + 6, RKey: 4097, DMALen: 5}, "wor"))
+	r.AddRoCE(ts, flow, testWrite(0b01000, nil, "ld"))
+	r.AddRoCE(ts, flow, testCDC(7, 0, 11))
+	if len(got) != 1 {
+		t.Fatalf("len(got) = %d; want 1", len(got))
+	}
+	if string(got[0].Data) != "hello world" || got[0].Missing != 0 {
+		t.Errorf("data = %q, missing %d; want %q, missing 0",
+			got[0].Data, got[0].Missing, "hello world")
+	}
+	if got[0].Net != srvNet.Reverse() ||
+		got[0].Transport != srvTransport.Reverse() {
+		t.Errorf("flows = %s %s; want %s %s", got[0].Net,
+			got[0].Transport, srvNet.Reverse(),
+			srvTransport.Reverse())
+	}
+	if got[0].Timestamp != ts {
+		t.Errorf("timestamp = %s; want %s", got[0].Timestamp, ts)
+	}
+
+	// old cursor does not produce data
+	r.AddRoCE(ts, flow, testCDC(7, 0, 11))
+	if len(got) != 1 {
+		t.Fatalf("len(got) = %d; want 1", len(got))
+	}
+
+	// move cursor close to end of rmbe without captured writes
+	r.AddRoCE(ts, flow, testCDC(7, 0, 16381))
+	if len(got) != 2 || got[1].Missing != 16381-11 {
+		t.Fatalf("missing data not reported")
+	}
+
+	// write over a second link announced in confirm rkey and wrap around
+	r.AddRoCE(ts, flow, &roce.RoCE{
+		BTH: &roce.BTH{Opcode: 0b00100},
+		LLC: &llc.ConfirmRKey{NumTkns: 1, RKey: 4097,
+			VAddr: 0x100000,
+			OtherRMBs: [2]llc.RMBSpec{{Link: 2, RKey: 8193,
+				VAddr: 0x200000}}},
+	})
+	base = 0x200000 + 16384
+	r.AddRoCE(ts, flow, testWrite(0b01010, &roce.RETH{
+		VirtualAddr: base + 16381, RKey: 8193, DMALen: 3}, "abc"))
+	r.AddRoCE(ts, flow, testWrite(0b01010, &roce.RETH{VirtualAddr: base,
+		RKey: 8193, DMALen: 2}, "de"))
+	r.AddRoCE(ts, flow, testCDC(7, 1, 2))
+	if len(got) != 3 {
+		t.Fatalf("len(got) = %d; want 3", len(got))
+	}
+	if string(got[2].Data) != "abcde" || got[2].Missing != 0 {
+		t.Errorf("data = %q, missing %d; want %q, missing 0",
+			got[2].Data, got[2].Missing, "abcde")
+	}
+
+	// cursor advances by more than the rmbe size
+	r.AddRoCE(ts, flow, testCDC(7, 2, 12))
+	if len(got) != 4 || got[3].Skipped != 10 ||
+		len(got[3].Data) != 16384 {
+		t.Errorf("skipped data not reported")
+	}
+}
+
+func TestReconstructorLargeRMBE(t *testing.T) {
+	var got []*StreamData
+	x := NewIndex()
+	r := NewReconstructor(x, func(data *StreamData) {
+		got = append(got, data)
+	})
+
+	// server announces a 512 MiB rmbe
+	testStreamCLC(x, r, &clc.AcceptSMCR{
+		RMBRKey:        4097,
+		RMBEIdx:        1,
+		RMBEAlertToken: 7,
+		RMBESize:       15,
+		RMBDMAAddr:     0x100000,
+	}, &clc.AcceptSMCR{RMBRKey: 9999, RMBEIdx: 1, RMBEAlertToken: 8,
+		RMBDMAAddr: 0x900000})
+	flow := testRoCEFlow(1, 2)
+
+	// only the written part of the rmbe is allocated
+	ts := time.Unix(1, 0)
+	r.AddRoCE(ts, flow, testWrite(0b01010, &roce.RETH{VirtualAddr: 0x100000,
+		RKey: 4097, DMALen: 5}, "hello"))
+	e := r.findRMBE(4097, 0x100000)
+	if e == nil || e.size != 512*1024*1024 || len(e.pages) != 1 {
+		t.Fatalf("unexpected rmbe: %v", e)
+	}
+
+	// data is passed to the handler in parts
+	r.AddRoCE(ts, flow, testCDC(7, 0, 2*maxStreamData+5))
+	if len(got) != 3 {
+		t.Fatalf("len(got) = %d; want 3", len(got))
+	}
+	if string(got[0].Data[:5]) != "hello" ||
+		got[0].Missing != maxStreamData-5 {
+		t.Errorf("data = %q, missing %d; want %q, missing %d",
+			got[0].Data[:5], got[0].Missing, "hello",
+			maxStreamData-5)
+	}
+	if len(got[1].Data) != maxStreamData || len(got[2].Data) != 5 ||
+		got[2].Missing != 5 {
+		t.Errorf("unexpected parts: %d, %d", len(got[1].Data),
+			len(got[2].Data))
+	}
+}
+
+func TestReconstructorEqualTokens(t *testing.T) {
+	var got []*StreamData
+	x := NewIndex()
+	r := NewReconstructor(x, func(data *StreamData) {
+		got = append(got, data)
+	})
+
+	// client and server use the same alert token for their rmbes
+	testStreamCLC(x, r, &clc.AcceptSMCR{RMBRKey: 4097, RMBEIdx: 1,
+		RMBEAlertToken: 7, RMBDMAAddr: 0x100000},
+		&clc.AcceptSMCR{RMBRKey: 9999, RMBEIdx: 1, RMBEAlertToken: 7,
+			RMBDMAAddr: 0x900000})
+
+	// client writes to the rmbe of the server with QP 263, server writes
+	// to the rmbe of the client with QP 264
+	ts := time.Unix(1, 0)
+	r.AddRoCE(ts, testRoCEFlow(1, 2), testWrite(0b01010, &roce.RETH{
+		VirtualAddr: 0x100000, RKey: 4097, DMALen: 4}, "ping"))
+	toClient := testWrite(0b01010, &roce.RETH{VirtualAddr: 0x900000,
+		RKey: 9999, DMALen: 4}, "pong")
+	toClient.BTH.DestQP = 264
+	r.AddRoCE(ts, testRoCEFlow(2, 1), toClient)
+	r.AddRoCE(ts, testRoCEFlow(1, 2), testCDC(7, 0, 4))
+	cdc := testCDC(7, 0, 4)
+	cdc.BTH.DestQP = 264
+	r.AddRoCE(ts, testRoCEFlow(2, 1), cdc)
+
+	netFlow, transport := testFlows(0x50)
+	want := []struct {
+		data           string
+		net, transport gopacket.Flow
+	}{
+		{"ping", netFlow, transport},
+		{"pong", netFlow.Reverse(), transport.Reverse()},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("len(got) = %d; want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if string(got[i].Data) != w.data || got[i].Net != w.net ||
+			got[i].Transport != w.transport {
+			t.Errorf("got %q %s %s; want %q %s %s", got[i].Data,
+				got[i].Net, got[i].Transport, w.data, w.net,
+				w.transport)
+		}
+	}
+}
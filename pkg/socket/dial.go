@@ -1,47 +1,345 @@
 package socket
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
 
+const (
+	// defaultKeepAlive is the keep-alive period if Dialer.KeepAlive is 0,
+	// like in net.Dialer
+	defaultKeepAlive = 15 * time.Second
+
+	// minDialTimeout is the minimum time of a dial attempt if there are
+	// multiple addresses, like in net.Dialer
+	minDialTimeout = 2 * time.Second
+)
+
+// Dialer contains options for creating SMC connections, similar to
+// net.Dialer
+type Dialer struct {
+	// Timeout is the maximum time a dial waits for a connection to
+	// complete including name resolution. Zero means no timeout
+	Timeout time.Duration
+
+	// LocalAddr is the local address to bind to, it must be a
+	// *net.TCPAddr. If nil, a local address is chosen automatically
+	LocalAddr net.Addr
+
+	// KeepAlive is the keep-alive period of the connection. Zero enables
+	// keep-alives with a default period, negative values disable them
+	KeepAlive time.Duration
+}
+
 // Dial creates a SMC connection to address and port
 func Dial(address string) (net.Conn, error) {
-	var conn net.Conn
-	var err error
-	var fd int
+	var d Dialer
+	return d.DialContext(context.Background(), "tcp", address)
+}
 
-	// construct socket address from address
-	typ, sockaddr := createSockaddr(address)
-	if typ == "err" {
-		return conn, fmt.Errorf("Error parsing IP")
+// resolve resolves the host:port address to the IP addresses of network and
+// the port
+func resolve(ctx context.Context, network, address string) ([]net.IPAddr,
+	int, error) {
+	host, p, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	if host == "" {
+		// default to unspecified address if no host given
+		host = "0.0.0.0"
+	}
+	if p == "" {
+		// default to unspecified port if no port given
+		p = "0"
+	}
+	port, err := net.DefaultResolver.LookupPort(ctx, "tcp", p)
+	if err != nil {
+		return nil, 0, err
+	}
+	ipaddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	// create socket
-	if typ == "ipv4" {
-		fd, err = unix.Socket(unix.AF_SMC, unix.SOCK_STREAM,
-			protoIPv4)
-	} else {
-		fd, err = unix.Socket(unix.AF_SMC, unix.SOCK_STREAM,
-			protoIPv6)
+	// only keep addresses of the network's address family
+	addrs := ipaddrs[:0]
+	for _, ipaddr := range ipaddrs {
+		ipv4 := ipaddr.IP.To4() != nil
+		if network == "tcp4" && !ipv4 || network == "tcp6" && ipv4 {
+			continue
+		}
+		addrs = append(addrs, ipaddr)
+	}
+	if len(addrs) == 0 {
+		return nil, 0, &net.AddrError{Err: "no suitable address found",
+			Addr: host}
 	}
+	return addrs, port, nil
+}
 
+// DialContext creates a SMC connection to address on the network "tcp",
+// "tcp4" or "tcp6" like net.Dialer.DialContext. It tries all resolved
+// addresses in order and returns the first successful connection. With a
+// timeout or deadline, each address gets a share of the remaining time. If
+// ctx is canceled or expires before the connection is complete, an error is
+// returned
+func (d *Dialer) DialContext(ctx context.Context, network,
+	address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("Error dialing %s: %w", address,
+			net.UnknownNetworkError(network))
+	}
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	// only use addresses of the local address's address family
+	if laddr, ok := d.LocalAddr.(*net.TCPAddr); ok && laddr.IP != nil &&
+		network == "tcp" {
+		network = "tcp6"
+		if laddr.IP.To4() != nil {
+			network = "tcp4"
+		}
+	}
+	ipaddrs, port, err := resolve(ctx, network, address)
 	if err != nil {
-		return conn, err
+		return nil, fmt.Errorf("Error dialing %s: %w", address, err)
 	}
-	defer unix.Close(fd)
 
-	// connect to server
-	err = unix.Connect(fd, sockaddr)
+	conn, err := dialSerial(ctx, ipaddrs, port, d.dialAddr)
 	if err != nil {
-		return conn, err
+		return nil, fmt.Errorf("Error dialing %s: %w", address, err)
 	}
+	return conn, nil
+}
 
-	// create a connection from connected socket
+// partialDeadline returns the deadline of the next of remaining dial
+// attempts that share the time until deadline, like in net.Dialer. Each
+// attempt gets at least minDialTimeout if there is enough time left
+func partialDeadline(now, deadline time.Time, remaining int) time.Time {
+	timeLeft := deadline.Sub(now)
+	if timeLeft <= 0 {
+		return deadline
+	}
+	timeout := timeLeft / time.Duration(remaining)
+	if timeout < minDialTimeout {
+		timeout = min(timeLeft, minDialTimeout)
+	}
+	return now.Add(timeout)
+}
+
+// dialFunc creates a connection to ipaddr and port
+type dialFunc func(ctx context.Context, ipaddr *net.IPAddr,
+	port int) (net.Conn, error)
+
+// dialSerial tries all addresses in ipaddrs in order with dial and returns
+// the first successful connection or the first error if all fail. If ctx has
+// a deadline, each attempt gets a share of the remaining time, so the other
+// addresses are tried if an address does not respond
+func dialSerial(ctx context.Context, ipaddrs []net.IPAddr, port int,
+	dial dialFunc) (net.Conn, error) {
+	var firstErr error
+	for i := range ipaddrs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		dialCtx, cancel := ctx, func() {}
+		if deadline, ok := ctx.Deadline(); ok {
+			dialCtx, cancel = context.WithDeadline(ctx,
+				partialDeadline(time.Now(), deadline,
+					len(ipaddrs)-i))
+		}
+		conn, err := dial(dialCtx, &ipaddrs[i], port)
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// setKeepAlive sets the keep-alive options of the socket fd
+func (d *Dialer) setKeepAlive(fd int) error {
+	if d.KeepAlive < 0 {
+		return nil
+	}
+	period := d.KeepAlive
+	if period == 0 {
+		period = defaultKeepAlive
+	}
+
+	// round up to seconds
+	secs := int((period + time.Second - 1) / time.Second)
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_KEEPALIVE,
+		1); err != nil {
+		return err
+	}
+	if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPIDLE,
+		secs); err != nil {
+		return err
+	}
+	return unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_KEEPINTVL,
+		secs)
+}
+
+// localSockaddr returns the socket address of the local address of the
+// dialer for a socket of the address family typ, i.e., "ipv4" or "ipv6", or
+// nil if the dialer has no local address. A local address without IP
+// address uses the unspecified address of the address family
+func (d *Dialer) localSockaddr(typ string) (unix.Sockaddr, error) {
+	if d.LocalAddr == nil {
+		return nil, nil
+	}
+	laddr, ok := d.LocalAddr.(*net.TCPAddr)
+	if !ok {
+		return nil, &net.AddrError{Err: "unexpected local address type",
+			Addr: d.LocalAddr.String()}
+	}
+	ip := laddr.IP
+	if ip == nil {
+		ip = net.IPv4zero
+		if typ == "ipv6" {
+			ip = net.IPv6unspecified
+		}
+	}
+	ltyp, sockaddr := ipSockaddr(&net.IPAddr{IP: ip, Zone: laddr.Zone},
+		laddr.Port)
+	if ltyp == "err" {
+		return nil, &net.AddrError{Err: "invalid local address",
+			Addr: laddr.String()}
+	}
+	return sockaddr, nil
+}
+
+// bindLocal binds the socket fd of the address family typ to the local
+// address of the dialer
+func (d *Dialer) bindLocal(fd int, typ string) error {
+	sockaddr, err := d.localSockaddr(typ)
+	if err != nil || sockaddr == nil {
+		return err
+	}
+	return unix.Bind(fd, sockaddr)
+}
+
+// dialAddr creates a SMC connection to ipaddr and port with a non-blocking
+// connect that is aborted when ctx is done
+func (d *Dialer) dialAddr(ctx context.Context, ipaddr *net.IPAddr,
+	port int) (net.Conn, error) {
+	// construct socket address from ip address and port
+	typ, sockaddr := ipSockaddr(ipaddr, port)
+	if typ == "err" {
+		return nil, fmt.Errorf("Error parsing IP")
+	}
+
+	// create non-blocking socket
+	proto := protoIPv4
+	if typ == "ipv6" {
+		proto = protoIPv6
+	}
+	fd, err := unix.Socket(unix.AF_SMC,
+		unix.SOCK_STREAM|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.setKeepAlive(fd); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	if err := d.bindLocal(fd, typ); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// start connecting to server
+	err = unix.Connect(fd, sockaddr)
+	if err != nil && err != unix.EINPROGRESS {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// the file owns the socket from here and registers it in the runtime
+	// poller, so waiting for the connection honours write deadlines
 	file := os.NewFile(uintptr(fd), "")
-	conn, err = net.FileConn(file)
-	return conn, err
+	defer file.Close()
+	if err == unix.EINPROGRESS {
+		if err := waitConnect(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+
+	// create a connection from connected socket
+	return net.FileConn(file)
+}
+
+// waitConnect waits until the non-blocking connect of the socket in file is
+// complete or ctx is done
+func waitConnect(ctx context.Context, file *os.File) error {
+	rc, err := file.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// abort waiting when ctx is done by setting a deadline in the past
+	if deadline, ok := ctx.Deadline(); ok {
+		file.SetWriteDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			file.SetWriteDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	// socket is writable when connect is complete, check the result
+	var connErr error
+	err = rc.Write(func(fd uintptr) bool {
+		soErr, err := unix.GetsockoptInt(int(fd), unix.SOL_SOCKET,
+			unix.SO_ERROR)
+		if err != nil {
+			connErr = err
+			return true
+		}
+		switch unix.Errno(soErr) {
+		case unix.EINPROGRESS, unix.EALREADY, unix.EINTR:
+			return false
+		case 0:
+			// connected if there is a peer
+			_, err := unix.Getpeername(int(fd))
+			if err == unix.ENOTCONN {
+				return false
+			}
+			connErr = err
+			return true
+		default:
+			connErr = unix.Errno(soErr)
+			return true
+		}
+	})
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return context.DeadlineExceeded
+	}
+	if err != nil {
+		return err
+	}
+	return connErr
 }
@@ -1,9 +1,15 @@
 package socket
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net"
+	"reflect"
 	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 func TestDialHostname(t *testing.T) {
@@ -172,3 +178,171 @@ func TestDial(t *testing.T) {
 	cs.Close()
 	l.Close()
 }
+
+func TestDialerDialContext(t *testing.T) {
+	var want, got string
+	var cc, cs net.Conn
+	var l net.Listener
+	var err error
+
+	// test dialer with local address, timeout and keep-alive
+	l, err = Listen("127.0.0.1:50104")
+	if err != nil {
+		t.Skip(err)
+	}
+	d := &Dialer{
+		Timeout:   5 * time.Second,
+		LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)},
+		KeepAlive: 30 * time.Second,
+	}
+	cc, err = d.DialContext(context.Background(), "tcp",
+		"localhost:50104")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cs, err = l.Accept()
+	if err != nil {
+		log.Fatal(err)
+	}
+	want = l.Addr().String()
+	got = cc.RemoteAddr().String()
+	if got != want {
+		t.Errorf("RemoteAddr() = %s; want %s", got, want)
+	}
+	cc.Close()
+	cs.Close()
+	l.Close()
+}
+
+func TestDialerErrors(t *testing.T) {
+	var d Dialer
+
+	// test canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := d.DialContext(ctx, "tcp", "127.0.0.1:50105")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DialContext() error = %v; want %v", err,
+			context.Canceled)
+	}
+
+	// test unknown network
+	_, err = d.DialContext(context.Background(), "udp", "127.0.0.1:50105")
+	var netErr net.UnknownNetworkError
+	if !errors.As(err, &netErr) {
+		t.Errorf("DialContext() error = %v; want unknown network", err)
+	}
+
+	// test address of the wrong address family
+	_, err = d.DialContext(context.Background(), "tcp6", "127.0.0.1:50105")
+	var addrErr *net.AddrError
+	if !errors.As(err, &addrErr) {
+		t.Errorf("DialContext() error = %v; want address error", err)
+	}
+}
+
+func TestPartialDeadline(t *testing.T) {
+	now := time.Unix(100, 0)
+	for _, test := range []struct {
+		timeLeft  time.Duration
+		remaining int
+		want      time.Duration
+	}{
+		{10 * time.Second, 1, 10 * time.Second},
+		{10 * time.Second, 2, 5 * time.Second},
+		{10 * time.Second, 10, minDialTimeout},
+		{time.Second, 2, time.Second},
+		{-time.Second, 2, -time.Second},
+	} {
+		got := partialDeadline(now, now.Add(test.timeLeft),
+			test.remaining)
+		if got.Sub(now) != test.want {
+			t.Errorf("partialDeadline(%s, %d) = %s; want %s",
+				test.timeLeft, test.remaining, got.Sub(now),
+				test.want)
+		}
+	}
+}
+
+func TestDialSerialFallback(t *testing.T) {
+	ipaddrs := []net.IPAddr{
+		{IP: net.IPv4(192, 0, 2, 1)},
+		{IP: net.IPv4(127, 0, 0, 1)},
+	}
+
+	// first address does not respond, second address accepts connection
+	var tried []string
+	dial := func(ctx context.Context, ipaddr *net.IPAddr,
+		port int) (net.Conn, error) {
+		tried = append(tried, ipaddr.String())
+		if ipaddr.IP.IsLoopback() {
+			cc, cs := net.Pipe()
+			cs.Close()
+			return cc, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		2*minDialTimeout)
+	defer cancel()
+	conn, err := dialSerial(ctx, ipaddrs, 50106, dial)
+	if err != nil {
+		t.Fatalf("dialSerial() error = %v; want nil", err)
+	}
+	conn.Close()
+	if len(tried) != 2 {
+		t.Errorf("tried = %v; want both addresses", tried)
+	}
+
+	// all addresses fail, the first error is returned
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	_, err = dialSerial(ctx, ipaddrs, 50106, func(_ context.Context,
+		ipaddr *net.IPAddr, _ int) (net.Conn, error) {
+		return nil, errors.New(ipaddr.String())
+	})
+	if err == nil || err.Error() != "192.0.2.1" {
+		t.Errorf("dialSerial() error = %v; want 192.0.2.1", err)
+	}
+}
+
+func TestDialerLocalSockaddr(t *testing.T) {
+	for _, test := range []struct {
+		laddr net.Addr
+		typ   string
+		want  unix.Sockaddr
+	}{
+		{nil, "ipv4", nil},
+		{&net.TCPAddr{Port: 50107}, "ipv4",
+			&unix.SockaddrInet4{Port: 50107}},
+		{&net.TCPAddr{Port: 50107}, "ipv6",
+			&unix.SockaddrInet6{Port: 50107}},
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, "ipv4",
+			&unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}},
+		{&net.TCPAddr{IP: net.IPv6loopback}, "ipv6",
+			&unix.SockaddrInet6{Addr: [16]byte{15: 1}}},
+	} {
+		d := &Dialer{LocalAddr: test.laddr}
+		got, err := d.localSockaddr(test.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("localSockaddr(%s) with %v = %#v; want %#v",
+				test.typ, test.laddr, got, test.want)
+		}
+	}
+
+	// invalid local addresses
+	for _, laddr := range []net.Addr{
+		&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+		&net.TCPAddr{IP: net.IP{1, 2, 3}},
+	} {
+		d := &Dialer{LocalAddr: laddr}
+		if _, err := d.localSockaddr("ipv4"); err == nil {
+			t.Errorf("localSockaddr() with %v = nil; want error",
+				laddr)
+		}
+	}
+}
@@ -55,6 +55,11 @@ func createSockaddr(address string) (typ string, s unix.Sockaddr) {
 	if ipaddr == nil {
 		return "err", nil
 	}
+	return ipSockaddr(ipaddr, port)
+}
+
+// ipSockaddr constructs a socket address from ipaddr and port
+func ipSockaddr(ipaddr *net.IPAddr, port int) (typ string, s unix.Sockaddr) {
 	ipv4 := ipaddr.IP.To4()
 	ipv6 := ipaddr.IP.To16()
 	if ipv4 != nil {